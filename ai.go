@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// attachedImages returns the image attached to a request, if any, for the
+// user's message
+func attachedImages(ctx PromptContext) []string {
+	if ctx.Attachment == nil || !ctx.Attachment.IsImage() {
+		return nil
+	}
+	return []string{ctx.Attachment.DataURL()}
+}
+
+// aiClient is the provider client for config: contacting only the hosts
+// air-gapped mode allows, counting the tokens used, and answering from the
+// script in a demo
+func aiClient(config Config) ai.Client {
+	client := ai.Client{
+		URL:   config.LiteLLMURL,
+		Token: config.LiteLLMToken,
+		Model: config.Model,
+		Allow: func(url string) error { return CheckEndpoint(config, url) },
+		Usage: addTokenUsage,
+	}
+	if demo != nil {
+		client.Answer = demo.answer
+	}
+	return client
+}
+
+// chatCompletion sends a chat request with the client for config and
+// returns the content of every choice. The model from config is used
+// unless the request names one explicitly.
+func chatCompletion(config Config, request ai.Request) ([]string, error) {
+	return aiClient(config).Complete(request)
+}
+
+// systemPrompt builds the system message describing the task and environment
+func systemPrompt(ctx PromptContext) string {
+	return ai.SystemPrompt + " " + placeholderPrompt + "\n\n" +
+		untrustedPrompt + personaPrompt(ctx) + domainPrompt(ctx.Domain) + manualPrompt(ctx) + ctx.String()
+}
+
+// GenerateCommand generates a shell command from a natural language query
+func GenerateCommand(config Config, query string, ctx PromptContext) (string, error) {
+	commands, err := GenerateCommands(config, query, ctx, 1)
+	if err != nil {
+		return "", err
+	}
+	return commands[0], nil
+}
+
+// GenerateCommands generates up to n distinct candidate commands for a query.
+// Providers that ignore the n parameter yield a single candidate.
+func GenerateCommands(config Config, query string, ctx PromptContext, n int) ([]string, error) {
+	return RegenerateCommands(config, query, nil, ctx, n)
+}
+
+// RegenerateCommands is GenerateCommands with earlier attempts replayed as
+// conversation, so the model can take corrections into account
+func RegenerateCommands(config Config, query string, attempts []ai.Attempt, ctx PromptContext, n int) ([]string, error) {
+	commands, _, err := regenerateCommands(config, query, attempts, ctx, n, false)
+	return commands, err
+}
+
+// ExplainCommands is RegenerateCommands with each command explained in a
+// sentence and its risk noted, asked for as structured output. The
+// explanations are keyed by command; a command answered bare has none.
+func ExplainCommands(config Config, query string, attempts []ai.Attempt, ctx PromptContext, n int) ([]string, map[string]ai.Explanation, error) {
+	return regenerateCommands(config, query, attempts, ctx, n, true)
+}
+
+// regenerateCommands asks for the commands, explained or not
+func regenerateCommands(config Config, query string, attempts []ai.Attempt, ctx PromptContext, n int, explain bool) ([]string, map[string]ai.Explanation, error) {
+	history := append(fewShotMessages(config, query), conversationMessages(ctx.Conversation)...)
+	commands, explanations, err := aiClient(config).GenerateCommands(ai.Prompt{
+		System:   systemPrompt(ctx),
+		History:  history,
+		Query:    query,
+		Images:   attachedImages(ctx),
+		Attempts: attempts,
+		N:        n,
+		Explain:  explain,
+		Model:    personaModel(ctx),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	// Running code fetched from the internet is what instructions injected
+	// in the context would ask for, so it takes the user's own request or
+	// corrections
+	asked := query
+	for _, attempt := range attempts {
+		asked += "\n" + attempt.Feedback
+	}
+	commands, err = dropRemoteExec(asked, commands)
+	return commands, explanations, err
+}
+
+// AskAboutText answers a free-form question about a piece of terminal text,
+// such as an error message or log excerpt selected from the scrollback.
+// Without text the question is about the attached file or image.
+func AskAboutText(config Config, question, text string, ctx PromptContext) (string, error) {
+	messages := []ai.Message{{Role: "system", Content: "You are a terminal assistant. Answer the user's question about the terminal text they selected or the file they attached. " +
+		"Be concise and practical; when a command would help, show it on its own line. " + untrustedPrompt + "\n\n" + personaPrompt(ctx) + ctx.String()}}
+	messages = append(messages, conversationMessages(ctx.Conversation)...)
+	request := "Question: " + question
+	if text != "" {
+		request = fmt.Sprintf("Selected terminal text:\n%s\n\n%s", untrustedBlock("selected text", redactText(config, text)), request)
+	}
+	messages = append(messages, ai.Message{Role: "user", Content: request, Images: attachedImages(ctx)})
+	contents, err := chatCompletion(config, ai.Request{
+		Model:       personaModel(ctx),
+		Messages:    messages,
+		Temperature: 0.2,
+		MaxTokens:   600,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(contents[0]), nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Airgapped reports whether the TUI must stay off the public internet,
+// either because the binary was built for it or the config asks for it
+func (c Config) Airgapped() bool {
+	return airgapBuild || c.Airgap
+}
+
+// isInternalHost reports whether host may be contacted in air-gapped mode:
+// localhost, a loopback, private or link-local address, or a host listed in
+// allowed_hosts. Other names are refused since there is no way to tell where
+// they resolve without asking DNS.
+func isInternalHost(host string, allowed []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	for _, h := range allowed {
+		h = strings.ToLower(h)
+		if host == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(host, h)) {
+			return true
+		}
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast())
+}
+
+// CheckEndpoint refuses to contact rawURL in air-gapped mode unless it points
+// at an internal host
+func CheckEndpoint(config Config, rawURL string) error {
+	if !config.Airgapped() {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("air-gapped mode: invalid endpoint %q", rawURL)
+	}
+	if !isInternalHost(u.Hostname(), config.AllowedHosts) {
+		return fmt.Errorf("air-gapped mode: %s is not an internal host (add it to allowed_hosts)", u.Hostname())
+	}
+	return nil
+}
+
+// maxRedirects is how many redirects a request follows, as Go's default
+// client does
+const maxRedirects = 10
+
+// checkedClient is an HTTP client that runs CheckEndpoint on every
+// redirect too, so an internal endpoint can't send a request on to an
+// outside host in air-gapped mode
+func checkedClient(config Config, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("stopped after 10 redirects")
+			}
+			return CheckEndpoint(config, req.URL.String())
+		},
+	}
+}
+
+var configURLRe = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s"'<>]+`)
+
+// configStrings collects the strings in a config value decoded from JSON,
+// however deeply nested in objects and lists, under the path of keys and
+// indexes leading to each
+func configStrings(path string, value any, sources map[string]string) {
+	switch v := value.(type) {
+	case string:
+		sources[path] = v
+	case map[string]any:
+		for key, item := range v {
+			if path != "" {
+				key = path + "." + key
+			}
+			configStrings(key, item, sources)
+		}
+	case []any:
+		for i, item := range v {
+			configStrings(fmt.Sprintf("%s[%d]", path, i), item, sources)
+		}
+	}
+}
+
+// ExternalReferences lists every URL anywhere in the config, in the
+// proxy environment variables the HTTP client honours and in the policy
+// source, whose host is not internal
+func ExternalReferences(config Config) []string {
+	data, _ := json.Marshal(config)
+	var values map[string]any
+	json.Unmarshal(data, &values)
+
+	sources := make(map[string]string)
+	configStrings("", values, sources)
+	if source := PolicySource(); source != "" {
+		sources["policy"] = source
+	}
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		if value := os.Getenv(name); value != "" {
+			sources["$"+name] = value
+		}
+	}
+
+	var external []string
+	for source, value := range sources {
+		for _, ref := range configURLRe.FindAllString(value, -1) {
+			u, err := url.Parse(ref)
+			if err != nil || !isInternalHost(u.Hostname(), config.AllowedHosts) {
+				external = append(external, fmt.Sprintf("%s: %s", source, ref))
+			}
+		}
+	}
+	sort.Strings(external)
+	return external
+}
+
+// doctorAirgap validates the configuration for an air-gapped environment
+// and reports whether it passed
+func doctorAirgap(config Config) bool {
+	ok := true
+	fmt.Println("Air-gapped mode:")
+
+	switch {
+	case airgapBuild:
+		fmt.Println("  ✓ enforced by this build")
+	case config.Airgap:
+		fmt.Println("  ✓ enabled by the airgap config key")
+	default:
+		fmt.Println("  ✗ not enabled (set airgap to true or build with -tags airgap)")
+		ok = false
+	}
+	if config.Airgapped() || !config.UpdateCheck {
+		fmt.Println("  ✓ release checks disabled")
+	} else {
+		fmt.Println("  ✗ release checks reach api.github.com (set update_check to false)")
+		ok = false
+	}
+	if config.Airgapped() || config.Telemetry != TelemetryOn {
+		fmt.Println("  ✓ telemetry never sent")
+	} else {
+		fmt.Printf("  ✗ telemetry is sent to %s (set telemetry to local)\n", valueOrDefault(config.TelemetryURL, "(not set)"))
+		ok = false
+	}
+
+	external := ExternalReferences(config)
+	for _, ref := range external {
+		fmt.Printf("  ✗ external host referenced by %s\n", ref)
+	}
+	if len(external) == 0 {
+		fmt.Println("  ✓ no external hosts referenced in config")
+	} else {
+		ok = false
+	}
+	return ok
+}
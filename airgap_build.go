@@ -0,0 +1,6 @@
+//go:build airgap
+
+package main
+
+// airgapBuild forces air-gapped mode in binaries built with -tags airgap
+const airgapBuild = true
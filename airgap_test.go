@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckEndpoint(t *testing.T) {
+	config := defaultConfig()
+	config.Airgap = true
+	config.AllowedHosts = []string{"llm.corp.example", ".internal"}
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"http://localhost:4000", true},
+		{"http://127.0.0.1:4000/v1", true},
+		{"https://10.0.0.5", true},
+		{"https://[fe80::1]:8443", true},
+		{"https://llm.corp.example", true},
+		{"https://gpu.internal/v1", true},
+		{"https://api.openai.com", false},
+		{"https://llm.corp.example.evil.com", false},
+		{"https://8.8.8.8", false},
+		{"not a url", false},
+	}
+	for _, test := range tests {
+		if err := CheckEndpoint(config, test.url); (err == nil) != test.ok {
+			t.Errorf("CheckEndpoint(%q) = %v, want ok %t", test.url, err, test.ok)
+		}
+	}
+	config.Airgap = false
+	if err := CheckEndpoint(config, "https://api.openai.com"); err != nil && !airgapBuild {
+		t.Errorf("CheckEndpoint outside air-gapped mode = %v", err)
+	}
+}
+
+func TestCheckedClientRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/outside":
+			http.Redirect(w, r, "http://example.com/collect", http.StatusTemporaryRedirect)
+		case "/inside":
+			http.Redirect(w, r, "/ok", http.StatusPermanentRedirect)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer internal.Close()
+
+	config := defaultConfig()
+	config.Airgap = true
+	client := checkedClient(config, 5*time.Second)
+	if _, err := client.Post(internal.URL+"/outside", "application/json", strings.NewReader("{}")); err == nil || !strings.Contains(err.Error(), "air-gapped mode") {
+		t.Errorf("redirect to an outside host = %v, want refused", err)
+	}
+	resp, err := client.Post(internal.URL+"/inside", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("redirect to an internal host = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("redirect to an internal host ended with status %d", resp.StatusCode)
+	}
+}
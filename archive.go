@@ -0,0 +1,453 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Limits for listing archives, which can be large
+const (
+	archiveTimeout    = 3 * time.Second
+	maxArchiveEntries = 5000
+	maxArchivePreview = 8
+)
+
+// Archive formats, as detected from the file's contents
+const (
+	ArchiveTar    = "tar"
+	ArchiveTarGz  = "tar.gz"
+	ArchiveTarBz2 = "tar.bz2"
+	ArchiveTarXz  = "tar.xz"
+	ArchiveTarZst = "tar.zst"
+	ArchiveZip    = "zip"
+	Archive7z     = "7z"
+	ArchiveRar    = "rar"
+	ArchiveGzip   = "gzip"
+	ArchiveBzip2  = "bzip2"
+	ArchiveXz     = "xz"
+	ArchiveZstd   = "zstd"
+)
+
+// archiveExtractors are the commands that extract each format
+var archiveExtractors = map[string]string{
+	ArchiveTar:    "tar -xf ARCHIVE -C DIR",
+	ArchiveTarGz:  "tar -xzf ARCHIVE -C DIR",
+	ArchiveTarBz2: "tar -xjf ARCHIVE -C DIR",
+	ArchiveTarXz:  "tar -xJf ARCHIVE -C DIR",
+	ArchiveTarZst: "tar --zstd -xf ARCHIVE -C DIR",
+	ArchiveZip:    "unzip ARCHIVE -d DIR",
+	Archive7z:     "7z x ARCHIVE -oDIR",
+	ArchiveRar:    "unrar x ARCHIVE DIR/",
+	ArchiveGzip:   "gzip -dk ARCHIVE",
+	ArchiveBzip2:  "bzip2 -dk ARCHIVE",
+	ArchiveXz:     "xz -dk ARCHIVE",
+	ArchiveZstd:   "zstd -d ARCHIVE",
+}
+
+var (
+	// extractCommandRe matches commands that extract an archive, and
+	// extractTargetRe the options that choose where to
+	extractCommandRe = regexp.MustCompile(`\btar\s+(?:-\S*x|x|--extract)|\b(?:unzip|bsdtar)\b|\b7za?\s+[xe]\b|\bunrar\s+[xe]\b`)
+	extractTargetRe  = regexp.MustCompile(`\s(?:-C|--directory|-d)(?:\s|=)|\s-o\S|\S/$`)
+	// archiveNameRe matches file names with an archive extension
+	archiveNameRe = regexp.MustCompile(`(?i)\.(?:tar(?:\.(?:gz|bz2|xz|zst))?|tgz|tbz2?|txz|tzst|zip|jar|war|7z|rar|gz|bz2|xz|zst)$`)
+	// extractQueryRe matches requests to unpack an archive
+	extractQueryRe = regexp.MustCompile(`(?i)\b(?:extract|unpack|unzip|untar|decompress|uncompress|unarchive|open)\b`)
+)
+
+// ArchiveInfo is what an archive holds, from listing it
+type ArchiveInfo struct {
+	Path   string
+	Format string
+	// Entries are the first names in the archive, Count how many there
+	// are and Size their unpacked size, when the lister reports it.
+	// Complete is false when the listing stopped early.
+	Entries  []string
+	Count    int
+	Size     int64
+	Complete bool
+	// TopLevel are the distinct names at the root of the archive; more
+	// than one means extracting it in place scatters them
+	TopLevel []string
+	// Unsafe are entries that extract outside the target directory
+	Unsafe []string
+}
+
+// archiveCache keeps listings until the file changes
+var archiveCache struct {
+	sync.Mutex
+	entries map[string]archiveCacheEntry
+}
+
+type archiveCacheEntry struct {
+	modTime time.Time
+	size    int64
+	info    *ArchiveInfo
+}
+
+// InspectArchive lists an archive, detecting its format from its contents
+// rather than its name. Listings are cached until the file changes.
+func InspectArchive(name string) (*ArchiveInfo, error) {
+	stat, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	archiveCache.Lock()
+	defer archiveCache.Unlock()
+	if cached, ok := archiveCache.entries[name]; ok && cached.modTime.Equal(stat.ModTime()) && cached.size == stat.Size() {
+		return cached.info, nil
+	}
+
+	info, err := listArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	if archiveCache.entries == nil {
+		archiveCache.entries = make(map[string]archiveCacheEntry)
+	}
+	archiveCache.entries[name] = archiveCacheEntry{modTime: stat.ModTime(), size: stat.Size(), info: info}
+	return info, nil
+}
+
+// archiveMagic are the leading bytes of each format; tar is recognised by
+// the ustar mark in its first header instead
+var archiveMagic = []struct {
+	format string
+	magic  []byte
+}{
+	{ArchiveGzip, []byte{0x1f, 0x8b}},
+	{ArchiveBzip2, []byte("BZh")},
+	{ArchiveXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{ArchiveZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{ArchiveZip, []byte("PK\x03\x04")},
+	{ArchiveZip, []byte("PK\x05\x06")},
+	{Archive7z, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}},
+	{ArchiveRar, []byte("Rar!\x1a\x07")},
+}
+
+// isTar reports whether a block starts a tar archive
+func isTar(header []byte) bool {
+	return len(header) >= 263 && string(header[257:262]) == "ustar"
+}
+
+func listArchive(name string) (*ArchiveInfo, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(f, header)
+	header = header[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	info := &ArchiveInfo{Path: name}
+	for _, m := range archiveMagic {
+		if bytes.HasPrefix(header, m.magic) {
+			info.Format = m.format
+			break
+		}
+	}
+	if info.Format == "" && isTar(header) {
+		info.Format = ArchiveTar
+	}
+
+	var names []string
+	switch info.Format {
+	case ArchiveTar:
+		names, err = listTar(f, info)
+	case ArchiveGzip, ArchiveBzip2:
+		var r io.Reader
+		if info.Format == ArchiveGzip {
+			if r, err = gzip.NewReader(f); err != nil {
+				return nil, err
+			}
+		} else {
+			r = bzip2.NewReader(f)
+		}
+		br := bufio.NewReader(r)
+		if peek, _ := br.Peek(512); isTar(peek) {
+			info.Format = map[string]string{ArchiveGzip: ArchiveTarGz, ArchiveBzip2: ArchiveTarBz2}[info.Format]
+			names, err = listTar(br, info)
+		} else {
+			names, info.Complete = []string{strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))}, true
+		}
+	case ArchiveXz, ArchiveZstd:
+		// Only tar knows whether there is a tar inside, through the
+		// xz or zstd program
+		if names, err = listExternal(info, "tar", "-tf", name); err == nil {
+			info.Format = map[string]string{ArchiveXz: ArchiveTarXz, ArchiveZstd: ArchiveTarZst}[info.Format]
+		} else {
+			names, info.Complete, err = []string{strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))}, true, nil
+		}
+	case ArchiveZip:
+		names, err = listZip(name, info)
+	case Archive7z:
+		names, err = listExternal(info, "7z", "l", "-ba", "-slt", name)
+	case ArchiveRar:
+		names, err = listExternal(info, "unrar", "lb", name)
+	default:
+		return nil, fmt.Errorf("%s is not an archive this tool recognises", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range names {
+		if len(info.Entries) < maxArchivePreview {
+			info.Entries = append(info.Entries, entry)
+		}
+		clean := path.Clean(strings.ReplaceAll(entry, `\`, "/"))
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			info.Unsafe = append(info.Unsafe, entry)
+		}
+		top, _, _ := strings.Cut(strings.TrimPrefix(clean, "./"), "/")
+		if top != "" && top != "." && !seen[top] {
+			seen[top] = true
+			info.TopLevel = append(info.TopLevel, top)
+		}
+	}
+	info.Count = len(names)
+	return info, nil
+}
+
+// listTar reads the tar headers, giving up after maxArchiveEntries or
+// archiveTimeout
+func listTar(r io.Reader, info *ArchiveInfo) ([]string, error) {
+	start := time.Now()
+	tr := tar.NewReader(r)
+	var names []string
+	for len(names) < maxArchiveEntries && time.Since(start) < archiveTimeout {
+		header, err := tr.Next()
+		if err == io.EOF {
+			info.Complete = true
+			return names, nil
+		}
+		if err != nil {
+			return names, err
+		}
+		names = append(names, header.Name)
+		info.Size += header.Size
+	}
+	return names, nil
+}
+
+// listZip reads the zip's central directory
+func listZip(name string, info *ArchiveInfo) ([]string, error) {
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		if len(names) == maxArchiveEntries {
+			return names, nil
+		}
+		names = append(names, f.Name)
+		info.Size += int64(f.UncompressedSize64)
+	}
+	info.Complete = true
+	return names, nil
+}
+
+// listExternal lists an archive with a tool that prints one name per line,
+// or with 7z's "Path = name" lines
+func listExternal(info *ArchiveInfo, tool string, args ...string) ([]string, error) {
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%s is needed to list %s archives", tool, info.Format)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, tool, args...).Output()
+	if err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if tool == "7z" {
+			var ok bool
+			if line, ok = strings.CutPrefix(line, "Path = "); !ok {
+				continue
+			}
+		}
+		if line != "" && len(names) < maxArchiveEntries {
+			names = append(names, line)
+		}
+	}
+	info.Complete = ctx.Err() == nil && len(names) < maxArchiveEntries
+	return names, nil
+}
+
+// archivesIn finds the archives a request or command names, resolved
+// against dir
+func archivesIn(text, dir string) []string {
+	var found []string
+	for _, field := range strings.Fields(text) {
+		name := strings.Trim(field, `"'.,;:!?()`)
+		if !archiveNameRe.MatchString(name) {
+			continue
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		if stat, err := os.Stat(name); err == nil && stat.Mode().IsRegular() {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// requestArchive is the archive a request is about: the one it names, or
+// for a request to extract "the archive", the only one in dir
+func requestArchive(query, dir string) string {
+	if found := archivesIn(query, dir); len(found) > 0 {
+		return found[0]
+	}
+	if !extractQueryRe.MatchString(query) {
+		return ""
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var only string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && archiveNameRe.MatchString(entry.Name()) {
+			if only != "" {
+				return ""
+			}
+			only = filepath.Join(dir, entry.Name())
+		}
+	}
+	return only
+}
+
+// GatherArchive lists the archive the request is about, so the command
+// uses the right flags for its real format and doesn't scatter its files
+func (c *PromptContext) GatherArchive(config Config, query string) {
+	if !config.ArchiveContext {
+		return
+	}
+	dir := c.Cwd
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	if name := requestArchive(query, dir); name != "" {
+		c.Archive, _ = InspectArchive(name)
+	}
+}
+
+// Describe summarises the archive on one line, e.g. "tar.gz, 12 entries,
+// 3.1M unpacked"
+func (a *ArchiveInfo) Describe() string {
+	count := fmt.Sprintf("%d", a.Count)
+	if !a.Complete {
+		count += "+"
+	}
+	s := fmt.Sprintf("%s, %s entries", a.Format, count)
+	if a.Size > 0 {
+		s += ", " + formatBytes(a.Size) + " unpacked"
+	}
+	return s
+}
+
+// extensionFormats map file name endings to the format they promise,
+// longest first
+var extensionFormats = []struct{ ext, format string }{
+	{".tar.gz", ArchiveTarGz}, {".tgz", ArchiveTarGz},
+	{".tar.bz2", ArchiveTarBz2}, {".tbz2", ArchiveTarBz2}, {".tbz", ArchiveTarBz2},
+	{".tar.xz", ArchiveTarXz}, {".txz", ArchiveTarXz},
+	{".tar.zst", ArchiveTarZst}, {".tzst", ArchiveTarZst},
+	{".tar", ArchiveTar}, {".zip", ArchiveZip}, {".jar", ArchiveZip}, {".war", ArchiveZip},
+	{".7z", Archive7z}, {".rar", ArchiveRar},
+	{".gz", ArchiveGzip}, {".bz2", ArchiveBzip2}, {".xz", ArchiveXz}, {".zst", ArchiveZstd},
+}
+
+// extensionFormat is the format a file's name promises, or ""
+func extensionFormat(name string) string {
+	name = strings.ToLower(name)
+	for _, e := range extensionFormats {
+		if strings.HasSuffix(name, e.ext) {
+			return e.format
+		}
+	}
+	return ""
+}
+
+// ExtractWarnings explain what extracting the archive with command would do
+// wrong; there are none when command doesn't extract it
+func (a *ArchiveInfo) ExtractWarnings(command string) []string {
+	if !extractCommandRe.MatchString(command) {
+		return nil
+	}
+	var warnings []string
+	if len(a.TopLevel) > 1 && !extractTargetRe.MatchString(command) {
+		warnings = append(warnings, fmt.Sprintf("%s has %d entries at its top level; extracted in place they would be scattered through the current directory", filepath.Base(a.Path), len(a.TopLevel)))
+	}
+	if len(a.Unsafe) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%s has entries that would land outside the directory it is extracted into, e.g. %s", filepath.Base(a.Path), a.Unsafe[0]))
+	}
+	return warnings
+}
+
+// String renders the archive for the system prompt
+func (a *ArchiveInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nArchive %s, listed: %s.\n", a.Path, a.Describe())
+	if format := extensionFormat(a.Path); format != a.Format {
+		fmt.Fprintf(&b, "Its contents show it is %s, whatever its name says; use the flags for %s.\n", a.Format, a.Format)
+	}
+	if extractor := archiveExtractors[a.Format]; extractor != "" {
+		fmt.Fprintf(&b, "Extract it with: %s\n", extractor)
+	}
+	if len(a.TopLevel) > 1 {
+		b.WriteString("It has no single top-level directory: extract it into a new directory named after the archive (mkdir it first) instead of the current one.\n")
+	} else if len(a.TopLevel) == 1 && a.Count > 1 {
+		fmt.Fprintf(&b, "Everything is under %s/ inside it.\n", a.TopLevel[0])
+	}
+	if len(a.Unsafe) > 0 {
+		fmt.Fprintf(&b, "Some entries are absolute or climb out with ..: %s. Never extract with -P or --absolute-names; say that it is unsafe.\n", strings.Join(a.Unsafe[:min(len(a.Unsafe), 3)], ", "))
+	}
+	fmt.Fprintf(&b, "First entries: %s\n", strings.Join(a.Entries, ", "))
+	return b.String()
+}
+
+// renderArchivePreview shows what an archive holds in the review
+func renderArchivePreview(a *ArchiveInfo, dimStyle lipgloss.Style) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", filepath.Base(a.Path), a.Describe())
+	for _, entry := range a.Entries {
+		b.WriteString(dimStyle.Render("  "+entry) + "\n")
+	}
+	if more := a.Count - len(a.Entries); more > 0 || !a.Complete {
+		suffix := ""
+		if !a.Complete {
+			suffix = "+"
+		}
+		b.WriteString(dimStyle.Render(fmt.Sprintf("  ... %d%s more", more, suffix)) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Values of the ascii config key
+const (
+	ASCIIAuto = "auto" // ASCII unless the locale is UTF-8
+	ASCIIOn   = "on"
+	ASCIIOff  = "off"
+)
+
+// asciiOnly draws the UI in ASCII alone, for serial consoles, old Windows
+// consoles and fonts that garble box drawing; set at startup by ApplyASCII
+var asciiOnly bool
+
+// asciiBorder stands in for rounded and single-line borders
+var asciiBorder = lipgloss.Border{
+	Top: "-", Bottom: "-", Left: "|", Right: "|",
+	TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+	MiddleLeft: "+", MiddleRight: "+", Middle: "+", MiddleTop: "+", MiddleBottom: "+",
+}
+
+// asciiGlyphs maps the glyphs the UI draws, and typography common in model
+// answers, to ASCII that looks alike
+var asciiGlyphs = map[string]string{
+	"─": "-", "━": "-", "═": "=", "│": "|", "┃": "|", "║": "|",
+	"╭": "+", "╮": "+", "╰": "+", "╯": "+", "┌": "+", "┐": "+", "└": "+", "┘": "+",
+	"├": "+", "┤": "+", "┬": "+", "┴": "+", "┼": "+", "╔": "+", "╗": "+", "╚": "+", "╝": "+",
+	"✓": "+", "✗": "x", "⚠": "!", "•": "*", "▶": ">", "▸": ">", "▾": "v",
+	"↑": "^", "↓": "v", "→": ">", "←": "<", "█": "#", "▌": "|", "░": ".", "…": ".",
+	"‘": "'", "’": "'", "“": `"`, "”": `"`, "–": "-", "—": "-",
+	"⏱": "~", "📅": "*", "🐢": "~",
+}
+
+// asciiReplacer swaps glyphs for their ASCII look-alikes, padded to the
+// glyph's width so boxes drawn around them stay aligned
+var asciiReplacer = func() *strings.Replacer {
+	var pairs []string
+	for glyph, ascii := range asciiGlyphs {
+		pad := max(0, lipgloss.Width(glyph)-len(ascii))
+		pairs = append(pairs, glyph, ascii+strings.Repeat(" ", pad))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// ApplyASCII decides whether the UI is drawn in ASCII: as the ascii setting
+// says, or on auto when the locale isn't UTF-8
+func ApplyASCII(setting string, caps Capabilities) {
+	asciiOnly = setting == ASCIIOn || (setting == ASCIIAuto && !caps.Unicode.Supported)
+}
+
+// asciiText replaces the UI's glyphs in rendered text when drawing in
+// ASCII. The shell's own output is left as it came.
+func asciiText(text string) string {
+	if !asciiOnly {
+		return text
+	}
+	return asciiReplacer.Replace(text)
+}
+
+// roundedBorder is the border of overlays
+func roundedBorder() lipgloss.Border {
+	if asciiOnly {
+		return asciiBorder
+	}
+	return lipgloss.RoundedBorder()
+}
+
+// normalBorder is the single line set beside previews
+func normalBorder() lipgloss.Border {
+	if asciiOnly {
+		return asciiBorder
+	}
+	return lipgloss.NormalBorder()
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxAttachBytes caps how much of an attached file is sent to the model
+const maxAttachBytes = 16 * 1024
+
+// maxImageBytes is the largest image sent; images can't be cut short
+const maxImageBytes = 5 * 1024 * 1024
+
+// clipboardImage is the path shown for an image pasted from the clipboard
+const clipboardImage = "clipboard.png"
+
+// imageTypes are the image formats multimodal models accept
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Attachment is a file whose contents are sent with a request
+type Attachment struct {
+	Path    string
+	Content string
+	// Size is the whole file's size; Content may hold only its start
+	Size int64
+	// Image holds the file when it is a picture, sent to the model as is
+	// rather than as text; MediaType is its format
+	Image     []byte
+	MediaType string
+}
+
+// IsImage reports whether the attachment is a picture
+func (a Attachment) IsImage() bool {
+	return a.Image != nil
+}
+
+// Truncated reports whether only the start of the file is attached
+func (a Attachment) Truncated() bool {
+	return !a.IsImage() && int64(len(a.Content)) < a.Size
+}
+
+// DataURL encodes an image attachment for a multimodal chat message
+func (a Attachment) DataURL() string {
+	return "data:" + a.MediaType + ";base64," + base64.StdEncoding.EncodeToString(a.Image)
+}
+
+// ReadAttachment reads the start of a text file, or the whole of an image,
+// for use as context
+func ReadAttachment(path string) (*Attachment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachBytes))
+	if err != nil {
+		return nil, err
+	}
+	if mediaType := http.DetectContentType(data); imageTypes[mediaType] {
+		if info.Size() > maxImageBytes {
+			return nil, fmt.Errorf("%s is larger than %d MB", path, maxImageBytes/1024/1024)
+		}
+		image, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Attachment{Path: path, Size: int64(len(image)), Image: image, MediaType: mediaType}, nil
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, fmt.Errorf("%s looks like a binary file", path)
+	}
+	// Don't leave half a character at the cut
+	for len(data) > 0 && int64(len(data)) < info.Size() && !utf8.Valid(data) {
+		data = data[:len(data)-1]
+	}
+	return &Attachment{Path: path, Content: string(data), Size: info.Size()}, nil
+}
+
+// ReadClipboardImage takes the image on the clipboard, through whichever
+// of wl-paste, xclip and pngpaste is installed
+func ReadClipboardImage() (*Attachment, error) {
+	var tools [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, []string{"wl-paste", "--no-newline", "--type", "image/png"})
+	}
+	tools = append(tools,
+		[]string{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"},
+		[]string{"pngpaste", "-"},
+	)
+
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			continue
+		}
+		image, err := exec.Command(tool[0], tool[1:]...).Output()
+		if err != nil || !imageTypes[http.DetectContentType(image)] {
+			return nil, errors.New("the clipboard holds no image")
+		}
+		if len(image) > maxImageBytes {
+			return nil, fmt.Errorf("the clipboard image is larger than %d MB", maxImageBytes/1024/1024)
+		}
+		return &Attachment{Path: clipboardImage, Size: int64(len(image)), Image: image, MediaType: http.DetectContentType(image)}, nil
+	}
+	return nil, errors.New("reading the clipboard needs wl-paste, xclip or pngpaste")
+}
+
+// String renders the attachment for the system prompt
+func (a Attachment) String() string {
+	if a.IsImage() {
+		return fmt.Sprintf("Attached image %s: sent with the request.\n", filepath.Base(a.Path))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Attached file %s:\n%s\n", filepath.Base(a.Path), strings.TrimRight(a.Content, "\n"))
+	if a.Truncated() {
+		fmt.Fprintf(&b, "[... only the first %d of %d bytes are shown]\n", len(a.Content), a.Size)
+	}
+	return b.String()
+}
+
+// filePicker browses directories to choose a file to attach
+type filePicker struct {
+	dir     string
+	entries []os.DirEntry
+	cursor  int
+}
+
+// newFilePicker lists dir, directories first
+func newFilePicker(dir string) (*filePicker, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+	return &filePicker{dir: dir, entries: entries}, nil
+}
+
+// openFilePicker starts choosing a file in the shell's directory
+func (m *Model) openFilePicker() {
+	dir := m.shellCwd()
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	picker, err := newFilePicker(dir)
+	if err != nil {
+		m.input.Placeholder = err.Error()
+		return
+	}
+	m.filePicker = picker
+}
+
+// updateFilePicker handles keys while choosing a file. The first row goes
+// up to the parent directory.
+func (m Model) updateFilePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.filePicker
+	switch msg.Type {
+	case tea.KeyUp, tea.KeyShiftTab:
+		p.cursor = max(0, p.cursor-1)
+	case tea.KeyDown, tea.KeyTab:
+		p.cursor = min(len(p.entries), p.cursor+1)
+	case tea.KeyBackspace, tea.KeyLeft:
+		m.browse(filepath.Dir(p.dir))
+	case tea.KeyEnter, tea.KeyRight:
+		if p.cursor == 0 {
+			m.browse(filepath.Dir(p.dir))
+			return m, nil
+		}
+		entry := p.entries[p.cursor-1]
+		path := filepath.Join(p.dir, entry.Name())
+		if entry.IsDir() {
+			m.browse(path)
+			return m, nil
+		}
+		if msg.Type == tea.KeyRight {
+			return m, nil
+		}
+		m.filePicker = nil
+		attachment, err := ReadAttachment(path)
+		if err != nil {
+			m.input.Placeholder = err.Error()
+			return m, nil
+		}
+		countFeature("attach file")
+		if attachment.IsImage() {
+			countFeature("attach image")
+		}
+		m.attachment = attachment
+	case tea.KeyEsc:
+		m.filePicker = nil
+	}
+	return m, nil
+}
+
+// browse moves the file picker to dir, staying put if it can't be read
+func (m *Model) browse(dir string) {
+	if picker, err := newFilePicker(dir); err == nil {
+		m.filePicker = picker
+	}
+}
+
+// renderFilePicker lists the current directory with the cursor
+// highlighted, scrolled to keep the cursor within rows
+func (m Model) renderFilePicker(titleStyle, hintStyle lipgloss.Style, rows int) string {
+	p := m.filePicker
+	selectedStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+	dirStyle := lipgloss.NewStyle().Foreground(theme.Info)
+
+	names := []string{"../"}
+	for _, entry := range p.entries {
+		if entry.IsDir() {
+			names = append(names, dirStyle.Render(entry.Name()+"/"))
+		} else {
+			names = append(names, entry.Name())
+		}
+	}
+
+	rows = max(1, rows)
+	top := max(0, p.cursor-rows+1)
+	bottom := min(len(names), top+rows)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Attach a file: "+p.dir) + "\n\n")
+	for i := top; i < bottom; i++ {
+		if i == p.cursor {
+			b.WriteString(selectedStyle.Render("> ") + names[i])
+		} else {
+			b.WriteString("  " + names[i])
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render(fmt.Sprintf("↑/↓ to move, Enter to open or attach, Backspace for the parent, Esc to cancel (the first %d KB of text files are sent, images whole)", maxAttachBytes/1024)))
+	return b.String()
+}
+
+// attachmentContext returns a copy of the attachment with secrets redacted
+// for sending, or nil when there is none
+func (m Model) attachmentContext() *Attachment {
+	if m.attachment == nil {
+		return nil
+	}
+	redacted := *m.attachment
+	redacted.Content = redactText(m.config, redacted.Content)
+	return &redacted
+}
+
+// renderAttachment describes the attached file in the prompt
+func (m Model) renderAttachment() string {
+	a := m.attachment
+	text := fmt.Sprintf("Attached %s (%d bytes", filepath.Base(a.Path), a.Size)
+	if a.Truncated() {
+		text += fmt.Sprintf(", first %d sent", len(a.Content))
+	}
+	if a.IsImage() {
+		// Nothing in a picture can be redacted
+		text += ", image sent as is"
+	}
+	text += ") (Alt+A to remove, Alt+Enter to ask about it)"
+	if _, redacted := RedactSecrets(m.config, a.Content); redacted > 0 {
+		text += " " + redactedNotice(redacted)
+	}
+	return lipgloss.NewStyle().Foreground(theme.Info).Render(text)
+}
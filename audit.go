@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How an audited command reached the shell
+const (
+	AuditRun    = "run"    // run from the review or straight away
+	AuditEdited = "edited" // typed at the shell prompt and run from there
+	AuditInline = "inline" // completed by an inline suggestion
+	AuditScript = "script" // a generated script, saved and invoked
+	AuditStep   = "step"   // one command of a chain, run as its own step
+)
+
+// AuditEntry is one line of the audit log: an AI-suggested command that
+// was executed and, when the shell reports it, its exit code
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	Query    string    `json:"query,omitempty"`
+	Command  string    `json:"command"`
+	Model    string    `json:"model"`
+	Source   string    `json:"source"`
+	Cwd      string    `json:"cwd,omitempty"`
+	Host     string    `json:"host,omitempty"`
+	ExitCode *int      `json:"exit_code"`
+	// Ended is when the shell reported the exit code
+	Ended *time.Time `json:"ended,omitempty"`
+}
+
+// GetAuditPath returns the path to the audit log next to the config file
+func GetAuditPath() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "audit.jsonl")
+}
+
+// AppendAudit adds entry to the audit log. The log is only ever appended
+// to; nothing in the tool rewrites or trims it.
+func AppendAudit(entry AuditEntry) error {
+	path := GetAuditPath()
+	if path == "" {
+		return os.ErrNotExist
+	}
+	file, err := os.OpenFile(path, appendFlags, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return appendLocked(file, append(data, '\n'))
+}
+
+// LoadAudit reads every entry in the audit log. A missing log is empty;
+// lines that fail to parse are skipped.
+func LoadAudit() ([]AuditEntry, error) {
+	file, err := os.Open(GetAuditPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// generationModel is the model commands are generated with: the persona's,
+// when it names one, or the configured model
+func generationModel(config Config) string {
+	if config.Persona != "" {
+		if persona, err := LoadPersona(config.Persona); err == nil && persona.Model != "" {
+			return persona.Model
+		}
+	}
+	return config.Model
+}
+
+// auditExecuted starts auditing a command that was just sent to the shell.
+// It is written once the shell reports its exit code, or without one when
+// the next command starts or the session ends first.
+func (m *Model) auditExecuted(entry AuditEntry) {
+	m.startReceipt(entry.Command)
+	m.markProvenance()
+	if !m.config.AuditLog {
+		return
+	}
+	m.flushAudit()
+	entry.Time = time.Now()
+	entry.Session = m.session
+	if entry.Model == "" {
+		entry.Model = generationModel(m.config)
+	}
+	if remote := m.currentRemote(); remote != nil {
+		entry.Host = remote.Host
+	} else {
+		entry.Cwd = m.shellCwd()
+	}
+	m.auditRunning = &entry
+}
+
+// auditTyped notes an AI-suggested command typed at the shell prompt; it is
+// audited, as finally edited, if it is then run
+func (m *Model) auditTyped(entry AuditEntry) {
+	m.markProvenance()
+	m.auditPrompt = &entry
+}
+
+// auditSubmitted audits the line run at the shell prompt when a suggested
+// command was typed there. When the line was edited beyond what is tracked,
+// the command as typed is logged.
+func (m *Model) auditSubmitted(line string, known bool) {
+	entry := m.auditPrompt
+	m.auditPrompt = nil
+	if entry == nil || (known && strings.TrimSpace(line) == "") {
+		return
+	}
+	if known {
+		entry.Command = strings.TrimSpace(line)
+	}
+	m.auditExecuted(*entry)
+}
+
+// watchAudit completes the running command's entry with the exit code the
+// shell integration reports (OSC 133;D), and when it came
+func (m *Model) watchAudit(chunk []byte) {
+	if m.auditRunning == nil {
+		return
+	}
+	if match := exitStatusRe.FindSubmatch(chunk); match != nil {
+		if code, err := strconv.Atoi(string(match[1])); err == nil {
+			ended := time.Now()
+			m.auditRunning.ExitCode, m.auditRunning.Ended = &code, &ended
+		}
+		m.flushAudit()
+	}
+}
+
+// flushAudit writes the running command's entry, with or without an exit
+// code
+func (m *Model) flushAudit() {
+	if m.auditRunning == nil {
+		return
+	}
+	// Like history, a failed write must not break the session
+	AppendAudit(*m.auditRunning)
+	m.auditRunning = nil
+}
+
+// handleAuditCommand handles the audit subcommands
+func handleAuditCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui audit show [-n N] [--session ID] [--failed] [--json]")
+		fmt.Println("       ai-terminal-tui audit path")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+
+	switch args[0] {
+	case "path":
+		fmt.Println(GetAuditPath())
+
+	case "show":
+		limit, session, failed, asJSON := 50, "", false, false
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "-n":
+				if i+1 >= len(args) {
+					usage()
+				}
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					usage()
+				}
+				limit = n
+				i++
+			case "--session", "-s":
+				if i+1 >= len(args) {
+					usage()
+				}
+				session = args[i+1]
+				i++
+			case "--failed":
+				failed = true
+			case "--json":
+				asJSON = true
+			default:
+				usage()
+			}
+		}
+
+		entries, err := LoadAudit()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		var selected []AuditEntry
+		for _, entry := range entries {
+			if session != "" && entry.Session != session {
+				continue
+			}
+			if failed && (entry.ExitCode == nil || *entry.ExitCode == 0) {
+				continue
+			}
+			selected = append(selected, entry)
+		}
+		if len(selected) > limit {
+			selected = selected[len(selected)-limit:]
+		}
+		if len(selected) == 0 {
+			fmt.Println("No executed AI commands in the audit log; it is kept while audit_log is true.")
+			return
+		}
+
+		for _, entry := range selected {
+			if asJSON {
+				data, _ := json.Marshal(entry)
+				fmt.Println(string(data))
+				continue
+			}
+			exit, took := "?", "?"
+			if entry.ExitCode != nil {
+				exit = strconv.Itoa(*entry.ExitCode)
+			}
+			if entry.Ended != nil {
+				took = formatElapsed(entry.Ended.Sub(entry.Time))
+			}
+			where := entry.Cwd
+			if entry.Host != "" {
+				where = "ssh " + entry.Host
+			}
+			fmt.Printf("%s  exit %-3s in %-6s %-6s %s\n", entry.Time.Local().Format(time.DateTime), exit, took, entry.Source, entry.Command)
+			fmt.Printf("    %s, %s, session %s", entry.Model, valueOrDefault(where, "unknown directory"), entry.Session)
+			if entry.Query != "" {
+				fmt.Printf(": %s", entry.Query)
+			}
+			fmt.Println()
+		}
+
+	default:
+		usage()
+	}
+}
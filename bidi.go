@@ -0,0 +1,301 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Values of the bidi config key
+const (
+	BidiAuto = "auto" // reorder unless the terminal does it itself
+	BidiOn   = "on"
+	BidiOff  = "off"
+)
+
+// mirroredRunes swap in right-to-left runs, where an opening bracket is
+// drawn facing the other way
+var mirroredRunes = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<',
+	'«': '»', '»': '«', '‹': '›', '›': '‹',
+}
+
+// bidiClass returns the bidirectional class of a rune. Explicit embeddings
+// and isolates are rare in model output and treated as neutral.
+func bidiClass(r rune) bidi.Class {
+	props, _ := bidi.LookupRune(r)
+	switch class := props.Class(); class {
+	case bidi.L, bidi.R, bidi.AL, bidi.EN, bidi.ES, bidi.ET, bidi.AN, bidi.CS, bidi.B, bidi.S, bidi.WS, bidi.NSM:
+		return class
+	}
+	return bidi.ON
+}
+
+// HasRTL reports whether text contains right-to-left letters, such as
+// Arabic or Hebrew
+func HasRTL(text string) bool {
+	for _, r := range text {
+		if class := bidiClass(r); class == bidi.R || class == bidi.AL {
+			return true
+		}
+	}
+	return false
+}
+
+// isRTLParagraph reports whether a paragraph reads right to left, as its
+// first letter with a direction does
+func isRTLParagraph(text string) bool {
+	for _, r := range text {
+		switch bidiClass(r) {
+		case bidi.L:
+			return false
+		case bidi.R, bidi.AL:
+			return true
+		}
+	}
+	return false
+}
+
+// bidiLevels resolves the embedding level of each rune of one line, with
+// the implicit rules of the Unicode Bidirectional Algorithm (UAX #9, W1-W7,
+// N1-N2, I1-I2 and L1): odd levels run right to left
+func bidiLevels(runes []rune, rtl bool) []int {
+	n := len(runes)
+	types := make([]bidi.Class, n)
+	for i, r := range runes {
+		types[i] = bidiClass(r)
+	}
+	base, sos := 0, bidi.L
+	if rtl {
+		base, sos = 1, bidi.R
+	}
+
+	// W1: marks take the type of what they mark
+	for i := range types {
+		if types[i] == bidi.NSM {
+			types[i] = sos
+			if i > 0 {
+				types[i] = types[i-1]
+			}
+		}
+	}
+	// W2, W3: numbers after Arabic letters are Arabic numbers, and Arabic
+	// letters are then plain right to left
+	strong := sos
+	for i, t := range types {
+		switch t {
+		case bidi.L, bidi.R, bidi.AL:
+			strong = t
+		case bidi.EN:
+			if strong == bidi.AL {
+				types[i] = bidi.AN
+			}
+		}
+	}
+	for i, t := range types {
+		if t == bidi.AL {
+			types[i] = bidi.R
+		}
+	}
+	// W4: a single separator between numbers of a kind joins them
+	for i := 1; i+1 < n; i++ {
+		prev, next := types[i-1], types[i+1]
+		switch {
+		case types[i] == bidi.ES && prev == bidi.EN && next == bidi.EN:
+			types[i] = bidi.EN
+		case types[i] == bidi.CS && prev == next && (prev == bidi.EN || prev == bidi.AN):
+			types[i] = prev
+		}
+	}
+	// W5: terminators like % and $ next to European numbers join them
+	for i := 0; i < n; i++ {
+		if types[i] != bidi.ET {
+			continue
+		}
+		j := i
+		for j < n && types[j] == bidi.ET {
+			j++
+		}
+		if (i > 0 && types[i-1] == bidi.EN) || (j < n && types[j] == bidi.EN) {
+			for k := i; k < j; k++ {
+				types[k] = bidi.EN
+			}
+		}
+		i = j
+	}
+	// W6, W7: leftover separators are neutral, and European numbers in
+	// left-to-right text are plain left to right
+	strong = sos
+	for i, t := range types {
+		switch t {
+		case bidi.ES, bidi.ET, bidi.CS:
+			types[i] = bidi.ON
+		case bidi.L, bidi.R:
+			strong = t
+		case bidi.EN:
+			if strong == bidi.L {
+				types[i] = bidi.L
+			}
+		}
+	}
+	// N1, N2: neutrals between text of one direction take it, others the
+	// paragraph's
+	direction := func(t bidi.Class) bidi.Class {
+		if t == bidi.L {
+			return bidi.L
+		}
+		return bidi.R
+	}
+	neutral := func(t bidi.Class) bool {
+		return t == bidi.ON || t == bidi.WS || t == bidi.S || t == bidi.B
+	}
+	for i := 0; i < n; i++ {
+		if !neutral(types[i]) {
+			continue
+		}
+		j := i
+		for j < n && neutral(types[j]) {
+			j++
+		}
+		before, after := sos, sos
+		if i > 0 {
+			before = direction(types[i-1])
+		}
+		if j < n {
+			after = direction(types[j])
+		}
+		resolved := sos
+		if before == after {
+			resolved = before
+		}
+		for k := i; k < j; k++ {
+			types[k] = resolved
+		}
+		i = j
+	}
+
+	// I1, I2
+	levels := make([]int, n)
+	for i, t := range types {
+		switch {
+		case base == 0 && t == bidi.R:
+			levels[i] = 1
+		case base == 0 && (t == bidi.EN || t == bidi.AN):
+			levels[i] = 2
+		case base == 1 && t != bidi.R:
+			levels[i] = 2
+		default:
+			levels[i] = base
+		}
+	}
+	// L1: whitespace at the end of the line goes back to the paragraph's
+	// level
+	for i := n - 1; i >= 0; i-- {
+		if class := bidiClass(runes[i]); class != bidi.WS && class != bidi.S {
+			break
+		}
+		levels[i] = base
+	}
+	return levels
+}
+
+// VisualLine reorders one line of text from the order it is written in to
+// the order it is drawn in, for terminals that draw runes left to right
+// as they come. Marks stay after the letters they mark, and brackets in
+// right-to-left runs are mirrored.
+func VisualLine(line string, rtl bool) string {
+	runes := []rune(line)
+	levels := bidiLevels(runes, rtl)
+
+	// Clusters of a letter and its marks move together
+	type cluster struct {
+		runes []rune
+		level int
+	}
+	var clusters []cluster
+	for i, r := range runes {
+		if bidiClass(r) == bidi.NSM && len(clusters) > 0 {
+			last := &clusters[len(clusters)-1]
+			last.runes = append(last.runes, r)
+			continue
+		}
+		if levels[i]%2 == 1 {
+			if mirrored, ok := mirroredRunes[r]; ok {
+				r = mirrored
+			}
+		}
+		clusters = append(clusters, cluster{runes: []rune{r}, level: levels[i]})
+	}
+
+	// L2: from the highest level down to the lowest odd one, reverse every
+	// run at that level or above
+	highest, lowestOdd := 0, 3
+	for _, c := range clusters {
+		highest = max(highest, c.level)
+		if c.level%2 == 1 {
+			lowestOdd = min(lowestOdd, c.level)
+		}
+	}
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(clusters); i++ {
+			if clusters[i].level < level {
+				continue
+			}
+			j := i
+			for j < len(clusters) && clusters[j].level >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				clusters[a], clusters[b] = clusters[b], clusters[a]
+			}
+			i = j
+		}
+	}
+
+	var b strings.Builder
+	for _, c := range clusters {
+		b.WriteString(string(c.runes))
+	}
+	return b.String()
+}
+
+// wrapBidi wraps text to width like the overlays do, keeping right-to-left
+// paragraphs readable: they are wrapped in the order they are written, so
+// their first words come first, and with reorder each line is then put in
+// the order it is drawn and right-to-left paragraphs are right-aligned.
+// Terminals that reorder text themselves get it as written.
+func wrapBidi(text string, width int, reorder bool) string {
+	wrap := lipgloss.NewStyle().Width(width)
+	if !reorder || !HasRTL(text) {
+		return wrap.Render(text)
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		rtl := isRTLParagraph(paragraph)
+		for _, line := range strings.Split(wrap.Render(paragraph), "\n") {
+			line = strings.TrimRight(line, " ")
+			if HasRTL(line) {
+				line = VisualLine(line, rtl)
+			}
+			if rtl {
+				line = lipgloss.PlaceHorizontal(width, lipgloss.Right, line)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reorderBidi reports whether right-to-left text is reordered for display,
+// which the bidi setting decides or, on auto, whether the terminal does it
+// itself
+func (m Model) reorderBidi() bool {
+	switch m.config.Bidi {
+	case BidiOn:
+		return true
+	case BidiOff:
+		return false
+	}
+	return !m.caps.Bidi.Supported
+}
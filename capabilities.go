@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+// Colour depths a terminal may support
+const (
+	ColorNone      = "none"
+	Color16        = "16"
+	Color256       = "256"
+	ColorTrueColor = "truecolor"
+)
+
+// Capability records whether a terminal feature is available and why we
+// think so
+type Capability struct {
+	Supported bool
+	Reason    string
+}
+
+// Capabilities is the detected feature profile of the host terminal. It is
+// computed once at startup and consulted by rendering, clipboard and key
+// encoding code instead of each re-reading the environment.
+type Capabilities struct {
+	Term        string
+	TermProgram string
+	Multiplexer string
+
+	ColorDepth    string
+	ColorReason   string
+	Terminfo      Capability
+	Unicode       Capability
+	OSC52         Capability
+	KittyKeyboard Capability
+	// Bidi is whether the terminal itself draws right-to-left text in
+	// visual order
+	Bidi Capability
+}
+
+// DetectCapabilities inspects the environment to build a capability profile.
+// It never writes to the terminal; see ProbeCapabilities for active queries.
+func DetectCapabilities() Capabilities {
+	caps := Capabilities{
+		Term:        os.Getenv("TERM"),
+		TermProgram: os.Getenv("TERM_PROGRAM"),
+	}
+
+	switch {
+	case os.Getenv("TMUX") != "":
+		caps.Multiplexer = "tmux"
+	case os.Getenv("STY") != "" || strings.HasPrefix(caps.Term, "screen"):
+		caps.Multiplexer = "screen"
+	}
+
+	caps.ColorDepth, caps.ColorReason = detectColorDepth(caps.Term)
+	caps.Terminfo = detectTerminfo(caps.Term)
+	caps.Unicode = detectUnicode()
+	caps.OSC52 = detectOSC52(caps)
+	caps.KittyKeyboard = detectKittyKeyboard(caps)
+	caps.Bidi = detectBidi(caps)
+
+	return caps
+}
+
+// detectColorDepth follows the usual NO_COLOR / COLORTERM / TERM conventions
+func detectColorDepth(termName string) (string, string) {
+	colorTerm := strings.ToLower(os.Getenv("COLORTERM"))
+
+	switch {
+	case os.Getenv("NO_COLOR") != "":
+		return ColorNone, "NO_COLOR is set"
+	case termName == "dumb":
+		return ColorNone, "TERM=dumb"
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		return ColorTrueColor, "COLORTERM=" + colorTerm
+	case os.Getenv("WT_SESSION") != "":
+		return ColorTrueColor, "Windows Terminal"
+	case strings.Contains(termName, "256color"):
+		return Color256, "TERM=" + termName
+	case strings.Contains(termName, "kitty") || strings.Contains(termName, "direct"):
+		return ColorTrueColor, "TERM=" + termName
+	case termName == "" && runtime.GOOS == "windows":
+		return Color16, "Windows console"
+	}
+	return Color16, "default for TERM=" + termName
+}
+
+// detectTerminfo looks for a compiled terminfo entry for TERM in the standard
+// search path, handling both letter and hex (macOS) directory layouts
+func detectTerminfo(termName string) Capability {
+	if runtime.GOOS == "windows" {
+		return Capability{false, "not used on Windows"}
+	}
+	if termName == "" {
+		return Capability{false, "TERM is not set"}
+	}
+
+	var dirs []string
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("TERMINFO_DIRS")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	dirs = append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo")
+
+	for _, dir := range dirs {
+		for _, sub := range []string{termName[:1], fmt.Sprintf("%x", termName[0])} {
+			path := filepath.Join(dir, sub, termName)
+			if _, err := os.Stat(path); err == nil {
+				return Capability{true, path}
+			}
+		}
+	}
+	return Capability{false, "no entry for " + termName}
+}
+
+// detectUnicode checks the locale for UTF-8
+func detectUnicode() Capability {
+	if runtime.GOOS == "windows" {
+		return Capability{true, "Windows console"}
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		upper := strings.ToUpper(value)
+		supported := strings.Contains(upper, "UTF-8") || strings.Contains(upper, "UTF8")
+		return Capability{supported, name + "=" + value}
+	}
+	return Capability{false, "no UTF-8 locale set"}
+}
+
+// detectOSC52 guesses whether the terminal accepts OSC 52 clipboard writes.
+// Terminals rarely advertise it, so this is based on known implementations.
+func detectOSC52(caps Capabilities) Capability {
+	if caps.Multiplexer == "tmux" {
+		return Capability{true, "tmux forwards OSC 52 (set-clipboard)"}
+	}
+	if os.Getenv("WT_SESSION") != "" {
+		return Capability{true, "Windows Terminal"}
+	}
+	switch caps.TermProgram {
+	case "iTerm.app", "WezTerm", "ghostty", "vscode", "tabby", "rio":
+		return Capability{true, "TERM_PROGRAM=" + caps.TermProgram}
+	case "Apple_Terminal":
+		return Capability{false, "Terminal.app ignores OSC 52"}
+	}
+	for _, name := range []string{"kitty", "alacritty", "foot", "wezterm", "ghostty", "contour"} {
+		if strings.Contains(caps.Term, name) {
+			return Capability{true, "TERM=" + caps.Term}
+		}
+	}
+	if strings.HasPrefix(caps.Term, "xterm") {
+		return Capability{false, "xterm-compatible terminals often disable OSC 52"}
+	}
+	return Capability{false, "unknown terminal"}
+}
+
+// detectKittyKeyboard guesses whether the terminal speaks the kitty keyboard
+// protocol. Multiplexers sit in between and do not pass it through.
+func detectKittyKeyboard(caps Capabilities) Capability {
+	if caps.Multiplexer != "" {
+		return Capability{false, caps.Multiplexer + " does not pass the protocol through"}
+	}
+	if os.Getenv("KITTY_WINDOW_ID") != "" || caps.Term == "xterm-kitty" {
+		return Capability{true, "kitty"}
+	}
+	switch caps.TermProgram {
+	case "ghostty", "WezTerm", "rio":
+		return Capability{true, "TERM_PROGRAM=" + caps.TermProgram}
+	}
+	for _, name := range []string{"foot", "alacritty", "ghostty"} {
+		if strings.Contains(caps.Term, name) {
+			return Capability{true, "TERM=" + caps.Term}
+		}
+	}
+	return Capability{false, "not advertised"}
+}
+
+// detectBidi guesses whether the terminal reorders right-to-left text
+// itself, in which case reordering it again would scramble it
+func detectBidi(caps Capabilities) Capability {
+	if version, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && version >= 5800 {
+		return Capability{true, "VTE 0.58+ (GNOME Terminal, Tilix, ...)"}
+	}
+	if os.Getenv("KONSOLE_VERSION") != "" {
+		return Capability{true, "Konsole"}
+	}
+	if os.Getenv("MLTERM") != "" {
+		return Capability{true, "mlterm"}
+	}
+	if caps.TermProgram == "Apple_Terminal" {
+		return Capability{true, "Terminal.app"}
+	}
+	return Capability{false, "draws text as written; answers are reordered for it"}
+}
+
+var (
+	kittyFlagsReply  = regexp.MustCompile(`\x1b\[\?\d+u`)
+	deviceAttrsReply = regexp.MustCompile(`\x1b\[\?[\d;]*c`)
+)
+
+// ProbeCapabilities refines caps by querying the terminal directly: it asks
+// for the kitty keyboard flags (CSI ? u) followed by primary device
+// attributes, which every terminal answers. Only safe on an interactive
+// terminal before any TUI has taken over the screen.
+func ProbeCapabilities(caps Capabilities) Capabilities {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return caps
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return caps
+	}
+	defer term.Restore(fd, state)
+
+	os.Stdout.WriteString("\x1b[?u\x1b[c")
+
+	// The reply arrives on stdin; give up if the terminal stays silent
+	replies := make(chan string, 1)
+	go func() {
+		var reply []byte
+		buf := make([]byte, 64)
+		for !deviceAttrsReply.Match(reply) {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				break
+			}
+			reply = append(reply, buf[:n]...)
+		}
+		replies <- string(reply)
+	}()
+
+	select {
+	case reply := <-replies:
+		if kittyFlagsReply.MatchString(reply) {
+			caps.KittyKeyboard = Capability{true, "terminal answered CSI ? u"}
+		} else {
+			caps.KittyKeyboard = Capability{false, "terminal did not answer CSI ? u"}
+		}
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	return caps
+}
+
+// ColorProfile maps the detected colour depth to a termenv profile for
+// lipgloss
+func (c Capabilities) ColorProfile() termenv.Profile {
+	switch c.ColorDepth {
+	case ColorTrueColor:
+		return termenv.TrueColor
+	case Color256:
+		return termenv.ANSI256
+	case Color16:
+		return termenv.ANSI
+	}
+	return termenv.Ascii
+}
+
+// printCapabilities prints the capability matrix for doctor
+func printCapabilities(caps Capabilities) {
+	mark := func(c Capability) string {
+		if c.Supported {
+			return "✓"
+		}
+		return "✗"
+	}
+
+	fmt.Println("Terminal capabilities:")
+	fmt.Printf("  %-18s %s\n", "TERM", valueOrDefault(caps.Term, "(not set)"))
+	fmt.Printf("  %-18s %s\n", "TERM_PROGRAM", valueOrDefault(caps.TermProgram, "(not set)"))
+	fmt.Printf("  %-18s %s\n", "Multiplexer", valueOrDefault(caps.Multiplexer, "(none)"))
+	fmt.Printf("  %-18s %-9s %s\n", "Colors", caps.ColorDepth, "("+caps.ColorReason+")")
+	fmt.Printf("  %-18s %-9s %s\n", "Terminfo", mark(caps.Terminfo), "("+caps.Terminfo.Reason+")")
+	fmt.Printf("  %-18s %-9s %s\n", "Unicode", mark(caps.Unicode), "("+caps.Unicode.Reason+")")
+	fmt.Printf("  %-18s %-9s %s\n", "OSC 52 clipboard", mark(caps.OSC52), "("+caps.OSC52.Reason+")")
+	fmt.Printf("  %-18s %-9s %s\n", "Kitty keyboard", mark(caps.KittyKeyboard), "("+caps.KittyKeyboard.Reason+")")
+	fmt.Printf("  %-18s %-9s %s\n", "Terminal bidi", mark(caps.Bidi), "("+caps.Bidi.Reason+")")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// clipboardWriters are the tools text is copied through when the terminal
+// can't take it with OSC 52, in the order they are tried
+var clipboardWriters = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"pbcopy"},
+	{"clip.exe"},
+}
+
+// copyToClipboard puts text on the clipboard: through the terminal with
+// OSC 52 where it is supported, which also works over ssh, or else through
+// the first clipboard tool installed
+func copyToClipboard(caps Capabilities, text string) error {
+	if caps.OSC52.Supported {
+		_, err := os.Stdout.WriteString("\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a")
+		return err
+	}
+	for _, tool := range clipboardWriters {
+		if tool[0] == "wl-copy" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			continue
+		}
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(tool[0], tool[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard available: the terminal does not support OSC 52 and none of wl-copy, xclip, xsel or pbcopy is installed")
+}
+
+// copyCommand puts a generated command on the clipboard instead of running
+// it, to be pasted into another terminal or a runbook, and records it as
+// copied
+func (m *Model) copyCommand(query, command string) error {
+	if err := copyToClipboard(m.caps, command); err != nil {
+		return err
+	}
+	countFeature("copy command")
+	m.recordHistory(HistoryEntry{Kind: HistoryAI, Query: query, Command: command, Outcome: OutcomeCopied})
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Cloud providers whose active account can be described to the model
+const (
+	CloudAWS   = "aws"
+	CloudGCP   = "gcp"
+	CloudAzure = "azure"
+)
+
+// cloudProviders are all providers, the default for cloud_context
+var cloudProviders = []string{CloudAWS, CloudGCP, CloudAzure}
+
+// ParseCloudProviders parses the cloud_context setting: a comma-separated
+// list of providers, or none
+func ParseCloudProviders(value string) ([]string, error) {
+	var providers []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "", "none", "off":
+		case CloudAWS, CloudGCP, CloudAzure:
+			providers = append(providers, name)
+		case "gcloud", "google":
+			providers = append(providers, CloudGCP)
+		case "az":
+			providers = append(providers, CloudAzure)
+		default:
+			return nil, fmt.Errorf("unknown cloud provider %q (expected aws, gcp or azure)", name)
+		}
+	}
+	return providers, nil
+}
+
+// CloudContext is the account each cloud CLI will act on. It is read from
+// the environment and the CLIs' config files rather than by running them,
+// which would take seconds.
+type CloudContext struct {
+	AWSProfile string
+	AWSRegion  string
+
+	GCPProject string
+	GCPRegion  string
+
+	AzureSubscription string
+}
+
+// GatherCloudContext reads the active account of each provider, returning
+// nil when none is configured
+func GatherCloudContext(providers []string) *CloudContext {
+	var c CloudContext
+	for _, provider := range providers {
+		switch provider {
+		case CloudAWS:
+			c.AWSProfile, c.AWSRegion = awsContext()
+		case CloudGCP:
+			c.GCPProject, c.GCPRegion = gcpContext()
+		case CloudAzure:
+			c.AzureSubscription = azureContext()
+		}
+	}
+	if c == (CloudContext{}) {
+		return nil
+	}
+	return &c
+}
+
+// String renders the cloud accounts for the system prompt
+func (c *CloudContext) String() string {
+	var b strings.Builder
+	b.WriteString("Cloud accounts in use (target these unless the request names others):\n")
+	if c.AWSProfile != "" || c.AWSRegion != "" {
+		fmt.Fprintf(&b, "  AWS profile: %s, region: %s\n", valueOrDefault(c.AWSProfile, "default"), valueOrDefault(c.AWSRegion, "not set"))
+	}
+	if c.GCPProject != "" {
+		fmt.Fprintf(&b, "  gcloud project: %s", c.GCPProject)
+		if c.GCPRegion != "" {
+			fmt.Fprintf(&b, ", region: %s", c.GCPRegion)
+		}
+		b.WriteString("\n")
+	}
+	if c.AzureSubscription != "" {
+		fmt.Fprintf(&b, "  Azure subscription: %s\n", c.AzureSubscription)
+	}
+	return b.String()
+}
+
+// awsContext returns the AWS CLI's profile and region. The profile is only
+// reported when it is chosen explicitly or a config file exists.
+func awsContext() (profile, region string) {
+	profile = valueOrDefault(os.Getenv("AWS_PROFILE"), os.Getenv("AWS_DEFAULT_PROFILE"))
+	region = valueOrDefault(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return profile, region
+		}
+		path = filepath.Join(home, ".aws", "config")
+	}
+	sections := readINI(path)
+	if sections == nil {
+		return profile, region
+	}
+
+	if profile == "" {
+		profile = "default"
+	}
+	if region == "" {
+		section := "profile " + profile
+		if profile == "default" {
+			section = "default"
+		}
+		region = sections[section]["region"]
+	}
+	return profile, region
+}
+
+// gcloudConfigDir returns where gcloud keeps its configurations
+func gcloudConfigDir() string {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return dir
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud")
+}
+
+// gcpContext returns the project and region of the active gcloud
+// configuration, with environment overrides applied
+func gcpContext() (project, region string) {
+	dir := gcloudConfigDir()
+	name := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME")
+	if name == "" && dir != "" {
+		data, _ := os.ReadFile(filepath.Join(dir, "active_config"))
+		name = strings.TrimSpace(string(data))
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	var sections map[string]map[string]string
+	if dir != "" {
+		sections = readINI(filepath.Join(dir, "configurations", "config_"+name))
+	}
+	project = valueOrDefault(os.Getenv("CLOUDSDK_CORE_PROJECT"), sections["core"]["project"])
+	region = valueOrDefault(os.Getenv("CLOUDSDK_COMPUTE_REGION"), sections["compute"]["region"])
+	return project, region
+}
+
+// azureContext returns the default subscription of the az CLI as "name (id)"
+func azureContext() string {
+	if id := os.Getenv("AZURE_SUBSCRIPTION_ID"); id != "" {
+		return id
+	}
+
+	dir := os.Getenv("AZURE_CONFIG_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".azure")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "azureProfile.json"))
+	if err != nil {
+		return ""
+	}
+
+	var profile struct {
+		Subscriptions []struct {
+			ID        string `json:"id"`
+			Name      string `json:"name"`
+			IsDefault bool   `json:"isDefault"`
+		} `json:"subscriptions"`
+	}
+	// az writes the file with a byte order mark
+	if json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &profile) != nil {
+		return ""
+	}
+	for _, sub := range profile.Subscriptions {
+		if sub.IsDefault {
+			return fmt.Sprintf("%s (%s)", sub.Name, sub.ID)
+		}
+	}
+	return ""
+}
+
+// readINI parses the INI files the cloud CLIs use into sections of keys,
+// or returns nil when the file can't be read
+func readINI(path string) map[string]map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	sections := map[string]map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if sections[section] == nil {
+			sections[section] = map[string]string{}
+		}
+		sections[section][strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return sections
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// maxCommitDiffBytes caps how much of the staged diff is sent to the model;
+// the diffstat is always included in full
+const maxCommitDiffBytes = 12000
+
+// commitMsgMsg carries a generated commit message, or why there is none
+type commitMsgMsg struct {
+	message string
+	err     error
+}
+
+// StagedChanges returns the diffstat and diff of what is staged in the
+// repository containing dir
+func StagedChanges(dir string) (string, string, error) {
+	if inside, err := runGit(dir, "rev-parse", "--is-inside-work-tree"); err != nil || inside != "true" {
+		return "", "", fmt.Errorf("not inside a git repository")
+	}
+	stat, err := runGit(dir, "diff", "--cached", "--stat")
+	if err != nil {
+		return "", "", err
+	}
+	if stat == "" {
+		return "", "", fmt.Errorf("nothing staged; use git add first")
+	}
+	diff, err := runGit(dir, "diff", "--cached")
+	if err != nil {
+		return "", "", err
+	}
+	if len(diff) > maxCommitDiffBytes {
+		diff = diff[:maxCommitDiffBytes] + "\n... (diff truncated)"
+	}
+	return stat, diff, nil
+}
+
+// GenerateCommitMessage asks the model for a conventional-commit message
+// describing the staged changes
+func GenerateCommitMessage(config Config, stat, diff string) (string, error) {
+	contents, err := chatCompletion(config, ai.Request{
+		Messages: []ai.Message{
+			{Role: "system", Content: "You write git commit messages in the Conventional Commits format: " +
+				"a subject line `type(optional scope): summary` of at most 72 characters, using one of " +
+				"feat, fix, docs, style, refactor, perf, test, build, ci, chore or revert, in the imperative mood; " +
+				"then, if the change is not trivial, a blank line and a short body explaining what and why, wrapped at 72 columns. " +
+				"Respond with ONLY the commit message, no markdown fences or commentary."},
+			{Role: "user", Content: fmt.Sprintf("Staged changes:\n%s\n\n%s", stat, redactText(config, diff))},
+		},
+		Temperature: 0.2,
+		MaxTokens:   400,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	message := ai.CleanCommand(contents[0])
+	if message == "" {
+		return "", fmt.Errorf("no response from AI")
+	}
+	return message, nil
+}
+
+// queryCommitMessage generates a commit message for the shell's repository
+func (m Model) queryCommitMessage() tea.Cmd {
+	config := m.config
+	cwd := m.shellCwd()
+	return func() tea.Msg {
+		stat, diff, err := StagedChanges(cwd)
+		if err != nil {
+			return commitMsgMsg{err: err}
+		}
+		message, err := GenerateCommitMessage(config, stat, diff)
+		return commitMsgMsg{message: message, err: err}
+	}
+}
+
+// updateCommitMsg handles keys while a generated commit message is shown.
+// The commit itself runs in the shell so its output and hooks are visible.
+func (m Model) updateCommitMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter", "e":
+		if m.commitErr != nil || m.config.SuggestOnlyMode() {
+			break
+		}
+		file, err := writeCommitMessage(m.commitMsg)
+		if err != nil {
+			m.commitErr = err
+			return m, nil
+		}
+		command := fmt.Sprintf("git commit -F %q", file)
+		if msg.String() == "e" {
+			// -e opens the message in the user's editor before committing
+			command = fmt.Sprintf("git commit -e -F %q", file)
+		}
+		m.commitMsg, m.commitErr = "", nil
+		m.lastQuery = "commit message"
+		return m.runCommand(command), nil
+	case "esc", "q", "ctrl+k":
+		if m.commitErr == nil {
+			m.lastQuery = "commit message"
+			m.rejectCommand(m.commitMsg)
+		}
+		m.commitMsg, m.commitErr = "", nil
+		m.closePrompt()
+	}
+	return m, nil
+}
+
+// writeCommitMessage saves message to a temporary file for git commit -F
+func writeCommitMessage(message string) (string, error) {
+	file, err := os.CreateTemp("", "ai-terminal-commit-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(message + "\n"); err != nil {
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// renderCommitMsg draws the generated commit message for confirmation
+func (m Model) renderCommitMsg(titleStyle, hintStyle lipgloss.Style) string {
+	if m.commitErr != nil {
+		errorStyle := lipgloss.NewStyle().Foreground(theme.Warning)
+		return fmt.Sprintf("%s\n\n%s\n\n%s",
+			titleStyle.Render("Commit message"),
+			errorStyle.Render("Error: "+m.commitErr.Error()),
+			hintStyle.Render("Esc to close"),
+		)
+	}
+	hint := "Enter to commit, e to edit in $EDITOR first, Esc to cancel"
+	if m.config.SuggestOnlyMode() {
+		hint = "Suggest-only mode: nothing is committed. Esc to close"
+	}
+	return fmt.Sprintf("%s\n\n%s\n\n%s",
+		titleStyle.Render("Commit message for staged changes"),
+		m.commitMsg,
+		hintStyle.Render(hint),
+	)
+}
+
+// handleCommitMsgCommand prints a commit message for the staged changes, or
+// commits with it after confirmation when --commit is given
+func handleCommitMsgCommand(args []string) {
+	commit, yes := false, false
+	for _, arg := range args {
+		switch arg {
+		case "--commit":
+			commit = true
+		case "--yes", "-y":
+			yes = true
+		default:
+			fmt.Printf("Unknown commitmsg option: %s\n", arg)
+			fmt.Println("Usage: ai-terminal-tui commitmsg [--commit [--yes]]")
+			os.Exit(1)
+		}
+	}
+
+	config := mustLoadConfig()
+	if config.LiteLLMURL == "" {
+		fmt.Println("Error: litellm_url not configured. Run 'ai-terminal-tui setup' first.")
+		os.Exit(1)
+	}
+
+	stat, diff, err := StagedChanges("")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	message, err := GenerateCommitMessage(config, stat, diff)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !commit {
+		fmt.Println(message)
+		return
+	}
+	refuseInSuggestOnly(config, "commitmsg --commit")
+
+	if !yes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			fmt.Println("Error: refusing to commit without confirmation; pass --yes")
+			os.Exit(1)
+		}
+		fmt.Printf("%s\n\nCommit with this message? [y/N]: ", message)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Aborted.")
+			return
+		}
+	}
+
+	cmd := exec.Command("git", "commit", "-F", "-")
+	cmd.Stdin = strings.NewReader(message + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("Error: git commit failed: %v\n", err)
+		os.Exit(1)
+	}
+}
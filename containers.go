@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// containerTTL is how long the container listing is reused before the
+// runtime is asked again; requests come in bursts while a prompt is open
+const containerTTL = 30 * time.Second
+
+// composeServiceLabel names the compose service a container belongs to
+const composeServiceLabel = "com.docker.compose.service"
+
+// composeFiles are the names compose looks for in a project directory
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yml", "docker-compose.yaml"}
+
+// containerFormats are the ps templates of each runtime, which differ in
+// how a label is read
+var containerFormats = map[string]string{
+	"docker": "{{.Names}}\t{{.Image}}\t{{.Ports}}\t{{.Label \"" + composeServiceLabel + "\"}}",
+	"podman": "{{.Names}}\t{{.Image}}\t{{.Ports}}\t{{index .Labels \"" + composeServiceLabel + "\"}}",
+}
+
+// Container is a running container
+type Container struct {
+	Name    string
+	Image   string
+	Ports   string
+	Service string
+}
+
+// ContainerContext is what runs in the local container runtime, and the
+// services of the compose project in the working directory
+type ContainerContext struct {
+	Runtime     string
+	Containers  []Container
+	ComposeFile string
+	Services    []string
+}
+
+// containerCache keeps the last listing for containerTTL
+var containerCache struct {
+	sync.Mutex
+	cwd     string
+	at      time.Time
+	context *ContainerContext
+}
+
+// GatherContainerContext lists running containers and compose services
+// through docker or podman, returning nil when neither is installed or
+// there is nothing to describe. Listings are cached for containerTTL.
+func GatherContainerContext(cwd string) *ContainerContext {
+	containerCache.Lock()
+	defer containerCache.Unlock()
+	if containerCache.cwd == cwd && time.Since(containerCache.at) < containerTTL {
+		return containerCache.context
+	}
+
+	c := gatherContainers(cwd)
+	containerCache.cwd, containerCache.at, containerCache.context = cwd, time.Now(), c
+	return c
+}
+
+// gatherContainers asks the first installed runtime for its containers
+func gatherContainers(cwd string) *ContainerContext {
+	for _, runtime := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(runtime); err != nil {
+			continue
+		}
+		c := &ContainerContext{Runtime: runtime}
+		for _, line := range strings.Split(runDomainTool(cwd, runtime, "ps", "--format", containerFormats[runtime]), "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) < 4 {
+				// Blank output, or the "... and N more" of a long listing
+				continue
+			}
+			c.Containers = append(c.Containers, Container{
+				Name:    fields[0],
+				Image:   fields[1],
+				Ports:   fields[2],
+				Service: strings.TrimSpace(fields[3]),
+			})
+		}
+
+		for _, name := range composeFiles {
+			if _, err := os.Stat(filepath.Join(cwd, name)); err == nil {
+				c.ComposeFile = name
+				break
+			}
+		}
+		if c.ComposeFile != "" {
+			for _, service := range strings.Split(runDomainTool(cwd, runtime, "compose", "config", "--services"), "\n") {
+				if service != "" && !strings.HasPrefix(service, "... ") {
+					c.Services = append(c.Services, service)
+				}
+			}
+		}
+
+		if len(c.Containers) == 0 && c.ComposeFile == "" {
+			return nil
+		}
+		return c
+	}
+	return nil
+}
+
+// String renders the containers for the system prompt
+func (c *ContainerContext) String() string {
+	var b strings.Builder
+	running := make(map[string]bool)
+	if len(c.Containers) > 0 {
+		fmt.Fprintf(&b, "Running %s containers (name, image, ports):\n", c.Runtime)
+		for _, container := range c.Containers {
+			line := fmt.Sprintf("  %s  %s", container.Name, container.Image)
+			if container.Ports != "" {
+				line += "  " + container.Ports
+			}
+			if container.Service != "" {
+				line += fmt.Sprintf("  (compose service %s)", container.Service)
+				running[container.Service] = true
+			}
+			b.WriteString(line + "\n")
+		}
+	} else {
+		fmt.Fprintf(&b, "No %s containers are running.\n", c.Runtime)
+	}
+
+	if c.ComposeFile != "" {
+		var services []string
+		for _, service := range c.Services {
+			if running[service] {
+				service += " (running)"
+			}
+			services = append(services, service)
+		}
+		fmt.Fprintf(&b, "Compose file %s", c.ComposeFile)
+		if len(services) > 0 {
+			fmt.Fprintf(&b, ", services: %s", strings.Join(services, ", "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Refer to containers by these exact names.\n")
+	return b.String()
+}
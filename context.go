@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PromptContext describes the environment a generated command will run in
+type PromptContext struct {
+	OS     string
+	Arch   string
+	Distro string
+	// Userland is the flavour of core utilities (gnu, bsd, busybox)
+	Userland string
+	Shell    string
+	Cwd      string
+	Git      *GitContext
+	WSL      *WSLContext
+	Tools    *ToolInventory
+	Cloud    *CloudContext
+	Kube     *KubeTarget
+	// Containers is what runs in docker or podman
+	Containers *ContainerContext
+	// Systemd is the units the request may be about
+	Systemd *SystemdContext
+	// Archive is the archive the request is about, as listed
+	Archive *ArchiveInfo
+	// Remote is set when the shell is in an ssh session, and the rest
+	// then describes the remote host as far as it is known
+	Remote *RemoteHost
+
+	// PackageManager is the one the model should install software with
+	PackageManager string
+
+	// Domain is the active domain mode, and DomainContext the state of its
+	// tool when gathered
+	Domain        string
+	DomainContext string
+
+	// Manual is the documentation of ManualTool, included for howto
+	// requests so suggested flags match the installed version
+	ManualTool string
+	Manual     string
+
+	// LastCommand is the command run before the request, which it may
+	// refer to as "the last command" or by its arguments
+	LastCommand string
+
+	// Notes are pinned by the user for the session, e.g. which cluster or
+	// region they are working on
+	Notes []string
+
+	// Persona is the profile the model takes on, if one is chosen
+	Persona *Persona
+
+	// Attachment is a file the user attached to the request
+	Attachment *Attachment
+
+	// Conversation holds the earlier exchanges replayed before the request
+	Conversation []Turn
+
+	// RecentOutput is the tail of the terminal scrollback, included only
+	// when the user opts in from the prompt box
+	RecentOutput string
+}
+
+// GatherPromptContext collects environment details for the generation prompt.
+// cwd should be the shell's working directory; when empty, the process
+// working directory is used instead.
+func GatherPromptContext(config Config, cwd string) PromptContext {
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+
+	ctx := PromptContext{
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Distro:   detectDistro(),
+		Userland: DetectUserland(),
+		Shell:    config.Shell,
+		Cwd:      cwd,
+
+		PackageManager: config.PackageManager,
+		Domain:         config.Domain,
+	}
+	if config.Persona != "" {
+		// A persona deleted since it was chosen is ignored
+		ctx.Persona, _ = LoadPersona(config.Persona)
+	}
+	if ctx.PackageManager == "" {
+		ctx.PackageManager = DetectPackageManager()
+	}
+
+	ctx.WSL = GatherWSLContext(config.WSLInterop)
+
+	if config.GitContext {
+		ctx.Git = GatherGitContext(cwd)
+		if ctx.Git != nil {
+			ctx.Git.DiffStat = redactText(config, ctx.Git.DiffStat)
+		}
+	}
+	if len(config.CloudContext) > 0 {
+		ctx.Cloud = GatherCloudContext(config.CloudContext)
+	}
+	if config.KubeContext {
+		ctx.Kube = CurrentKubeTarget()
+	}
+	if config.ContainerContext {
+		ctx.Containers = GatherContainerContext(cwd)
+	}
+	if config.ToolContext {
+		ctx.Tools = GatherToolInventory(config.Shell)
+		for name, value := range ctx.Tools.Aliases {
+			ctx.Tools.Aliases[name] = redactText(config, value)
+		}
+	}
+
+	return ctx
+}
+
+// String renders the context as a block for the system prompt
+func (c PromptContext) String() string {
+	var b strings.Builder
+
+	switch {
+	case c.Remote == nil:
+		fmt.Fprintf(&b, "Operating system: %s/%s\n", c.OS, c.Arch)
+	case c.OS != "":
+		fmt.Fprintf(&b, "The command will run on the remote host %s over ssh, not locally.\n", c.Remote.Host)
+		fmt.Fprintf(&b, "Operating system: %s (guessed from its login banner)\n", c.OS)
+	default:
+		fmt.Fprintf(&b, "The command will run on the remote host %s over ssh, not locally. ", c.Remote.Host)
+		b.WriteString("Its system is unknown, so prefer portable POSIX commands.\n")
+	}
+	if c.Distro != "" {
+		fmt.Fprintf(&b, "Linux distribution: %s\n", c.Distro)
+	}
+	if c.WSL != nil {
+		b.WriteString(c.WSL.String())
+	}
+	if hints := userlandHints(c.Userland); hints != "" {
+		fmt.Fprintf(&b, "%s\n", hints)
+	}
+	if c.PackageManager != "" {
+		fmt.Fprintf(&b, "%s\n", packageManagerHint(c.PackageManager))
+	}
+	if c.Shell != "" {
+		fmt.Fprintf(&b, "Shell: %s (%s)\n", shellName(c.Shell), c.Shell)
+	}
+	if c.Tools != nil {
+		b.WriteString(c.Tools.String())
+	}
+	if c.Cwd != "" {
+		if quoted := QuotePath(c.Cwd, c.Shell); quoted != c.Cwd {
+			fmt.Fprintf(&b, "Current directory: %s (quote it in commands: %s)\n", c.Cwd, quoted)
+		} else {
+			fmt.Fprintf(&b, "Current directory: %s\n", c.Cwd)
+		}
+	}
+	if c.Git != nil {
+		b.WriteString(c.Git.String())
+	}
+	if c.Cloud != nil {
+		b.WriteString(c.Cloud.String())
+	}
+	if c.Kube != nil {
+		fmt.Fprintf(&b, "kubectl context: %s, namespace: %s (kubectl commands act on this cluster)\n", c.Kube.Context, c.Kube.Namespace)
+	}
+	if c.Containers != nil {
+		b.WriteString(c.Containers.String())
+	}
+	if c.Systemd != nil {
+		b.WriteString(c.Systemd.String())
+	}
+	if c.Archive != nil {
+		b.WriteString(untrustedBlock("archive listing", c.Archive.String()) + "\n")
+	}
+	if c.DomainContext != "" {
+		fmt.Fprintf(&b, "\n%s\n", untrustedBlock("tool output", c.DomainContext))
+	}
+	if c.Manual != "" {
+		fmt.Fprintf(&b, "\nManual of the installed %s:\n%s\n", c.ManualTool, untrustedBlock("manual", c.Manual))
+	}
+	if c.LastCommand != "" {
+		fmt.Fprintf(&b, "\nThe last command run: %s\n", c.LastCommand)
+		if words := historyWords(c.LastCommand); len(words) > 1 {
+			fmt.Fprintf(&b, "\"The last command\" means it, and \"that file\" or \"it\" most likely its last argument, %s.\n", words[len(words)-1])
+		}
+	}
+	if len(c.Notes) > 0 {
+		b.WriteString("\nNotes from the user about this session:\n")
+		for _, note := range c.Notes {
+			fmt.Fprintf(&b, "- %s\n", note)
+		}
+	}
+	if c.Attachment != nil {
+		fmt.Fprintf(&b, "\n%s\n", untrustedBlock("attached file", c.Attachment.String()))
+	}
+	if c.RecentOutput != "" {
+		fmt.Fprintf(&b, "\nRecent terminal output:\n%s\n", untrustedBlock("terminal output", c.RecentOutput))
+	}
+
+	return b.String()
+}
+
+// shellName returns the bare name of a shell path, e.g. "zsh" or "powershell"
+func shellName(shell string) string {
+	name := filepath.Base(shell)
+	if i := strings.LastIndex(name, "\\"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.TrimSuffix(strings.ToLower(name), ".exe")
+}
+
+// detectDistro returns a human-readable Linux distribution name from
+// /etc/os-release, or an empty string on other systems
+func detectDistro() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+
+	fields := readOSRelease("/etc/os-release")
+	if fields == nil {
+		fields = readOSRelease("/usr/lib/os-release")
+	}
+
+	if name := fields["PRETTY_NAME"]; name != "" {
+		return name
+	}
+	return strings.TrimSpace(fields["NAME"] + " " + fields["VERSION_ID"])
+}
+
+// readOSRelease parses an os-release file into a key/value map
+func readOSRelease(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields[key] = strings.Trim(value, `"'`)
+	}
+
+	return fields
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// maxConversationTurns is how many earlier exchanges are replayed to the
+// model with each request
+const maxConversationTurns = 10
+
+// Turn is one exchange of a conversation: a request and the command or
+// answer the user kept
+type Turn struct {
+	Time  time.Time `json:"time"`
+	Kind  string    `json:"kind"`
+	Query string    `json:"query"`
+	Reply string    `json:"reply"`
+}
+
+// ConversationInfo summarises a stored conversation for listing
+type ConversationInfo struct {
+	Name    string
+	Turns   int
+	Updated time.Time
+	First   string
+}
+
+// GetConversationsDir returns the directory holding stored conversations
+func GetConversationsDir() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "conversations")
+}
+
+// conversationPath returns the file a conversation is stored in
+func conversationPath(name string) string {
+	return filepath.Join(GetConversationsDir(), name+".jsonl")
+}
+
+// ValidateConversationName checks that name can be used as a file name
+func ValidateConversationName(name string) error {
+	if !validSessionName.MatchString(name) {
+		return fmt.Errorf("invalid conversation name %q: use letters, digits, '.', '_' and '-' (up to 64 characters)", name)
+	}
+	return nil
+}
+
+// LoadConversation reads every turn of a stored conversation. Lines that
+// fail to parse are skipped.
+func LoadConversation(name string) ([]Turn, error) {
+	file, err := os.Open(conversationPath(name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var turns []Turn
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var turn Turn
+		if json.Unmarshal(scanner.Bytes(), &turn) == nil {
+			turns = append(turns, turn)
+		}
+	}
+	return turns, scanner.Err()
+}
+
+// AppendTurn adds a turn to a stored conversation, creating it if needed
+func AppendTurn(name string, turn Turn) error {
+	if err := os.MkdirAll(GetConversationsDir(), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(conversationPath(name), appendFlags, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(turn)
+	if err != nil {
+		return err
+	}
+	return appendLocked(file, append(data, '\n'))
+}
+
+// conversationMessages replays turns as chat messages, in the same form
+// the requests were originally sent
+func conversationMessages(turns []Turn) []ai.Message {
+	var messages []ai.Message
+	for _, turn := range turns {
+		request := fmt.Sprintf("User request: %s\n\nShell command:", turn.Query)
+		if turn.Kind == HistoryAsk {
+			request = "Question: " + turn.Query
+		}
+		messages = append(messages,
+			ai.Message{Role: "user", Content: request},
+			ai.Message{Role: "assistant", Content: turn.Reply},
+		)
+	}
+	return messages
+}
+
+// remember adds an exchange to the running conversation, storing it when
+// the conversation is persisted
+func (m *Model) remember(entry HistoryEntry) {
+	turn := Turn{Time: entry.Time, Kind: entry.Kind, Query: entry.Query, Reply: entry.Command}
+	if entry.Kind == HistoryAsk {
+		turn.Reply = entry.Answer
+	}
+	m.turns = append(m.turns, turn)
+	if m.conversation != "" {
+		// Like history, the conversation is a convenience
+		AppendTurn(m.conversation, turn)
+	}
+}
+
+// conversationContext returns the turns sent with a request
+func (m Model) conversationContext() []Turn {
+	return m.turns[max(0, len(m.turns)-maxConversationTurns):]
+}
+
+// resumeConversation continues a stored conversation, which is created on
+// the first exchange if it does not exist yet
+func (m *Model) resumeConversation(name string) error {
+	if err := ValidateConversationName(name); err != nil {
+		return err
+	}
+	turns, err := LoadConversation(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	m.conversation = name
+	m.turns = turns
+	return nil
+}
+
+// listConversations returns every stored conversation, most recently
+// updated first
+func listConversations() ([]ConversationInfo, error) {
+	files, err := os.ReadDir(GetConversationsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var conversations []ConversationInfo
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), ".jsonl")
+		if !ok || file.IsDir() {
+			continue
+		}
+		turns, err := LoadConversation(name)
+		if err != nil {
+			continue
+		}
+		info := ConversationInfo{Name: name, Turns: len(turns)}
+		if len(turns) > 0 {
+			info.First = turns[0].Query
+			info.Updated = turns[len(turns)-1].Time
+		}
+		conversations = append(conversations, info)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].Updated.After(conversations[j].Updated)
+	})
+	return conversations, nil
+}
+
+// conversationExists reports whether a conversation is stored under name
+func conversationExists(name string) bool {
+	_, err := os.Stat(conversationPath(name))
+	return err == nil
+}
+
+// handleConversationsCommand handles the conversations subcommands
+func handleConversationsCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui conversations list")
+		fmt.Println("       ai-terminal-tui conversations show NAME")
+		fmt.Println("       ai-terminal-tui conversations resume NAME")
+		fmt.Println("       ai-terminal-tui conversations rename NAME NEW-NAME")
+		fmt.Println("       ai-terminal-tui conversations delete NAME")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	// Every subcommand but list names an existing conversation
+	if args[0] != "list" {
+		if len(args) < 2 {
+			usage()
+		}
+		if ValidateConversationName(args[1]) != nil || !conversationExists(args[1]) {
+			fmt.Printf("Error: no conversation named %q\n", args[1])
+			os.Exit(1)
+		}
+	}
+
+	switch args[0] {
+	case "list":
+		conversations, err := listConversations()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(conversations) == 0 {
+			fmt.Println("No conversations yet. Start one with: ai-terminal-tui --conversation NAME")
+			return
+		}
+		for _, info := range conversations {
+			first := []rune(info.First)
+			if len(first) > 40 {
+				first = append(first[:37], []rune("...")...)
+			}
+			fmt.Printf("%-32s %s  %3d turn(s)  %s\n", info.Name, info.Updated.Format(time.DateTime), info.Turns, string(first))
+		}
+
+	case "show":
+		turns, err := LoadConversation(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, turn := range turns {
+			fmt.Printf("[%s] %s\n", turn.Time.Format(time.DateTime), turn.Query)
+			fmt.Printf("  %s\n\n", strings.ReplaceAll(turn.Reply, "\n", "\n  "))
+		}
+
+	case "resume":
+		runTUIMode("", args[1])
+
+	case "rename":
+		if len(args) < 3 {
+			usage()
+		}
+		if err := ValidateConversationName(args[2]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if conversationExists(args[2]) {
+			fmt.Printf("Error: a conversation named %s already exists\n", args[2])
+			os.Exit(1)
+		}
+		if err := os.Rename(conversationPath(args[1]), conversationPath(args[2])); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Renamed %s to %s\n", args[1], args[2])
+
+	case "delete":
+		if err := os.Remove(conversationPath(args[1])); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted %s\n", args[1])
+
+	default:
+		usage()
+	}
+}
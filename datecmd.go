@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// datePreviewTimeout bounds running a date command for its preview
+const datePreviewTimeout = time.Second
+
+var (
+	// dateCommandRe matches a date command, with the time zone it may be
+	// given, at the start of one command of a command line
+	dateCommandRe = regexp.MustCompile(`^\s*(?:TZ=\S+\s+)?g?date(?:\s|$)`)
+	// tzAssignRe finds TZ assignments and the zone they name
+	tzAssignRe = regexp.MustCompile(`\bTZ=["']?([^\s"';&|]+)`)
+	// zoneAbbrevRe finds time zone abbreviations in a date command
+	zoneAbbrevRe = regexp.MustCompile(`\b([A-Z]{3,4})\b`)
+	// dateReadFlagRe matches the options of date that only read the clock
+	// or a file's time, which are all a previewed date command may use:
+	// -u, -R and -I as they come, and -d and -r with the value joined or
+	// following
+	dateReadFlagRe = regexp.MustCompile(`^(?:-u|--utc|--universal|-R|--rfc-email|-I(?:date|hours|minutes|seconds|ns)?|--iso-8601(?:=(?:date|hours|minutes|seconds|ns))?|-[dr].+|--(?:date|reference)=.+)$`)
+	// dateValueFlags are the options of date whose value is the next word
+	dateValueFlags = map[string]bool{"-d": true, "--date": true, "-r": true, "--reference": true}
+	// plainWordRe matches a word with nothing the shell would expand, and
+	// plainPartRe each unquoted or quoted part of one
+	plainWordRe = regexp.MustCompile(`^(?:[^\s'"$` + "`" + `\\;&|<>(){}*?\[\]~]|'[^']*'|"[^"$` + "`" + `\\]*")+$`)
+	plainPartRe = regexp.MustCompile(`'[^']*'|"[^"]*"|[^'"]+`)
+	// posixZoneRe matches POSIX TZ rules like EST5EDT, which need no zone
+	// file
+	posixZoneRe = regexp.MustCompile(`^[A-Z]{3,}[+-]?\d`)
+)
+
+// ambiguousZones are abbreviations that name more than one zone, or a
+// zone without the daylight saving time people usually mean by it
+var ambiguousZones = map[string]string{
+	"CST": "CST is US Central, China or Cuba Standard Time",
+	"IST": "IST is India, Israel or Irish Standard Time",
+	"BST": "BST is British Summer Time or Bangladesh Standard Time",
+	"AST": "AST is Atlantic or Arabia Standard Time",
+	"EST": "EST is UTC-5 all year, without daylight saving",
+	"MST": "MST is UTC-7 all year, without daylight saving",
+	"HST": "HST is UTC-10 all year",
+}
+
+// zoneAdvice is appended to time zone warnings
+const zoneAdvice = "; name a region like America/Chicago or Asia/Kolkata instead"
+
+// zoneFilesInstalled reports whether the system has a time zone database
+// to check TZ values against
+var zoneFilesInstalled = sync.OnceValue(func() bool {
+	_, err := time.LoadLocation("America/New_York")
+	return err == nil
+})
+
+// DateWarnings flags time zones in a command that don't mean what they
+// seem to: ambiguous abbreviations, and TZ values that aren't zones, which
+// date silently treats as UTC
+func DateWarnings(command string) []string {
+	var warnings []string
+	seen := make(map[string]bool)
+	warn := func(warning string) {
+		if !seen[warning] {
+			seen[warning] = true
+			warnings = append(warnings, warning)
+		}
+	}
+
+	for _, match := range tzAssignRe.FindAllStringSubmatch(command, -1) {
+		zone := strings.TrimPrefix(match[1], ":")
+		if reason, ok := ambiguousZones[zone]; ok {
+			warn("TZ=" + zone + ": " + reason + zoneAdvice)
+			continue
+		}
+		if zoneFilesInstalled() && !posixZoneRe.MatchString(zone) {
+			if _, err := time.LoadLocation(zone); err != nil {
+				warn("TZ=" + zone + " is not a known time zone, so date silently uses UTC" + zoneAdvice)
+			}
+		}
+	}
+
+	for _, segment := range commandSeparatorRe.Split(command, -1) {
+		if !dateCommandRe.MatchString(segment) {
+			continue
+		}
+		// Abbreviations in the date given to -d or parsed with -f
+		rest := tzAssignRe.ReplaceAllString(segment, "")
+		for _, match := range zoneAbbrevRe.FindAllStringSubmatch(rest, -1) {
+			if reason, ok := ambiguousZones[match[1]]; ok {
+				warn(match[1] + " in a date: " + reason + zoneAdvice)
+			}
+		}
+	}
+	return warnings
+}
+
+// datePreviewCache keeps the last preview, as the review assesses the
+// command again on every edit
+var datePreviewCache struct {
+	sync.Mutex
+	command string
+	preview string
+}
+
+// dateReadArgs returns the program, arguments and time zone of a date
+// command that only reads the time, so it can be run without a shell for
+// its preview; ok is false for anything else, like a command setting the
+// clock with -s or an operand, an option abbreviated, or a word the shell
+// would expand
+func dateReadArgs(segment string) (name string, args []string, zone string, ok bool) {
+	words := historyWords(strings.TrimSpace(segment))
+	for i, word := range words {
+		if !plainWordRe.MatchString(word) {
+			return "", nil, "", false
+		}
+		var unquoted strings.Builder
+		for _, part := range plainPartRe.FindAllString(word, -1) {
+			if part[0] == '\'' || part[0] == '"' {
+				part = part[1 : len(part)-1]
+			}
+			unquoted.WriteString(part)
+		}
+		words[i] = unquoted.String()
+	}
+	if len(words) > 0 && strings.HasPrefix(words[0], "TZ=") {
+		zone, words = strings.TrimPrefix(words[0], "TZ="), words[1:]
+	}
+	if len(words) == 0 || words[0] != "date" && words[0] != "gdate" {
+		return "", nil, "", false
+	}
+	format := false
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case strings.HasPrefix(word, "+") && !format:
+			format = true
+		case dateValueFlags[word] && i+1 < len(words):
+			i++
+		case dateReadFlagRe.MatchString(word):
+		default:
+			return "", nil, "", false
+		}
+	}
+	return words[0], words[1:], zone, true
+}
+
+// PreviewDate runs the first date command in a command line on its own,
+// locally and without a shell, to show the timestamp it resolves to. Only
+// commands reading the time with a format, -d, -r, -u, -R or -I are run;
+// for others the preview is "", as it is on Windows.
+func PreviewDate(command string) string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	datePreviewCache.Lock()
+	defer datePreviewCache.Unlock()
+	if datePreviewCache.command == command {
+		return datePreviewCache.preview
+	}
+	datePreviewCache.command, datePreviewCache.preview = command, ""
+
+	for _, segment := range commandSeparatorRe.Split(command, -1) {
+		if !dateCommandRe.MatchString(segment) {
+			continue
+		}
+		name, args, zone, ok := dateReadArgs(segment)
+		if !ok {
+			return ""
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), datePreviewTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, name, args...)
+		if zone != "" {
+			cmd.Env = append(os.Environ(), "TZ="+zone)
+		}
+		out, err := cmd.CombinedOutput()
+		preview := strings.TrimSpace(string(out))
+		if preview == "" {
+			return ""
+		}
+		preview, _, _ = strings.Cut(preview, "\n")
+		if err != nil {
+			preview = "fails: " + preview
+		}
+		datePreviewCache.preview = preview
+		return preview
+	}
+	return ""
+}
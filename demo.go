@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// Pacing of a demo unless its script says otherwise
+const (
+	defaultDemoTyping   = 60 * time.Millisecond
+	defaultDemoThinking = 800 * time.Millisecond
+)
+
+// DemoStep is one exchange of a demo: the request typed at the prompt and
+// the answer the scripted AI gives it
+type DemoStep struct {
+	Query    string `json:"query"`
+	Response string `json:"response"`
+}
+
+// DemoScript is a demo played back by the demo command, read from JSON
+type DemoScript struct {
+	// TypingDelayMS is the pause between the characters of a request
+	// typed for the presenter
+	TypingDelayMS int `json:"typing_delay_ms"`
+	// ThinkingMS is how long the scripted AI takes to answer, so the
+	// demo looks like it would live
+	ThinkingMS int        `json:"thinking_ms"`
+	Steps      []DemoStep `json:"steps"`
+}
+
+// demo is the script played back, set by the demo command. While set, the
+// scripted AI answers every request and no provider is contacted.
+var demo *DemoScript
+
+// LoadDemoScript reads and checks a demo script
+func LoadDemoScript(path string) (*DemoScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var script DemoScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	if len(script.Steps) == 0 {
+		return nil, fmt.Errorf("%s has no steps", path)
+	}
+	for i, step := range script.Steps {
+		if strings.TrimSpace(step.Query) == "" || strings.TrimSpace(step.Response) == "" {
+			return nil, fmt.Errorf("step %d of %s needs a query and a response", i+1, path)
+		}
+	}
+	return &script, nil
+}
+
+// typingDelay is the pause between typed characters
+func (d *DemoScript) typingDelay() time.Duration {
+	if d.TypingDelayMS > 0 {
+		return time.Duration(d.TypingDelayMS) * time.Millisecond
+	}
+	return defaultDemoTyping
+}
+
+// thinking is how long an answer takes
+func (d *DemoScript) thinking() time.Duration {
+	if d.ThinkingMS > 0 {
+		return time.Duration(d.ThinkingMS) * time.Millisecond
+	}
+	return defaultDemoThinking
+}
+
+// answer stands in for the provider: the response of the step whose query
+// the latest request mentions, looking back past corrections to it
+func (d *DemoScript) answer(request ai.Request) ([]string, error) {
+	time.Sleep(d.thinking())
+	for i := len(request.Messages) - 1; i >= 0; i-- {
+		message := request.Messages[i]
+		if message.Role != "user" {
+			continue
+		}
+		for _, step := range d.Steps {
+			if strings.Contains(message.Content, step.Query) {
+				return []string{step.Response}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("the demo script has no answer for this request")
+}
+
+// demoConfig keeps a demo from showing or using credentials and from
+// leaving anything in the history, audit log or usage counts
+func demoConfig(config Config) Config {
+	config.LiteLLMToken = ""
+	config.History = false
+	config.AuditLog = false
+	config.Telemetry = TelemetryOff
+	config.UpdateCheck = false
+	return config
+}
+
+// demoTypeMsg types the next character of a scripted request
+type demoTypeMsg struct{}
+
+// startDemoTyping types the next scripted request into the opened prompt,
+// a character at a time, for the presenter to send with Enter
+func (m *Model) startDemoTyping() tea.Cmd {
+	if demo == nil || m.input.Value() != "" {
+		return nil
+	}
+	countFeature("demo")
+	m.demoTyping = []rune(demo.Steps[m.demoNext%len(demo.Steps)].Query)
+	m.demoNext++
+	return demoType()
+}
+
+// demoType waits before the next character
+func demoType() tea.Cmd {
+	return tea.Tick(demo.typingDelay(), func(time.Time) tea.Msg {
+		return demoTypeMsg{}
+	})
+}
+
+// demoTyped types a character of the scripted request, until it is done or
+// the prompt is closed
+func (m *Model) demoTyped() tea.Cmd {
+	if len(m.demoTyping) == 0 {
+		return nil
+	}
+	if !m.showPrompt {
+		m.demoTyping = nil
+		return nil
+	}
+	m.input.SetValue(m.input.Value() + string(m.demoTyping[0]))
+	m.input.CursorEnd()
+	m.demoTyping = m.demoTyping[1:]
+	return demoType()
+}
+
+// handleDemoCommand handles the demo subcommand: the TUI with the scripted
+// AI
+func handleDemoCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: ai-terminal-tui demo SCRIPT.json")
+		os.Exit(1)
+	}
+	script, err := LoadDemoScript(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	demo = script
+	runTUIMode("", "")
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// Limits on how much aggregated history goes into a digest
+const (
+	digestTopItems      = 10
+	digestRepeatMinimum = 3
+	digestAliasMinLen   = 20
+)
+
+// Count is an item and how often it occurred
+type Count struct {
+	Item  string
+	Count int
+}
+
+// DigestData is history aggregated locally; only this summary, not the raw
+// history, is sent to the model
+type DigestData struct {
+	Since    time.Time
+	Until    time.Time
+	Sessions int
+	Stats    SessionStats
+
+	Programs []Count // most used programs
+	Repeated []Count // long command lines typed again and again
+	Queries  []Count // most frequent AI requests
+	Failures []Count // AI requests whose suggestions were rejected or edited
+}
+
+// topCounts sorts counts descending, keeping those with at least minimum
+// occurrences, up to digestTopItems
+func topCounts(counts map[string]int, minimum int) []Count {
+	var result []Count
+	for item, count := range counts {
+		if count >= minimum {
+			result = append(result, Count{item, count})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Item < result[j].Item
+	})
+	if len(result) > digestTopItems {
+		result = result[:digestTopItems]
+	}
+	return result
+}
+
+// programName returns the program a command line runs, skipping sudo and
+// leading environment assignments
+func programName(command string) string {
+	for _, field := range strings.Fields(command) {
+		if field == "sudo" || strings.Contains(field, "=") {
+			continue
+		}
+		return field
+	}
+	return ""
+}
+
+// AggregateDigest summarises history entries between since and until
+func AggregateDigest(entries []HistoryEntry, since, until time.Time) DigestData {
+	data := DigestData{Since: since, Until: until}
+
+	var window []HistoryEntry
+	sessions := make(map[string]bool)
+	programs := make(map[string]int)
+	commands := make(map[string]int)
+	queries := make(map[string]int)
+	failures := make(map[string]int)
+
+	for _, entry := range entries {
+		if entry.Time.Before(since) || entry.Time.After(until) {
+			continue
+		}
+		window = append(window, entry)
+		sessions[entry.Session] = true
+
+		executed := entry.Kind == HistoryShell || (entry.Kind == HistoryAI && entry.Outcome == OutcomeAccepted)
+		if executed && !isScript(entry.Command) {
+			if program := programName(entry.Command); program != "" {
+				programs[program]++
+			}
+			if len(entry.Command) >= digestAliasMinLen {
+				commands[entry.Command]++
+			}
+		}
+
+		if entry.Kind == HistoryAI && entry.Query != "" {
+			query := strings.ToLower(strings.TrimSpace(entry.Query))
+			queries[query]++
+			if entry.Outcome == OutcomeRejected || entry.Outcome == OutcomeEdited {
+				failures[query]++
+			}
+		}
+	}
+
+	data.Sessions = len(sessions)
+	data.Stats = ComputeStats(window)
+	data.Programs = topCounts(programs, 1)
+	data.Repeated = topCounts(commands, digestRepeatMinimum)
+	data.Queries = topCounts(queries, 1)
+	data.Failures = topCounts(failures, 2)
+	return data
+}
+
+// String renders the aggregate as markdown
+func (d DigestData) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Activity\n\n")
+	fmt.Fprintf(&b, "- Sessions: %d\n", d.Sessions)
+	fmt.Fprintf(&b, "- Commands executed: %d\n", d.Stats.CommandsExecuted)
+	fmt.Fprintf(&b, "- AI suggestions: %d accepted, %d edited, %d rejected\n", d.Stats.Accepted, d.Stats.Edited, d.Stats.Rejected)
+	fmt.Fprintf(&b, "- Estimated time saved: %s\n", time.Duration(d.Stats.TimeSavedSeconds)*time.Second)
+	fmt.Fprintf(&b, "- Tokens used: %d\n", d.Stats.TokensUsed)
+
+	sections := []struct {
+		title  string
+		counts []Count
+		code   bool
+	}{
+		{"Most used programs", d.Programs, true},
+		{"Repeated command lines", d.Repeated, true},
+		{"Most frequent AI requests", d.Queries, false},
+		{"AI requests that needed rework", d.Failures, false},
+	}
+	for _, section := range sections {
+		if len(section.counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", section.title)
+		for _, count := range section.counts {
+			if section.code {
+				fmt.Fprintf(&b, "- `%s` (%d)\n", count.Item, count.Count)
+			} else {
+				fmt.Fprintf(&b, "- %s (%d)\n", count.Item, count.Count)
+			}
+		}
+	}
+	return b.String()
+}
+
+// GenerateDigest asks the model to turn aggregated history into a short
+// markdown report with top tasks, recurring failures and suggested aliases
+func GenerateDigest(config Config, data DigestData) (string, error) {
+	contents, err := chatCompletion(config, ai.Request{
+		Messages: []ai.Message{
+			{Role: "system", Content: "You write a short weekly digest of someone's terminal usage in markdown. " +
+				"Use exactly these sections: '## Top tasks' (what they spent their time on), " +
+				"'## Recurring failures' (requests that repeatedly needed rework, and what might help), and " +
+				"'## Suggestions' (concrete aliases, functions or small scripts to create, as code blocks for their shell). " +
+				"Base everything on the data given; do not invent activity. Be brief and encouraging.\n\n" +
+				fmt.Sprintf("The user's shell is %s.", shellName(config.Shell))},
+			{Role: "user", Content: redactText(config, data.String())},
+		},
+		Temperature: 0.3,
+		MaxTokens:   1200,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(contents[0]), nil
+}
+
+// handleDigestCommand prints a markdown digest of recent history
+func handleDigestCommand(args []string) {
+	days := 7
+	useAI := true
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--days":
+			if i+1 >= len(args) {
+				fmt.Println("Error: --days requires a number")
+				os.Exit(1)
+			}
+			value, err := strconv.Atoi(args[i+1])
+			if err != nil || value < 1 {
+				fmt.Println("Error: --days must be a positive number")
+				os.Exit(1)
+			}
+			days = value
+			i++
+		case "--no-ai":
+			useAI = false
+		default:
+			fmt.Printf("Unknown digest option: %s\n", args[i])
+			fmt.Println("Usage: ai-terminal-tui digest [--days N] [--no-ai]")
+			os.Exit(1)
+		}
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	until := time.Now()
+	since := until.AddDate(0, 0, -days)
+	data := AggregateDigest(entries, since, until)
+	if data.Sessions == 0 {
+		fmt.Printf("No history recorded in the last %d days.\n", days)
+		return
+	}
+
+	fmt.Printf("# Terminal digest: %s to %s\n\n", since.Format(time.DateOnly), until.Format(time.DateOnly))
+	fmt.Print(data.String())
+
+	if !useAI {
+		return
+	}
+	config := mustLoadConfig()
+	if config.LiteLLMURL == "" {
+		fmt.Fprintln(os.Stderr, "Warning: litellm_url not configured; showing local statistics only.")
+		return
+	}
+	summary, err := GenerateDigest(config, data)
+	if err != nil {
+		// The local aggregate above is still useful on its own
+		fmt.Fprintf(os.Stderr, "Warning: could not generate summary: %v\n", err)
+		return
+	}
+	fmt.Printf("\n%s\n", summary)
+}
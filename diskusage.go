@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Limits for disk usage scans
+const (
+	diskScanTimeout  = 2 * time.Minute
+	maxDiskChildren  = 15
+	diskCleanupItems = 10
+)
+
+// diskQueryRe recognises requests about what is using up disk space, which
+// open the disk usage explorer instead of generating a command
+var diskQueryRe = regexp.MustCompile(`(?i)\b(?:eating|filling|hogging|taking up|using up)\b.*\b(?:disk|space|storage|drive)\b|` +
+	`\b(?:disk|storage|drive)\s+(?:is\s+)?(?:full|usage|space)\b|\b(?:running|run|ran)\s+out\s+of\s+(?:disk\s+)?space\b|` +
+	`\bno space left\b|\bfree\s+up\s+(?:some\s+)?(?:disk\s+)?space\b`)
+
+// diskSkipDirs are virtual filesystems that take no disk space
+var diskSkipDirs = map[string]bool{"/proc": true, "/sys": true, "/dev": true, "/run": true}
+
+// IsDiskQuery reports whether a request asks what is using disk space
+func IsDiskQuery(query string) bool {
+	return diskQueryRe.MatchString(query)
+}
+
+// diskNode is a file or directory and the space it takes. Directories keep
+// their largest entries; the rest are only counted.
+type diskNode struct {
+	Name     string
+	Path     string
+	Size     int64
+	Files    int
+	Dir      bool
+	Children []*diskNode
+	// More entries of Size MoreSize were left out of Children
+	More     int
+	MoreSize int64
+	// Unreadable is set when the directory could not be listed
+	Unreadable bool
+
+	expanded bool
+}
+
+// ScanDisk adds up the size of everything under root, like du, keeping the
+// largest entries of each directory. Symbolic links are not followed. It
+// stops early when ctx is done, leaving sizes as far as they were counted.
+func ScanDisk(ctx context.Context, root string) (*diskNode, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	node := &diskNode{Name: root, Path: root, Dir: true, expanded: true}
+	scanDir(ctx, node)
+	return node, nil
+}
+
+func scanDir(ctx context.Context, node *diskNode) {
+	if ctx.Err() != nil {
+		return
+	}
+	entries, err := os.ReadDir(node.Path)
+	if err != nil {
+		node.Unreadable = true
+		return
+	}
+
+	var children []*diskNode
+	for _, entry := range entries {
+		child := &diskNode{Name: entry.Name(), Path: filepath.Join(node.Path, entry.Name())}
+		switch {
+		case entry.IsDir():
+			if diskSkipDirs[child.Path] {
+				continue
+			}
+			child.Dir = true
+			scanDir(ctx, child)
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			child.Size, child.Files = info.Size(), 1
+		default:
+			continue
+		}
+		node.Size += child.Size
+		node.Files += child.Files
+		children = append(children, child)
+	}
+
+	sort.Slice(children, func(i, j int) bool { return children[i].Size > children[j].Size })
+	if len(children) > maxDiskChildren {
+		for _, child := range children[maxDiskChildren:] {
+			node.More++
+			node.MoreSize += child.Size
+		}
+		children = children[:maxDiskChildren]
+	}
+	node.Children = children
+}
+
+// formatBytes renders a size the way du -h does, e.g. 1.5G
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	size, suffix := float64(n), "B"
+	for _, s := range []string{"K", "M", "G", "T", "P"} {
+		if size < unit {
+			break
+		}
+		size, suffix = size/unit, s
+	}
+	if size < 10 {
+		return fmt.Sprintf("%.1f%s", size, suffix)
+	}
+	return fmt.Sprintf("%.0f%s", size, suffix)
+}
+
+// diskExplorer browses the result of a disk usage scan as a tree
+type diskExplorer struct {
+	root     *diskNode
+	dir      string
+	free     string
+	scanning bool
+	partial  bool
+	err      error
+	cursor   int
+	cancel   context.CancelFunc
+}
+
+// diskRow is a line of the tree: a node, or the entries left out of parent
+type diskRow struct {
+	node   *diskNode
+	parent *diskNode
+	depth  int
+}
+
+// rows flattens the expanded part of the tree
+func (e *diskExplorer) rows() []diskRow {
+	var rows []diskRow
+	var walk func(node *diskNode, depth int)
+	walk = func(node *diskNode, depth int) {
+		rows = append(rows, diskRow{node: node, depth: depth})
+		if !node.expanded {
+			return
+		}
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+		if node.More > 0 {
+			rows = append(rows, diskRow{parent: node, depth: depth + 1})
+		}
+	}
+	if e.root != nil {
+		walk(e.root, 0)
+	}
+	return rows
+}
+
+// diskScanMsg carries a finished scan of dir
+type diskScanMsg struct {
+	dir     string
+	root    *diskNode
+	free    string
+	partial bool
+	err     error
+}
+
+// openDiskExplorer starts scanning dir, or the directory the request
+// names, in the background
+func (m *Model) openDiskExplorer(request string) tea.Cmd {
+	dir := m.shellCwd()
+	for _, field := range strings.Fields(request) {
+		path := strings.TrimRight(field, ".,;:!?)'\"")
+		if strings.HasPrefix(path, "~") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = home + path[1:]
+			}
+		}
+		if info, err := os.Stat(path); err == nil && info.IsDir() && filepath.IsAbs(path) {
+			dir = path
+			break
+		}
+	}
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	return m.scanDisk(dir)
+}
+
+// scanDisk (re)starts the explorer on dir
+func (m *Model) scanDisk(dir string) tea.Cmd {
+	if m.diskExplorer != nil && m.diskExplorer.cancel != nil {
+		m.diskExplorer.cancel()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), diskScanTimeout)
+	m.diskExplorer = &diskExplorer{dir: dir, scanning: true, cancel: cancel}
+	return func() tea.Msg {
+		defer cancel()
+		root, err := ScanDisk(ctx, dir)
+		// The last line of df is the filesystem the directory is on
+		free := runDomainTool(dir, "df", "-h", dir)
+		free = free[strings.LastIndex(free, "\n")+1:]
+		return diskScanMsg{dir: dir, root: root, free: free, partial: ctx.Err() == context.DeadlineExceeded, err: err}
+	}
+}
+
+// closeDiskExplorer stops any scan and closes the explorer
+func (m *Model) closeDiskExplorer() {
+	if m.diskExplorer != nil && m.diskExplorer.cancel != nil {
+		m.diskExplorer.cancel()
+	}
+	m.diskExplorer = nil
+}
+
+// diskScanned shows a finished scan, unless the explorer has since been
+// closed or moved to another directory
+func (m *Model) diskScanned(msg diskScanMsg) {
+	e := m.diskExplorer
+	if e == nil || !e.scanning || e.dir != msg.dir {
+		return
+	}
+	e.scanning, e.cancel = false, nil
+	e.root, e.free, e.partial, e.err = msg.root, msg.free, msg.partial, msg.err
+	e.cursor = 0
+}
+
+// updateDiskExplorer handles keys while exploring disk usage
+func (m Model) updateDiskExplorer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	e := m.diskExplorer
+	if msg.Type == tea.KeyEsc || msg.Type == tea.KeyCtrlK {
+		m.closeDiskExplorer()
+		return m, nil
+	}
+	if e.scanning {
+		return m, nil
+	}
+
+	rows := e.rows()
+	var node *diskNode
+	if e.cursor < len(rows) {
+		node = rows[e.cursor].node
+	}
+	switch msg.Type {
+	case tea.KeyUp, tea.KeyShiftTab:
+		e.cursor = max(0, e.cursor-1)
+	case tea.KeyDown, tea.KeyTab:
+		e.cursor = min(len(rows)-1, e.cursor+1)
+	case tea.KeyRight, tea.KeyEnter:
+		if node != nil && node.Dir {
+			node.expanded = msg.Type == tea.KeyRight || !node.expanded
+		}
+	case tea.KeyLeft:
+		if node != nil && node.expanded && node != e.root {
+			node.expanded = false
+			break
+		}
+		// Move up to the parent directory's row
+		for i := e.cursor - 1; i >= 0; i-- {
+			if rows[i].depth < rows[e.cursor].depth {
+				e.cursor = i
+				break
+			}
+		}
+	case tea.KeyBackspace:
+		if parent := filepath.Dir(e.dir); parent != e.dir {
+			return m, m.scanDisk(parent)
+		}
+	case tea.KeyRunes:
+		switch string(msg.Runes) {
+		case "r":
+			return m, m.scanDisk(e.dir)
+		case "c":
+			if node != nil {
+				return m, m.queryDiskCleanup(node)
+			}
+		}
+	}
+	return m, nil
+}
+
+// queryDiskCleanup asks for commands that free space in node, going
+// through the usual review, where their risk is shown, before they run
+func (m *Model) queryDiskCleanup(node *diskNode) tea.Cmd {
+	countFeature("disk cleanup")
+	m.closeDiskExplorer()
+
+	var b strings.Builder
+	kind := "file"
+	if node.Dir {
+		kind = "directory"
+	}
+	fmt.Fprintf(&b, "Free disk space taken by the %s %s (%s", kind, node.Path, formatBytes(node.Size))
+	if node.Dir {
+		fmt.Fprintf(&b, " in %d files", node.Files)
+	}
+	b.WriteString(").\n")
+	if len(node.Children) > 0 {
+		b.WriteString("Its largest entries:\n")
+		for _, child := range node.Children[:min(len(node.Children), diskCleanupItems)] {
+			name := child.Name
+			if child.Dir {
+				name += "/"
+			}
+			fmt.Fprintf(&b, "%8s  %s\n", formatBytes(child.Size), name)
+		}
+	}
+	b.WriteString("Give a safe cleanup command: prefer the owning tool's own cleaner (package manager caches, docker/podman prune, " +
+		"journalctl --vacuum-size, language build and module caches) and removing caches, build output, old logs or old files " +
+		"over deleting the path itself. Never delete user documents, and don't use rm -rf on the path as a whole when it holds anything else.")
+
+	m.loading = true
+	m.lastQuery = "free up space in " + node.Path
+	m.genQuery, m.attempts = b.String(), nil
+	return m.queryAI(m.genQuery, nil)
+}
+
+// renderDiskExplorer shows the scanned tree with a bar for each entry's
+// share of its directory, scrolled to keep the cursor within rows
+func (m Model) renderDiskExplorer(titleStyle, hintStyle lipgloss.Style, rows int) string {
+	e := m.diskExplorer
+	dimStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	dirStyle := lipgloss.NewStyle().Foreground(theme.Info)
+	selectedStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+
+	var b strings.Builder
+	if e.scanning {
+		b.WriteString(titleStyle.Render("Disk usage: "+e.dir) + "\n\n")
+		b.WriteString(dimStyle.Render("Scanning...") + "\n\n")
+		b.WriteString(hintStyle.Render("Esc to cancel"))
+		return b.String()
+	}
+	if e.err != nil {
+		b.WriteString(titleStyle.Render("Disk usage: "+e.dir) + "\n\n")
+		b.WriteString(lipgloss.NewStyle().Foreground(theme.Error).Render(e.err.Error()) + "\n\n")
+		b.WriteString(hintStyle.Render("Backspace for the parent, Esc to close"))
+		return b.String()
+	}
+
+	title := fmt.Sprintf("Disk usage: %s (%s in %d files)", e.dir, formatBytes(e.root.Size), e.root.Files)
+	b.WriteString(titleStyle.Render(title) + "\n")
+	if e.free != "" {
+		b.WriteString(dimStyle.Render(e.free) + "\n")
+	}
+	if e.partial {
+		b.WriteString(lipgloss.NewStyle().Foreground(theme.Warning).Render(fmt.Sprintf("The scan stopped after %s; sizes are incomplete", diskScanTimeout)) + "\n")
+	}
+	b.WriteString("\n")
+
+	all := e.rows()
+	rows = max(1, rows)
+	top := max(0, e.cursor-rows+1)
+	bottom := min(len(all), top+rows)
+	for i := top; i < bottom; i++ {
+		row := all[i]
+		indent := strings.Repeat("  ", row.depth)
+		var line string
+		if row.node == nil {
+			line = dimStyle.Render(fmt.Sprintf("%8s  %s  %s… %d more", formatBytes(row.parent.MoreSize), diskBar(row.parent.MoreSize, row.parent.Size), indent, row.parent.More))
+		} else {
+			node := row.node
+			marker := "  "
+			if node.Dir && len(node.Children) > 0 {
+				marker = "▸ "
+				if node.expanded {
+					marker = "▾ "
+				}
+			}
+			name := node.Name
+			if node.Dir {
+				name = dirStyle.Render(name + "/")
+			}
+			if node.Unreadable {
+				name += dimStyle.Render(" (unreadable)")
+			}
+			parentSize := node.Size
+			for j := i - 1; j >= 0 && row.depth > 0; j-- {
+				if all[j].depth == row.depth-1 {
+					parentSize = all[j].node.Size
+					break
+				}
+			}
+			line = fmt.Sprintf("%8s  %s  %s%s%s", formatBytes(node.Size), diskBar(node.Size, parentSize), indent, marker, name)
+		}
+		if i == e.cursor {
+			b.WriteString(selectedStyle.Render("> ") + line)
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("↑/↓ to move, →/Enter to expand, ← to collapse, c for cleanup commands, Backspace for the parent, r to rescan, Esc to close"))
+	return b.String()
+}
+
+// diskBar draws size as a share of total
+func diskBar(size, total int64) string {
+	const width = 10
+	filled := 0
+	if total > 0 {
+		filled = int(size * width / total)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
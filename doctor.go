@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// handleDoctorCommand checks the installation and prints the environment
+// the TUI will run in
+func handleDoctorCommand(args []string) {
+	fmt.Printf("%s %s (%s/%s)\n\n", AppName, Version, runtime.GOOS, runtime.GOARCH)
+
+	config, err := LoadConfig()
+	fmt.Println("Configuration:")
+	var policyErr *PolicyError
+	if err != nil && !errors.As(err, &policyErr) {
+		fmt.Printf("  ✗ %v\n", err)
+	} else {
+		fmt.Printf("  ✓ %s\n", GetConfigPath())
+	}
+	doctorPolicy()
+	if err := ValidateShell(config.Shell); err != nil {
+		fmt.Printf("  ✗ %v\n", err)
+	} else {
+		fmt.Printf("  ✓ shell %s\n", config.Shell)
+	}
+	if config.LiteLLMURL == "" {
+		fmt.Println("  ✗ litellm_url is not set")
+	} else {
+		fmt.Printf("  ✓ litellm_url %s\n", config.LiteLLMURL)
+	}
+	if packageManager := valueOrDefault(config.PackageManager, DetectPackageManager()); packageManager == "" {
+		fmt.Println("  ✗ no package manager found (set package_manager)")
+	} else {
+		fmt.Printf("  ✓ package manager %s\n", packageManager)
+	}
+	if backend, err := SandboxBackend(config.Sandbox, config.Shell); err != nil {
+		fmt.Printf("  ✗ sandbox: %v\n", err)
+	} else {
+		fmt.Printf("  ✓ sandbox %s\n", backend)
+	}
+	fmt.Println()
+
+	printCapabilities(ProbeCapabilities(DetectCapabilities()))
+
+	if power := ReadPower(); power.HasBattery {
+		fmt.Println()
+		fmt.Printf("Power: %s", power)
+		if config.BatterySaver > 0 {
+			fmt.Printf(" (inline suggestions and failure watching pause below %d%% on battery)", config.BatterySaver)
+		}
+		fmt.Println()
+	}
+
+	if config.ToolContext {
+		tools := GatherToolInventory(config.Shell)
+		fmt.Println()
+		fmt.Println("Tools described to the model:")
+		fmt.Printf("  installed: %s\n", valueOrDefault(strings.Join(tools.Installed, ", "), "(none found)"))
+		fmt.Printf("  aliases:   %d from shell profile files\n", len(tools.Aliases))
+	}
+
+	if len(config.CloudContext) > 0 {
+		fmt.Println()
+		if cloud := GatherCloudContext(config.CloudContext); cloud != nil {
+			fmt.Print(cloud)
+		} else {
+			fmt.Printf("No cloud account configured (%s)\n", strings.Join(config.CloudContext, ", "))
+		}
+	}
+
+	if len(args) > 0 && args[0] == "--airgap" {
+		fmt.Println()
+		if !doctorAirgap(config) {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Domain modes specialise generation for one kind of work
+const (
+	DomainNone       = ""
+	DomainGit        = "git"
+	DomainDocker     = "docker"
+	DomainKubernetes = "kubernetes"
+	DomainSQL        = "sql"
+	DomainHTTP       = "http"
+)
+
+// domainOrder is the order the TUI cycles through domains
+var domainOrder = []string{DomainNone, DomainGit, DomainDocker, DomainKubernetes, DomainSQL, DomainHTTP}
+
+// Limits for the commands that gather domain context
+const (
+	domainTimeout  = 2 * time.Second
+	maxDomainLines = 15
+)
+
+// domainPrompts are added to the system prompt in each domain
+var domainPrompts = map[string]string{
+	DomainGit: "You are in git mode: the request is about version control. Prefer plain git porcelain commands. " +
+		"Never rewrite published history or discard work (reset --hard, push --force, clean -f) unless explicitly asked, " +
+		"and prefer --force-with-lease over --force.",
+	DomainDocker: "You are in docker mode: the request is about containers. Use the docker CLI (or docker compose when a compose file is present), " +
+		"refer to the containers and images listed below by name, and avoid removing volumes unless asked.",
+	DomainKubernetes: "You are in kubernetes mode: the request is about the cluster. Use kubectl against the current context and namespace below, " +
+		"pass -n explicitly when another namespace is meant, and prefer read-only commands unless a change is requested.",
+	DomainSQL: "You are in SQL mode: the request is about a database. Respond with a command running the SQL through the available client " +
+		"(psql, mysql, sqlite3), using the connection settings below, or with only the SQL when the request asks for a query to paste; " +
+		"read-only queries unless a change is requested.",
+	DomainHTTP: "You are in HTTP mode: the request describes an API call. Respond with a curl command (or an httpie/xh one when only that is installed), " +
+		"with the method, headers and JSON body spelled out, quoting the body for the shell, and following the API spec below when there is one.",
+}
+
+// domainPrompt returns the system prompt paragraph for a domain
+func domainPrompt(domain string) string {
+	if prompt := domainPrompts[domain]; prompt != "" {
+		return prompt + "\n\n"
+	}
+	return ""
+}
+
+// ParseDomain normalises a domain name, accepting a few aliases
+func ParseDomain(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", "none", "off":
+		return DomainNone, nil
+	case "git":
+		return DomainGit, nil
+	case "docker":
+		return DomainDocker, nil
+	case "kubernetes", "k8s", "kubectl":
+		return DomainKubernetes, nil
+	case "sql", "db", "database":
+		return DomainSQL, nil
+	case "http", "api", "curl", "httpie":
+		return DomainHTTP, nil
+	}
+	return "", fmt.Errorf("unknown domain %q (expected git, docker, kubernetes, sql, http or none)", name)
+}
+
+// nextDomain returns the domain after current in the TUI cycle
+func nextDomain(current string) string {
+	for i, domain := range domainOrder {
+		if domain == current {
+			return domainOrder[(i+1)%len(domainOrder)]
+		}
+	}
+	return DomainNone
+}
+
+// runDomainTool runs a command in dir with a short timeout and returns the
+// first few lines of its output
+func runDomainTool(dir, name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), domainTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(lines) > maxDomainLines {
+		lines = append(lines[:maxDomainLines], fmt.Sprintf("... and %d more", len(lines)-maxDomainLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// GatherDomain adds the state of the configured domain's tool to the
+// context. It runs external commands, so only generation requests ask for it.
+func (c *PromptContext) GatherDomain(config Config) {
+	if c.Domain != DomainNone {
+		c.DomainContext = redactText(config, GatherDomainContext(c.Domain, c.Cwd))
+	}
+	switch c.Domain {
+	case DomainSQL:
+		c.DomainContext += sqlContext(config)
+	case DomainHTTP:
+		c.DomainContext += redactText(config, httpContext(config.OpenAPISpec, c.Cwd))
+	}
+}
+
+// GatherDomainContext collects what the model needs to know in a domain:
+// the state of the tool the requests will be about
+func GatherDomainContext(domain, cwd string) string {
+	var b strings.Builder
+	section := func(title, content string) {
+		if content != "" {
+			fmt.Fprintf(&b, "%s:\n%s\n", title, content)
+		}
+	}
+
+	switch domain {
+	case DomainGit:
+		section("git status", runDomainTool(cwd, "git", "status", "--short", "--branch"))
+		section("Recent commits", runDomainTool(cwd, "git", "log", "--oneline", "-5"))
+		section("Remotes", runDomainTool(cwd, "git", "remote", "-v"))
+		section("Stashes", runDomainTool(cwd, "git", "stash", "list"))
+
+	case DomainDocker:
+		section("Running containers", runDomainTool(cwd, "docker", "ps", "--format", "{{.Names}}\t{{.Image}}\t{{.Status}}"))
+		section("Images", runDomainTool(cwd, "docker", "images", "--format", "{{.Repository}}:{{.Tag}}"))
+		for _, name := range composeFiles {
+			if _, err := os.Stat(filepath.Join(cwd, name)); err == nil {
+				section("Compose file", name)
+				break
+			}
+		}
+
+	case DomainKubernetes:
+		if target := CurrentKubeTarget(); target != nil {
+			section("Current context", target.Context)
+			section("Namespace", target.Namespace)
+		}
+
+	case DomainSQL:
+		var clients []string
+		for _, client := range []string{"psql", "mysql", "sqlite3", "sqlcmd"} {
+			if _, err := exec.LookPath(client); err == nil {
+				clients = append(clients, client)
+			}
+		}
+		section("Database clients", strings.Join(clients, ", "))
+
+		var settings []string
+		for _, name := range []string{"PGHOST", "PGPORT", "PGDATABASE", "PGUSER", "MYSQL_HOST", "MYSQL_TCP_PORT"} {
+			if value := os.Getenv(name); value != "" {
+				settings = append(settings, name+"="+value)
+			}
+		}
+		section("Connection settings", strings.Join(settings, "\n"))
+
+		var files []string
+		for _, pattern := range []string{"*.db", "*.sqlite", "*.sqlite3", "*.sql"} {
+			matches, _ := filepath.Glob(filepath.Join(cwd, pattern))
+			for _, match := range matches {
+				files = append(files, filepath.Base(match))
+			}
+		}
+		if len(files) > maxDomainLines {
+			files = files[:maxDomainLines]
+		}
+		section("Database files here", strings.Join(files, ", "))
+	}
+	return b.String()
+}
+
+// httpContext describes the HTTP clients, how to pretty-print responses,
+// and the project's API spec for the http domain. spec overrides the
+// search for one in cwd.
+func httpContext(spec, cwd string) string {
+	var b strings.Builder
+	var clients []string
+	for _, client := range []string{"curl", "http", "xh", "wget"} {
+		if _, err := exec.LookPath(client); err == nil {
+			clients = append(clients, client)
+		}
+	}
+	fmt.Fprintf(&b, "HTTP clients: %s\n", valueOrDefault(strings.Join(clients, ", "), "none found"))
+
+	for _, formatter := range []string{"jq .", "python3 -m json.tool"} {
+		tool, _, _ := strings.Cut(formatter, " ")
+		if _, err := exec.LookPath(tool); err == nil {
+			fmt.Fprintf(&b, "Pretty-print JSON responses from curl by piping them through %s (with curl -sS so progress output stays out of it).\n", formatter)
+			break
+		}
+	}
+
+	b.WriteString(apiSpecContext(spec, cwd))
+	return b.String()
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// probeBudget bounds the probes for one command; what they have
+	// counted by then makes the estimate a lower bound
+	probeBudget = time.Second
+	// probeCacheTTL is how long probed sizes are reused while a command
+	// is edited
+	probeCacheTTL = 2 * time.Minute
+	// longOperation is the estimate from which a command is held in the
+	// review to show it
+	longOperation = 30 * time.Second
+	// networkRate is the transfer rate assumed for copies to other hosts
+	networkRate = 10 << 20
+)
+
+// opRate is how fast a tool gets through files and bytes on a local disk:
+// a cost per file for the metadata, and a throughput for the contents
+type opRate struct {
+	perFile     time.Duration
+	bytesPerSec float64
+}
+
+// opRates are rough rates of the tools whose runtime grows with the data
+// they touch, measured on an SSD with a warm cache
+var opRates = map[string]opRate{
+	"find":      {perFile: 20 * time.Microsecond},
+	"du":        {perFile: 20 * time.Microsecond},
+	"chmod":     {perFile: 30 * time.Microsecond},
+	"chown":     {perFile: 30 * time.Microsecond},
+	"grep":      {perFile: 50 * time.Microsecond, bytesPerSec: 500 << 20},
+	"rg":        {perFile: 20 * time.Microsecond, bytesPerSec: 1 << 30},
+	"cp":        {perFile: 200 * time.Microsecond, bytesPerSec: 200 << 20},
+	"rsync":     {perFile: time.Millisecond, bytesPerSec: 100 << 20},
+	"scp":       {perFile: 5 * time.Millisecond, bytesPerSec: networkRate},
+	"tar":       {perFile: 200 * time.Microsecond, bytesPerSec: 150 << 20},
+	"zip":       {perFile: 300 * time.Microsecond, bytesPerSec: 40 << 20},
+	"dd":        {bytesPerSec: 150 << 20},
+	"shred":     {bytesPerSec: 50 << 20},
+	"gzip":      {bytesPerSec: 40 << 20},
+	"xz":        {bytesPerSec: 8 << 20},
+	"zstd":      {bytesPerSec: 300 << 20},
+	"md5sum":    {bytesPerSec: 500 << 20},
+	"sha256sum": {bytesPerSec: 400 << 20},
+}
+
+// commandWrappers run the command that follows them
+var commandWrappers = map[string]bool{"sudo": true, "nice": true, "ionice": true, "time": true, "nohup": true, "command": true}
+
+// tarCompression slows tar down to its compressor's rate
+var tarCompression = map[rune]float64{'z': 40 << 20, 'j': 10 << 20, 'J': 8 << 20}
+
+var (
+	// commandSeparatorRe splits a command line into the commands it runs
+	commandSeparatorRe = regexp.MustCompile(`&&|\|\||[;|&\n]`)
+	// remotePathRe matches rsync and scp paths on another host
+	remotePathRe = regexp.MustCompile(`^(?:[\w.-]+@)?[\w.-]+:`)
+	// ddSizeRe parses dd sizes like 4M or 512k
+	ddSizeRe = regexp.MustCompile(`^(\d+)([kKMGT]?)(?:i?B)?$`)
+)
+
+// Estimate is how long a command should take, from the files and bytes it
+// works on
+type Estimate struct {
+	Duration time.Duration
+	Files    int
+	Bytes    int64
+	// Partial is set when the probes ran out of time, so there is at
+	// least this much to do
+	Partial bool
+	// Network is set when a copy goes to another host, timed at
+	// networkRate
+	Network bool
+}
+
+// probeResult is what a probe counted under a path
+type probeResult struct {
+	files    int
+	bytes    int64
+	complete bool
+	taken    time.Time
+}
+
+// probeCache keeps probed paths while the command is being reviewed
+var probeCache struct {
+	sync.Mutex
+	entries map[string]probeResult
+}
+
+// probePath counts the files and bytes under path without following
+// symbolic links, until ctx is done. Block devices count their size.
+func probePath(ctx context.Context, path string) (probeResult, bool) {
+	probeCache.Lock()
+	cached, ok := probeCache.entries[path]
+	probeCache.Unlock()
+	if ok && cached.complete && time.Since(cached.taken) < probeCacheTTL {
+		return cached, true
+	}
+
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return probeResult{}, false
+	}
+	result := probeResult{complete: true, taken: time.Now()}
+	switch {
+	case stat.Mode()&fs.ModeDevice != 0:
+		result.files, result.bytes = 1, blockDeviceSize(path)
+	case !stat.IsDir():
+		result.files, result.bytes = 1, stat.Size()
+	default:
+		filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				result.complete = false
+				return filepath.SkipAll
+			}
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if diskSkipDirs[p] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			result.files++
+			if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+				result.bytes += info.Size()
+			}
+			return nil
+		})
+	}
+
+	probeCache.Lock()
+	if probeCache.entries == nil {
+		probeCache.entries = make(map[string]probeResult)
+	}
+	probeCache.entries[path] = result
+	probeCache.Unlock()
+	return result, true
+}
+
+// blockDeviceSize reads a disk's size from sysfs, or 0 where it can't
+func blockDeviceSize(path string) int64 {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return 0
+	}
+	data, err := os.ReadFile(filepath.Join("/sys/class/block", filepath.Base(resolved), "size"))
+	if err != nil {
+		return 0
+	}
+	sectors, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return sectors * 512
+}
+
+// parseDDSize parses a dd size such as 4M
+func parseDDSize(value string) int64 {
+	match := ddSizeRe.FindStringSubmatch(value)
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(match[1], 10, 64)
+	shift := map[string]uint{"": 0, "k": 10, "K": 10, "M": 20, "G": 30, "T": 40}[match[2]]
+	return n << shift
+}
+
+// operation is one command in a command line that works through data: the
+// tool, the paths it reads, and what it moves in bytes when that is known
+// without probing
+type operation struct {
+	tool    string
+	rate    opRate
+	sources []string
+	bytes   int64
+	network bool
+}
+
+// parseOperation recognises a command that takes time in proportion to its
+// data, and the paths it reads
+func parseOperation(command string) (operation, bool) {
+	fields := strings.Fields(command)
+	// Skip what merely wraps the command
+wrappers:
+	for len(fields) > 0 {
+		switch f := fields[0]; {
+		case commandWrappers[f], strings.Contains(f, "=") && !strings.HasPrefix(f, "-"):
+			fields = fields[1:]
+		case strings.HasPrefix(f, "-") && len(fields) > 1:
+			// An option of the wrapper, like nice -n 19
+			fields = fields[1:]
+			if _, err := strconv.Atoi(fields[0]); err == nil {
+				fields = fields[1:]
+			}
+		default:
+			break wrappers
+		}
+	}
+	if len(fields) == 0 {
+		return operation{}, false
+	}
+	op := operation{tool: filepath.Base(fields[0])}
+	rate, ok := opRates[op.tool]
+	if !ok {
+		return op, false
+	}
+	op.rate = rate
+
+	var args []string
+	var options []string
+	for _, field := range fields[1:] {
+		field = strings.Trim(field, `'"`)
+		if strings.HasPrefix(field, "-") {
+			options = append(options, field)
+			continue
+		}
+		args = append(args, field)
+	}
+	hasOption := func(short byte, long string) bool {
+		for _, o := range options {
+			if o == long || (!strings.HasPrefix(o, "--") && strings.IndexByte(o, short) > 0) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch op.tool {
+	case "find":
+		// Paths come before the first expression
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "-") || field == "(" || field == "!" || field == `\(` {
+				break
+			}
+			op.sources = append(op.sources, strings.Trim(field, `'"`))
+		}
+		if len(op.sources) == 0 {
+			op.sources = []string{"."}
+		}
+	case "du":
+		op.sources = args
+		if len(op.sources) == 0 {
+			op.sources = []string{"."}
+		}
+	case "grep":
+		if !hasOption('r', "--recursive") && !hasOption('R', "--dereference-recursive") {
+			return op, false
+		}
+		if len(args) > 1 {
+			op.sources = args[1:]
+		} else {
+			op.sources = []string{"."}
+		}
+	case "rg":
+		if len(args) > 1 {
+			op.sources = args[1:]
+		} else {
+			op.sources = []string{"."}
+		}
+	case "chmod", "chown":
+		if !hasOption('R', "--recursive") || len(args) < 2 {
+			return op, false
+		}
+		op.sources = args[1:]
+	case "cp", "rsync", "scp":
+		if len(args) < 2 {
+			return op, false
+		}
+		op.sources = args[:len(args)-1]
+		for _, arg := range args {
+			if remotePathRe.MatchString(arg) {
+				op.network = true
+			}
+		}
+	case "tar":
+		// mode gathers the short options; old-style tar takes them as the
+		// first word, without a dash
+		mode := ""
+		for _, o := range options {
+			if !strings.HasPrefix(o, "--") {
+				mode += o[1:]
+			}
+		}
+		if len(args) > 0 && args[0] == strings.Trim(fields[1], `'"`) {
+			mode += args[0]
+			args = args[1:]
+		}
+		for flag, rate := range tarCompression {
+			if strings.ContainsRune(mode, flag) {
+				op.rate.bytesPerSec = rate
+			}
+		}
+		// The archive is the first argument when f names it there
+		archive := ""
+		if (strings.ContainsRune(mode, 'f') || hasOption(0, "--file")) && len(args) > 0 {
+			archive, args = args[0], args[1:]
+		}
+		switch {
+		case strings.ContainsRune(mode, 'c') || hasOption(0, "--create"):
+			op.sources = args
+		case strings.ContainsRune(mode, 'x') || hasOption(0, "--extract"):
+			if archive != "" {
+				op.sources = []string{archive}
+			}
+		}
+		if len(op.sources) == 0 {
+			return op, false
+		}
+	case "zip":
+		if len(args) < 2 {
+			return op, false
+		}
+		op.sources = args[1:]
+	case "dd":
+		var bs, count int64 = 512, 0
+		for _, arg := range args {
+			key, value, _ := strings.Cut(arg, "=")
+			switch key {
+			case "if":
+				op.sources = []string{value}
+			case "bs":
+				bs = parseDDSize(value)
+			case "count":
+				count, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+		if count > 0 {
+			op.bytes = bs * count
+		} else if len(op.sources) == 0 {
+			return op, false
+		}
+	default:
+		op.sources = args
+		if len(op.sources) == 0 {
+			return op, false
+		}
+	}
+	return op, true
+}
+
+// EstimateCommand probes the paths a command line reads, relative to dir,
+// and estimates how long it takes. ok is false when nothing in it is known
+// to take time in proportion to its data.
+func EstimateCommand(command, dir string) (Estimate, bool) {
+	var est Estimate
+	found := false
+	ctx, cancel := context.WithTimeout(context.Background(), probeBudget)
+	defer cancel()
+
+	for _, part := range commandSeparatorRe.Split(command, -1) {
+		op, ok := parseOperation(strings.TrimSpace(part))
+		if !ok {
+			continue
+		}
+		files, bytes := 0, op.bytes
+		if bytes == 0 {
+			for _, source := range op.sources {
+				if remotePathRe.MatchString(source) {
+					continue
+				}
+				path := expandHome(source)
+				if !filepath.IsAbs(path) {
+					if dir == "" {
+						continue
+					}
+					path = filepath.Join(dir, path)
+				}
+				// Globs the shell would expand
+				matches, _ := filepath.Glob(path)
+				if len(matches) == 0 {
+					matches = []string{path}
+				}
+				for _, match := range matches {
+					result, ok := probePath(ctx, match)
+					if !ok {
+						continue
+					}
+					files += result.files
+					bytes += result.bytes
+					est.Partial = est.Partial || !result.complete
+				}
+			}
+		}
+		if files == 0 && bytes == 0 {
+			continue
+		}
+		found = true
+
+		rate := op.rate
+		if op.network {
+			rate.bytesPerSec = networkRate
+			est.Network = true
+		}
+		d := time.Duration(files) * rate.perFile
+		if rate.bytesPerSec > 0 {
+			d += time.Duration(float64(bytes) / rate.bytesPerSec * float64(time.Second))
+		}
+		est.Duration += d
+		est.Files += files
+		est.Bytes += bytes
+	}
+	return est, found
+}
+
+// expandHome expands a leading ~ to the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
+
+// formatDuration renders a duration coarsely, as people estimate: 40s,
+// 3m, 1h20m
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Round(time.Second).Seconds()))
+	case d < 10*time.Minute:
+		d = d.Round(time.Second)
+		if s := int(d.Seconds()) % 60; s != 0 {
+			return fmt.Sprintf("%dm%02ds", int(d.Minutes()), s)
+		}
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Round(time.Minute).Minutes()))
+	}
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// String describes the estimate and what it is based on
+func (e Estimate) String() string {
+	about := "about "
+	if e.Partial {
+		about = "at least "
+	}
+	basis := formatBytes(e.Bytes)
+	if e.Files > 1 {
+		basis += fmt.Sprintf(" in %d files", e.Files)
+	}
+	s := fmt.Sprintf("Takes %s%s (%s", about, formatDuration(e.Duration), basis)
+	if e.Network {
+		s += fmt.Sprintf(", assuming %s/s over the network", formatBytes(networkRate))
+	}
+	return s + ")"
+}
+
+// runningOp is a generated command running in the shell, timed in the
+// status bar; ticking is set once the refresh timer runs. A command with a
+// deadline is interrupted when it passes it.
+type runningOp struct {
+	command     string
+	start       time.Time
+	estimate    time.Duration
+	deadline    time.Time
+	interrupted bool
+	ticking     bool
+}
+
+// progressTickMsg refreshes the elapsed time of the running command
+type progressTickMsg struct{}
+
+// progressTick schedules the next refresh of the status bar
+func progressTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return progressTickMsg{}
+	})
+}
+
+// commandTimeout is how long a generated command may run before it is
+// interrupted, 0 for as long as it takes
+func (m Model) commandTimeout() time.Duration {
+	return time.Duration(m.config.CommandTimeout) * time.Second
+}
+
+// startProgress times a generated command that was estimated to take a
+// while, or that must finish within command_timeout. The timer starts with
+// the shell's echo of the command.
+func (m *Model) startProgress(command string) {
+	m.running = nil
+	op := &runningOp{command: command, start: time.Now()}
+	if m.estimate != nil && m.estimate.Duration >= time.Second {
+		op.estimate = m.estimate.Duration
+	}
+	if timeout := m.commandTimeout(); timeout > 0 {
+		op.deadline = op.start.Add(timeout)
+	}
+	if op.estimate > 0 || !op.deadline.IsZero() {
+		m.running = op
+	}
+}
+
+// interruptForeground sends Ctrl+C to the shell, which interrupts the job
+// in the foreground and leaves the shell itself running
+func (m *Model) interruptForeground() {
+	if m.pty != nil {
+		m.pty.Write([]byte{0x03})
+	}
+}
+
+// stopRunning interrupts the generated command or step running in the
+// shell, for Alt+X
+func (m *Model) stopRunning() {
+	countFeature("stop")
+	m.interruptForeground()
+	if m.running != nil {
+		m.running.interrupted = true
+	}
+	if m.steps != nil && m.steps.running {
+		m.steps.interrupted = true
+	}
+}
+
+// watchProgress stops timing once the shell reports the command finished,
+// and starts the refresh timer for one that just started
+func (m *Model) watchProgress(chunk []byte) tea.Cmd {
+	if m.running == nil {
+		return nil
+	}
+	if exitStatusRe.Match(chunk) {
+		m.running = nil
+		return nil
+	}
+	if !m.running.ticking {
+		m.running.ticking = true
+		return progressTick()
+	}
+	return nil
+}
+
+// progressTicked refreshes the status bar, interrupts the command once it
+// is past its deadline, and stops once the shell has no command in the
+// foreground any more. Where the shell can't be asked, the interrupt ends
+// the timing, as Ctrl+C does.
+func (m *Model) progressTicked() tea.Cmd {
+	if m.running == nil {
+		return nil
+	}
+	busy, known := false, false
+	if m.pty != nil {
+		busy, known = m.pty.Busy()
+		if known && !busy && time.Since(m.running.start) > time.Second {
+			m.running = nil
+			return nil
+		}
+	}
+	if !m.running.deadline.IsZero() && !m.running.interrupted && time.Now().After(m.running.deadline) {
+		m.interruptForeground()
+		m.running.interrupted = true
+		if !known {
+			m.running = nil
+			return nil
+		}
+	}
+	return progressTick()
+}
+
+// renderProgress shows how long the running command has taken, how long
+// it should still take and when it times out
+func (m Model) renderProgress() string {
+	elapsed := time.Since(m.running.start).Truncate(time.Second)
+	status := formatDuration(elapsed) + " elapsed"
+	switch {
+	case m.running.interrupted:
+		status += ", interrupted"
+	case m.running.estimate == 0:
+	case m.running.estimate-elapsed >= time.Second:
+		status += ", about " + formatDuration(m.running.estimate-elapsed) + " left"
+	case elapsed > m.running.estimate*2:
+		status += ", taking longer than estimated"
+	default:
+		status += ", should finish any moment"
+	}
+	if !m.running.deadline.IsZero() && !m.running.interrupted {
+		status += ", stopped in " + formatDuration(max(0, m.running.deadline.Sub(m.running.start)-elapsed))
+	}
+	return lipgloss.NewStyle().
+		Foreground(theme.Info).
+		Width(m.width).
+		MaxHeight(1).
+		Render(fmt.Sprintf(" ⏱ %s  %s  (Alt+X: stop)", m.running.command, status))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// renderExplanation shows what the model said a command does and what
+// could go wrong running it, noting when the command was edited since
+func renderExplanation(e *ai.Explanation, width int, edited bool) string {
+	summary := "💡 " + strings.TrimSpace(e.Summary)
+	if edited {
+		summary += " (as generated)"
+	}
+	style := lipgloss.NewStyle().Width(width)
+	out := style.Foreground(theme.Info).Render(summary) + "\n"
+	switch risk := strings.TrimSpace(e.Risk); {
+	case risk == "":
+	case strings.EqualFold(strings.TrimRight(risk, "."), "none"):
+		out += style.Foreground(theme.Dim).Render("Risk: none") + "\n"
+	default:
+		out += style.Foreground(theme.Warning).Render("Risk: "+risk) + "\n"
+	}
+	return out
+}
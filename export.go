@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxExportOutputLines caps the output exported under each command
+const maxExportOutputLines = 40
+
+// executed reports whether an entry is a command that ran in the shell
+func (e HistoryEntry) executed() bool {
+	return e.Kind == HistoryShell || (e.Kind == HistoryAI && e.Outcome == OutcomeAccepted)
+}
+
+// RenderConversationMarkdown writes a session as Markdown: each request with
+// the command or answer it got, and each command that ran. outputs holds
+// the output of the entry at the same index, when known.
+func RenderConversationMarkdown(title string, entries []HistoryEntry, outputs []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	if len(entries) > 0 {
+		fmt.Fprintf(&b, "Recorded %s to %s.\n", entries[0].Time.Format(time.DateTime), entries[len(entries)-1].Time.Format(time.DateTime))
+	}
+
+	for i, entry := range entries {
+		switch entry.Kind {
+		case HistoryAI:
+			fmt.Fprintf(&b, "\n## %s\n\n", entry.Query)
+			if entry.Outcome != OutcomeAccepted {
+				fmt.Fprintf(&b, "Suggested (%s):\n\n", entry.Outcome)
+			}
+		case HistoryShell, HistoryInline:
+			fmt.Fprintf(&b, "\n## Shell\n\n")
+		case HistoryAsk:
+			fmt.Fprintf(&b, "\n## %s\n\n%s\n", entry.Query, strings.TrimSpace(entry.Answer))
+			continue
+		default:
+			continue
+		}
+
+		fmt.Fprintf(&b, "```sh\n%s\n```\n", strings.TrimRight(entry.Command, "\n"))
+		if entry.Cwd != "" {
+			fmt.Fprintf(&b, "\nIn `%s` at %s.\n", entry.Cwd, entry.Time.Format(time.TimeOnly))
+		}
+		if i < len(outputs) && outputs[i] != "" {
+			fmt.Fprintf(&b, "\nOutput:\n\n```\n%s\n```\n", outputs[i])
+		}
+	}
+	return b.String()
+}
+
+// commandOutputs cuts the scrollback into the output of each command the
+// session ran, from when it was submitted to when the next one was
+func (m Model) commandOutputs() []string {
+	outputs := make([]string, len(m.history))
+	end := m.outputDropped + len(m.output)
+	for i := len(m.history) - 1; i >= 0; i-- {
+		entry := m.history[i]
+		if !entry.executed() {
+			continue
+		}
+		start := entry.outputAt - m.outputDropped
+		switch {
+		case start < 0:
+			outputs[i] = "(no longer in the scrollback)"
+		case start < end-m.outputDropped:
+			// The first line echoes the command and the last is the prompt
+			// that followed it
+			lines := strings.Split(strings.TrimRight(plainText(m.output[start:end-m.outputDropped]), "\n"), "\n")
+			if len(lines) > 2 {
+				lines = lastLines(strings.Join(lines[1:len(lines)-1], "\n"), maxExportOutputLines)
+				outputs[i] = redactText(m.config, strings.Join(lines, "\n"))
+			}
+		}
+		end = entry.outputAt
+	}
+	return outputs
+}
+
+// exportConversation writes the session so far to a Markdown file in the
+// shell's directory and returns its path
+func (m Model) exportConversation() (string, error) {
+	title := "Session " + m.session
+	if m.conversation != "" && m.conversation != m.session {
+		title = "Conversation " + m.conversation
+	}
+	dir := m.shellCwd()
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	path := filepath.Join(dir, "ai-terminal-tui-"+valueOrDefault(m.conversation, m.session)+".md")
+	markdown := RenderConversationMarkdown(title, m.history, m.commandOutputs())
+	if err := os.WriteFile(path, []byte(markdown), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// handleHistoryCommand handles the history subcommands
+func handleHistoryCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui history list")
+		fmt.Println("       ai-terminal-tui history export [--session ID] [-o FILE]")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Println("The history log is empty; it is kept while history is true.")
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		var sessions []string
+		counts := make(map[string]int)
+		for _, entry := range entries {
+			if _, seen := counts[entry.Session]; !seen {
+				sessions = append(sessions, entry.Session)
+				counts[entry.Session] = 0
+			}
+			if entry.Kind == HistoryAI || entry.Kind == HistoryAsk {
+				counts[entry.Session]++
+			}
+		}
+		for _, session := range sessions {
+			fmt.Printf("%-20s %3d request(s)\n", session, counts[session])
+		}
+
+	case "export":
+		session, file := entries[len(entries)-1].Session, ""
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "--session", "-s":
+				if i+1 >= len(args) {
+					usage()
+				}
+				session = args[i+1]
+				i++
+			case "-o", "--output":
+				if i+1 >= len(args) {
+					usage()
+				}
+				file = args[i+1]
+				i++
+			default:
+				usage()
+			}
+		}
+
+		var selected []HistoryEntry
+		for _, entry := range entries {
+			if entry.Session == session {
+				selected = append(selected, entry)
+			}
+		}
+		if len(selected) == 0 {
+			fmt.Printf("Error: no session %q in the history log (see ai-terminal-tui history list)\n", session)
+			os.Exit(1)
+		}
+		// The log keeps commands but not their output, which only the TUI
+		// that ran them can export
+		markdown := RenderConversationMarkdown("Session "+session, selected, nil)
+		if file == "" {
+			fmt.Print(markdown)
+			return
+		}
+		if err := os.WriteFile(file, []byte(markdown), 0600); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported session %s to %s\n", session, file)
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// fewShotWindow is how many recent history entries are considered when
+// picking examples
+const fewShotWindow = 2000
+
+// maxFewShotExamples caps the few_shot_examples setting, since every example
+// is sent with each request
+const maxFewShotExamples = 10
+
+// FewShotExamples picks up to n commands the user accepted in the past to
+// show the model their preferred style and tools. Requests sharing words
+// with query come first, then the most recent.
+func FewShotExamples(entries []HistoryEntry, query string, n int) []HistoryEntry {
+	if n <= 0 {
+		return nil
+	}
+	if len(entries) > fewShotWindow {
+		entries = entries[len(entries)-fewShotWindow:]
+	}
+
+	type candidate struct {
+		entry HistoryEntry
+		score int
+		order int
+	}
+	words := queryWords(query)
+	seen := make(map[string]bool)
+	var candidates []candidate
+
+	// Walk backwards so the newest copy of a repeated command wins
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Kind != HistoryAI || entry.Outcome != OutcomeAccepted || entry.Query == "" ||
+			isScript(entry.Command) || seen[entry.Command] {
+			continue
+		}
+		seen[entry.Command] = true
+
+		score := 0
+		for word := range queryWords(entry.Query) {
+			if words[word] {
+				score++
+			}
+		}
+		candidates = append(candidates, candidate{entry, score, len(candidates)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	// Oldest first reads more naturally as conversation
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].order > candidates[j].order
+	})
+	examples := make([]HistoryEntry, len(candidates))
+	for i, c := range candidates {
+		examples[i] = c.entry
+	}
+	return examples
+}
+
+// queryWords returns the distinct meaningful words of a request
+func queryWords(query string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Trim(word, ".,;:!?\"'()")
+		if len(word) > 2 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// fewShotMessages turns the user's accepted commands into example exchanges
+// placed before the real request
+func fewShotMessages(config Config, query string) []ai.Message {
+	if config.FewShotExamples <= 0 {
+		return nil
+	}
+	entries, err := LoadHistory()
+	if err != nil {
+		return nil
+	}
+
+	var messages []ai.Message
+	for _, example := range FewShotExamples(entries, query, config.FewShotExamples) {
+		messages = append(messages,
+			ai.Message{Role: "user", Content: fmt.Sprintf("User request: %s\n\nShell command:", redactText(config, example.Query))},
+			ai.Message{Role: "assistant", Content: redactText(config, example.Command)},
+		)
+	}
+	return messages
+}
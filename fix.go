@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// fixOutputLines is how much output before a failure is sent with a fix
+// request
+const fixOutputLines = 20
+
+var (
+	// failurePatterns match the messages of common failures in shell output
+	failurePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)command not found|: not found$|is not recognized as (?:an internal or external command|the name of a cmdlet)`),
+		regexp.MustCompile(`(?i)permission denied|operation not permitted|are you root\?`),
+		regexp.MustCompile(`No such file or directory|cannot access '`),
+		regexp.MustCompile(`^(?:fatal|error): |Unable to locate package|ModuleNotFoundError|Segmentation fault`),
+	}
+	// exitStatusRe matches the command-finished mark (OSC 133;D) that shell
+	// integrations print with the exit status before each prompt
+	exitStatusRe = regexp.MustCompile(`\x1b\]133;D;(\d+)`)
+)
+
+// failure is a command that appears to have failed, with the line of
+// output that gave it away
+type failure struct {
+	Command string
+	Message string
+}
+
+// detectFailure looks for a failure in a chunk of shell output
+func detectFailure(chunk []byte) (string, bool) {
+	if match := exitStatusRe.FindSubmatch(chunk); match != nil && string(match[1]) != "0" {
+		return "exit status " + string(match[1]), true
+	}
+	for _, line := range strings.Split(ansi.Strip(string(chunk)), "\n") {
+		line = strings.TrimSpace(line)
+		for _, pattern := range failurePatterns {
+			if pattern.MatchString(line) {
+				return line, true
+			}
+		}
+	}
+	return "", false
+}
+
+// watchFailures offers a fix when output after a submitted command shows it
+// failed. Only the first failure after each command is offered, and none
+// while saving a low battery.
+func (m *Model) watchFailures(chunk []byte) {
+	if m.lastCommand == "" || m.fixOffer != nil || m.powerSaving() {
+		return
+	}
+	if message, ok := detectFailure(chunk); ok {
+		// The chunk may hold only part of the line; show all of it
+		lines := strings.Split(m.recentOutput(fixOutputLines), "\n")
+		for i := len(lines) - 1; i >= 0; i-- {
+			if strings.Contains(lines[i], message) {
+				message = strings.TrimSpace(lines[i])
+				break
+			}
+		}
+		m.fixOffer = &failure{Command: m.lastCommand, Message: message}
+		m.lastCommand = ""
+		// A command that failed may have changed nothing to undo
+		m.undoOffer = nil
+	}
+}
+
+// commandSubmitted notes a command sent to the shell, whose output is then
+// watched for failures, and which becomes the previous command
+func (m *Model) commandSubmitted(command string) {
+	m.lastCommand = strings.TrimSpace(command)
+	m.fixOffer, m.undoOffer = nil, nil
+	previous := m.lastCommand
+	if historyExpands(m.config.Shell) {
+		// A command like sudo !! is remembered as the shell ran it
+		var err error
+		if previous, err = ExpandHistory(m.lastCommand, m.prevCommand); err != nil {
+			previous = ""
+		}
+	}
+	m.prevCommand = previous
+}
+
+// queryFix asks for a command that fixes the offered failure; the answer
+// goes through the usual review before it runs
+func (m *Model) queryFix() tea.Cmd {
+	offer := m.fixOffer
+	m.fixOffer = nil
+	countFeature("fix")
+
+	output := redactText(m.config, m.recentOutput(fixOutputLines))
+	query := fmt.Sprintf("The command `%s` failed (%s). Give a command that fixes the problem or does what it was meant to do.\n\nTerminal output:\n%s",
+		offer.Command, offer.Message, untrustedBlock("terminal output", output))
+	// Permission errors are fixed from the modes and owners of the paths
+	// involved, which can only be inspected on this machine
+	if isPermissionError(offer.Message+"\n"+output) && len(m.remotes) == 0 {
+		countFeature("permission fix")
+		report := redactText(m.config, PermissionReport(offer.Command, output, m.shellCwd()))
+		query = permissionFixQuery(offer, output, report)
+	}
+	m.showPrompt = true
+	m.loading = true
+	m.lastQuery = "fix: " + offer.Command
+	m.genQuery, m.attempts = query, nil
+	return m.queryAI(query, nil)
+}
+
+// renderFixOffer is the status line shown while a fix is on offer
+func (m Model) renderFixOffer() string {
+	return lipgloss.NewStyle().
+		Foreground(theme.Warning).
+		Width(m.width).
+		MaxHeight(1).
+		Render(fmt.Sprintf(" %s failed: %s  (Ctrl+F to ask AI for a fix)", m.fixOffer.Command, m.fixOffer.Message))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitTimeout bounds every git invocation so a slow repository never stalls
+// a query
+const gitTimeout = 2 * time.Second
+
+// maxDiffStatLines caps the `git diff --stat` excerpt sent to the model
+const maxDiffStatLines = 15
+
+// GitContext summarises the state of the git repository around the cwd
+type GitContext struct {
+	Branch   string
+	Dirty    bool
+	Changes  int
+	DiffStat string
+}
+
+// runGit runs git in dir and returns its trimmed stdout
+func runGit(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// GatherGitContext returns the repository state for dir, or nil when dir is
+// not inside a git work tree or git is not installed
+func GatherGitContext(dir string) *GitContext {
+	if dir == "" {
+		return nil
+	}
+	if inside, err := runGit(dir, "rev-parse", "--is-inside-work-tree"); err != nil || inside != "true" {
+		return nil
+	}
+
+	git := &GitContext{}
+
+	if branch, err := runGit(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		git.Branch = branch
+	}
+	if git.Branch == "HEAD" {
+		if sha, err := runGit(dir, "rev-parse", "--short", "HEAD"); err == nil {
+			git.Branch = "detached at " + sha
+		}
+	}
+
+	if status, err := runGit(dir, "status", "--porcelain"); err == nil && status != "" {
+		git.Dirty = true
+		git.Changes = len(strings.Split(status, "\n"))
+	}
+
+	if git.Dirty {
+		stat, err := runGit(dir, "diff", "HEAD", "--stat")
+		if err != nil {
+			// No commits yet
+			stat, _ = runGit(dir, "diff", "--stat")
+		}
+		git.DiffStat = truncateLines(stat, maxDiffStatLines)
+	}
+
+	return git
+}
+
+// String renders the git state for the system prompt
+func (g *GitContext) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Git branch: %s\n", g.Branch)
+	if g.Dirty {
+		fmt.Fprintf(&b, "Git status: %d uncommitted change(s)\n", g.Changes)
+	} else {
+		b.WriteString("Git status: clean\n")
+	}
+	if g.DiffStat != "" {
+		fmt.Fprintf(&b, "Git diff --stat:\n%s\n", g.DiffStat)
+	}
+
+	return b.String()
+}
+
+// truncateLines keeps the first n lines of text and notes how many were cut
+func truncateLines(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= n {
+		return text
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n... (%d more lines)", len(lines)-n)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// historyExpands reports whether shell expands history designators like !!
+// in the commands it is given
+func historyExpands(shell string) bool {
+	switch shellName(shell) {
+	case "bash", "zsh":
+		return true
+	}
+	return false
+}
+
+// historyWords splits a command into the words history designators count,
+// as bash does: on unquoted blanks, with runs of operators words of their
+// own
+func historyWords(command string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	var quote rune
+	escaped := false
+	operator := false
+	for _, r := range command {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case unicode.IsSpace(r):
+			flush()
+			operator = false
+			continue
+		case strings.ContainsRune(";|&<>", r):
+			if !operator {
+				flush()
+			}
+			operator = true
+			word.WriteRune(r)
+			continue
+		}
+		if operator {
+			flush()
+			operator = false
+		}
+		word.WriteRune(r)
+	}
+	flush()
+	return words
+}
+
+// ExpandHistory expands the history designators in command that refer to
+// previous, the command run before it: !!, !-1, !$, !^, !* and word
+// designators like !:2 or !!:1-3. Designators that need more history
+// than that, or modifiers like :h, are an error, as is any designator
+// when previous isn't known. Like the shell, nothing is expanded inside
+// single quotes, after a backslash, in $!, ${!name} and [!...], or where !
+// is followed by a blank, = or (.
+func ExpandHistory(command, previous string) (string, error) {
+	if !strings.Contains(command, "!") {
+		return command, nil
+	}
+	words := historyWords(previous)
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+		case (c == '\'' || c == '"') && quote == rune(c):
+			quote = 0
+		case (c == '\'' || c == '"') && quote == 0:
+			quote = rune(c)
+		case c == '!' && quote != '\'':
+			end, designator, err := historyDesignator(command, i, quote)
+			if err != nil {
+				return command, err
+			}
+			if end == i {
+				break
+			}
+			if previous == "" {
+				return command, fmt.Errorf("%s refers to the previous command, which isn't known", command[i:end])
+			}
+			expansion, err := designateWords(words, designator)
+			if err != nil {
+				return command, fmt.Errorf("%s: %v", command[i:end], err)
+			}
+			b.WriteString(expansion)
+			i = end - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// historyDesignator parses the history expansion starting at the ! at i,
+// returning where it ends and its word designator ("" for the whole
+// command). end is i when the ! is taken literally.
+func historyDesignator(command string, i int, quote rune) (end int, designator string, err error) {
+	rest := command[i+1:]
+	switch {
+	case i > 0 && strings.ContainsAny(command[i-1:i], "$[") || strings.HasSuffix(command[:i], "${"):
+		// $!, ${!name} and [!...] globs
+		return i, "", nil
+	case rest == "" || strings.ContainsAny(rest[:1], " \t\n=(") || (quote == '"' && rest[0] == '"'):
+		return i, "", nil
+	case strings.HasPrefix(rest, "!") || strings.HasPrefix(rest, "-1") && !startsWithDigit(rest[2:]):
+		end = i + 2
+		if rest[0] == '-' {
+			end++
+		}
+	case strings.ContainsAny(rest[:1], "$^*"):
+		return i + 2, rest[:1], nil
+	case rest[0] == ':':
+		end = i + 1
+	case !startsWithDigit(rest) && !unicode.IsLetter(rune(rest[0])) && !strings.ContainsAny(rest[:1], "?#-_."):
+		return i, "", nil
+	default:
+		// !n, !-n, !string, !?string? and !# reach further back in the
+		// history than the previous command
+		word := rest
+		if j := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(`;|&"'`, r) }); j >= 0 {
+			word = rest[:j]
+		}
+		return i, "", fmt.Errorf("!%s refers to shell history that isn't tracked here", word)
+	}
+
+	// An optional word designator after a colon
+	if end >= len(command) || command[end] != ':' {
+		return end, "", nil
+	}
+	j := end + 1
+	for j < len(command) && strings.ContainsRune("0123456789$^*-", rune(command[j])) {
+		j++
+	}
+	if j == end+1 {
+		return i, "", fmt.Errorf("%s: modifiers aren't supported", command[i:min(j+1, len(command))])
+	}
+	if j < len(command) && command[j] == ':' {
+		return i, "", fmt.Errorf("%s: modifiers aren't supported", command[i:min(j+2, len(command))])
+	}
+	return j, command[end+1 : j], nil
+}
+
+// startsWithDigit reports whether s starts with a digit
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// designateWords picks the words of a command a word designator names:
+// N, ^, $, *, N-M, N- and N*
+func designateWords(words []string, designator string) (string, error) {
+	last := len(words) - 1
+	index := func(s string) (int, error) {
+		switch s {
+		case "^":
+			return 1, nil
+		case "$":
+			return last, nil
+		}
+		return strconv.Atoi(s)
+	}
+	from, to := 0, last
+	switch {
+	case designator == "":
+	case designator == "*":
+		if last < 1 {
+			return "", nil
+		}
+		from = 1
+	case strings.HasSuffix(designator, "*"):
+		n, err := index(strings.TrimSuffix(designator, "*"))
+		if err != nil {
+			return "", fmt.Errorf("bad word designator")
+		}
+		from = n
+	case strings.Contains(designator[1:], "-"):
+		a, bound, _ := strings.Cut(designator, "-")
+		n, err := index(a)
+		if err != nil {
+			return "", fmt.Errorf("bad word designator")
+		}
+		from = n
+		if bound == "" {
+			// N- leaves out the last word
+			to = last - 1
+		} else if to, err = index(bound); err != nil {
+			return "", fmt.Errorf("bad word designator")
+		}
+	default:
+		n, err := index(designator)
+		if err != nil {
+			return "", fmt.Errorf("bad word designator")
+		}
+		from, to = n, n
+	}
+	if from < 0 || to > last || from > to {
+		return "", fmt.Errorf("the previous command has no such word")
+	}
+	return strings.Join(words[from:to+1], " "), nil
+}
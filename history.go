@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// History entry kinds
+const (
+	HistoryShell  = "shell"  // a command typed at the shell prompt
+	HistoryAI     = "ai"     // a command generated from the AI prompt
+	HistoryInline = "inline" // an inline suggestion accepted while typing
+	HistoryAsk    = "ask"    // a question about selected text and its answer
+	HistoryEnd    = "end"    // the session ended
+)
+
+// Outcomes of an AI suggestion
+const (
+	OutcomeAccepted = "accepted"
+	OutcomeEdited   = "edited"
+	OutcomeRejected = "rejected"
+	OutcomeCopied   = "copied"
+)
+
+// HistoryEntry is one line of the history log. Tokens holds the API tokens
+// spent since the previous entry was written.
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Session string    `json:"session"`
+	Kind    string    `json:"kind"`
+	Query   string    `json:"query,omitempty"`
+	Command string    `json:"command,omitempty"`
+	Outcome string    `json:"outcome,omitempty"`
+	Answer  string    `json:"answer,omitempty"`
+	Cwd     string    `json:"cwd,omitempty"`
+	Tokens  int       `json:"tokens,omitempty"`
+
+	// outputAt is where the command's output starts in the scrollback,
+	// counting bytes since the session began; it is not logged
+	outputAt int
+}
+
+// tokensUsed accumulates API token usage until it is written to history
+var tokensUsed atomic.Int64
+
+// addTokenUsage records tokens reported by the API
+func addTokenUsage(n int) {
+	tokensUsed.Add(int64(n))
+}
+
+// GetHistoryPath returns the path to the history log next to the config file
+func GetHistoryPath() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "history.jsonl")
+}
+
+// newSessionID names a session after the time it started
+func newSessionID(start time.Time) string {
+	return start.Format("20060102-150405")
+}
+
+// AppendHistory writes entry to the history log
+func AppendHistory(entry HistoryEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	path := GetHistoryPath()
+	if path == "" {
+		return os.ErrNotExist
+	}
+	file, err := os.OpenFile(path, appendFlags, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return appendLocked(file, append(data, '\n'))
+}
+
+// LoadHistory reads every entry in the history log. A missing log is empty;
+// lines that fail to parse are skipped.
+func LoadHistory() ([]HistoryEntry, error) {
+	file, err := os.Open(GetHistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// recordHistory logs an entry for the current session, attributing token
+// usage not yet recorded to it. The session keeps its own copy so stats
+// work even with the log turned off; the log on disk gets the command and
+// request with secrets redacted, as lines typed at the shell go there too.
+func (m *Model) recordHistory(entry HistoryEntry) {
+	entry.Time = time.Now()
+	entry.Session = m.session
+	entry.Tokens = int(tokensUsed.Swap(0))
+	if entry.Cwd == "" {
+		entry.Cwd = m.shellCwd()
+	}
+	entry.outputAt = m.outputDropped + len(m.output)
+
+	m.history = append(m.history, entry)
+	if (entry.Kind == HistoryAI && entry.Outcome != OutcomeRejected) || entry.Kind == HistoryAsk {
+		m.remember(entry)
+	}
+	if m.config.History {
+		saved := entry
+		saved.Command = redactText(m.config, entry.Command)
+		saved.Query = redactText(m.config, entry.Query)
+		// History is a convenience; failing to write it is not fatal
+		AppendHistory(saved)
+	}
+}
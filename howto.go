@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Limits on the documentation fetched for howto requests
+const (
+	manualTimeout  = 3 * time.Second
+	maxManualBytes = 12000
+	manualWidth    = "100"
+)
+
+// howtoStopWords are everyday words that happen to be commands too, and so
+// rarely name the tool a request is about
+var howtoStopWords = map[string]bool{
+	"a": true, "at": true, "date": true, "do": true, "false": true,
+	"file": true, "help": true, "how": true, "in": true, "info": true,
+	"install": true, "last": true, "less": true, "link": true, "look": true,
+	"make": true, "man": true, "more": true, "see": true, "size": true,
+	"test": true, "time": true, "top": true, "true": true, "w": true,
+	"wall": true, "watch": true, "which": true, "who": true, "write": true,
+	"yes": true,
+}
+
+// howtoToolMarkers are words that introduce the tool in requests like
+// "sort by size with ls" or "using jq, ..."
+var howtoToolMarkers = map[string]bool{
+	"with": true, "using": true, "use": true, "via": true, "run": true,
+}
+
+var (
+	// queryTokenRe matches words in a request that could be command names
+	queryTokenRe = regexp.MustCompile(`[A-Za-z0-9][A-Za-z0-9._+-]*`)
+	// overstrikeRe matches the backspace sequences man uses for bold and
+	// underline when its output is not a terminal
+	overstrikeRe = regexp.MustCompile(`.\x08`)
+)
+
+// HowtoTool picks the installed tool a request is about: a command on PATH
+// named after "with", "using" and the like, or else the first word of the
+// query that is a command on PATH, skipping everyday words
+func HowtoTool(query string) string {
+	installed := func(word string) bool {
+		_, err := exec.LookPath(word)
+		return err == nil
+	}
+
+	var words []string
+	for _, loc := range queryTokenRe.FindAllStringIndex(query, -1) {
+		// Parts of paths, options and variables are not commands
+		if loc[0] > 0 && strings.ContainsRune("/\\~-$.", rune(query[loc[0]-1])) {
+			continue
+		}
+		// "a tar.gz" is about tar, "report.txt" about nothing
+		word, _, _ := strings.Cut(query[loc[0]:loc[1]], ".")
+		words = append(words, word)
+	}
+	for i := 1; i < len(words); i++ {
+		if howtoToolMarkers[strings.ToLower(words[i-1])] && installed(words[i]) {
+			return words[i]
+		}
+	}
+	for _, word := range words {
+		if !howtoStopWords[strings.ToLower(word)] && installed(word) {
+			return word
+		}
+	}
+	return ""
+}
+
+// FetchManual returns the documentation of the locally installed tool: its
+// man page where man is available, its --help output otherwise
+func FetchManual(tool string) string {
+	if runtime.GOOS != "windows" {
+		// man exits non-zero when there is no page for the tool
+		if page, err := runManual("man", "-P", "cat", tool); err == nil && page != "" {
+			return truncateManual(overstrikeRe.ReplaceAllString(page, ""))
+		}
+	}
+	// Many tools print --help to stderr or exit non-zero, so any output
+	// is accepted
+	page, _ := runManual(tool, "--help")
+	return truncateManual(page)
+}
+
+// runManual runs a documentation command with a short timeout
+func runManual(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), manualTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "MANWIDTH="+manualWidth, "MANPAGER=cat", "PAGER=cat")
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+// truncateManual keeps the start of a manual, where the synopsis and most
+// options are, within maxManualBytes
+func truncateManual(page string) string {
+	if len(page) <= maxManualBytes {
+		return page
+	}
+	cut := strings.LastIndex(page[:maxManualBytes], "\n")
+	if cut < 0 {
+		cut = maxManualBytes
+	}
+	return page[:cut] + "\n[... manual truncated]"
+}
+
+// GatherManual adds the documentation of the tool a request is about to
+// the context. tool may be empty to find it in the query. It reports
+// whether a manual was found.
+func (c *PromptContext) GatherManual(query, tool string) bool {
+	if tool == "" {
+		tool = HowtoTool(query)
+	}
+	if tool == "" {
+		return false
+	}
+	manual := FetchManual(tool)
+	if manual == "" {
+		return false
+	}
+	c.ManualTool, c.Manual = tool, manual
+	return true
+}
+
+// manualPrompt tells the model to keep to the documented flags when a
+// manual is part of the context
+func manualPrompt(ctx PromptContext) string {
+	if ctx.Manual == "" {
+		return ""
+	}
+	return fmt.Sprintf("The manual of the installed %s is included below. Only use options it documents, "+
+		"since the installed version may differ from the one you know.\n\n", ctx.ManualTool)
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// Channels a job's result can be delivered through
+const (
+	JobFile    = "file"
+	JobDesktop = "desktop"
+	JobWebhook = "webhook"
+	JobEmail   = "email"
+)
+
+const (
+	// jobTailBytes is how much of a job's output goes to the model, the
+	// webhook and the email; the log file gets all of it
+	jobTailBytes = 8000
+	// jobMarker tags the crontab lines the tool manages
+	jobMarker = "# " + AppName + " job "
+)
+
+// ParseJobChannels parses a comma-separated list of delivery channels
+func ParseJobChannels(value string) ([]string, error) {
+	var channels []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "", "none", "off":
+		case JobFile, JobDesktop, JobWebhook, JobEmail:
+			channels = append(channels, name)
+		case "mail", "smtp":
+			channels = append(channels, JobEmail)
+		case "notify", "notification":
+			channels = append(channels, JobDesktop)
+		default:
+			return nil, fmt.Errorf("unknown channel %q (expected file, desktop, webhook or email)", name)
+		}
+	}
+	return channels, nil
+}
+
+// JobResult is one run of a job
+type JobResult struct {
+	Name     string
+	Command  string
+	Host     string
+	Start    time.Time
+	Duration time.Duration
+	ExitCode int
+	Output   string
+	// Summary is the model's one-line account of the run; it falls back to
+	// the exit status when the model can't be reached
+	Summary string
+}
+
+// shellCommand runs command with shell the way a terminal would
+func shellCommand(shell, command string) *exec.Cmd {
+	switch name := strings.TrimSuffix(strings.ToLower(filepath.Base(shell)), ".exe"); name {
+	case "cmd":
+		return exec.Command(shell, "/c", command)
+	case "powershell", "pwsh":
+		return exec.Command(shell, "-NoProfile", "-Command", command)
+	}
+	return exec.Command(shell, "-c", command)
+}
+
+// RunJob runs a job's command to completion, capturing its output
+func RunJob(config Config, name, command string) JobResult {
+	result := JobResult{Name: name, Command: command, Start: time.Now()}
+	result.Host, _ = os.Hostname()
+
+	cmd := shellCommand(config.Shell, command)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	err := cmd.Run()
+	result.Duration = time.Since(result.Start).Round(time.Second)
+	result.Output = output.String()
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		// The shell itself couldn't be started
+		result.ExitCode = 127
+		result.Output += err.Error() + "\n"
+	}
+	return result
+}
+
+// tail returns the end of a job's output, cut at a line break
+func (r JobResult) tail() string {
+	if len(r.Output) <= jobTailBytes {
+		return r.Output
+	}
+	out := r.Output[len(r.Output)-jobTailBytes:]
+	if i := strings.IndexByte(out, '\n'); i >= 0 {
+		out = out[i+1:]
+	}
+	return "... (earlier output cut)\n" + out
+}
+
+// status is the exit status in words
+func (r JobResult) status() string {
+	if r.ExitCode == 0 {
+		return "ok"
+	}
+	return fmt.Sprintf("FAILED (exit %d)", r.ExitCode)
+}
+
+// Subject is the one line a job's result is announced with: its status,
+// name and summary
+func (r JobResult) Subject() string {
+	summary := r.Summary
+	if summary == "" {
+		summary = fmt.Sprintf("finished in %s", r.Duration)
+	}
+	return fmt.Sprintf("[%s] %s: %s", r.status(), r.Name, summary)
+}
+
+// Report is the full account of a run, for the log file and the email body
+func (r JobResult) Report(output string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job:      %s\n", r.Name)
+	fmt.Fprintf(&b, "Command:  %s\n", r.Command)
+	fmt.Fprintf(&b, "Host:     %s\n", r.Host)
+	fmt.Fprintf(&b, "Started:  %s\n", r.Start.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Duration: %s\n", r.Duration)
+	fmt.Fprintf(&b, "Exit:     %d\n\n", r.ExitCode)
+	if strings.TrimSpace(output) == "" {
+		b.WriteString("(no output)\n")
+	} else {
+		b.WriteString(strings.TrimRight(output, "\n") + "\n")
+	}
+	return b.String()
+}
+
+// SummarizeJob asks the model for a one-line summary of a run
+func SummarizeJob(config Config, r JobResult) (string, error) {
+	contents, err := chatCompletion(config, ai.Request{
+		Messages: []ai.Message{
+			{Role: "system", Content: "You summarise the output of scheduled jobs for a notification subject line. " +
+				"Respond with ONE line of at most 80 characters saying what happened: what was done, counts or sizes that matter, " +
+				"and for failures the error that caused it. No status prefix, no quotes, no markdown."},
+			{Role: "user", Content: redactText(config, fmt.Sprintf("Command: %s\nExit code: %d\nDuration: %s\n\nOutput:\n%s",
+				r.Command, r.ExitCode, r.Duration, r.tail()))},
+		},
+		Temperature: 0.2,
+		MaxTokens:   60,
+	})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(strings.SplitN(strings.TrimSpace(contents[0]), "\n", 2)[0])
+	return strings.Trim(summary, "\"'`"), nil
+}
+
+// GetJobsDir returns the directory job logs are kept in
+func GetJobsDir() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), "jobs")
+}
+
+// deliverFile appends the run to the job's log file
+func deliverFile(config Config, r JobResult) error {
+	dir := GetJobsDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, r.Name+".log"), appendFlags, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return appendLocked(file, []byte("=== "+r.Subject()+"\n"+r.Report(r.Output)+"\n"))
+}
+
+// deliverDesktop shows the subject as a desktop notification
+func deliverDesktop(r JobResult) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", r.Subject(), AppName))
+	case "windows":
+		cmd = exec.Command("msg", "*", r.Subject())
+	default:
+		urgency := "normal"
+		if r.ExitCode != 0 {
+			urgency = "critical"
+		}
+		cmd = exec.Command("notify-send", "-u", urgency, "-a", AppName, r.Name, r.Subject())
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", cmd.Args[0], valueOrDefault(strings.TrimSpace(string(out)), err.Error()))
+	}
+	return nil
+}
+
+// redacted is the run with secrets redacted from everything that leaves
+// the machine or lands in a log: the command, the summary and the output
+func (r JobResult) redacted(config Config) JobResult {
+	r.Command = redactText(config, r.Command)
+	r.Summary = redactText(config, r.Summary)
+	r.Output = redactText(config, r.Output)
+	return r
+}
+
+// DeliverJob sends the run through every configured channel, trying them
+// all even when one fails. Channels get it with secrets redacted.
+func DeliverJob(config Config, r JobResult) error {
+	r = r.redacted(config)
+	var errs []error
+	for _, channel := range config.JobChannels {
+		var err error
+		switch channel {
+		case JobFile:
+			err = deliverFile(config, r)
+		case JobDesktop:
+			err = deliverDesktop(r)
+		case JobWebhook:
+			err = deliverWebhook(config, r)
+		case JobEmail:
+			err = deliverEmail(config, r)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// crontabEntry is the line that runs a job on schedule. Its own output is
+// dropped, so cron has nothing to mail: the tool delivers it instead.
+func crontabEntry(schedule, name, command string) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	line := fmt.Sprintf("%s %s job run %s -- %s >/dev/null 2>&1", schedule, shellQuote(self), name, shellQuote(command))
+	// cron turns unescaped % into newlines
+	return strings.ReplaceAll(line, "%", `\%`) + " " + jobMarker + name, nil
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// readCrontab returns the user's crontab, which is empty when there is none
+func readCrontab() (string, error) {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return "", errors.New("crontab is not installed; on Windows create a Task Scheduler task that runs '" + AppName + " job run NAME -- COMMAND'")
+	}
+	out, err := exec.Command("crontab", "-l").CombinedOutput()
+	if err != nil {
+		if strings.Contains(strings.ToLower(string(out)), "no crontab") {
+			return "", nil
+		}
+		return "", fmt.Errorf("crontab -l: %s", strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// writeCrontab replaces the user's crontab
+func writeCrontab(content string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(content)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("crontab: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// scheduledJobs finds the jobs in a crontab, by name
+func scheduledJobs(crontab string) map[string]string {
+	jobs := make(map[string]string)
+	for _, line := range strings.Split(crontab, "\n") {
+		if i := strings.LastIndex(line, jobMarker); i >= 0 {
+			jobs[strings.TrimSpace(line[i+len(jobMarker):])] = line
+		}
+	}
+	return jobs
+}
+
+// withoutJob drops a job's line from a crontab
+func withoutJob(crontab, name string) string {
+	var kept []string
+	for _, line := range strings.Split(strings.TrimRight(crontab, "\n"), "\n") {
+		if i := strings.LastIndex(line, jobMarker); i >= 0 && strings.TrimSpace(line[i+len(jobMarker):]) == name {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimLeft(strings.Join(kept, "\n")+"\n", "\n")
+}
+
+// handleJobCommand handles the job subcommands
+func handleJobCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui job run NAME -- COMMAND")
+		fmt.Println("       ai-terminal-tui job schedule \"CRON SCHEDULE\" NAME -- COMMAND")
+		fmt.Println("       ai-terminal-tui job list")
+		fmt.Println("       ai-terminal-tui job remove NAME")
+		os.Exit(1)
+	}
+	fail := func(err error) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// nameAndCommand reads NAME -- COMMAND
+	nameAndCommand := func(args []string) (string, string) {
+		if len(args) < 3 || args[1] != "--" {
+			usage()
+		}
+		if ValidateSessionName(args[0]) != nil {
+			fail(fmt.Errorf("invalid job name %q: use letters, digits, '.', '_' and '-' (up to 64 characters)", args[0]))
+		}
+		return args[0], strings.Join(args[2:], " ")
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	switch args[0] {
+	case "run":
+		name, command := nameAndCommand(args[1:])
+		config := mustLoadConfig()
+		refuseInSuggestOnly(config, "job run")
+		result := RunJob(config, name, command)
+		if config.LiteLLMURL != "" {
+			// Without a summary the subject still says how it went
+			result.Summary, _ = SummarizeJob(config, result)
+		}
+		if err := DeliverJob(config, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error delivering %s: %v\n", name, err)
+		}
+		if IsTTY() {
+			fmt.Println(result.Subject())
+		}
+		os.Exit(result.ExitCode)
+
+	case "schedule":
+		if len(args) < 2 {
+			usage()
+		}
+		schedule := args[1]
+		if fields := strings.Fields(schedule); len(fields) != 5 && !(len(fields) == 1 && strings.HasPrefix(schedule, "@")) {
+			fail(fmt.Errorf("invalid schedule %q: expected five cron fields, like \"30 2 * * *\", or @daily, @hourly, ...", schedule))
+		}
+		name, command := nameAndCommand(args[2:])
+		refuseInSuggestOnly(mustLoadConfig(), "job schedule")
+		crontab, err := readCrontab()
+		if err != nil {
+			fail(err)
+		}
+		entry, err := crontabEntry(schedule, name, command)
+		if err != nil {
+			fail(err)
+		}
+		_, replacing := scheduledJobs(crontab)[name]
+		if err := writeCrontab(withoutJob(crontab, name) + entry + "\n"); err != nil {
+			fail(err)
+		}
+		if replacing {
+			fmt.Printf("Rescheduled %s: %s\n", name, schedule)
+		} else {
+			fmt.Printf("Scheduled %s: %s\n", name, schedule)
+		}
+		config := mustLoadConfig()
+		fmt.Printf("Results go to: %s\n", valueOrDefault(strings.Join(config.JobChannels, ", "), "nowhere (set job_channels)"))
+
+	case "list":
+		crontab, err := readCrontab()
+		if err != nil {
+			fail(err)
+		}
+		jobs := scheduledJobs(crontab)
+		if len(jobs) == 0 {
+			fmt.Println("No scheduled jobs. Add one with: ai-terminal-tui job schedule \"30 2 * * *\" NAME -- COMMAND")
+			return
+		}
+		for _, line := range strings.Split(crontab, "\n") {
+			if i := strings.LastIndex(line, jobMarker); i >= 0 {
+				fmt.Printf("%s\n    %s\n", strings.TrimSpace(line[i+len(jobMarker):]), strings.TrimSpace(line[:i]))
+			}
+		}
+		fmt.Printf("\nLogs: %s\n", GetJobsDir())
+
+	case "remove":
+		if len(args) != 2 {
+			usage()
+		}
+		crontab, err := readCrontab()
+		if err != nil {
+			fail(err)
+		}
+		if _, ok := scheduledJobs(crontab)[args[1]]; !ok {
+			fail(fmt.Errorf("no scheduled job named %q (see ai-terminal-tui job list)", args[1]))
+		}
+		if err := writeCrontab(withoutJob(crontab, args[1])); err != nil {
+			fail(err)
+		}
+		fmt.Printf("Removed %s\n", args[1])
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,20 @@
+//go:build minimal
+
+package main
+
+import "net/url"
+
+// deliverWebhook can't post in a minimal build
+func deliverWebhook(config Config, r JobResult) error {
+	return leftOut("delivering jobs by webhook")
+}
+
+// ParseSMTPURL refuses every smtp_url in a minimal build, which can't mail
+func ParseSMTPURL(value string) (*url.URL, error) {
+	return nil, leftOut("delivering jobs by email")
+}
+
+// deliverEmail can't mail in a minimal build
+func deliverEmail(config Config, r JobResult) error {
+	return leftOut("delivering jobs by email")
+}
@@ -0,0 +1,55 @@
+//go:build !minimal
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliverJobRedacts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	config := defaultConfig()
+	config.JobChannels = []string{JobFile, JobWebhook}
+	config.JobWebhook = server.URL
+	r := JobResult{
+		Name:     "backup",
+		Command:  "API_TOKEN=hunter2hunter2 ./backup.sh",
+		Host:     "db1",
+		Start:    time.Now(),
+		Duration: time.Second,
+		Summary:  "used password=correcthorse to log in",
+		Output:   "Authorization: Bearer abcdefghijklmnop\nok",
+	}
+	if err := DeliverJob(config, r); err != nil {
+		t.Fatal(err)
+	}
+	log, err := os.ReadFile(filepath.Join(GetJobsDir(), "backup.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for channel, sent := range map[string]string{"webhook": body, "file": string(log)} {
+		for _, secret := range []string{"hunter2hunter2", "correcthorse", "abcdefghijklmnop"} {
+			if strings.Contains(sent, secret) {
+				t.Errorf("%s got %q unredacted:\n%s", channel, secret, sent)
+			}
+		}
+		if !strings.Contains(sent, "./backup.sh") {
+			t.Errorf("%s lost the command:\n%s", channel, sent)
+		}
+	}
+}
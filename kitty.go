@@ -0,0 +1,259 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Kitty keyboard protocol settings
+const (
+	KittyKeyboardAuto = "auto"
+	KittyKeyboardOn   = "on"
+	KittyKeyboardOff  = "off"
+)
+
+// Escape sequences that push/pop the "disambiguate escape codes" flag
+const (
+	kittyKeyboardPush = "\x1b[>1u"
+	kittyKeyboardPop  = "\x1b[<u"
+)
+
+// Kitty modifier bits, encoded in sequences as 1 + bitmask
+const (
+	kittyShift = 1 << iota
+	kittyAlt
+	kittyCtrl
+	kittySuper
+)
+
+var (
+	// kittyKeyRe matches CSI code[:alternates] [; mods[:event] [; text]] u
+	kittyKeyRe = regexp.MustCompile(`^\x1b\[(\d+)(?::\d*)*(?:;(\d+)(?::\d+)?)?(?:;[\d:]*)?u`)
+	// kittyPartialRe matches a CSI u sequence cut off at the end of a read
+	kittyPartialRe = regexp.MustCompile(`\x1b\[\d[\d;:]*$`)
+	// innerKittyRe matches an inner application pushing or popping flags
+	innerKittyRe = regexp.MustCompile(`\x1b\[([><])\d*u`)
+)
+
+// kittyKeyMsg is a key chord that only the kitty keyboard protocol can
+// express, such as Ctrl+Shift+K or Ctrl+I as distinct from Tab
+type kittyKeyMsg struct {
+	Code rune
+	Mods int
+	Raw  []byte
+}
+
+// String names the chord like tea.KeyMsg does, e.g. "ctrl+shift+k"
+func (k kittyKeyMsg) String() string {
+	var parts []string
+	if k.Mods&kittyCtrl != 0 {
+		parts = append(parts, "ctrl")
+	}
+	if k.Mods&kittyAlt != 0 {
+		parts = append(parts, "alt")
+	}
+	if k.Mods&kittySuper != 0 {
+		parts = append(parts, "super")
+	}
+	if k.Mods&kittyShift != 0 {
+		parts = append(parts, "shift")
+	}
+
+	switch k.Code {
+	case 9:
+		parts = append(parts, "tab")
+	case 13:
+		parts = append(parts, "enter")
+	case 27:
+		parts = append(parts, "esc")
+	case 32:
+		parts = append(parts, "space")
+	case 127:
+		parts = append(parts, "backspace")
+	default:
+		parts = append(parts, string(k.Code))
+	}
+	return strings.Join(parts, "+")
+}
+
+// legacy returns the closest traditional encoding of the chord for inner
+// applications that have not enabled the protocol themselves
+func (k kittyKeyMsg) legacy() []byte {
+	var b []byte
+	if k.Mods&kittyAlt != 0 {
+		b = append(b, 0x1b)
+	}
+	switch {
+	case k.Mods&kittyCtrl != 0 && k.Code >= 'a' && k.Code <= 'z':
+		return append(b, byte(k.Code)&0x1f)
+	case k.Code < 128:
+		return append(b, byte(k.Code))
+	}
+	return append(b, []byte(string(k.Code))...)
+}
+
+// useKittyKeyboard decides whether to negotiate the protocol
+func useKittyKeyboard(setting string, caps Capabilities) bool {
+	switch setting {
+	case KittyKeyboardOn:
+		return true
+	case KittyKeyboardOff:
+		return false
+	}
+	return caps.KittyKeyboard.Supported
+}
+
+// kittyInputReader wraps the terminal input. CSI u sequences that have an
+// unambiguous legacy form are rewritten into it so Bubble Tea parses them
+// as usual; the rest are delivered as kittyKeyMsg through send. It exposes
+// Fd so Bubble Tea still puts the terminal into raw mode, but not Name, so
+// reads go through Read rather than straight to the file descriptor.
+type kittyInputReader struct {
+	file    *os.File
+	send    func(tea.Msg)
+	buf     []byte
+	out     []byte
+	partial []byte
+}
+
+// newKittyInputReader wraps file; send must be set before the program runs
+func newKittyInputReader(file *os.File) *kittyInputReader {
+	return &kittyInputReader{file: file, buf: make([]byte, 4096)}
+}
+
+func (r *kittyInputReader) Fd() uintptr                 { return r.file.Fd() }
+func (r *kittyInputReader) Write(p []byte) (int, error) { return r.file.Write(p) }
+func (r *kittyInputReader) Close() error                { return r.file.Close() }
+
+func (r *kittyInputReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		n, err := r.file.Read(r.buf)
+		if n > 0 {
+			data := append(r.partial, r.buf[:n]...)
+			r.partial = nil
+			r.out = r.translate(data)
+		}
+		if err != nil {
+			if len(r.out) > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// translate rewrites CSI u sequences in data, holding back a trailing
+// incomplete sequence until the next read
+func (r *kittyInputReader) translate(data []byte) []byte {
+	if loc := kittyPartialRe.FindIndex(data); loc != nil {
+		r.partial = append([]byte(nil), data[loc[0]:]...)
+		data = data[:loc[0]]
+	}
+
+	var out []byte
+	for i := 0; i < len(data); {
+		m := kittyKeyRe.FindSubmatchIndex(data[i:])
+		if data[i] != 0x1b || m == nil {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+
+		raw := data[i : i+m[1]]
+		code, _ := strconv.Atoi(string(data[i+m[2] : i+m[3]]))
+		mods := 0
+		if m[4] >= 0 {
+			value, _ := strconv.Atoi(string(data[i+m[4] : i+m[5]]))
+			mods = value - 1
+		}
+		i += m[1]
+
+		key := kittyKeyMsg{Code: rune(code), Mods: mods, Raw: append([]byte(nil), raw...)}
+		if legacy, ok := legacyEquivalent(key); ok {
+			out = append(out, legacy...)
+		} else if r.send != nil {
+			r.send(key)
+		}
+	}
+	return out
+}
+
+// legacyEquivalent reports the traditional bytes for chords that Bubble Tea
+// already understands unambiguously
+func legacyEquivalent(k kittyKeyMsg) ([]byte, bool) {
+	mods := k.Mods &^ kittyAlt
+	prefix := []byte{}
+	if k.Mods&kittyAlt != 0 {
+		prefix = append(prefix, 0x1b)
+	}
+
+	switch {
+	case mods == 0 && (k.Code == 27 || k.Code == 13 || k.Code == 9 || k.Code == 127):
+		return append(prefix, byte(k.Code)), true
+	case mods == 0 && k.Code >= 32 && k.Code < 127:
+		return append(prefix, byte(k.Code)), true
+	case mods == kittyShift && k.Code >= 'a' && k.Code <= 'z':
+		return append(prefix, byte(k.Code)-32), true
+	case mods == kittyCtrl && k.Code >= 'a' && k.Code <= 'z':
+		// Ctrl+I, Ctrl+M and Ctrl+H collide with Tab, Enter and Backspace
+		if k.Code == 'i' || k.Code == 'm' || k.Code == 'h' {
+			return nil, false
+		}
+		return append(prefix, byte(k.Code)&0x1f), true
+	}
+	return nil, false
+}
+
+// trackInnerKitty follows an inner application enabling or disabling the
+// protocol so its chords can be passed through unmodified
+func (m *Model) trackInnerKitty(output []byte) {
+	for _, match := range innerKittyRe.FindAllSubmatch(output, -1) {
+		if string(match[1]) == ">" {
+			m.innerKitty++
+		} else if m.innerKitty > 0 {
+			m.innerKitty--
+		}
+	}
+}
+
+// handleKittyKey runs bindings for protocol-only chords and forwards the
+// rest to the shell
+func (m Model) handleKittyKey(msg kittyKeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+shift+k":
+		// Alias for Ctrl+K
+		return m.Update(tea.KeyMsg{Type: tea.KeyCtrlK})
+	case "ctrl+shift+s":
+		if !m.showPrompt && m.selection == nil {
+			countFeature("selection")
+			m.selection = newSelection(m.output)
+		}
+		return m, nil
+	}
+
+	if m.showPrompt || m.selection != nil || m.pty == nil {
+		return m, nil
+	}
+	if m.innerKitty > 0 {
+		m.pty.Write(msg.Raw)
+	} else {
+		m.pty.Write(msg.legacy())
+	}
+	m.typed.known = false
+	return m, m.scheduleSuggestion()
+}
+
+// enableKittyKeyboard pushes the protocol flags once the TUI is on the
+// alternate screen; Cleanup pops them again on exit
+func enableKittyKeyboard() tea.Msg {
+	os.Stdout.WriteString(kittyKeyboardPush)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// defaultProductionContexts are the kubectl contexts treated as production
+// unless production_contexts says otherwise
+var defaultProductionContexts = []string{"*prod*"}
+
+var (
+	// kubectlRe finds kubectl invocations in a command line
+	kubectlRe = regexp.MustCompile(`(?:^|[\s;&|(])kubectl\s`)
+	// kubeContextFlagRe and kubeNamespaceFlagRe find explicit targets
+	kubeContextFlagRe   = regexp.MustCompile(`--context[= ](\S+)`)
+	kubeNamespaceFlagRe = regexp.MustCompile(`(?:-n|--namespace)[= ](\S+)`)
+)
+
+// KubeTarget is the cluster context and namespace kubectl acts on
+type KubeTarget struct {
+	Context   string
+	Namespace string
+}
+
+// String names the target as context/namespace
+func (t KubeTarget) String() string {
+	return t.Context + "/" + t.Namespace
+}
+
+// CurrentKubeTarget returns kubectl's current context and namespace, or
+// nil when kubectl is missing or has no context
+func CurrentKubeTarget() *KubeTarget {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return nil
+	}
+	out := runDomainTool("", "kubectl", "config", "view", "--minify", "-o",
+		`jsonpath={.contexts[0].name}{"\t"}{.contexts[0].context.namespace}`)
+	name, namespace, _ := strings.Cut(out, "\t")
+	if name == "" {
+		return nil
+	}
+	return &KubeTarget{Context: name, Namespace: valueOrDefault(namespace, "default")}
+}
+
+// KubeCommandTarget returns the target a command's kubectl invocations hit,
+// taking --context and --namespace into account, or nil when the command
+// does not run kubectl
+func KubeCommandTarget(command string, current *KubeTarget) *KubeTarget {
+	if !kubectlRe.MatchString(command) {
+		return nil
+	}
+	target := KubeTarget{Context: "(no context)", Namespace: "default"}
+	if current != nil {
+		target = *current
+	}
+	if match := kubeContextFlagRe.FindStringSubmatch(command); match != nil {
+		target.Context = strings.Trim(match[1], `"'`)
+	}
+	if match := kubeNamespaceFlagRe.FindStringSubmatch(command); match != nil {
+		target.Namespace = strings.Trim(match[1], `"'`)
+	}
+	return &target
+}
+
+// IsProductionContext reports whether context matches one of the
+// production patterns, which are shell globs such as *prod*
+func IsProductionContext(context string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, context); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseGlobList parses a comma-separated list of glob patterns
+func ParseGlobList(value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// renderKubeBadge labels a command with the cluster it will hit, in red
+// for production
+func renderKubeBadge(target *KubeTarget, production bool) string {
+	style := lipgloss.NewStyle().Foreground(theme.BadgeText).Background(theme.Info).Padding(0, 1)
+	label := "kubectl → " + target.String()
+	if production {
+		style = style.Background(theme.Error)
+		label += " (PRODUCTION)"
+	}
+	return style.Render(label)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Below this size nothing useful fits, so a placeholder is shown instead
+const (
+	minWindowWidth  = 20
+	minWindowHeight = 5
+)
+
+// Below this size the compact layout is used: no status bars, tighter
+// overlays that may take most of the screen
+const (
+	compactWidth  = 60
+	compactHeight = 16
+)
+
+// tooSmall reports whether the window is below the minimum usable size
+func (m Model) tooSmall() bool {
+	return m.width < minWindowWidth || m.height < minWindowHeight
+}
+
+// compact reports whether to use the compact layout
+func (m Model) compact() bool {
+	return m.width < compactWidth || m.height < compactHeight
+}
+
+// overlayHeight is how many rows an overlay may take from the terminal
+func (m Model) overlayHeight() int {
+	if m.compact() {
+		return m.height - 1
+	}
+	return m.height * 2 / 3
+}
+
+// terminalSize is the size of the terminal area the shell draws in, inside
+// its padding, as the shell is told
+func (m Model) terminalSize() (int, int) {
+	return max(1, m.width-4), max(1, m.height-3)
+}
+
+// renderTooSmall is shown instead of the UI when the window is too small
+func (m Model) renderTooSmall() string {
+	text := lipgloss.NewStyle().
+		Foreground(theme.Warning).
+		Width(m.width).
+		MaxHeight(m.height).
+		Align(lipgloss.Center).
+		Render(fmt.Sprintf("Window too small (%dx%d), need %dx%d", m.width, m.height, minWindowWidth, minWindowHeight))
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, text)
+}
+
+// fitRows keeps the last lines of terminal output that fit in rows once
+// wrapped at width, so the line with the shell's cursor stays in view
+// however much room the prompt takes
+func fitRows(lines []string, width, rows int) []string {
+	width = max(width, 1)
+	used := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		used += max(1, (ansi.StringWidth(lines[i])+width-1)/width)
+		if used > rows {
+			if i == len(lines)-1 {
+				// A single line taller than the area: show its end
+				return []string{ansi.Cut(lines[i], ansi.StringWidth(lines[i])-rows*width, ansi.StringWidth(lines[i]))}
+			}
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+// fitHeight clips a bordered box to maxHeight rows, dropping lines from the
+// bottom of its content but keeping the bottom border
+func fitHeight(box string, maxHeight int) string {
+	lines := strings.Split(box, "\n")
+	if len(lines) <= maxHeight || maxHeight < 2 {
+		return box
+	}
+	return strings.Join(append(lines[:maxHeight-1], lines[len(lines)-1]), "\n")
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/term"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/session"
+)
+
+// Values of the line_mode config key
+const (
+	LineModeAuto = "auto" // on dumb terminals and serial consoles
+	LineModeOn   = "on"
+	LineModeOff  = "off"
+)
+
+// linePrefix starts a line that goes to the AI rather than the shell
+const linePrefix = ":ai"
+
+// serialTTYRe matches the device names of serial consoles: UARTs, USB
+// serial adapters and hypervisor consoles
+var serialTTYRe = regexp.MustCompile(`^/dev/(?:ttyS|ttyUSB|ttyACM|ttyAMA|ttymxc|ttyO|hvc|ttysclp)\d*$`)
+
+// useLineMode reports whether to run in line mode: as line_mode says, or
+// on auto when TERM is dumb or unset, or stdin is a serial console
+func useLineMode(setting string) bool {
+	switch setting {
+	case LineModeOn:
+		return true
+	case LineModeOff:
+		return false
+	}
+	if termName := os.Getenv("TERM"); termName == "" || termName == "dumb" {
+		// Windows consoles don't set TERM
+		return runtime.GOOS != "windows"
+	}
+	tty, err := os.Readlink("/proc/self/fd/0")
+	return err == nil && serialTTYRe.MatchString(tty)
+}
+
+// lineSession is the shell run in line mode: its output goes to the
+// terminal with escape sequences stripped, what is typed goes to it, and
+// lines starting with :ai go to the AI instead
+type lineSession struct {
+	config  Config
+	pty     *session.PTY
+	in      *bufio.Reader
+	session string
+
+	// raw is set when the terminal is in raw mode and the shell's terminal
+	// driver echoes; otherwise the terminal echoes lines itself
+	raw bool
+	mu  sync.Mutex
+}
+
+// RunLineMode runs the shell in line mode, for dumb terminals and serial
+// consoles: no alternate screen and no cursor addressing, just the
+// shell's lines with the AI a prefixed command among them
+func RunLineMode(config Config) error {
+	pty, err := session.NewLinePTY(config.Shell)
+	if err != nil {
+		return fmt.Errorf("failed to start shell %s: %w", config.Shell, err)
+	}
+	defer pty.Close()
+	if width, height, err := term.GetSize(int(os.Stdin.Fd())); err == nil && width > 0 && height > 0 {
+		pty.Resize(width, height)
+	} else {
+		pty.Resize(80, 24)
+	}
+
+	s := &lineSession{
+		config:  config,
+		pty:     pty,
+		in:      bufio.NewReader(os.Stdin),
+		session: newSessionID(time.Now()),
+	}
+	// Where the shell has a terminal driver, keys go to it as they are
+	// typed, so it echoes them, hides passwords and turns Ctrl+C into an
+	// interrupt
+	if runtime.GOOS != "windows" {
+		if state, err := term.MakeRaw(int(os.Stdin.Fd())); err == nil {
+			defer term.Restore(int(os.Stdin.Fd()), state)
+			s.raw = true
+		}
+	}
+	if !s.raw {
+		// Ctrl+C interrupts the shell's command, not this program
+		signal.Ignore(os.Interrupt)
+	}
+
+	s.print("%s line mode. Type %s and a request to have a command suggested, or %s alone for help.\n", AppName, linePrefix, linePrefix)
+	done := make(chan struct{})
+	go s.copyOutput(done)
+	go s.readInput()
+	<-done
+	return nil
+}
+
+// print writes a message of the program's own between the shell's output
+func (s *lineSession) print(format string, args ...any) {
+	text := fmt.Sprintf(format, args...)
+	if s.raw {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	os.Stdout.WriteString(text)
+}
+
+// copyOutput copies the shell's output to the terminal without escape
+// sequences, until the shell exits
+func (s *lineSession) copyOutput(done chan<- struct{}) {
+	defer close(done)
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			s.mu.Lock()
+			os.Stdout.WriteString(ansi.Strip(string(buf[:n])))
+			s.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readInput sends what is typed to the shell, holding back lines that
+// start with :ai for the AI
+func (s *lineSession) readInput() {
+	if !s.raw {
+		for {
+			line, err := s.in.ReadString('\n')
+			if text := strings.TrimSpace(line); isLineRequest(text) {
+				s.request(strings.TrimSpace(text[len(linePrefix):]))
+			} else if line != "" {
+				s.pty.Write([]byte(line))
+			}
+			if err != nil {
+				s.pty.Write([]byte("exit\n"))
+				return
+			}
+		}
+	}
+
+	// In raw mode a line is held while it could still become :ai, and
+	// echoed here since the shell hasn't seen it
+	var held []byte
+	fresh := true
+	for {
+		c, err := s.in.ReadByte()
+		if err != nil {
+			return
+		}
+		if held == nil {
+			if c == ':' && fresh && !s.busy() {
+				held = []byte{c}
+				s.print(":")
+				continue
+			}
+			s.pty.Write([]byte{c})
+			fresh = c == '\r' || c == '\n' || c == 0x03 || c == 0x15
+			continue
+		}
+
+		switch c {
+		case '\r', '\n':
+			line := string(held)
+			held = nil
+			s.print("\n")
+			if isLineRequest(line) {
+				s.request(strings.TrimSpace(strings.TrimSpace(line)[len(linePrefix):]))
+			} else {
+				s.run(line + "\n")
+			}
+		case 0x7f, '\b':
+			s.print("\b \b")
+			if held = held[:len(held)-1]; len(held) == 0 {
+				held = nil
+			}
+		case 0x03, 0x15:
+			// Ctrl+C and Ctrl+U drop the line
+			s.print("%s", strings.Repeat("\b \b", len(held)))
+			held = nil
+		default:
+			held = append(held, c)
+			s.print("%s", string(c))
+			if !strings.HasPrefix(string(held), linePrefix) && !strings.HasPrefix(linePrefix, string(held)) ||
+				len(held) > len(linePrefix) && held[len(linePrefix)] != ' ' {
+				// Not for the AI after all: hand it to the shell, which
+				// echoes it again
+				s.print("%s", strings.Repeat("\b \b", len(held)))
+				s.pty.Write(held)
+				held, fresh = nil, false
+			}
+		}
+	}
+}
+
+// isLineRequest reports whether a line typed is for the AI: :ai alone or
+// followed by a request
+func isLineRequest(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == linePrefix || strings.HasPrefix(line, linePrefix+" ")
+}
+
+// busy reports whether a command holds the shell's foreground, whose input
+// lines are its own business
+func (s *lineSession) busy() bool {
+	busy, known := s.pty.Busy()
+	return known && busy
+}
+
+// readLine reads a line typed in answer to a question, echoing it in raw
+// mode
+func (s *lineSession) readLine() string {
+	if !s.raw {
+		line, _ := s.in.ReadString('\n')
+		return strings.TrimSpace(line)
+	}
+	var b []byte
+	for {
+		c, err := s.in.ReadByte()
+		switch {
+		case err != nil, c == 0x03:
+			s.print("\n")
+			return ""
+		case c == '\r' || c == '\n':
+			s.print("\n")
+			return strings.TrimSpace(string(b))
+		case c == 0x7f || c == '\b':
+			if len(b) > 0 {
+				b = b[:len(b)-1]
+				s.print("\b \b")
+			}
+		default:
+			b = append(b, c)
+			s.print("%s", string(c))
+		}
+	}
+}
+
+// request generates a command for query and, once confirmed, runs it in
+// the shell, with the checks the review makes shown as warnings
+func (s *lineSession) request(query string) {
+	if command := s.suggest(query); command != "" {
+		s.run(command + "\n")
+	} else {
+		// A fresh prompt, as the shell didn't see the line
+		s.run("\n")
+	}
+}
+
+// run types text into the shell, with the Enter the terminal driver expects
+func (s *lineSession) run(text string) {
+	if s.raw {
+		text = strings.ReplaceAll(text, "\n", "\r")
+	}
+	s.pty.Write([]byte(text))
+}
+
+// suggest generates a command for query and returns it once confirmed, or
+// "" when it isn't to run
+func (s *lineSession) suggest(query string) string {
+	if query == "" || query == "help" {
+		s.print("%s REQUEST   suggest a command for REQUEST, e.g. %s find files over 1G here\n", linePrefix, linePrefix)
+		s.print("Suggestions are checked like in the full UI, then run once you answer y.\n")
+		return ""
+	}
+	if ReloadPolicy() {
+		var err error
+		if s.config, err = reapplyPolicy(s.config); err != nil {
+			s.print("Warning: %v\nCommands are only suggested until it loads.\n", err)
+		}
+	}
+	if s.config.LiteLLMURL == "" {
+		s.print("Error: litellm_url not configured. Run 'ai-terminal-tui setup' first.\n")
+		return ""
+	}
+
+	s.print("Generating...\n")
+	cwd := s.pty.WorkingDir()
+	ctx := GatherPromptContext(s.config, cwd)
+	ctx.GatherDomain(s.config)
+	ctx.GatherSystemd(s.config, query)
+	ctx.GatherArchive(s.config, query)
+	command, err := GenerateCommand(s.config, query, ctx)
+	if err != nil {
+		s.print("Error: %v\n", err)
+		return ""
+	}
+	if isScript(command) {
+		// A script is never typed into the shell line by line
+		s.print("The answer is a script, which line mode only shows:\n%s\n", command)
+		return ""
+	}
+
+	command = FixPathQuoting(command, ContextPaths(cwd, nil), s.config.Shell)
+	if names := Placeholders(command); len(names) > 0 {
+		s.print("  %s\n", command)
+		values := make(map[string]string)
+		for _, name := range names {
+			s.print("%s: ", name)
+			if values[name] = s.readLine(); values[name] == "" {
+				s.print("Not run.\n")
+				return ""
+			}
+		}
+		command = FillPlaceholders(command, values, s.config.Shell)
+	}
+	s.print("  %s\n", command)
+	var kube *KubeTarget
+	production := false
+	if kubectlRe.MatchString(command) {
+		kube = KubeCommandTarget(command, CurrentKubeTarget())
+		production = IsProductionContext(kube.Context, s.config.ProductionContexts)
+		if production {
+			s.print("  Warning: kubectl will act on PRODUCTION context %s\n", kube)
+		} else {
+			s.print("  kubectl will act on %s\n", kube)
+		}
+	}
+	risk, elevation := AssessRisk(command), DetectElevation(command)
+	warnings := CheckPortability(command, DetectUserland())
+	warnings = append(warnings, CheckQuoting(command, s.config.Shell)...)
+	if risk.Level > RiskNone {
+		warnings = append(warnings, risk.String())
+	}
+	if elevation.Elevated() {
+		warnings = append(warnings, elevation.String())
+	}
+	protected := ProtectedPaths(command, cwd, s.config.ProtectedPaths)
+	if len(protected) > 0 {
+		warnings = append(warnings, "touches protected paths "+strings.Join(protected, ", "))
+	}
+	warnings = append(warnings, DateWarnings(command)...)
+	syntaxErr := error(nil)
+	if s.config.SyntaxCheck {
+		if syntaxErr = CheckSyntax(command, s.config.Shell); syntaxErr != nil {
+			warnings = append(warnings, "Syntax error: "+syntaxErr.Error())
+		}
+	}
+	var lint []LintIssue
+	if s.config.LintCommands {
+		lint = LintCommand(command, s.config.Shell)
+		for _, issue := range lint {
+			warnings = append(warnings, issue.String())
+		}
+	}
+	for _, warning := range warnings {
+		s.print("  Warning: %s\n", warning)
+	}
+
+	switch {
+	case s.config.SuggestOnlyMode():
+		s.print("Suggest-only mode: copy the command to run it.\n")
+		return ""
+	case risk.Level == RiskBlocked:
+		s.print("Blocked by policy; it won't run.\n")
+		return ""
+	case s.config.BlockElevated && elevation.Elevated():
+		s.print("block_elevated refuses commands that need root or Administrator rights.\n")
+		return ""
+	case len(protected) > 0:
+		s.print("protected_paths keeps generated commands from running against %s; copy it to run it yourself.\n", strings.Join(protected, ", "))
+		return ""
+	case syntaxErr != nil:
+		s.print("The shell can't parse it, so it won't run.\n")
+		return ""
+	}
+
+	confirmed := false
+	if risk.NeedsConfirmation(true) {
+		s.print("%s", risk.ConfirmationPrompt())
+		if !risk.Confirmed(s.readLine(), command) {
+			s.print("Not run.\n")
+			return ""
+		}
+		confirmed = true
+	}
+	// As with Ctrl+Y in the review, a production cluster or a command the
+	// linter found broken takes more than a y out of habit
+	if production {
+		s.print("This runs against production context %s. Type the context name to run it: ", kube.Context)
+		if s.readLine() != kube.Context {
+			s.print("Not run.\n")
+			return ""
+		}
+		confirmed = true
+	} else if lintErrors(lint) {
+		s.print("The linter found errors. Type yes to run it anyway: ")
+		if strings.ToLower(s.readLine()) != "yes" {
+			s.print("Not run.\n")
+			return ""
+		}
+		confirmed = true
+	}
+	if !confirmed && (s.config.ConfirmCommands || len(warnings) > 0) {
+		s.print("Run it? [y/N] ")
+		if answer := strings.ToLower(s.readLine()); answer != "y" && answer != "yes" {
+			s.print("Not run.\n")
+			return ""
+		}
+	}
+
+	if s.config.AuditLog {
+		AppendAudit(AuditEntry{
+			Time: time.Now(), Session: s.session, Query: query, Command: command,
+			Model: generationModel(s.config), Source: AuditRun, Cwd: cwd,
+		})
+	}
+	return command
+}
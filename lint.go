@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lintTimeout bounds one run of the linter, so a slow one can't hold up
+// the review
+const lintTimeout = 3 * time.Second
+
+// LintIssue is one finding of shellcheck or PSScriptAnalyzer
+type LintIssue struct {
+	Tool    string
+	Code    string
+	Message string
+	Column  int
+	// Error is set for findings that make the command fail or do something
+	// else than it appears to, which block running it
+	Error bool
+}
+
+// String describes the finding as a review warning
+func (i LintIssue) String() string {
+	level := "warning"
+	if i.Error {
+		level = "error"
+	}
+	return fmt.Sprintf("%s %s %s at column %d: %s", i.Tool, level, i.Code, i.Column, i.Message)
+}
+
+// shellcheckDialects maps shells to the dialect shellcheck checks them as.
+// shellcheck has no zsh mode; zsh is checked as bash, and its findings
+// only warn since zsh syntax can look wrong to it.
+var shellcheckDialects = map[string]string{
+	"sh": "sh", "dash": "dash", "ash": "sh", "bash": "bash", "ksh": "ksh", "mksh": "ksh", "zsh": "bash",
+}
+
+// lintCache keeps the last result, as the review lints again on every edit
+var lintCache struct {
+	sync.Mutex
+	key    string
+	issues []LintIssue
+}
+
+// LintCommand checks a command with the linter for the shell it runs in:
+// shellcheck for POSIX shells, PSScriptAnalyzer for PowerShell. Without the
+// linter installed, or for shells neither knows, there are no findings.
+func LintCommand(command, shell string) []LintIssue {
+	key := shell + "\x00" + command
+	lintCache.Lock()
+	if lintCache.key == key {
+		issues := lintCache.issues
+		lintCache.Unlock()
+		return issues
+	}
+	lintCache.Unlock()
+
+	var issues []LintIssue
+	switch name := shellName(shell); name {
+	case "powershell", "pwsh":
+		issues = scriptAnalyzer(command)
+	default:
+		if dialect, ok := shellcheckDialects[name]; ok {
+			issues = shellcheck(command, dialect)
+			if name == "zsh" {
+				for i := range issues {
+					issues[i].Error = false
+				}
+			}
+		}
+	}
+
+	lintCache.Lock()
+	lintCache.key, lintCache.issues = key, issues
+	lintCache.Unlock()
+	return issues
+}
+
+// shellcheckComment is one finding in shellcheck's json1 output
+type shellcheckComment struct {
+	Column  int    `json:"column"`
+	Level   string `json:"level"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// shellcheck runs shellcheck on a command, reporting warnings and errors
+func shellcheck(command, dialect string) []LintIssue {
+	path, err := exec.LookPath("shellcheck")
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lintTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "--format=json1", "--severity=warning", "--shell="+dialect, "-")
+	cmd.Stdin = strings.NewReader(command + "\n")
+	out, err := cmd.Output()
+	// shellcheck exits with 1 when it has findings
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil
+	}
+
+	var result struct {
+		Comments []shellcheckComment `json:"comments"`
+	}
+	if json.Unmarshal(out, &result) != nil {
+		return nil
+	}
+	var issues []LintIssue
+	for _, c := range result.Comments {
+		issues = append(issues, LintIssue{
+			Tool:    "shellcheck",
+			Code:    "SC" + strconv.Itoa(c.Code),
+			Message: c.Message,
+			Column:  c.Column,
+			Error:   c.Level == "error",
+		})
+	}
+	return issues
+}
+
+// scriptAnalyzerScript reads a command from stdin and prints what
+// PSScriptAnalyzer finds in it, one finding per line
+const scriptAnalyzerScript = `$ErrorActionPreference = 'Stop'
+Invoke-ScriptAnalyzer -ScriptDefinition ([Console]::In.ReadToEnd()) -Severity Warning,Error,ParseError |
+  ForEach-Object { '{0}|{1}|{2}|{3}' -f $_.Severity, $_.RuleName, $_.Column, ($_.Message -replace '\s+', ' ') }`
+
+// scriptAnalyzer runs PSScriptAnalyzer on a command, when pwsh or Windows
+// PowerShell has the module
+func scriptAnalyzer(command string) []LintIssue {
+	path, err := exec.LookPath("pwsh")
+	if err != nil {
+		if path, err = exec.LookPath("powershell"); err != nil {
+			return nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lintTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "-NoProfile", "-NonInteractive", "-Command", scriptAnalyzerScript)
+	cmd.Stdin = strings.NewReader(command)
+	// Without the module installed the script fails, and there is nothing
+	// to report
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var issues []LintIssue
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		fields := strings.SplitN(strings.TrimSpace(string(line)), "|", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		column, _ := strconv.Atoi(fields[2])
+		issues = append(issues, LintIssue{
+			Tool:    "PSScriptAnalyzer",
+			Code:    fields[1],
+			Message: fields[3],
+			Column:  column,
+			Error:   fields[0] == "Error" || fields[0] == "ParseError",
+		})
+	}
+	return issues
+}
+
+// checkDebounce is how long editing in the review must pause before the
+// command is linted and its syntax checked again
+const checkDebounce = 300 * time.Millisecond
+
+// checkTickMsg fires after the debounce delay; stale ticks are ignored
+type checkTickMsg struct {
+	seq int
+}
+
+// checkedMsg carries what the syntax check and the linter found in the
+// command under review at request time
+type checkedMsg struct {
+	seq         int
+	syntaxError string
+	lint        []LintIssue
+}
+
+// commandChecks runs the shell's syntax check and the linter on command,
+// as configured; neither runs for a remote shell, whose tools may differ
+func commandChecks(config Config, command string, remote bool) (syntaxError string, lint []LintIssue) {
+	if remote {
+		return "", nil
+	}
+	if config.SyntaxCheck {
+		if err := CheckSyntax(command, config.Shell); err != nil {
+			syntaxError = err.Error()
+		}
+	}
+	if config.LintCommands {
+		lint = LintCommand(command, config.Shell)
+	}
+	return syntaxError, lint
+}
+
+// checkWarnings are the review warnings for a syntax error and lint findings
+func checkWarnings(syntaxError string, lint []LintIssue) []string {
+	var warnings []string
+	if syntaxError != "" {
+		warnings = append(warnings, "Syntax error: "+syntaxError)
+	}
+	for _, issue := range lint {
+		warnings = append(warnings, issue.String())
+	}
+	return warnings
+}
+
+// scheduleChecks starts the debounce timer for checking the command under
+// review again
+func (m *Model) scheduleChecks() tea.Cmd {
+	m.checkSeq++
+	if (!m.config.SyntaxCheck && !m.config.LintCommands) || len(m.remotes) > 0 {
+		return nil
+	}
+	m.checkPending = true
+	seq := m.checkSeq
+	return tea.Tick(checkDebounce, func(time.Time) tea.Msg {
+		return checkTickMsg{seq: seq}
+	})
+}
+
+// runChecks checks the command under review in the background
+func (m Model) runChecks(seq int) tea.Cmd {
+	if seq != m.checkSeq || m.pending == "" {
+		return nil
+	}
+	config, command, remote := m.config, m.reviewInput.Value(), len(m.remotes) > 0
+	return func() tea.Msg {
+		syntaxError, lint := commandChecks(config, command, remote)
+		return checkedMsg{seq: seq, syntaxError: syntaxError, lint: lint}
+	}
+}
+
+// settleChecks checks the command under review now if its checks are
+// still to come, so an action never goes by those of an earlier edit
+func (m *Model) settleChecks() {
+	if !m.checkPending {
+		return
+	}
+	m.checkSeq++
+	m.applyChecks(commandChecks(m.config, m.reviewInput.Value(), len(m.remotes) > 0))
+}
+
+// applyChecks replaces the warnings of the last checks with those of new
+// ones, merged by message so a finding that stands isn't shown twice
+func (m *Model) applyChecks(syntaxError string, lint []LintIssue) {
+	stale := make(map[string]bool)
+	for _, warning := range checkWarnings(m.syntaxError, m.lint) {
+		stale[warning] = true
+	}
+	seen := make(map[string]bool)
+	var warnings []string
+	for _, warning := range m.warnings {
+		if !stale[warning] {
+			warnings = append(warnings, warning)
+			seen[warning] = true
+		}
+	}
+	for _, warning := range checkWarnings(syntaxError, lint) {
+		if !seen[warning] {
+			warnings = append(warnings, warning)
+			seen[warning] = true
+		}
+	}
+	m.warnings = warnings
+	m.syntaxError, m.lint = syntaxError, lint
+	m.checkPending = false
+}
+
+// lintBlocked reports whether the linter found errors in the command under
+// review, which runs only when overridden
+func (m Model) lintBlocked() bool {
+	return lintErrors(m.lint)
+}
+
+// lintErrors reports whether any of issues is an error rather than a
+// warning
+func lintErrors(issues []LintIssue) bool {
+	for _, issue := range issues {
+		if issue.Error {
+			return true
+		}
+	}
+	return false
+}
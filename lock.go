@@ -0,0 +1,20 @@
+package main
+
+import "os"
+
+// appendFlags opens a file for locked appends. Windows only allows locking
+// handles with read or write access, and O_APPEND drops the latter.
+const appendFlags = os.O_CREATE | os.O_APPEND | os.O_RDWR
+
+// appendLocked appends data to a file opened with appendFlags in one write,
+// holding the file lock so several running instances sharing the file can
+// never interleave their records
+func appendLocked(file *os.File, data []byte) error {
+	if err := lockFile(file); err != nil {
+		return err
+	}
+	defer unlockFile(file)
+
+	_, err := file.Write(data)
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build minimal
+
+package main
+
+import "fmt"
+
+// minimalBuild leaves the VT emulator, session recording, the sandbox,
+// job delivery by webhook and email, telemetry, network diagnosis, named
+// SQL connections and OpenAPI specs out of binaries built with -tags
+// minimal, for routers and other small devices
+const minimalBuild = true
+
+// leftOut is the error of a feature a minimal build doesn't have
+func leftOut(feature string) error {
+	return fmt.Errorf("%s is left out of this build (built with -tags minimal)", feature)
+}
@@ -0,0 +1,9 @@
+//go:build !minimal
+
+package main
+
+// minimalBuild leaves the VT emulator, session recording, the sandbox,
+// job delivery by webhook and email, telemetry, network diagnosis, named
+// SQL connections and OpenAPI specs out of binaries built with -tags
+// minimal, for routers and other small devices
+const minimalBuild = false
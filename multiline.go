@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Bracketed paste wraps text so the shell's line editor takes it as typed
+// input, newlines included, rather than keys to act on
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// bracketedPasteRe matches the shell's line editor turning bracketed paste
+// on or off, as it does around reading each command line
+var bracketedPasteRe = regexp.MustCompile(`\x1b\[\?2004([hl])`)
+
+// watchBracketedPaste tracks whether the shell's line editor takes
+// bracketed paste
+func (m *Model) watchBracketedPaste(chunk []byte) {
+	if matches := bracketedPasteRe.FindAllSubmatch(chunk, -1); matches != nil {
+		m.bracketedPaste = matches[len(matches)-1][1][0] == 'h'
+	}
+}
+
+// Script execution settings: when generated commands run from a temporary
+// script in a shell of their own rather than being typed at the prompt
+const (
+	ScriptExecutionOff    = "off"
+	ScriptExecutionAuto   = "auto"
+	ScriptExecutionAlways = "always"
+)
+
+// complexCommandLength is the length from which auto runs a one-line
+// command from a script
+const complexCommandLength = 200
+
+// shellStateRe matches commands that change the interactive shell itself:
+// its working directory, environment, aliases, functions and options, and
+// activated environments. A shell of their own would lose that.
+var shellStateRe = regexp.MustCompile(`(?mi)(?:^|[;&|({]|\b(?:then|do|else)\s)\s*(?:cd|pushd|popd|chdir|export|unset|alias|unalias|source|\.|setopt|unsetopt|shopt|(?-i:set\s+-[a-zA-Z]*[gU][a-zA-Z]*)|Set-Location|sl|Push-Location|Pop-Location|Set-Alias|New-Alias|Import-Module|conda|nvm|deactivate)(?:\s|$|;)|\$env:\w+\s*=|^\s*function\s|\w+\s*\(\)\s*\{`)
+
+// ParseScriptExecution validates a script_execution setting
+func ParseScriptExecution(value string) (string, error) {
+	switch value {
+	case ScriptExecutionOff, ScriptExecutionAuto, ScriptExecutionAlways:
+		return value, nil
+	}
+	return "", fmt.Errorf("%q (expected off, auto or always)", value)
+}
+
+// runsAsScript reports whether command runs from a temporary script in a
+// shell of its own, where prompt hooks, aliases and what is half typed at
+// the prompt can't get in its way: always, or with auto for multi-line and
+// long commands that leave the shell's state alone. Commands for a remote
+// shell are always typed, as the script would be on this machine.
+func (m Model) runsAsScript(command string) bool {
+	if len(m.remotes) > 0 {
+		return false
+	}
+	switch m.config.ScriptExecution {
+	case ScriptExecutionAlways:
+		return true
+	case ScriptExecutionAuto:
+		complex := isScript(command) || len(command) >= complexCommandLength || strings.Contains(command, "<<")
+		return complex && !shellStateRe.MatchString(command)
+	}
+	return false
+}
+
+// sendCommand sends command to the shell and runs it, first clearing what
+// was typed at the prompt to put back after it. Per script_execution, it runs from a temporary
+// script in a shell of its own. Otherwise a multi-line command is pasted
+// whole where the shell's line editor takes bracketed paste, so the lines
+// of a heredoc or loop reach it intact, without continuation prompts, tab
+// completion or indentation getting in the way, and elsewhere it is
+// written to a temporary script the shell sources, which keeps its effect
+// on the working directory and variables. Should writing a script fail,
+// the command is typed as it is. A command starting with a space keeps
+// it, which leaves it out of the shell's history, and is never run in a
+// shell of its own, as such lines set and unset the shell's variables.
+func (m *Model) sendCommand(command string) {
+	if m.pty == nil {
+		return
+	}
+	hidden := strings.HasPrefix(command, " ")
+	command = strings.TrimSpace(command)
+	m.saveInput()
+	switch {
+	case m.runsAsScript(command) && !hidden:
+		if path, err := m.writeScript(command); err == nil {
+			command = scriptRunner(path, m.config.Shell)
+		}
+	case !isScript(command):
+	case m.bracketedPaste:
+		m.pty.Write([]byte(pasteStart + command + pasteEnd + "\r"))
+		return
+	case len(m.remotes) == 0:
+		if path, err := m.writeScript(command); err == nil {
+			command = scriptSourcer(path, m.config.Shell)
+		}
+	}
+	if hidden {
+		command = " " + command
+	}
+	m.pty.Write([]byte(command + "\n"))
+}
+
+// writeScript writes command to a temporary script for the configured
+// shell. The scripts are removed when the session ends.
+func (m *Model) writeScript(command string) (string, error) {
+	if m.scriptDir == "" {
+		dir, err := os.MkdirTemp("", AppName+"-")
+		if err != nil {
+			return "", fmt.Errorf("could not create a directory for the script: %w", err)
+		}
+		m.scriptDir = dir
+	}
+	dialect, _ := ParseDialect(m.config.Shell)
+	ext, ok := scriptExtensions[dialect]
+	if !ok {
+		ext = ".sh"
+	}
+	m.scriptCount++
+	path := filepath.Join(m.scriptDir, fmt.Sprintf("command-%d%s", m.scriptCount, ext))
+	content := command + "\n"
+	if dialect == DialectCmd {
+		content = "@echo off\n" + content
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("could not write the script: %w", err)
+	}
+	return path, nil
+}
+
+// scriptSourcer is how shell runs the script at path in its own scope
+func scriptSourcer(path, shell string) string {
+	quoted := QuotePath(path, shell)
+	dialect, _ := ParseDialect(shell)
+	switch dialect {
+	case DialectPowerShell:
+		// A script block, unlike a .ps1 file, isn't held back by the
+		// execution policy
+		return fmt.Sprintf(". ([scriptblock]::Create((Get-Content -Raw -LiteralPath %s)))", quoted)
+	case DialectCmd:
+		return "call " + quoted
+	case DialectFish:
+		return "source " + quoted
+	}
+	return ". " + quoted
+}
+
+// scriptSourcerOnce sources the script at path like scriptSourcer, then
+// deletes it, on the same line, whether or not sourcing succeeded
+func scriptSourcerOnce(path, shell string) string {
+	quoted := QuotePath(path, shell)
+	dialect, _ := ParseDialect(shell)
+	switch dialect {
+	case DialectPowerShell:
+		return scriptSourcer(path, shell) + "; Remove-Item -LiteralPath " + quoted
+	case DialectCmd:
+		return scriptSourcer(path, shell) + " & del " + quoted
+	}
+	return scriptSourcer(path, shell) + "; rm -f " + quoted
+}
+
+// scriptRunner is how shell runs the script at path in a new shell of the
+// same kind, bypassing aliases and functions of the same name
+func scriptRunner(path, shell string) string {
+	quoted := QuotePath(path, shell)
+	dialect, _ := ParseDialect(shell)
+	switch dialect {
+	case DialectPowerShell:
+		return fmt.Sprintf("& %s -NoProfile -ExecutionPolicy Bypass -File %s", QuotePath(shell, shell), quoted)
+	case DialectCmd:
+		return "cmd /d /c " + quoted
+	}
+	return fmt.Sprintf("command %s %s", QuotePath(shell, shell), quoted)
+}
+
+// removeScripts deletes the temporary scripts of multi-line commands
+func (m *Model) removeScripts() {
+	if m.scriptDir != "" {
+		os.RemoveAll(m.scriptDir)
+	}
+}
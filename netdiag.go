@@ -0,0 +1,319 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// Limits for network checks
+const (
+	checkTimeout      = 15 * time.Second
+	tracerouteTimeout = 40 * time.Second
+	maxCheckOutput    = 3000
+)
+
+// diagnoseTargetRe finds the host or URL a request is about, e.g. in
+// "can't reach api.example.com:8443"
+var diagnoseTargetRe = regexp.MustCompile(`(?i)\b(?:https?://\S+|(?:[a-z0-9-]+\.)+[a-z][a-z0-9-]+(?::\d+)?|\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?|localhost(?::\d+)?)`)
+
+// DiagnoseTarget is what a connectivity problem is about
+type DiagnoseTarget struct {
+	Host string
+	Port string
+	// URL is set when the target is a web address, for the HTTP check
+	URL string
+}
+
+// ParseDiagnoseTarget finds the target in a request: a URL, host:port, a
+// host name or an IP address
+func ParseDiagnoseTarget(request string) (DiagnoseTarget, error) {
+	match := diagnoseTargetRe.FindString(request)
+	if match == "" {
+		return DiagnoseTarget{}, fmt.Errorf("no host or URL in %q", request)
+	}
+	match = strings.TrimRight(match, ".,;:!?)'\"")
+
+	if strings.Contains(match, "://") {
+		u, err := url.Parse(match)
+		if err != nil {
+			return DiagnoseTarget{}, err
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+		}
+		return DiagnoseTarget{Host: u.Hostname(), Port: port, URL: match}, nil
+	}
+	t := DiagnoseTarget{Host: match}
+	if host, port, err := net.SplitHostPort(match); err == nil {
+		t.Host, t.Port = host, port
+	}
+	switch t.Port {
+	case "80":
+		t.URL = "http://" + match
+	case "443":
+		t.URL = "https://" + t.Host
+	}
+	return t, nil
+}
+
+// String names the target as host or host:port
+func (t DiagnoseTarget) String() string {
+	if t.Port != "" {
+		return net.JoinHostPort(t.Host, t.Port)
+	}
+	return t.Host
+}
+
+// NetCheck is one check and what it found
+type NetCheck struct {
+	Layer   string
+	Command string
+	Output  string
+	OK      bool
+	// Skipped is why the check did not run, e.g. its tool is missing
+	Skipped string
+}
+
+// runCheck runs a read-only diagnostic command; the check is OK when it
+// exits with status zero
+func runCheck(layer string, timeout time.Duration, name string, args ...string) NetCheck {
+	check := NetCheck{Layer: layer, Command: strings.Join(append([]string{name}, args...), " ")}
+	if _, err := exec.LookPath(name); err != nil {
+		check.Skipped = name + " is not installed"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	check.Output = strings.TrimSpace(string(out))
+	if len(check.Output) > maxCheckOutput {
+		check.Output = check.Output[:maxCheckOutput] + "\n..."
+	}
+	if ctx.Err() != nil {
+		check.Output += fmt.Sprintf("\n(timed out after %s)", timeout)
+	}
+	check.OK = err == nil
+	return check
+}
+
+// firstInstalled returns the first of the tools that is installed, or ""
+func firstInstalled(tools ...string) string {
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err == nil {
+			return tool
+		}
+	}
+	return ""
+}
+
+// RunNetworkChecks runs read-only checks against target, layer by layer,
+// going further only while they succeed: the route out, DNS, ping (with a
+// traceroute if it fails), the TCP port, and the HTTP request. Checks that
+// can't say anything more once a lower layer has failed are not run.
+func RunNetworkChecks(target DiagnoseTarget) []NetCheck {
+	var checks []NetCheck
+
+	switch runtime.GOOS {
+	case "linux":
+		checks = append(checks, runCheck("local", checkTimeout, "ip", "route", "show", "default"))
+	case "darwin":
+		checks = append(checks, runCheck("local", checkTimeout, "route", "-n", "get", "default"))
+	case "windows":
+		checks = append(checks, runCheck("local", checkTimeout, "ipconfig"))
+	}
+	if data, err := os.ReadFile("/etc/resolv.conf"); err == nil {
+		var servers []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "nameserver" {
+				servers = append(servers, fields[1])
+			}
+		}
+		checks = append(checks, NetCheck{Layer: "local", Command: "nameservers in /etc/resolv.conf", Output: strings.Join(servers, " "), OK: len(servers) > 0})
+	}
+
+	if net.ParseIP(target.Host) == nil && target.Host != "localhost" {
+		var dns NetCheck
+		switch tool := firstInstalled("dig", "nslookup"); tool {
+		case "dig":
+			dns = runCheck("dns", checkTimeout, "dig", "+time=3", "+tries=1", target.Host)
+			// dig exits 0 when the server answers, even with NXDOMAIN
+			dns.OK = dns.OK && strings.Contains(dns.Output, "status: NOERROR") && strings.Contains(dns.Output, "ANSWER SECTION")
+		case "nslookup":
+			dns = runCheck("dns", checkTimeout, "nslookup", target.Host)
+		default:
+			dns = NetCheck{Layer: "dns", Command: "resolve " + target.Host}
+			addrs, err := net.LookupHost(target.Host)
+			dns.OK = err == nil
+			dns.Output = strings.Join(addrs, " ")
+			if err != nil {
+				dns.Output = err.Error()
+			}
+		}
+		checks = append(checks, dns)
+		if !dns.OK {
+			return checks
+		}
+	}
+
+	count := "-c"
+	if runtime.GOOS == "windows" {
+		count = "-n"
+	}
+	ping := runCheck("icmp", checkTimeout, "ping", count, "3", target.Host)
+	checks = append(checks, ping)
+	if !ping.OK && ping.Skipped == "" {
+		// ICMP is often filtered, so a failed ping alone proves little;
+		// the path shows where packets stop
+		switch tool := firstInstalled("traceroute", "tracepath", "tracert"); tool {
+		case "traceroute":
+			checks = append(checks, runCheck("routing", tracerouteTimeout, "traceroute", "-n", "-w", "2", "-q", "1", "-m", "20", target.Host))
+		case "tracepath":
+			checks = append(checks, runCheck("routing", tracerouteTimeout, "tracepath", "-n", "-m", "20", target.Host))
+		case "tracert":
+			checks = append(checks, runCheck("routing", tracerouteTimeout, "tracert", "-d", "-h", "20", target.Host))
+		}
+	}
+
+	if target.Port == "" {
+		return checks
+	}
+	tcp := NetCheck{Layer: "tcp", Command: "connect to " + target.String()}
+	conn, err := net.DialTimeout("tcp", target.String(), 5*time.Second)
+	if err != nil {
+		tcp.Output = err.Error()
+	} else {
+		tcp.OK = true
+		tcp.Output = "connected from " + conn.LocalAddr().String()
+		conn.Close()
+	}
+	checks = append(checks, tcp)
+	if !tcp.OK {
+		if ip := net.ParseIP(target.Host); target.Host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			// Nothing listening is the usual reason locally
+			checks = append(checks, runCheck("tcp", checkTimeout, "ss", "-ltn"))
+		}
+		return checks
+	}
+
+	if target.URL != "" {
+		checks = append(checks, runCheck("http", checkTimeout, "curl", "-sS", "-v", "-o", os.DevNull, "--max-time", "10", target.URL))
+	}
+	return checks
+}
+
+// renderChecks lists the checks with a mark for each, and their output
+// when full is set
+func renderChecks(checks []NetCheck, full bool) string {
+	var b strings.Builder
+	for _, check := range checks {
+		mark := "✗"
+		switch {
+		case check.Skipped != "":
+			mark = "-"
+		case check.OK:
+			mark = "✓"
+		}
+		fmt.Fprintf(&b, "%s [%s] %s", mark, check.Layer, check.Command)
+		if check.Skipped != "" {
+			fmt.Fprintf(&b, " (skipped: %s)", check.Skipped)
+		}
+		b.WriteString("\n")
+		if full && check.Output != "" {
+			fmt.Fprintf(&b, "%s\n\n", check.Output)
+		}
+	}
+	return b.String()
+}
+
+// DiagnoseNetwork runs the checks against target and asks the model which
+// layer fails and how to fix it
+func DiagnoseNetwork(config Config, target DiagnoseTarget, ctx PromptContext) (string, error) {
+	checks := RunNetworkChecks(target)
+	contents, err := chatCompletion(config, ai.Request{
+		Model: personaModel(ctx),
+		Messages: []ai.Message{
+			{Role: "system", Content: "You diagnose network connectivity problems from the output of read-only checks. " +
+				"Say which layer most likely fails (local network, DNS, routing, firewall or filtered port, service not listening, TLS, or HTTP/application), " +
+				"what in the output shows it, and the fix, with commands where they help. Answer in this form:\n" +
+				"Layer: ...\nEvidence: ...\nFix: ...\n\n" + personaPrompt(ctx) + ctx.String()},
+			{Role: "user", Content: redactText(config, fmt.Sprintf("Target: %s\n\nChecks:\n%s", target, renderChecks(checks, true)))},
+		},
+		Temperature: 0.2,
+		MaxTokens:   600,
+	})
+	if err != nil {
+		return "", err
+	}
+	return renderChecks(checks, false) + "\n" + strings.TrimSpace(contents[0]), nil
+}
+
+// queryDiagnose runs the network checks for the target in the prompt and
+// shows the diagnosis in the answer overlay
+func (m Model) queryDiagnose(request string) tea.Cmd {
+	config := m.config
+	cwd := m.shellCwd()
+	notes := m.notesContext()
+	return func() tea.Msg {
+		target, err := ParseDiagnoseTarget(request)
+		if err != nil {
+			return diagnoseMsg{err: err}
+		}
+		ctx := GatherPromptContext(config, cwd)
+		ctx.Notes = notes
+		diagnosis, err := DiagnoseNetwork(config, target, ctx)
+		return diagnoseMsg{target: target.String(), diagnosis: diagnosis, err: err}
+	}
+}
+
+// diagnoseMsg carries a network diagnosis, or why there is none
+type diagnoseMsg struct {
+	target    string
+	diagnosis string
+	err       error
+}
+
+// handleDiagnoseCommand runs the network checks against the host or URL in
+// the arguments and prints the diagnosis
+func handleDiagnoseCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: ai-terminal-tui diagnose HOST|URL|HOST:PORT")
+		os.Exit(1)
+	}
+	target, err := ParseDiagnoseTarget(strings.Join(args, " "))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config := mustLoadConfig()
+	if config.LiteLLMURL == "" {
+		fmt.Println("Error: litellm_url not configured. Run 'ai-terminal-tui setup' first.")
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Checking %s...\n", target)
+	diagnosis, err := DiagnoseNetwork(config, target, GatherPromptContext(config, ""))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(diagnosis)
+}
@@ -0,0 +1,30 @@
+//go:build minimal
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// diagnoseMsg carries a network diagnosis, or why there is none
+type diagnoseMsg struct {
+	target    string
+	diagnosis string
+	err       error
+}
+
+// queryDiagnose says network diagnosis isn't in the build
+func (m Model) queryDiagnose(request string) tea.Cmd {
+	return func() tea.Msg {
+		return diagnoseMsg{err: leftOut("network diagnosis")}
+	}
+}
+
+// handleDiagnoseCommand says network diagnosis isn't in the build
+func handleDiagnoseCommand(args []string) {
+	fmt.Printf("Error: %v\n", leftOut("network diagnosis"))
+	os.Exit(1)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxNotes limits how many notes can be pinned in a session
+const maxNotes = 20
+
+// notesContext returns the pinned notes as sent to the model
+func (m Model) notesContext() []string {
+	notes := make([]string, len(m.notes))
+	for i, note := range m.notes {
+		notes[i] = redactText(m.config, note)
+	}
+	return notes
+}
+
+// openNotes shows the pinned notes, starting a new one if there are none
+func (m *Model) openNotes() {
+	m.notesOpen = true
+	m.notesCursor = max(0, min(m.notesCursor, len(m.notes)-1))
+	if len(m.notes) == 0 {
+		m.editNote(-1)
+	}
+}
+
+// editNote starts editing note i, or a new note when i is -1
+func (m *Model) editNote(i int) {
+	m.noteInput = textinput.New()
+	m.noteInput.Placeholder = "e.g. we're on the staging cluster, region eu-west-1"
+	m.noteInput.CharLimit = 200
+	m.noteInput.Width = max(10, m.width-8)
+	if i >= 0 {
+		m.noteInput.SetValue(m.notes[i])
+	}
+	m.noteInput.Focus()
+	m.noteEditing = i
+	m.editingNote = true
+}
+
+// saveNote stores the note being edited; an emptied note is removed
+func (m *Model) saveNote() {
+	m.editingNote = false
+	text := strings.TrimSpace(m.noteInput.Value())
+	switch {
+	case m.noteEditing >= 0 && text == "":
+		m.deleteNote(m.noteEditing)
+	case m.noteEditing >= 0:
+		m.notes[m.noteEditing] = text
+	case text != "" && len(m.notes) < maxNotes:
+		countFeature("pin note")
+		m.notes = append(m.notes, text)
+		m.notesCursor = len(m.notes) - 1
+	}
+}
+
+// deleteNote unpins note i
+func (m *Model) deleteNote(i int) {
+	m.notes = append(m.notes[:i], m.notes[i+1:]...)
+	m.notesCursor = max(0, min(m.notesCursor, len(m.notes)-1))
+}
+
+// updateNotes handles keys while the pinned notes are shown
+func (m Model) updateNotes(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.editingNote {
+		switch msg.Type {
+		case tea.KeyEnter:
+			m.saveNote()
+			if len(m.notes) == 0 {
+				m.notesOpen = false
+			}
+			return m, nil
+		case tea.KeyEsc:
+			m.editingNote = false
+			if len(m.notes) == 0 {
+				m.notesOpen = false
+			}
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.noteInput, cmd = m.noteInput.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		m.notesCursor = max(0, m.notesCursor-1)
+	case "down", "j":
+		m.notesCursor = min(len(m.notes)-1, m.notesCursor+1)
+	case "a", "n":
+		if len(m.notes) < maxNotes {
+			m.editNote(-1)
+		}
+	case "e", "enter":
+		if len(m.notes) > 0 {
+			m.editNote(m.notesCursor)
+		}
+	case "d", "delete", "backspace":
+		if len(m.notes) > 0 {
+			m.deleteNote(m.notesCursor)
+		}
+	case "esc", "q", "alt+n":
+		m.notesOpen = false
+	}
+	return m, nil
+}
+
+// renderNotes lists the pinned notes with the cursor highlighted
+func (m Model) renderNotes() string {
+	boxStyle := lipgloss.NewStyle().
+		Border(roundedBorder()).
+		BorderForeground(theme.Info).
+		Padding(0, 1).
+		Width(m.width - 2)
+
+	titleStyle := lipgloss.NewStyle().Foreground(theme.Info).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	selectedStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Pinned notes, sent with every request this session") + "\n\n")
+	for i, note := range m.notes {
+		switch {
+		case m.editingNote && i == m.noteEditing:
+			b.WriteString("> " + m.noteInput.View())
+		case !m.editingNote && i == m.notesCursor:
+			b.WriteString(selectedStyle.Render(fmt.Sprintf("> %d. %s", i+1, note)))
+		default:
+			b.WriteString(fmt.Sprintf("  %d. %s", i+1, note))
+		}
+		b.WriteString("\n")
+	}
+	if m.editingNote && m.noteEditing < 0 {
+		b.WriteString("> " + m.noteInput.View() + "\n")
+	}
+	b.WriteString("\n")
+	if m.editingNote {
+		b.WriteString(hintStyle.Render("Enter to pin, Esc to cancel"))
+	} else {
+		b.WriteString(hintStyle.Render("↑/↓ to move, a to add, e to edit, d to delete, Esc to close"))
+	}
+	return boxStyle.Render(b.String())
+}
@@ -0,0 +1,323 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxAPIEndpoints caps how many endpoints of a spec are listed in prompts
+const maxAPIEndpoints = 60
+
+// maxSpecBytes is the largest spec file read; bigger ones are skipped
+const maxSpecBytes = 2 * 1024 * 1024
+
+// specNames are the files searched for an OpenAPI spec, in the working
+// directory and the specDirs below it
+var (
+	specNames = []string{"openapi.yaml", "openapi.yml", "openapi.json", "swagger.yaml", "swagger.yml", "swagger.json"}
+	specDirs  = []string{".", "api", "docs", "spec", "openapi"}
+)
+
+// httpMethods are the operations of an OpenAPI path item
+var httpMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// APISpec is what the model needs from an OpenAPI spec: where the API is,
+// how to authenticate, and its endpoints
+type APISpec struct {
+	Path      string
+	Servers   []string
+	Auth      []string
+	Endpoints []string
+}
+
+// securityScheme is an OpenAPI security scheme or Swagger security
+// definition
+type securityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+	Name   string `json:"name"`
+	In     string `json:"in"`
+}
+
+// describe turns a scheme into the header or parameter it needs
+func (s securityScheme) describe(name string) string {
+	switch {
+	case s.Type == "http" && strings.EqualFold(s.Scheme, "bearer"), s.Type == "oauth2", s.Type == "openIdConnect":
+		return name + ": header Authorization: Bearer <token>"
+	case s.Type == "http" && strings.EqualFold(s.Scheme, "basic"), s.Type == "basic":
+		return name + ": HTTP basic auth"
+	case s.Type == "apiKey":
+		return fmt.Sprintf("%s: API key in %s %s", name, valueOrDefault(s.In, "header"), s.Name)
+	}
+	return name + ": " + s.Type
+}
+
+// FindAPISpec returns the OpenAPI spec in dir or its usual subdirectories,
+// or "" when there is none
+func FindAPISpec(dir string) string {
+	for _, sub := range specDirs {
+		for _, name := range specNames {
+			path := filepath.Join(dir, sub, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Size() <= maxSpecBytes {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// LoadAPISpec reads an OpenAPI 3 or Swagger 2 spec in JSON or YAML
+func LoadAPISpec(path string) (*APISpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec *APISpec
+	if strings.HasSuffix(path, ".json") {
+		spec, err = parseAPISpecJSON(data)
+	} else {
+		spec = parseAPISpecYAML(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec %s: %v", path, err)
+	}
+	if len(spec.Endpoints) == 0 {
+		return nil, fmt.Errorf("no paths found in %s", path)
+	}
+	spec.Path = path
+	return spec, nil
+}
+
+// parseAPISpecJSON reads a spec in JSON
+func parseAPISpecJSON(data []byte) (*APISpec, error) {
+	var doc struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Host       string                                `json:"host"`
+		BasePath   string                                `json:"basePath"`
+		Schemes    []string                              `json:"schemes"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+		} `json:"components"`
+		SecurityDefinitions map[string]securityScheme `json:"securityDefinitions"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	spec := &APISpec{}
+	for _, server := range doc.Servers {
+		spec.Servers = append(spec.Servers, server.URL)
+	}
+	if doc.Host != "" {
+		scheme := "https"
+		if len(doc.Schemes) > 0 {
+			scheme = doc.Schemes[0]
+		}
+		spec.Servers = append(spec.Servers, scheme+"://"+doc.Host+doc.BasePath)
+	}
+
+	schemes := doc.Components.SecuritySchemes
+	if len(schemes) == 0 {
+		schemes = doc.SecurityDefinitions
+	}
+	for _, name := range sortedKeys(schemes) {
+		spec.Auth = append(spec.Auth, schemes[name].describe(name))
+	}
+
+	for _, path := range sortedKeys(doc.Paths) {
+		for _, method := range sortedKeys(doc.Paths[path]) {
+			if !httpMethods[method] {
+				continue
+			}
+			var op struct {
+				Summary     string `json:"summary"`
+				OperationID string `json:"operationId"`
+			}
+			json.Unmarshal(doc.Paths[path][method], &op)
+			spec.Endpoints = append(spec.Endpoints, endpointLine(method, path, valueOrDefault(op.Summary, op.OperationID)))
+		}
+	}
+	return spec, nil
+}
+
+// parseAPISpecYAML reads the parts of a YAML spec that matter by their
+// indentation; a full YAML parser is not worth the dependency for it
+func parseAPISpecYAML(text string) *APISpec {
+	spec := &APISpec{}
+	var (
+		section  string // top-level key
+		path     string
+		method   string
+		summary  string
+		inAuth   bool
+		authName string
+		auth     securityScheme
+		levels   []int // indents of the keys under the current section
+	)
+	flushEndpoint := func() {
+		if path != "" && method != "" {
+			spec.Endpoints = append(spec.Endpoints, endpointLine(method, path, summary))
+		}
+		method, summary = "", ""
+	}
+	flushAuth := func() {
+		if authName != "" {
+			spec.Auth = append(spec.Auth, auth.describe(authName))
+		}
+		authName, auth = "", securityScheme{}
+	}
+	depth := func(indent int) int {
+		for len(levels) > 0 && levels[len(levels)-1] > indent {
+			levels = levels[:len(levels)-1]
+		}
+		if len(levels) == 0 || levels[len(levels)-1] < indent {
+			levels = append(levels, indent)
+		}
+		return len(levels)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value, _ := strings.Cut(strings.TrimPrefix(trimmed, "- "), ":")
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		if indent == 0 {
+			flushEndpoint()
+			flushAuth()
+			section, path, levels, inAuth = key, "", nil, false
+			switch key {
+			case "host":
+				spec.Servers = append(spec.Servers, "https://"+value)
+			case "basePath":
+				if len(spec.Servers) > 0 {
+					spec.Servers[len(spec.Servers)-1] += value
+				}
+			case "securityDefinitions":
+				inAuth = true
+			}
+			continue
+		}
+
+		level := depth(indent)
+		switch {
+		case section == "servers" && key == "url":
+			spec.Servers = append(spec.Servers, value)
+
+		case section == "paths":
+			switch {
+			case level == 1:
+				flushEndpoint()
+				path = key
+			case level == 2 && httpMethods[key]:
+				flushEndpoint()
+				method = key
+			case level == 3 && method != "" && (key == "summary" || (key == "operationId" && summary == "")):
+				summary = value
+			}
+
+		case section == "components" && level == 1:
+			flushAuth()
+			inAuth = key == "securitySchemes"
+
+		case inAuth:
+			// Scheme names sit one level below securitySchemes, which is
+			// itself nested under components
+			nameLevel := 1
+			if section == "components" {
+				nameLevel = 2
+			}
+			switch {
+			case level == nameLevel:
+				flushAuth()
+				authName = key
+			case level == nameLevel+1:
+				switch key {
+				case "type":
+					auth.Type = value
+				case "scheme":
+					auth.Scheme = value
+				case "name":
+					auth.Name = value
+				case "in":
+					auth.In = value
+				}
+			}
+		}
+	}
+	flushEndpoint()
+	flushAuth()
+	return spec
+}
+
+// endpointLine renders an endpoint as "GET /users/{id} - Get a user"
+func endpointLine(method, path, summary string) string {
+	line := strings.ToUpper(method) + " " + path
+	if summary != "" {
+		line += " - " + summary
+	}
+	return line
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String renders the spec for the system prompt
+func (s *APISpec) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "OpenAPI spec %s:\n", filepath.Base(s.Path))
+	if len(s.Servers) > 0 {
+		fmt.Fprintf(&b, "Base URL: %s\n", strings.Join(s.Servers, ", "))
+	}
+	if len(s.Auth) > 0 {
+		fmt.Fprintf(&b, "Authentication: %s\n", strings.Join(s.Auth, "; "))
+	}
+	b.WriteString("Endpoints:\n")
+	for i, endpoint := range s.Endpoints {
+		if i == maxAPIEndpoints {
+			fmt.Fprintf(&b, "  ... and %d more\n", len(s.Endpoints)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %s\n", endpoint)
+	}
+	return b.String()
+}
+
+// apiSpecContext describes the project's API spec for the http domain,
+// or "" when there is none; spec overrides the search for one in cwd
+func apiSpecContext(spec, cwd string) string {
+	if spec == "" {
+		spec = FindAPISpec(cwd)
+	}
+	if spec == "" {
+		return ""
+	}
+	api, err := LoadAPISpec(spec)
+	if err != nil {
+		return ""
+	}
+	return api.String() + "Use these paths and authentication; take tokens from environment variables such as $API_TOKEN rather than writing them out.\n"
+}
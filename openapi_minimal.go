@@ -0,0 +1,17 @@
+//go:build minimal
+
+package main
+
+// APISpec stands in for a parsed OpenAPI spec, which a minimal build
+// can't read
+type APISpec struct{}
+
+// LoadAPISpec says OpenAPI specs aren't in the build
+func LoadAPISpec(path string) (*APISpec, error) {
+	return nil, leftOut("reading OpenAPI specs")
+}
+
+// apiSpecContext has no spec to describe in a minimal build
+func apiSpecContext(spec, cwd string) string {
+	return ""
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// recentOutputLines is how many lines of scrollback are attached to a query
+// when the user opts in to sending terminal output
+const recentOutputLines = 50
+
+// plainText converts raw PTY output into printable text: escape sequences are
+// removed and carriage-return overwrites are resolved per line
+func plainText(raw []byte) string {
+	text := ansi.Strip(string(raw))
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if j := strings.LastIndex(line, "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// lastLines returns at most n trailing lines of text, ignoring trailing blank
+// lines such as an empty line after the final newline
+func lastLines(text string, n int) []string {
+	lines := strings.Split(text, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// recentOutput returns the last n lines of terminal output as plain text
+func (m Model) recentOutput(n int) string {
+	return strings.Join(lastLines(plainText(m.output), n), "\n")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"time"
+	"unicode/utf8"
+)
+
+// restoreDelay is how long after an AI command is sent what was typed at
+// the prompt waits to be put back where the shell can't tell when the
+// command is done
+const restoreDelay = 500 * time.Millisecond
+
+// savedInput is what the user had half typed at the prompt when an AI
+// command was sent, put back once the shell is at its prompt again
+type savedInput struct {
+	// line is the text to type back; empty when it was killed into the
+	// line editor's kill ring, to be yanked back
+	line   string
+	killed bool
+	sent   time.Time
+	// done is set when the shell integration reports the command finished
+	done bool
+}
+
+// killRingShells are the shells whose line editor, by default, kills a
+// whole line with Ctrl+E Ctrl+U and yanks it back with Ctrl+Y
+var killRingShells = map[string]bool{"bash": true, "zsh": true, "fish": true}
+
+// saveInput clears what was typed at the prompt before an AI command is
+// sent, keeping it to put back after the command. A line followed key by
+// key is deleted and typed again later; one edited in ways that can't be
+// followed, like history or completion, is killed in bash, zsh and fish
+// and yanked back, and left as it is in other shells.
+func (m *Model) saveInput() {
+	line, known := m.typed.current()
+	m.typed = newLineTracker()
+	now := time.Now()
+	switch {
+	case known && line == "":
+		if m.savedInput != nil {
+			// An earlier command is still to give it back
+			m.savedInput.sent, m.savedInput.done = now, false
+		}
+	case known:
+		m.pty.Write(bytes.Repeat([]byte{127}, utf8.RuneCountInString(line)))
+		m.savedInput = &savedInput{line: line, sent: now}
+	case len(m.remotes) == 0 && killRingShells[shellName(m.config.Shell)]:
+		m.pty.Write([]byte{0x05, 0x15})
+		m.savedInput = &savedInput{killed: true, sent: now}
+	}
+}
+
+// watchSavedInput notes the shell integration reporting the command
+// finished, when the saved input can go back
+func (m *Model) watchSavedInput(chunk []byte) {
+	if m.savedInput != nil && exitStatusRe.Match(chunk) {
+		m.savedInput.done = true
+	}
+}
+
+// restoreInput puts back what was typed at the prompt once the AI command
+// sent after it is done: the shell integration reported it finished, or
+// the shell is in the foreground again. Where neither can be told, it is
+// typed ahead after restoreDelay, for the shell to read at its next
+// prompt. Should the user have typed since, it isn't put back.
+func (m *Model) restoreInput() {
+	s := m.savedInput
+	if s == nil || m.pty == nil {
+		return
+	}
+	if !s.done {
+		if time.Since(s.sent) < restoreDelay {
+			return
+		}
+		if busy, known := m.pty.Busy(); known && busy && len(m.remotes) == 0 {
+			return
+		}
+	}
+	m.savedInput = nil
+	if line, known := m.typed.current(); !known || line != "" {
+		return
+	}
+	if s.killed {
+		m.pty.Write([]byte{0x19})
+		m.typed = lineTracker{}
+		return
+	}
+	m.pty.Write([]byte(s.line))
+	m.typed = lineTracker{line: s.line, known: true}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxContextPaths bounds how many names of the working directory are
+// looked for in a generated command
+const maxContextPaths = 500
+
+var (
+	// safePathRe matches paths every shell takes as one word without
+	// quotes
+	safePathRe = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+	// patternNameRe matches names a command more likely means as a glob or
+	// an operator than as the file, like *, ? or &&: those holding * or ?,
+	// or made only of such characters
+	patternNameRe = regexp.MustCompile(`[*?]|^[\[\]{}!&|;<>()$~^#=\s-]+$`)
+)
+
+// QuotePath quotes a path as one word for shell, or returns it as it is
+// when it needs no quoting
+func QuotePath(path, shell string) string {
+	if safePathRe.MatchString(path) {
+		return path
+	}
+	dialect, _ := ParseDialect(shell)
+	switch dialect {
+	case DialectPowerShell:
+		return "'" + strings.ReplaceAll(path, "'", "''") + "'"
+	case DialectCmd:
+		// cmd has no escape for ", which Windows file names can't hold
+		return `"` + path + `"`
+	case DialectFish:
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(path) + "'"
+	}
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+// ContextPaths lists the paths a generated command may name that need
+// quoting: the working directory, the attached file, and the names in the
+// working directory. A name with spaces is left out when one of its words
+// is a name of its own, as the command may mean that, and so is a name a
+// command would write as a glob or operator, like *. Longer paths come
+// first, so they are matched before paths they start with.
+func ContextPaths(cwd string, attachment *Attachment) []string {
+	var paths []string
+	add := func(path string) {
+		if path != "" && !safePathRe.MatchString(path) {
+			paths = append(paths, path)
+		}
+	}
+	add(cwd)
+	if attachment != nil && attachment.Path != clipboardImage {
+		add(attachment.Path)
+		if filepath.Dir(attachment.Path) == cwd {
+			add(filepath.Base(attachment.Path))
+		}
+	}
+	if cwd != "" {
+		entries, _ := os.ReadDir(cwd)
+		names := make(map[string]bool, len(entries))
+		for _, entry := range entries[:min(len(entries), maxContextPaths)] {
+			names[entry.Name()] = true
+		}
+		for name := range names {
+			ambiguous := patternNameRe.MatchString(name)
+			if words := strings.Fields(name); len(words) > 1 {
+				for _, word := range words {
+					ambiguous = ambiguous || names[word]
+				}
+			}
+			if !ambiguous {
+				add(name)
+			}
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) > len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	return paths
+}
+
+// unquotedMask marks the bytes of command outside quotes, for shell
+func unquotedMask(command, shell string) []bool {
+	if posixShells[shellName(shell)] {
+		return scanQuotes(command).plain
+	}
+	dialect, _ := ParseDialect(shell)
+	mask := make([]bool, len(command))
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' && dialect != DialectCmd:
+			quote = c
+		case c == '`' && dialect == DialectPowerShell, c == '\\' && dialect == DialectFish:
+			i++
+		default:
+			mask[i] = true
+		}
+	}
+	return mask
+}
+
+// joinsOption reports whether quoting path as one word would take in a
+// word that starts with -, which the command more likely means as an option
+func joinsOption(path string) bool {
+	words := strings.Fields(path)
+	if len(words) < 2 {
+		return false
+	}
+	for _, word := range words {
+		if strings.HasPrefix(word, "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// FixPathQuoting quotes the paths in command that need quoting for shell
+// and were written without it, like a directory with spaces taken from the
+// context. Only whole words, or the start of a word that continues the
+// path with /, are quoted. A name starting with - gets ./ before it, so it
+// isn't taken for an option, but words starting with - are never quoted
+// into a name: "rm -rf backup" keeps its options even with a file called
+// "-rf backup" around.
+func FixPathQuoting(command string, paths []string, shell string) string {
+	// POSIX shells and fish join quoted and unquoted parts into one word
+	dialect, _ := ParseDialect(shell)
+	joins := posixShells[shellName(shell)] || dialect == DialectFish
+	for _, path := range paths {
+		if joinsOption(path) {
+			continue
+		}
+		for from := 0; from < len(command); {
+			i := strings.Index(command[from:], path)
+			if i < 0 {
+				break
+			}
+			start, end := from+i, from+i+len(path)
+			from = end
+			mask := unquotedMask(command, shell)
+			// There a name may be quoted on its own at the end of a path:
+			// dir/'My Report.pdf'
+			boundary := " \t=(;|&<>"
+			if joins {
+				boundary += "/"
+			}
+			if !mask[start] || start > 0 && !(mask[start-1] && strings.ContainsRune(boundary, rune(command[start-1]))) {
+				continue
+			}
+			prefix := ""
+			if strings.HasPrefix(path, "-") && (start == 0 || command[start-1] != '/') {
+				prefix = "./"
+			}
+			replacement := QuotePath(prefix+path, shell)
+			if end < len(command) {
+				switch {
+				case strings.ContainsRune(" \t;|&<>)\n", rune(command[end])):
+				case command[end] == '/' || command[end] == '\\':
+					if !joins {
+						// Elsewhere the whole word is quoted,
+						// unless it ends in a glob. A name with spaces
+						// after the path is part of the word.
+						rest := end + 1
+						for _, name := range paths {
+							if strings.HasPrefix(command[end+1:], name) {
+								rest = max(rest, end+1+len(name))
+							}
+						}
+						wordEnd := rest + strings.IndexAny(command[rest:]+" ", " \t;|&<>)\n")
+						if strings.ContainsAny(command[rest:wordEnd], "*?[") {
+							continue
+						}
+						end = wordEnd
+						replacement = QuotePath(prefix+command[start:end], shell)
+					}
+				default:
+					continue
+				}
+			}
+			command = command[:start] + replacement + command[end:]
+			from = start + len(replacement)
+		}
+	}
+	return command
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// nastyNames are files a generated command may name in the working
+// directory
+var nastyNames = []string{
+	"My Report.pdf",
+	"it's here.txt",
+	`say "hi".txt`,
+	"-rf backup",
+	"*",
+	"?",
+	"&&",
+	"a*b.log",
+	"notes",
+	"old notes",
+	"plain.txt",
+}
+
+func TestFixPathQuoting(t *testing.T) {
+	cwd := t.TempDir()
+	for _, name := range nastyNames {
+		if err := os.WriteFile(filepath.Join(cwd, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := ContextPaths(cwd, nil)
+
+	tests := []struct {
+		command, shell, want string
+	}{
+		// Spaces and quotes
+		{"cat My Report.pdf", "bash", "cat 'My Report.pdf'"},
+		{"cat 'My Report.pdf'", "bash", "cat 'My Report.pdf'"},
+		{"rm it's here.txt", "bash", `rm 'it'\''s here.txt'`},
+		{`cat say "hi".txt`, "bash", `cat 'say "hi".txt'`},
+		{"cat My Report.pdf", "pwsh", "cat 'My Report.pdf'"},
+		{"type My Report.pdf", "cmd.exe", `type "My Report.pdf"`},
+		{"cat it's here.txt", "fish", `cat 'it\'s here.txt'`},
+		{"cp My Report.pdf /tmp/", "bash", "cp 'My Report.pdf' /tmp/"},
+		// Globs and operators stay as they are
+		{"ls *", "bash", "ls *"},
+		{"rm -f *", "bash", "rm -f *"},
+		{"ls ?", "bash", "ls ?"},
+		{"make && make install", "bash", "make && make install"},
+		{"rm a*b.log", "bash", "rm a*b.log"},
+		// Options are never quoted into a name, whatever the files
+		{"rm -rf backup", "bash", "rm -rf backup"},
+		{"rm -rf backup/old", "bash", "rm -rf backup/old"},
+		{"rm -rf backup", "pwsh", "rm -rf backup"},
+		// A name whose words are names of their own is ambiguous
+		{"cat old notes", "bash", "cat old notes"},
+		// Names that need no quoting, and partial matches
+		{"cat plain.txt", "bash", "cat plain.txt"},
+		{"cat My Report.pdfx", "bash", "cat My Report.pdfx"},
+	}
+	for _, test := range tests {
+		if got := FixPathQuoting(test.command, paths, test.shell); got != test.want {
+			t.Errorf("FixPathQuoting(%q, %s) = %q, want %q", test.command, test.shell, got, test.want)
+		}
+	}
+}
+
+func TestContextPathsLeavesOutPatterns(t *testing.T) {
+	cwd := t.TempDir()
+	for _, name := range nastyNames {
+		if err := os.WriteFile(filepath.Join(cwd, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range ContextPaths(cwd, nil) {
+		switch path {
+		case "*", "?", "&&", "a*b.log", "old notes", "notes", "plain.txt":
+			t.Errorf("ContextPaths included %q", path)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// maxPermissionPaths caps how many paths are inspected for a permission
+// error
+const maxPermissionPaths = 3
+
+var (
+	// permissionErrorRe matches the messages of permission failures
+	permissionErrorRe = regexp.MustCompile(`(?i)permission denied|operation not permitted|access is denied|\bEACCES\b|\bEPERM\b|are you root\?`)
+	// permissionPathRe finds paths in error messages: quoted, as in
+	// "touch: cannot touch '/opt/x'", or bare, as in "bash: ./run.sh:"
+	permissionPathRe = regexp.MustCompile(`['‘"]([^'’"\s]+)['’"]|(?:^|\s)((?:~|\.{1,2})?/[^\s:'"()]+)`)
+)
+
+// isPermissionError reports whether a failure message is about permissions
+func isPermissionError(message string) bool {
+	return permissionErrorRe.MatchString(message)
+}
+
+// permissionPaths picks the paths a permission error is about: those named
+// in its error lines, then the command's arguments that exist, resolved
+// against the shell's directory
+func permissionPaths(command, output, cwd string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	// A path an error names may be a file that couldn't be created, so
+	// its directory existing is enough
+	add := func(path string, named bool) {
+		if strings.HasPrefix(path, "~") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = home + path[1:]
+			}
+		}
+		if path == "" || strings.HasPrefix(path, "-") || len(paths) == maxPermissionPaths {
+			return
+		}
+		if !filepath.IsAbs(path) {
+			if cwd == "" {
+				return
+			}
+			path = filepath.Join(cwd, path)
+		}
+		path = filepath.Clean(path)
+		if _, err := os.Lstat(path); err != nil {
+			if !named {
+				return
+			}
+			if _, err := os.Stat(filepath.Dir(path)); err != nil {
+				return
+			}
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if !permissionErrorRe.MatchString(line) {
+			continue
+		}
+		for _, match := range permissionPathRe.FindAllStringSubmatch(line, -1) {
+			add(match[1]+match[2], true)
+		}
+	}
+	for i, field := range strings.Fields(command) {
+		// The program itself matters only when it was run by path
+		if i > 0 || strings.ContainsRune(field, '/') {
+			add(strings.Trim(field, `'"`), false)
+		}
+	}
+	return paths
+}
+
+// describePath lists the mode, owner and group of path and every directory
+// above it, since any of them can deny access, along with its ACL when it
+// has one
+func describePath(path, cwd string) string {
+	if runtime.GOOS == "windows" {
+		return runDomainTool(cwd, "icacls", path)
+	}
+
+	var chain []string
+	var missing []string
+	for p := path; ; p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			chain = append(chain, p)
+		} else if len(chain) == 0 {
+			missing = append(missing, p)
+		}
+		if p == filepath.Dir(p) {
+			break
+		}
+	}
+
+	var b strings.Builder
+	for _, p := range missing {
+		fmt.Fprintf(&b, "%s does not exist\n", p)
+	}
+	listing := runDomainTool(cwd, "ls", append([]string{"-ld", "--"}, chain...)...)
+	b.WriteString(listing + "\n")
+	// ls sorts its arguments, so the path itself comes last, marked with a
+	// + after the mode when it has an ACL
+	lines := strings.Split(listing, "\n")
+	if mode := strings.Fields(lines[len(lines)-1] + " -")[0]; len(chain) > 0 && len(mode) == 11 && mode[10] == '+' {
+		if _, err := exec.LookPath("getfacl"); err == nil {
+			b.WriteString(runDomainTool(cwd, "getfacl", "-p", chain[0]) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// PermissionReport describes who the user is and the permissions along the
+// paths a failed command was denied, for the model to find the smallest fix
+func PermissionReport(command, output, cwd string) string {
+	var b strings.Builder
+	if runtime.GOOS == "windows" {
+		fmt.Fprintf(&b, "User: %s\n", runDomainTool(cwd, "whoami"))
+	} else {
+		fmt.Fprintf(&b, "User: %s\n", runDomainTool(cwd, "id"))
+	}
+	for _, path := range permissionPaths(command, output, cwd) {
+		fmt.Fprintf(&b, "\nPermissions from %s up to the root:\n%s", path, describePath(path, cwd))
+	}
+	return b.String()
+}
+
+// permissionFixQuery asks for the narrowest fix for a permission error,
+// given what the report shows
+func permissionFixQuery(offer *failure, output, report string) string {
+	return fmt.Sprintf("The command `%s` failed with a permission error (%s).\n\nTerminal output:\n%s\n\n%s\n"+
+		"Give the smallest fix that the permissions above call for: run the one command with sudo when it is meant to change system files; "+
+		"chown or chgrp the one path the user should own; add the user to the group that owns it; chmod u+x a script that isn't executable; "+
+		"or chmod g+w or setfacl -m u:USER:rwX for shared access. Change only the path that is denied, not its parents or everything under it, "+
+		"unless the listing shows a directory above it is what blocks access. Never use chmod 777, a+rwx, or a recursive chmod or chown on system directories.",
+		offer.Command, offer.Message, untrustedBlock("terminal output", output), report)
+}
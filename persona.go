@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// personaExt is the file extension of user-defined personas
+const personaExt = ".json"
+
+// Persona is a profile the model takes on: a paragraph added to the system
+// prompt and, optionally, the model that answers
+type Persona struct {
+	Name        string `json:"-"`
+	Description string `json:"description"`
+	Prompt      string `json:"prompt"`
+	// Model overrides the configured model when set
+	Model string `json:"model,omitempty"`
+}
+
+// builtinPersonas ship with the program; a user file of the same name
+// replaces one
+var builtinPersonas = map[string]Persona{
+	"devops": {
+		Description: "Infrastructure, deployments and automation",
+		Prompt: "The user is a DevOps engineer. Prefer idempotent, script-friendly commands and the infrastructure tools " +
+			"(terraform, ansible, kubectl, docker, systemctl, journalctl) over manual steps, and flags that make output machine-readable.",
+	},
+	"data-engineer": {
+		Description: "Data files, pipelines and databases",
+		Prompt: "The user is a data engineer. Prefer tools made for data (jq, awk, csvkit, duckdb, sqlite3, psql), " +
+			"stream large files rather than loading them whole, and keep CSV headers intact.",
+	},
+	"security-auditor": {
+		Description: "Read-only inspection of a system's security",
+		Prompt: "The user is auditing this system's security. Prefer read-only inspection (ss, lsof, find -perm, getfacl, openssl, " +
+			"last, journalctl, auditd logs), never change permissions, users, firewall rules or services unless explicitly asked, " +
+			"and only scan hosts the request names.",
+	},
+	"windows-admin": {
+		Description: "Windows administration with PowerShell",
+		Prompt: "The user administers Windows machines. Prefer PowerShell cmdlets (Get-Service, Get-WinEvent, Get-CimInstance, " +
+			"Get-Acl, Set-ItemProperty) over legacy tools, spell out cmdlet and parameter names rather than aliases, " +
+			"and target remote machines with Invoke-Command when the request names one.",
+	},
+}
+
+// examplePersona is written when a new persona is created
+const examplePersona = `{
+  "description": "What this persona is for",
+  "prompt": "The user is ... Prefer ...",
+  "model": ""
+}
+`
+
+// GetPersonasDir returns the directory holding user-defined personas
+func GetPersonasDir() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "personas")
+}
+
+// personaPath returns the file of the user-defined persona called name
+func personaPath(name string) string {
+	return filepath.Join(GetPersonasDir(), name+personaExt)
+}
+
+// ValidatePersonaName checks that name can be used as a persona file name
+func ValidatePersonaName(name string) error {
+	if !validSessionName.MatchString(name) {
+		return fmt.Errorf("invalid persona name %q: use letters, digits, '.', '_' and '-' (up to 64 characters)", name)
+	}
+	return nil
+}
+
+// LoadPersona returns the persona called name, preferring the user's file
+// over a built-in one
+func LoadPersona(name string) (*Persona, error) {
+	if err := ValidatePersonaName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(personaPath(name))
+	if os.IsNotExist(err) {
+		if persona, ok := builtinPersonas[name]; ok {
+			persona.Name = name
+			return &persona, nil
+		}
+		return nil, fmt.Errorf("no persona named %q (see ai-terminal-tui personas list)", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var persona Persona
+	if err := json.Unmarshal(data, &persona); err != nil {
+		return nil, fmt.Errorf("invalid persona %s: %v", personaPath(name), err)
+	}
+	if strings.TrimSpace(persona.Prompt) == "" {
+		return nil, fmt.Errorf("persona %s has no prompt", personaPath(name))
+	}
+	persona.Name = name
+	return &persona, nil
+}
+
+// ListPersonas returns the names of the built-in and user-defined
+// personas, sorted
+func ListPersonas() ([]string, error) {
+	seen := make(map[string]bool)
+	for name := range builtinPersonas {
+		seen[name] = true
+	}
+
+	files, err := os.ReadDir(GetPersonasDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if name, ok := strings.CutSuffix(file.Name(), personaExt); ok && !file.IsDir() {
+			seen[name] = true
+		}
+	}
+
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ParsePersona checks the persona setting, where none clears it
+func ParsePersona(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", "none", "off":
+		return "", nil
+	}
+	if _, err := LoadPersona(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// personaPrompt returns the system prompt paragraph for the persona
+func personaPrompt(ctx PromptContext) string {
+	if ctx.Persona == nil {
+		return ""
+	}
+	return strings.TrimSpace(ctx.Persona.Prompt) + "\n\n"
+}
+
+// personaModel returns the model the persona asks for, or "" for the
+// configured one
+func personaModel(ctx PromptContext) string {
+	if ctx.Persona == nil {
+		return ""
+	}
+	return ctx.Persona.Model
+}
+
+// openPersonaPicker lists the personas to choose from in the prompt
+func (m *Model) openPersonaPicker() {
+	names, err := ListPersonas()
+	if err != nil {
+		m.input.Placeholder = err.Error()
+		return
+	}
+	// The first entry clears the persona
+	m.personaPicker = append([]string{""}, names...)
+	m.personaCursor = 0
+	for i, name := range m.personaPicker {
+		if name == m.config.Persona {
+			m.personaCursor = i
+		}
+	}
+}
+
+// updatePersonaPicker handles keys while choosing a persona
+func (m Model) updatePersonaPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyUp, tea.KeyShiftTab:
+		m.personaCursor = max(0, m.personaCursor-1)
+	case tea.KeyDown, tea.KeyTab:
+		m.personaCursor = min(len(m.personaPicker)-1, m.personaCursor+1)
+	case tea.KeyEnter:
+		name := m.personaPicker[m.personaCursor]
+		m.personaPicker = nil
+		if name != "" {
+			if _, err := LoadPersona(name); err != nil {
+				m.input.Placeholder = err.Error()
+				return m, nil
+			}
+			countFeature("persona")
+		}
+		m.config.Persona = name
+	case tea.KeyEsc:
+		m.personaPicker = nil
+	}
+	return m, nil
+}
+
+// renderPersonaPicker lists the personas with the cursor highlighted
+func (m Model) renderPersonaPicker(titleStyle, hintStyle lipgloss.Style) string {
+	selectedStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Choose a persona") + "\n\n")
+	for i, name := range m.personaPicker {
+		label := "(none)"
+		if name != "" {
+			label = name
+			if persona, err := LoadPersona(name); err == nil && persona.Description != "" {
+				label += hintStyle.Render(" - " + persona.Description)
+			}
+		}
+		if i == m.personaCursor {
+			b.WriteString(selectedStyle.Render("> ") + label)
+		} else {
+			b.WriteString("  " + label)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("↑/↓ to move, Enter to use for the rest of the session, Esc to cancel"))
+	return b.String()
+}
+
+// handlePersonasCommand handles the personas subcommands
+func handlePersonasCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui personas list")
+		fmt.Println("       ai-terminal-tui personas show NAME")
+		fmt.Println("       ai-terminal-tui personas edit NAME")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		names, err := ListPersonas()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			persona, err := LoadPersona(name)
+			if err != nil {
+				fmt.Printf("%-20s (%v)\n", name, err)
+				continue
+			}
+			fmt.Printf("%-20s %s\n", name, persona.Description)
+		}
+
+	case "show":
+		if len(args) < 2 {
+			usage()
+		}
+		persona, err := LoadPersona(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %s\n", persona.Name, persona.Description)
+		fmt.Printf("model: %s\n\n%s\n", valueOrDefault(persona.Model, "(configured model)"), persona.Prompt)
+
+	case "edit":
+		if len(args) < 2 {
+			usage()
+		}
+		if err := ValidatePersonaName(args[1]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		path := personaPath(args[1])
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// Editing a built-in persona starts from its definition
+			content := []byte(examplePersona)
+			if persona, ok := builtinPersonas[args[1]]; ok {
+				data, err := json.MarshalIndent(persona, "", "  ")
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				content = append(data, '\n')
+			}
+			if err := os.MkdirAll(GetPersonasDir(), 0700); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(path, content, 0600); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := openInEditor(path); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	default:
+		usage()
+	}
+}
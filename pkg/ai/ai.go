@@ -0,0 +1,338 @@
+// Package ai is the provider client that turns requests in plain language
+// into shell commands, through an OpenAI-compatible chat completions
+// endpoint such as LiteLLM, OpenAI or a local Ollama. The TUI builds its
+// prompts, context and safety checks on top of it; other programs can use
+// it to embed command generation.
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds a request unless the client says otherwise
+const defaultTimeout = 30 * time.Second
+
+// maxRedirects is how many redirects a request follows, as Go's default
+// client does
+const maxRedirects = 10
+
+// ErrNoResponse is returned when the model answers with nothing usable
+var ErrNoResponse = errors.New("no response from AI")
+
+// SystemPrompt tells the model to answer with a command for the system it
+// is given, which the caller describes after it
+const SystemPrompt = "You are a helpful assistant that converts natural language descriptions into shell commands. " +
+	"Respond with ONLY the command, no explanations, no markdown formatting, no quotes. " +
+	"If you're unsure, provide the most likely command. " +
+	"If the request clearly needs a multi-line script (several steps, loops, scheduled jobs), respond with the complete script instead, " +
+	"starting with a shebang line where the shell uses one, and keep it commented. " +
+	"Quote file names and paths that contain spaces or characters special to the shell. " +
+	"Don't use shell history expansion like !!, !$ or !-2; write out the earlier command or argument in full. " +
+	"The command must work on the system described below; use the tools, flags and package managers native to it."
+
+// explainPrompt asks for each command as a JSON object saying what it does
+// and what could go wrong
+const explainPrompt = "\n\nAnswer with a JSON object instead of the bare command, with the keys " +
+	`"command" (the command or script, exactly as you would have answered), ` +
+	`"explanation" (one plain sentence on what it does, for someone new to the shell) and ` +
+	`"risk" (one short sentence on what could go wrong or what it changes, or "none").`
+
+// Message is a single message in a chat request
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images are data URLs sent alongside Content to multimodal models
+	Images []string `json:"-"`
+}
+
+// MarshalJSON sends a message with images as a list of content parts, the
+// form multimodal models expect; other messages keep plain string content
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if len(m.Images) == 0 {
+		return json.Marshal(plain(m))
+	}
+
+	type imageURL struct {
+		URL string `json:"url"`
+	}
+	type part struct {
+		Type     string    `json:"type"`
+		Text     string    `json:"text,omitempty"`
+		ImageURL *imageURL `json:"image_url,omitempty"`
+	}
+	parts := []part{{Type: "text", Text: m.Content}}
+	for _, url := range m.Images {
+		parts = append(parts, part{Type: "image_url", ImageURL: &imageURL{URL: url}})
+	}
+	return json.Marshal(struct {
+		Role    string `json:"role"`
+		Content []part `json:"content"`
+	}{m.Role, parts})
+}
+
+// Request is the body of a /v1/chat/completions request
+type Request struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+	MaxTokens   int       `json:"max_tokens"`
+	N           int       `json:"n,omitempty"`
+	// ResponseFormat asks for structured output, where the provider
+	// supports it
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat is the kind of output a chat request asks for
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// Client talks to an OpenAI-compatible chat completions endpoint
+type Client struct {
+	// URL is the base of the endpoint, without /v1/chat/completions
+	URL   string
+	Token string
+	// Model is used for requests that don't name one
+	Model string
+	// Timeout bounds a request; 30 seconds when zero
+	Timeout time.Duration
+
+	// Allow, when set, is asked before the endpoint is contacted and before
+	// each redirect is followed, and its error stops the request
+	Allow func(url string) error
+	// Usage, when set, is told the tokens each response used
+	Usage func(tokens int)
+	// Answer, when set, answers every request in place of the endpoint,
+	// as for a scripted demo
+	Answer func(request Request) ([]string, error)
+}
+
+// Complete sends a chat request and returns the content of every choice
+func (c Client) Complete(request Request) ([]string, error) {
+	if request.Model == "" {
+		request.Model = c.Model
+	}
+	if c.Answer != nil {
+		return c.Answer(request)
+	}
+
+	jsonBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(c.URL, "/") + "/v1/chat/completions"
+	if c.Allow != nil {
+		if err := c.Allow(url); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	if c.Allow != nil {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("stopped after 10 redirects")
+			}
+			return c.Allow(req.URL.String())
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if c.Usage != nil {
+		c.Usage(result.Usage.TotalTokens)
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, ErrNoResponse
+	}
+
+	contents := make([]string, 0, len(result.Choices))
+	for _, choice := range result.Choices {
+		contents = append(contents, choice.Message.Content)
+	}
+	return contents, nil
+}
+
+// CleanCommand strips markdown code fences and surrounding whitespace from
+// a model response
+func CleanCommand(content string) string {
+	content = strings.TrimSpace(content)
+	// Remove any markdown code block formatting, whatever the language tag
+	if i := strings.Index(content, "\n"); strings.HasPrefix(content, "```") && i >= 0 {
+		content = content[i+1:]
+	}
+	content = strings.TrimPrefix(content, "```bash")
+	content = strings.TrimPrefix(content, "```sh")
+	content = strings.TrimPrefix(content, "```shell")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	return strings.TrimSpace(content)
+}
+
+// Explanation is what the model says a command does and what could go wrong
+// running it
+type Explanation struct {
+	Command string `json:"command"`
+	Summary string `json:"explanation"`
+	Risk    string `json:"risk"`
+}
+
+// parseExplained reads a command answered as a JSON object with its
+// explanation. An answer that isn't one is taken as the bare command.
+func parseExplained(content string) (string, *Explanation) {
+	content = CleanCommand(content)
+	var e Explanation
+	if err := json.Unmarshal([]byte(content), &e); err != nil || strings.TrimSpace(e.Command) == "" {
+		return content, nil
+	}
+	e.Command = CleanCommand(e.Command)
+	return e.Command, &e
+}
+
+// Attempt is a command the model suggested earlier for the same request,
+// with the user's optional correction
+type Attempt struct {
+	Command  string
+	Feedback string
+}
+
+// Prompt is a request for commands
+type Prompt struct {
+	// System describes the task and the system the command is for;
+	// SystemPrompt followed by a description of the machine will do
+	System string
+	// History comes between the system message and the request, like
+	// examples or earlier turns of a conversation
+	History []Message
+	Query   string
+	// Images are data URLs sent with the request to multimodal models
+	Images []string
+	// Attempts are replayed as conversation, so the model takes
+	// corrections into account
+	Attempts []Attempt
+	// N asks for up to that many distinct candidates; providers that
+	// ignore it give one
+	N int
+	// Explain asks for each command to be explained in a sentence and its
+	// risk noted, as structured output
+	Explain bool
+	// Model overrides the client's model
+	Model string
+}
+
+// GenerateCommands asks for commands for a prompt, returning them without
+// duplicates, and with Explain their explanations keyed by command; a
+// command answered bare has none
+func (c Client) GenerateCommands(p Prompt) ([]string, map[string]Explanation, error) {
+	n := max(p.N, 1)
+
+	system := p.System
+	if p.Explain {
+		system += explainPrompt
+	}
+	messages := []Message{{Role: "system", Content: system}}
+	messages = append(messages, p.History...)
+	messages = append(messages, Message{Role: "user", Content: fmt.Sprintf("User request: %s\n\nShell command:", p.Query), Images: p.Images})
+	for _, attempt := range p.Attempts {
+		retry := "That is not what I want. Give a different command."
+		if attempt.Feedback != "" {
+			retry = fmt.Sprintf("Correction: %s\n\nGive a revised command.", attempt.Feedback)
+		}
+		messages = append(messages,
+			Message{Role: "assistant", Content: attempt.Command},
+			Message{Role: "user", Content: retry},
+		)
+	}
+
+	request := Request{
+		Model:       p.Model,
+		Messages:    messages,
+		Temperature: 0.1,
+		MaxTokens:   1000,
+	}
+	if len(p.Attempts) > 0 {
+		// A little variety keeps a retry from repeating the last answer
+		request.Temperature = 0.4
+	}
+	if n > 1 {
+		// Candidates are only useful if they differ
+		request.N = n
+		request.Temperature = 0.8
+	}
+	if p.Explain {
+		request.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	}
+
+	contents, err := c.Complete(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	var commands []string
+	explanations := make(map[string]Explanation)
+	for _, content := range contents {
+		command := CleanCommand(content)
+		if p.Explain {
+			var explanation *Explanation
+			if command, explanation = parseExplained(content); explanation != nil {
+				explanations[command] = *explanation
+			}
+		}
+		if command == "" || seen[command] {
+			continue
+		}
+		seen[command] = true
+		commands = append(commands, command)
+	}
+
+	if len(commands) == 0 {
+		return nil, nil, ErrNoResponse
+	}
+	return commands, explanations, nil
+}
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanCommand(t *testing.T) {
+	tests := []struct{ content, want string }{
+		{"ls -la", "ls -la"},
+		{"  ls -la\n", "ls -la"},
+		{"```bash\nls -la\n```", "ls -la"},
+		{"```powershell\nGet-ChildItem\n```", "Get-ChildItem"},
+		{"```\nfind . -name '*.go'\n```", "find . -name '*.go'"},
+		{"echo '```'", "echo '```'"},
+	}
+	for _, test := range tests {
+		if got := CleanCommand(test.content); got != test.want {
+			t.Errorf("CleanCommand(%q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
+
+func TestMessageMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Message{Role: "user", Content: "hi"})
+	if err != nil || string(data) != `{"role":"user","content":"hi"}` {
+		t.Errorf("plain message = %s, %v", data, err)
+	}
+	data, err = json.Marshal(Message{Role: "user", Content: "what is this", Images: []string{"data:image/png;base64,AAAA"}})
+	want := `{"role":"user","content":[{"type":"text","text":"what is this"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}`
+	if err != nil || string(data) != want {
+		t.Errorf("message with image = %s, %v", data, err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var got Request
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		switch got.Messages[0].Content {
+		case "fail":
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		case "empty":
+			w.Write([]byte(`{"choices": []}`))
+		default:
+			w.Write([]byte(`{"choices": [{"message": {"content": "ls"}}, {"message": {"content": "ls -a"}}], "usage": {"total_tokens": 42}}`))
+		}
+	}))
+	defer server.Close()
+
+	tokens := 0
+	client := Client{URL: server.URL + "/", Token: "secret", Model: "default-model", Usage: func(n int) { tokens += n }}
+	contents, err := client.Complete(Request{Messages: []Message{{Role: "user", Content: "list"}}})
+	if err != nil || !reflect.DeepEqual(contents, []string{"ls", "ls -a"}) {
+		t.Errorf("Complete = %q, %v", contents, err)
+	}
+	if got.Model != "default-model" || auth != "Bearer secret" || tokens != 42 {
+		t.Errorf("request used model %q and auth %q, and reported %d tokens", got.Model, auth, tokens)
+	}
+
+	if _, err := client.Complete(Request{Messages: []Message{{Role: "user", Content: "fail"}}}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Complete on an error status = %v", err)
+	}
+	if _, err := client.Complete(Request{Messages: []Message{{Role: "user", Content: "empty"}}}); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Complete with no choices = %v, want ErrNoResponse", err)
+	}
+
+	refused := errors.New("air-gapped")
+	client.Allow = func(url string) error { return refused }
+	if _, err := client.Complete(Request{Messages: []Message{{Role: "user", Content: "list"}}}); !errors.Is(err, refused) {
+		t.Errorf("Complete with Allow refusing = %v", err)
+	}
+}
+
+func TestCompleteChecksRedirects(t *testing.T) {
+	outside := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("followed a redirect Allow refused")
+	}))
+	defer outside.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, outside.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	refused := errors.New("not internal")
+	client := Client{URL: server.URL, Allow: func(url string) error {
+		if strings.HasPrefix(url, outside.URL) {
+			return refused
+		}
+		return nil
+	}}
+	if _, err := client.Complete(Request{}); !errors.Is(err, refused) {
+		t.Errorf("Complete through a refused redirect = %v", err)
+	}
+}
+
+func TestGenerateCommands(t *testing.T) {
+	var got Request
+	client := Client{Answer: func(request Request) ([]string, error) {
+		got = request
+		if request.ResponseFormat != nil {
+			return []string{
+				`{"command": "du -sh *", "explanation": "Shows sizes.", "risk": "none"}`,
+				"du -sh .",
+			}, nil
+		}
+		return []string{"```bash\nls -la\n```", "ls -la", "ls -lA", ""}, nil
+	}}
+
+	commands, _, err := client.GenerateCommands(Prompt{System: SystemPrompt, Query: "list files", N: 3})
+	if err != nil || !reflect.DeepEqual(commands, []string{"ls -la", "ls -lA"}) {
+		t.Errorf("GenerateCommands = %q, %v", commands, err)
+	}
+	if got.N != 3 || got.Temperature != 0.8 || len(got.Messages) != 2 {
+		t.Errorf("candidates asked with n %d, temperature %v, %d messages", got.N, got.Temperature, len(got.Messages))
+	}
+
+	_, _, err = client.GenerateCommands(Prompt{Query: "list files", Attempts: []Attempt{{Command: "ls"}, {Command: "ls -l", Feedback: "hidden files too"}}})
+	if err != nil || len(got.Messages) != 6 || !strings.Contains(got.Messages[5].Content, "hidden files too") || got.Temperature != 0.4 {
+		t.Errorf("retry sent %d messages at temperature %v: %v", len(got.Messages), got.Temperature, err)
+	}
+
+	commands, explanations, err := client.GenerateCommands(Prompt{Query: "disk usage", N: 2, Explain: true})
+	if err != nil || !reflect.DeepEqual(commands, []string{"du -sh *", "du -sh ."}) {
+		t.Errorf("GenerateCommands with Explain = %q, %v", commands, err)
+	}
+	if e, ok := explanations["du -sh *"]; !ok || e.Summary != "Shows sizes." {
+		t.Errorf("explanations = %v", explanations)
+	}
+	if _, ok := explanations["du -sh ."]; ok {
+		t.Error("a bare answer has an explanation")
+	}
+
+	client.Answer = func(Request) ([]string, error) { return []string{"", "```\n```"}, nil }
+	if _, _, err := client.GenerateCommands(Prompt{Query: "nothing"}); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("GenerateCommands with only empty answers = %v, want ErrNoResponse", err)
+	}
+}
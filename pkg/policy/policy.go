@@ -0,0 +1,232 @@
+// Package policy reads the rules an organization enforces on every user
+// of the TUI: generated commands that are refused whatever the user
+// confirms, and config settings users can't change. A policy is a JSON
+// file, or a document fetched over https:
+//
+//	{
+//	  "deny": [{"pattern": "\\bcurl\\b.*\\|\\s*sh\\b", "reason": "no piping downloads into a shell"}],
+//	  "settings": {"airgap": true, "block_elevated": true}
+//	}
+//
+// A policy can be signed with Ed25519, the signature kept next to it with
+// .sig added to the name, so it is only trusted as its authors wrote it.
+package policy
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FetchTimeout bounds fetching a policy from a URL
+const FetchTimeout = 10 * time.Second
+
+// maxRedirects is how many redirects a fetch follows, as Go's default
+// client does
+const maxRedirects = 10
+
+// DenyRule refuses generated commands matching Pattern, a regular
+// expression, for Reason
+type DenyRule struct {
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason"`
+
+	re *regexp.Regexp
+}
+
+// Policy is what an organization enforces on every user: commands denied
+// whatever is confirmed, and config keys set to values users can't change
+type Policy struct {
+	Source   string         `json:"-"`
+	Deny     []DenyRule     `json:"deny"`
+	Settings map[string]any `json:"settings"`
+}
+
+// Read reads a policy file, or fetches it from a URL. URLs must be https
+// unless allow accepts them; allow, when set, is asked before any URL is
+// fetched, redirects included, and its error stops the fetch. There is
+// deliberately no cached copy to fall back on, which users could edit.
+func Read(source string, allow func(u *url.URL) error) ([]byte, error) {
+	if !strings.Contains(source, "://") {
+		return os.ReadFile(source)
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if allow == nil {
+		allow = func(u *url.URL) error {
+			if u.Scheme != "https" {
+				return fmt.Errorf("fetch it over https")
+			}
+			return nil
+		}
+	}
+	if err := allow(u); err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout: FetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("stopped after 10 redirects")
+			}
+			return allow(req.URL)
+		},
+	}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching it returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// SignatureSource is where the signature of the policy at source is: the
+// same file or URL, with .sig added to its name
+func SignatureSource(source string) string {
+	if strings.Contains(source, "://") {
+		if u, err := url.Parse(source); err == nil {
+			u.Path += ".sig"
+			return u.String()
+		}
+	}
+	return source + ".sig"
+}
+
+// ParsePublicKey reads an Ed25519 public key, either PEM encoded as
+// openssl writes it or its 32 bytes in base64
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if ed, ok := key.(ed25519.PublicKey); ok {
+			return ed, nil
+		}
+		return nil, errors.New("the public key is not an Ed25519 key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("the public key is neither PEM nor 32 bytes in base64")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Verify checks that signature, an Ed25519 signature in base64 or its 64
+// raw bytes, is key's signature of data exactly as read
+func Verify(data, signature []byte, key ed25519.PublicKey) error {
+	raw := signature
+	if len(raw) != ed25519.SignatureSize {
+		var err error
+		if raw, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err != nil {
+			return errors.New("the signature is not base64")
+		}
+	}
+	if !ed25519.Verify(key, data, raw) {
+		return errors.New("the signature does not match the policy")
+	}
+	return nil
+}
+
+// Parse parses a policy read from source, checking its patterns compile
+func Parse(data []byte, source string) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	p.Source = source
+	for i, rule := range p.Deny {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("deny rule %d: %v", i+1, err)
+		}
+		p.Deny[i].re = re
+		if rule.Reason == "" {
+			p.Deny[i].Reason = "matches " + rule.Pattern
+		}
+	}
+	return &p, nil
+}
+
+// Keys lists the config keys the policy sets, sorted
+func (p *Policy) Keys() []string {
+	keys := make([]string, 0, len(p.Settings))
+	for key := range p.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Value returns the string the policy sets key to; lists are joined with
+// commas
+func (p *Policy) Value(key string) string {
+	return settingValue(p.Settings[key])
+}
+
+// settingValue turns a setting's JSON value into the string config keys
+// are set from
+func settingValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = settingValue(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// Apply calls set for each setting in the policy, in key order, stopping
+// at the first error
+func (p *Policy) Apply(set func(key, value string) error) error {
+	for _, key := range p.Keys() {
+		if err := set(key, p.Value(key)); err != nil {
+			return fmt.Errorf("settings: %v", err)
+		}
+	}
+	return nil
+}
+
+// Locks reports whether the policy sets key
+func (p *Policy) Locks(key string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.Settings[key]
+	return ok
+}
+
+// Denied returns the reasons the policy refuses command, if any
+func (p *Policy) Denied(command string) []string {
+	var reasons []string
+	for _, rule := range p.Deny {
+		if rule.re.MatchString(command) {
+			reasons = append(reasons, rule.Reason)
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sample = `{
+  "deny": [
+    {"pattern": "\\bterraform\\s+destroy\\b", "reason": "no terraform destroy"},
+    {"pattern": "--context[= ]prod"}
+  ],
+  "settings": {"block_elevated": true, "allowed_hosts": ["llm.corp", ".internal"], "sql_limit": 100}
+}`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(sample), "policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Source != "policy.json" {
+		t.Errorf("Source = %q", p.Source)
+	}
+
+	denied := []struct {
+		command string
+		reasons []string
+	}{
+		{"terraform destroy -auto-approve", []string{"no terraform destroy"}},
+		{"kubectl --context=prod delete pod x", []string{"matches --context[= ]prod"}},
+		{"terraform plan", nil},
+	}
+	for _, test := range denied {
+		if got := p.Denied(test.command); !reflect.DeepEqual(got, test.reasons) {
+			t.Errorf("Denied(%q) = %q, want %q", test.command, got, test.reasons)
+		}
+	}
+
+	if got, want := p.Keys(), []string{"allowed_hosts", "block_elevated", "sql_limit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+	var set [][2]string
+	p.Apply(func(key, value string) error {
+		set = append(set, [2]string{key, value})
+		return nil
+	})
+	want := [][2]string{{"allowed_hosts", "llm.corp,.internal"}, {"block_elevated", "true"}, {"sql_limit", "100"}}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("Apply set %q, want %q", set, want)
+	}
+	if err := p.Apply(func(key, value string) error { return errors.New("refused") }); err == nil {
+		t.Error("Apply returned no error when set refused a setting")
+	}
+
+	if !p.Locks("block_elevated") || p.Locks("model") {
+		t.Error("Locks reports the wrong keys")
+	}
+	var none *Policy
+	if none.Locks("model") {
+		t.Error("a nil policy locks keys")
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, data := range []string{
+		`{"deny": [`,
+		`not json`,
+		`{"deny": [{"pattern": "(unclosed"}]}`,
+		`{"deny": {"pattern": "x"}}`,
+	} {
+		if _, err := Parse([]byte(data), "policy.json"); err == nil {
+			t.Errorf("Parse(%q) returned no error", data)
+		}
+	}
+}
+
+func TestSignatureSource(t *testing.T) {
+	tests := []struct{ source, want string }{
+		{"/etc/ai-terminal-tui/policy.json", "/etc/ai-terminal-tui/policy.json.sig"},
+		{"https://corp.example/policy.json", "https://corp.example/policy.json.sig"},
+		{"https://corp.example/policy.json?v=2", "https://corp.example/policy.json.sig?v=2"},
+	}
+	for _, test := range tests {
+		if got := SignatureSource(test.source); got != test.want {
+			t.Errorf("SignatureSource(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string]string{
+		"PEM":    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		"base64": base64.StdEncoding.EncodeToString(public) + "\n",
+	} {
+		key, err := ParsePublicKey([]byte(data))
+		if err != nil || !key.Equal(public) {
+			t.Errorf("ParsePublicKey(%s) = %x, %v", name, key, err)
+		}
+	}
+	for _, data := range []string{"", "not a key", base64.StdEncoding.EncodeToString([]byte("too short"))} {
+		if _, err := ParsePublicKey([]byte(data)); err == nil {
+			t.Errorf("ParsePublicKey(%q) returned no error", data)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(sample)
+	signature := ed25519.Sign(private, data)
+	encoded := []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		signature []byte
+		key       ed25519.PublicKey
+		ok        bool
+	}{
+		{"base64 signature", data, encoded, public, true},
+		{"raw signature", data, signature, public, true},
+		{"edited policy", append([]byte(sample), ' '), encoded, public, false},
+		{"other key", data, encoded, other, false},
+		{"empty signature", data, nil, public, false},
+		{"not base64", data, []byte("not a signature!"), public, false},
+	}
+	for _, test := range tests {
+		if err := Verify(test.data, test.signature, test.key); (err == nil) != test.ok {
+			t.Errorf("%s: Verify = %v, want ok %t", test.name, err, test.ok)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(sample), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := Read(path, nil); err != nil || string(data) != sample {
+		t.Errorf("Read(file) = %q, %v", data, err)
+	}
+	if _, err := Read(path+".sig", nil); err == nil {
+		t.Error("Read of a missing file returned no error")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/policy.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(sample))
+	}))
+	defer server.Close()
+
+	// Plain http is refused unless allowed
+	if _, err := Read(server.URL+"/policy.json", nil); err == nil {
+		t.Error("Read fetched a policy over http")
+	}
+	allow := func(u *url.URL) error { return nil }
+	if data, err := Read(server.URL+"/policy.json", allow); err != nil || string(data) != sample {
+		t.Errorf("Read(URL) = %q, %v", data, err)
+	}
+	if _, err := Read(server.URL+"/policy.json.sig", allow); err == nil {
+		t.Error("Read of a missing URL returned no error")
+	}
+	// Redirects are checked like the first URL
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+r.URL.Path, http.StatusPermanentRedirect)
+	}))
+	defer redirect.Close()
+	onlyRedirect := func(u *url.URL) error {
+		if u.Host != strings.TrimPrefix(redirect.URL, "http://") {
+			return errors.New("not internal")
+		}
+		return nil
+	}
+	if _, err := Read(redirect.URL+"/policy.json", onlyRedirect); err == nil || !strings.Contains(err.Error(), "not internal") {
+		t.Errorf("Read through a refused redirect = %v", err)
+	}
+
+	refuse := func(u *url.URL) error { return errors.New("air-gapped") }
+	if _, err := Read(server.URL+"/policy.json", refuse); err == nil || err.Error() != "air-gapped" {
+		t.Errorf("Read with allow refusing = %v", err)
+	}
+}
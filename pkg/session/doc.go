@@ -0,0 +1,6 @@
+// Package session runs a shell on a pseudo-terminal, the way the TUI hosts
+// the user's shell, for other programs to embed: start one with NewPTY,
+// read its output, write keys to it and ask whether a command holds the
+// terminal with Busy and Foreground. On Windows the shell runs on plain
+// pipes, without resizing or a foreground process to tell by.
+package session
@@ -0,0 +1,171 @@
+//go:build !windows
+
+package session
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// PTY represents a pseudo-terminal interface
+type PTY struct {
+	file   *os.File
+	cmd    *exec.Cmd
+	width  int
+	height int
+}
+
+// PTYInterface defines the cross-platform PTY interface
+type PTYInterface interface {
+	Read(p []byte) (n int, err error)
+	Write(p []byte) (n int, err error)
+	Close() error
+	Resize(width, height int) error
+	SetSize(width, height int) error
+}
+
+// NewPTY creates a new PTY with the specified shell
+func NewPTY(shell string) (*PTY, error) {
+	cmd := exec.Command(shell)
+
+	// Start the command with a PTY
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PTY{
+		file: ptmx,
+		cmd:  cmd,
+	}, nil
+}
+
+// NewLinePTY starts shell for line mode, with TERM=dumb and without line
+// editing, which redraws the line with cursor movement; the terminal
+// driver then echoes and edits what is typed
+func NewLinePTY(shell string) (*PTY, error) {
+	var args []string
+	switch filepath.Base(shell) {
+	case "bash":
+		args = []string{"--noediting", "-i"}
+	case "zsh":
+		args = []string{"+Z", "-i"}
+	}
+	cmd := exec.Command(shell, args...)
+	cmd.Env = append(os.Environ(), "TERM=dumb")
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &PTY{
+		file: ptmx,
+		cmd:  cmd,
+	}, nil
+}
+
+// Read reads from the PTY
+func (p *PTY) Read(buf []byte) (int, error) {
+	return p.file.Read(buf)
+}
+
+// Write writes to the PTY
+func (p *PTY) Write(buf []byte) (int, error) {
+	return p.file.Write(buf)
+}
+
+// Close closes the PTY
+func (p *PTY) Close() error {
+	if p.file != nil {
+		p.file.Close()
+	}
+	if p.cmd != nil && p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// Resize resizes the PTY
+func (p *PTY) Resize(width, height int) error {
+	p.width = width
+	p.height = height
+	return pty.Setsize(p.file, &pty.Winsize{
+		Rows: uint16(height),
+		Cols: uint16(width),
+	})
+}
+
+// SetSize sets the PTY size (alias for Resize)
+func (p *PTY) SetSize(width, height int) error {
+	return p.Resize(width, height)
+}
+
+// WorkingDir returns the shell's current working directory, or an empty
+// string when it cannot be determined (only Linux exposes it via /proc)
+func (p *PTY) WorkingDir() string {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return ""
+	}
+	dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", p.cmd.Process.Pid))
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// Busy reports whether a command is running in the shell's foreground, as
+// the terminal's foreground process group is then no longer the shell's
+func (p *PTY) Busy() (busy, known bool) {
+	pgrp, ok := p.foregroundGroup()
+	if !ok {
+		return false, false
+	}
+	return pgrp != p.cmd.Process.Pid, true
+}
+
+// Foreground returns the name of the program in the terminal's foreground,
+// or an empty string when it cannot be determined (only Linux exposes it
+// via /proc)
+func (p *PTY) Foreground() string {
+	pgrp, ok := p.foregroundGroup()
+	if !ok {
+		return ""
+	}
+	name, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pgrp))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(name))
+}
+
+// foregroundGroup returns the terminal's foreground process group
+func (p *PTY) foregroundGroup() (int, bool) {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return 0, false
+	}
+	conn, err := p.file.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	pgrp, ioctlErr := 0, error(nil)
+	if err := conn.Control(func(fd uintptr) {
+		pgrp, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	}); err != nil || ioctlErr != nil {
+		return 0, false
+	}
+	return pgrp, true
+}
+
+// DefaultShell returns the default shell for Unix systems
+func DefaultShell() string {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/bash"
+	}
+	return shell
+}
@@ -0,0 +1,84 @@
+//go:build !windows
+
+package session
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readUntil reads from p until want appears or the timeout passes
+func readUntil(t *testing.T, p *PTY, want string) string {
+	t.Helper()
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 4096)
+		for !strings.Contains(out.String(), want) {
+			n, err := p.Read(buf)
+			if err != nil {
+				return
+			}
+			out.Write(buf[:n])
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		p.Close()
+		<-done
+		t.Fatalf("no %q in output %q", want, out.String())
+	}
+	return out.String()
+}
+
+func TestPTY(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewPTY("/bin/sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Resize(100, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Write([]byte("cd " + dir + " && stty size && echo do''ne\n"))
+	out := readUntil(t, p, "done")
+	if !strings.Contains(out, "30 100") {
+		t.Errorf("stty size printed %q, want 30 100", out)
+	}
+
+	if runtime.GOOS == "linux" {
+		if got := p.WorkingDir(); got != dir {
+			t.Errorf("WorkingDir() = %q, want %q", got, dir)
+		}
+		if busy, known := p.Busy(); !known || busy {
+			t.Errorf("Busy() = %t, %t at the prompt", busy, known)
+		}
+		p.Write([]byte("sleep 5\n"))
+		deadline := time.Now().Add(5 * time.Second)
+		for p.Foreground() != "sleep" && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if busy, _ := p.Busy(); !busy || p.Foreground() != "sleep" {
+			t.Errorf("Busy() = %t, Foreground() = %q while sleep runs", busy, p.Foreground())
+		}
+	}
+}
+
+func TestDefaultShell(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+	if got := DefaultShell(); got != "/bin/zsh" {
+		t.Errorf("DefaultShell() = %q with SHELL set", got)
+	}
+	os.Unsetenv("SHELL")
+	if got := DefaultShell(); got != "/bin/bash" {
+		t.Errorf("DefaultShell() = %q without SHELL", got)
+	}
+}
@@ -1,14 +1,12 @@
 //go:build windows
 
-package main
+package session
 
 import (
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
-
-	"golang.org/x/sys/windows"
 )
 
 // PTY represents a pseudo-terminal interface for Windows
@@ -26,7 +24,7 @@ type PTY struct {
 func NewPTY(shell string) (*PTY, error) {
 	// On Windows, we use cmd.exe or PowerShell
 	if shell == "" {
-		shell = GetDefaultShell()
+		shell = DefaultShell()
 	}
 
 	cmd := exec.Command(shell)
@@ -66,6 +64,12 @@ func NewPTY(shell string) (*PTY, error) {
 	}, nil
 }
 
+// NewLinePTY starts shell for line mode. Windows shells already run on
+// plain pipes here.
+func NewLinePTY(shell string) (*PTY, error) {
+	return NewPTY(shell)
+}
+
 // Read reads from the PTY (from stdout)
 func (p *PTY) Read(buf []byte) (int, error) {
 	return p.stdout.Read(buf)
@@ -108,8 +112,26 @@ func (p *PTY) SetSize(width, height int) error {
 	return p.Resize(width, height)
 }
 
-// GetDefaultShell returns the default shell for Windows
-func GetDefaultShell() string {
+// WorkingDir returns the shell's current working directory
+// Note: Windows offers no simple way to query another process's directory
+func (p *PTY) WorkingDir() string {
+	return ""
+}
+
+// Busy reports whether a command is running in the shell
+// Note: the console has no foreground process group to tell by
+func (p *PTY) Busy() (busy, known bool) {
+	return false, false
+}
+
+// Foreground returns the name of the program in the foreground
+// Note: the console has no foreground process group to tell by
+func (p *PTY) Foreground() string {
+	return ""
+}
+
+// DefaultShell returns the default shell for Windows
+func DefaultShell() string {
 	// Try to find PowerShell first
 	psPath := `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`
 	if _, err := os.Stat(psPath); err == nil {
@@ -132,84 +154,3 @@ func GetDefaultShell() string {
 
 	return `C:\Windows\System32\cmd.exe`
 }
-
-// IsTerminal checks if the given file descriptor is a terminal on Windows
-func IsTerminal(fd int) bool {
-	var mode uint32
-	err := windows.GetConsoleMode(windows.Handle(fd), &mode)
-	return err == nil
-}
-
-// GetTerminalSize returns the size of the terminal on Windows
-func GetTerminalSize(fd int) (width, height int, err error) {
-	var info windows.ConsoleScreenBufferInfo
-	err = windows.GetConsoleScreenBufferInfo(windows.Handle(fd), &info)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	width = int(info.Window.Right - info.Window.Left + 1)
-	height = int(info.Window.Bottom - info.Window.Top + 1)
-	return width, height, nil
-}
-
-// WindowsTerminalState stores the previous console mode
-type WindowsTerminalState struct {
-	stdinMode  uint32
-	stdoutMode uint32
-}
-
-// MakeRaw puts the terminal into raw mode on Windows
-func MakeRaw(fd int) (*WindowsTerminalState, error) {
-	var state WindowsTerminalState
-
-	// Get current console mode for stdin
-	err := windows.GetConsoleMode(windows.Handle(fd), &state.stdinMode)
-	if err != nil {
-		return nil, err
-	}
-
-	// Set raw mode (disable echo, line input, etc.)
-	rawMode := state.stdinMode &^ (windows.ENABLE_ECHO_INPUT | windows.ENABLE_LINE_INPUT | windows.ENABLE_PROCESSED_INPUT)
-	rawMode |= windows.ENABLE_VIRTUAL_TERMINAL_INPUT
-
-	err = windows.SetConsoleMode(windows.Handle(fd), rawMode)
-	if err != nil {
-		return nil, err
-	}
-
-	return &state, nil
-}
-
-// Restore restores the terminal to a previous state on Windows
-func Restore(fd int, state *WindowsTerminalState) error {
-	if state == nil {
-		return nil
-	}
-	return windows.SetConsoleMode(windows.Handle(fd), state.stdinMode)
-}
-
-// SetupTerminal prepares the terminal for the TUI on Windows
-func SetupTerminal() (*WindowsTerminalState, error) {
-	return MakeRaw(int(os.Stdin.Fd()))
-}
-
-// RestoreTerminal restores the terminal to normal mode on Windows
-func RestoreTerminal(state *WindowsTerminalState) error {
-	return Restore(int(os.Stdin.Fd()), state)
-}
-
-// KillProcess kills a process on Windows
-func KillProcess(process *os.Process) error {
-	return process.Kill()
-}
-
-// IsWindows returns true for Windows systems
-func IsWindows() bool {
-	return true
-}
-
-// PathSeparator returns the OS-specific path separator
-func PathSeparator() string {
-	return "\\"
-}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"sync"
+)
+
+// packageManager is a package manager and the command that installs with it
+type packageManager struct {
+	name    string
+	binary  string
+	install string
+}
+
+// packageManagers are probed in order of preference for each OS. Linux
+// systems with several installed (e.g. Homebrew on Ubuntu) get the native
+// one.
+var packageManagers = map[string][]packageManager{
+	"linux": {
+		{"apt", "apt-get", "sudo apt install"},
+		{"dnf", "dnf", "sudo dnf install"},
+		{"yum", "yum", "sudo yum install"},
+		{"pacman", "pacman", "sudo pacman -S"},
+		{"zypper", "zypper", "sudo zypper install"},
+		{"apk", "apk", "sudo apk add"},
+		{"xbps", "xbps-install", "sudo xbps-install"},
+		{"emerge", "emerge", "sudo emerge"},
+		{"nix", "nix-env", "nix-env -iA"},
+		{"brew", "brew", "brew install"},
+	},
+	"darwin": {
+		{"brew", "brew", "brew install"},
+		{"port", "port", "sudo port install"},
+		{"nix", "nix-env", "nix-env -iA"},
+	},
+	"windows": {
+		{"winget", "winget", "winget install"},
+		{"choco", "choco", "choco install"},
+		{"scoop", "scoop", "scoop install"},
+	},
+	"freebsd": {
+		{"pkg", "pkg", "sudo pkg install"},
+	},
+	"openbsd": {
+		{"pkg_add", "pkg_add", "doas pkg_add"},
+	},
+}
+
+var (
+	packageManagerOnce   sync.Once
+	cachedPackageManager string
+)
+
+// DetectPackageManager returns the name of the system package manager, or
+// an empty string when none is found. The probe runs once per process.
+func DetectPackageManager() string {
+	packageManagerOnce.Do(func() {
+		for _, pm := range packageManagers[runtime.GOOS] {
+			if _, err := exec.LookPath(pm.binary); err == nil {
+				cachedPackageManager = pm.name
+				return
+			}
+		}
+	})
+	return cachedPackageManager
+}
+
+// packageManagerHint tells the model how to install software with name
+func packageManagerHint(name string) string {
+	for _, pm := range packageManagers[runtime.GOOS] {
+		if pm.name == name {
+			return "Package manager: " + name + " (install software with `" + pm.install + "`; do not use other package managers)"
+		}
+	}
+	return "Package manager: " + name + " (do not use other package managers)"
+}
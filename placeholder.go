@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// placeholderPrompt asks the model to mark values it can't know rather
+// than guess them
+const placeholderPrompt = "When the command needs a value that neither the request nor the context gives (a host name, a user, a key file), " +
+	"write a placeholder in capitals and angle brackets, like <HOST> or <KEY_FILE>, instead of guessing. "
+
+// maxPlaceholderSuggestions bounds the values offered from history for
+// each placeholder
+const maxPlaceholderSuggestions = 5
+
+// placeholderRe matches a placeholder like <HOST> or <KEY_FILE>
+var placeholderRe = regexp.MustCompile(`<([A-Z][A-Z0-9_]{0,39})>`)
+
+// Placeholders lists the names of the placeholders in command, each once,
+// in the order they appear
+func Placeholders(command string) []string {
+	var names []string
+	for _, match := range placeholderRe.FindAllStringSubmatch(command, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// FillPlaceholders puts values in for the placeholders of command. Values
+// that need quoting are quoted for shell where the placeholder isn't
+// already inside quotes.
+func FillPlaceholders(command string, values map[string]string, shell string) string {
+	mask := unquotedMask(command, shell)
+	var b strings.Builder
+	last := 0
+	for _, match := range placeholderRe.FindAllStringSubmatchIndex(command, -1) {
+		value, ok := values[command[match[2]:match[3]]]
+		if !ok {
+			continue
+		}
+		if mask[match[0]] {
+			value = QuotePath(value, shell)
+		}
+		b.WriteString(command[last:match[0]])
+		b.WriteString(value)
+		last = match[1]
+	}
+	b.WriteString(command[last:])
+	return b.String()
+}
+
+// placeholderPattern matches commands like template in the history,
+// capturing the value used for the placeholder name: the word holding it,
+// after the word before it. It is nil when the placeholder starts the
+// command, where nothing tells its value apart.
+func placeholderPattern(template, name string) *regexp.Regexp {
+	words := strings.Fields(template)
+	for i, word := range words {
+		if i == 0 || !strings.Contains(word, "<"+name+">") || placeholderRe.MatchString(words[i-1]) {
+			continue
+		}
+		var pattern strings.Builder
+		last := 0
+		for _, match := range placeholderRe.FindAllStringSubmatchIndex(word, -1) {
+			pattern.WriteString(regexp.QuoteMeta(word[last:match[0]]))
+			if word[match[2]:match[3]] == name {
+				pattern.WriteString(`([^\s'"<>]+?)`)
+			} else {
+				pattern.WriteString(`[^\s'"<>]+?`)
+			}
+			last = match[1]
+		}
+		pattern.WriteString(regexp.QuoteMeta(word[last:]))
+		return regexp.MustCompile(`(?:^|\s)` + regexp.QuoteMeta(words[i-1]) + `\s+['"]?` + pattern.String() + `['"]?(?:$|[\s;|&])`)
+	}
+	return nil
+}
+
+// placeholderSuggestions finds the values used for each placeholder of
+// command in the history, most recent first
+func placeholderSuggestions(command string, history []HistoryEntry) map[string][]string {
+	suggestions := make(map[string][]string)
+	for _, name := range Placeholders(command) {
+		pattern := placeholderPattern(command, name)
+		if pattern == nil {
+			continue
+		}
+		for i := len(history) - 1; i >= 0 && len(suggestions[name]) < maxPlaceholderSuggestions; i-- {
+			if history[i].Outcome == OutcomeRejected {
+				continue
+			}
+			if match := pattern.FindStringSubmatch(history[i].Command); match != nil && !slices.Contains(suggestions[name], match[1]) {
+				suggestions[name] = append(suggestions[name], match[1])
+			}
+		}
+	}
+	return suggestions
+}
+
+// placeholderForm asks for the values of a command's placeholders before
+// it goes to review
+type placeholderForm struct {
+	command     string
+	names       []string
+	values      map[string]string
+	current     int
+	input       textinput.Model
+	suggestions map[string][]string
+	// suggestion is the one shown in the input, -1 when it holds what was
+	// typed
+	suggestion int
+}
+
+// newPlaceholderForm starts asking for the placeholders of command, with
+// values from the session's and the logged history on offer
+func (m Model) newPlaceholderForm(command string) *placeholderForm {
+	history, _ := LoadHistory()
+	history = append(history, m.history...)
+	f := &placeholderForm{
+		command:     command,
+		names:       Placeholders(command),
+		values:      make(map[string]string),
+		suggestions: placeholderSuggestions(command, history),
+	}
+	f.input = textinput.New()
+	f.input.Prompt = ""
+	f.input.CharLimit = 0
+	f.input.Width = max(10, m.width-20)
+	f.focus(0)
+	return f
+}
+
+// focus moves to placeholder i, with the value given or else the most
+// recent one from history filled in
+func (f *placeholderForm) focus(i int) {
+	f.current = i
+	f.suggestion = -1
+	value, ok := f.values[f.names[i]]
+	if !ok && len(f.suggestions[f.names[i]]) > 0 {
+		value, f.suggestion = f.suggestions[f.names[i]][0], 0
+	}
+	f.input.SetValue(value)
+	f.input.CursorEnd()
+	f.input.Focus()
+}
+
+// updatePlaceholders handles keys while placeholders are filled in
+func (m Model) updatePlaceholders(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := m.placeholders
+	name := f.names[f.current]
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.placeholders = nil
+		m.rejectCommand(f.command)
+		m.closePrompt()
+		return m, nil
+	case tea.KeyEnter, tea.KeyTab:
+		value := strings.TrimSpace(f.input.Value())
+		if value == "" {
+			return m, nil
+		}
+		f.values[name] = value
+		for i := range f.names {
+			if next := (f.current + 1 + i) % len(f.names); f.values[f.names[next]] == "" {
+				f.focus(next)
+				return m, nil
+			}
+		}
+		if msg.Type == tea.KeyTab {
+			f.focus((f.current + 1) % len(f.names))
+			return m, nil
+		}
+		m.placeholders = nil
+		return m.proposeCommand(FillPlaceholders(f.command, f.values, m.config.Shell)), nil
+	case tea.KeyShiftTab:
+		if value := strings.TrimSpace(f.input.Value()); value != "" {
+			f.values[name] = value
+		}
+		f.focus((f.current + len(f.names) - 1) % len(f.names))
+		return m, nil
+	case tea.KeyUp, tea.KeyDown:
+		suggestions := f.suggestions[name]
+		if len(suggestions) == 0 {
+			return m, nil
+		}
+		if msg.Type == tea.KeyDown {
+			f.suggestion = min(f.suggestion+1, len(suggestions)-1)
+		} else {
+			f.suggestion = max(f.suggestion-1, 0)
+		}
+		f.input.SetValue(suggestions[f.suggestion])
+		f.input.CursorEnd()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	f.input, cmd = f.input.Update(msg)
+	return m, cmd
+}
+
+// renderPlaceholders shows the command with a field for each placeholder
+func (m Model) renderPlaceholders() string {
+	f := m.placeholders
+	boxStyle := lipgloss.NewStyle().
+		Border(roundedBorder()).
+		BorderForeground(theme.Accent).
+		Padding(0, 1).
+		Width(m.width - 2)
+	titleStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	labelStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	currentStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Fill in the values the command needs") + "\n\n")
+	b.WriteString(lipgloss.NewStyle().Width(m.width-6).Render(highlightShell(f.command)) + "\n\n")
+	width := 0
+	for _, name := range f.names {
+		width = max(width, len(name))
+	}
+	for i, name := range f.names {
+		label := fmt.Sprintf("%-*s  ", width, name)
+		if i == f.current {
+			b.WriteString(currentStyle.Render("▸ "+label) + f.input.View() + "\n")
+			continue
+		}
+		b.WriteString(labelStyle.Render("  "+label) + f.values[name] + "\n")
+	}
+	if suggestions := f.suggestions[f.names[f.current]]; len(suggestions) > 0 {
+		b.WriteString("\n" + hintStyle.Render("Used before: "+strings.Join(suggestions, ", ")) + "\n")
+	}
+	b.WriteString("\n" + hintStyle.Render("Enter to confirm each value, ↑/↓ for values used before, Tab/Shift+Tab to move, Esc to cancel"))
+	return boxStyle.Render(b.String())
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// Limits for summarising a plan
+const (
+	planTimeout     = 5 * time.Minute
+	maxPlanBytes    = 24000
+	maxPlanListings = 30
+)
+
+// Actions a plan takes on a resource, in the order they are listed
+const (
+	PlanCreate  = "create"
+	PlanUpdate  = "update"
+	PlanReplace = "replace"
+	PlanDestroy = "destroy"
+)
+
+var (
+	// planStartRe finds where a plan starts in captured output
+	planStartRe = regexp.MustCompile(`(?:Terraform|OpenTofu) (?:used the selected providers to generate|will perform the following actions)|No changes\. (?:Your infrastructure matches|Infrastructure is up-to-date)`)
+	// planResourceRe matches the header terraform prints above each change
+	planResourceRe = regexp.MustCompile(`(?m)^\s*# (\S+)(?: \(deposed object \S+\))?(?: is tainted, so)? (will be created|will be updated in-place|must be replaced|will be replaced|will be destroyed)`)
+	// planTotalsRe matches the closing "Plan: 1 to add, 2 to change, 0 to destroy."
+	planTotalsRe = regexp.MustCompile(`Plan: (?:\d+ to import, )?(\d+) to add, (\d+) to change, (\d+) to destroy`)
+	// statefulResourceRe recognises resource types that hold data, whose
+	// loss can't be undone by applying again
+	statefulResourceRe = regexp.MustCompile(`(?i)(?:^|_)(?:db|rds|database|sql|table|bucket|volume|disk|ebs|efs|storage|filesystem|file_system|snapshot|dynamodb|redis|elasticache|kms|secret)(?:_|$)`)
+)
+
+// PlanChange is one resource a plan acts on
+type PlanChange struct {
+	Address string
+	Action  string
+}
+
+// Stateful reports whether destroying the resource would lose data
+func (c PlanChange) Stateful() bool {
+	parts := strings.Split(c.Address, ".")
+	// Resources in modules are addressed module.NAME.TYPE.NAME
+	for len(parts) > 2 && parts[0] == "module" {
+		parts = parts[2:]
+	}
+	return statefulResourceRe.MatchString(parts[0])
+}
+
+// PlanSummary is what a plan would do, read from its text output
+type PlanSummary struct {
+	Changes []PlanChange
+	Add     int
+	Change  int
+	Destroy int
+	// Totals is set when the plan's closing line was found
+	Totals bool
+	// Risks are the model's highlights, one per line
+	Risks string
+}
+
+// ParsePlan reads the changes out of terraform plan's text output
+func ParsePlan(output string) PlanSummary {
+	var s PlanSummary
+	for _, match := range planResourceRe.FindAllStringSubmatch(output, -1) {
+		action := PlanUpdate
+		switch match[2] {
+		case "will be created":
+			action = PlanCreate
+		case "must be replaced", "will be replaced":
+			action = PlanReplace
+		case "will be destroyed":
+			action = PlanDestroy
+		}
+		s.Changes = append(s.Changes, PlanChange{Address: match[1], Action: action})
+	}
+	if match := planTotalsRe.FindStringSubmatch(output); match != nil {
+		s.Totals = true
+		s.Add, _ = strconv.Atoi(match[1])
+		s.Change, _ = strconv.Atoi(match[2])
+		s.Destroy, _ = strconv.Atoi(match[3])
+	}
+	return s
+}
+
+// ExtractPlan returns the last plan in captured terminal output, or "" when
+// there is none
+func ExtractPlan(output string) string {
+	starts := planStartRe.FindAllStringIndex(output, -1)
+	if len(starts) == 0 {
+		return ""
+	}
+	plan := output[starts[len(starts)-1][0]:]
+	if loc := planTotalsRe.FindStringIndex(plan); loc != nil {
+		end := strings.IndexByte(plan[loc[1]:], '\n')
+		if end >= 0 {
+			plan = plan[:loc[1]+end]
+		}
+	}
+	return plan
+}
+
+// planTool returns the IaC tool to run: terraform, or OpenTofu when only
+// it is installed
+func planTool() string {
+	if _, err := exec.LookPath("terraform"); err != nil {
+		if _, err := exec.LookPath("tofu"); err == nil {
+			return "tofu"
+		}
+	}
+	return "terraform"
+}
+
+// RunPlan runs a plan in dir without taking the state lock, so it does not
+// get in the way of runs in the shell
+func RunPlan(dir string, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), planTimeout)
+	defer cancel()
+
+	tool := planTool()
+	cmd := exec.CommandContext(ctx, tool, append([]string{"plan", "-no-color", "-input=false", "-lock=false"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("%s plan took longer than %s", tool, planTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s plan failed: %v\n%s", tool, err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// SummarizePlan parses a plan and asks the model what could go wrong
+// applying it
+func SummarizePlan(config Config, plan string) (PlanSummary, error) {
+	summary := ParsePlan(plan)
+	if len(summary.Changes) == 0 {
+		return summary, nil
+	}
+
+	if len(plan) > maxPlanBytes {
+		plan = plan[:maxPlanBytes] + "\n... (plan truncated)"
+	}
+	contents, err := chatCompletion(config, ai.Request{
+		Messages: []ai.Message{
+			{Role: "system", Content: "You review infrastructure plans before they are applied. List the risks of applying this plan, most serious first, " +
+				"one per line starting with \"- \": data loss from destroyed or replaced stateful resources, downtime, " +
+				"security exposure (public access, widened IAM, open security groups), and large cost changes. " +
+				"Name the resource each risk comes from. Respond with only the list, or \"- No notable risks.\" when there are none."},
+			{Role: "user", Content: redactText(config, plan)},
+		},
+		Temperature: 0.2,
+		MaxTokens:   500,
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Risks = strings.TrimSpace(contents[0])
+	return summary, nil
+}
+
+// String renders the summary: totals, the resources under each action, and
+// the risks
+func (s PlanSummary) String() string {
+	if len(s.Changes) == 0 {
+		return "No changes: applying this plan would do nothing.\n"
+	}
+
+	var b strings.Builder
+	if s.Totals {
+		fmt.Fprintf(&b, "%d to add, %d to change, %d to destroy\n", s.Add, s.Change, s.Destroy)
+	}
+	sections := []struct {
+		action string
+		title  string
+		symbol string
+	}{
+		{PlanDestroy, "Destroy", "-"},
+		{PlanReplace, "Replace (destroy, then create)", "-/+"},
+		{PlanUpdate, "Update in place", "~"},
+		{PlanCreate, "Create", "+"},
+	}
+	for _, section := range sections {
+		var listed []string
+		for _, change := range s.Changes {
+			if change.Action != section.action {
+				continue
+			}
+			line := fmt.Sprintf("  %s %s", section.symbol, change.Address)
+			if (change.Action == PlanDestroy || change.Action == PlanReplace) && change.Stateful() {
+				line += "  ⚠ holds data"
+			}
+			listed = append(listed, line)
+		}
+		if len(listed) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", section.title, len(listed))
+		if len(listed) > maxPlanListings {
+			listed = append(listed[:maxPlanListings], fmt.Sprintf("  ... and %d more", len(listed)-maxPlanListings))
+		}
+		b.WriteString(strings.Join(listed, "\n") + "\n")
+	}
+	if s.Risks != "" {
+		fmt.Fprintf(&b, "\nRisks:\n%s\n", s.Risks)
+	}
+	return b.String()
+}
+
+// planMsg carries a summarised plan, or why there is none
+type planMsg struct {
+	summary string
+	err     error
+}
+
+// querySummarizePlan summarises the last plan in the scrollback, or runs
+// one in the shell's directory when there is none
+func (m Model) querySummarizePlan() tea.Cmd {
+	config := m.config
+	cwd := m.shellCwd()
+	captured := ExtractPlan(plainText(m.output))
+	return func() tea.Msg {
+		plan := captured
+		if plan == "" {
+			var err error
+			if plan, err = RunPlan(cwd, nil); err != nil {
+				return planMsg{err: err}
+			}
+		}
+		summary, err := SummarizePlan(config, plan)
+		if err != nil {
+			return planMsg{err: err}
+		}
+		return planMsg{summary: summary.String()}
+	}
+}
+
+// handlePlanCommand summarises a plan read from a file, from stdin, or by
+// running terraform plan with the given arguments
+func handlePlanCommand(args []string) {
+	file := ""
+	var planArgs []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--file", "-f":
+			if i+1 >= len(args) {
+				fmt.Println("Error: --file requires a path")
+				os.Exit(1)
+			}
+			file = args[i+1]
+			i++
+		case "--":
+			planArgs = append(planArgs, args[i+1:]...)
+			i = len(args)
+		default:
+			planArgs = append(planArgs, args[i])
+		}
+	}
+
+	config := mustLoadConfig()
+	if config.LiteLLMURL == "" {
+		fmt.Println("Error: litellm_url not configured. Run 'ai-terminal-tui setup' first.")
+		os.Exit(1)
+	}
+
+	var plan string
+	switch {
+	case file == "-" || (file == "" && len(planArgs) == 0 && !IsTTY()):
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		plan = string(data)
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		plan = string(data)
+	default:
+		fmt.Fprintf(os.Stderr, "Running %s plan...\n", planTool())
+		var err error
+		if plan, err = RunPlan("", planArgs); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Captured output may hold more than the plan
+	if extracted := ExtractPlan(plainText([]byte(plan))); extracted != "" {
+		plan = extracted
+	}
+	summary, err := SummarizePlan(config, plan)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(summary)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/policy"
+)
+
+// PolicyEnv names the organization policy file, or the https URL it is
+// fetched from, when no system-wide one is installed
+const PolicyEnv = "AI_TERMINAL_TUI_POLICY"
+
+// How often a running TUI reads the policy again: a file is cheap to
+// read, a URL is fetched less often
+const (
+	policyFileReload = time.Minute
+	policyURLReload  = 5 * time.Minute
+)
+
+// PolicyPublicKey, when set at build time with
+// -ldflags "-X main.PolicyPublicKey=BASE64", is the Ed25519 key the policy
+// must be signed with, as a key installed next to the system-wide policy is
+var PolicyPublicKey string
+
+// Policy is the organization policy; see pkg/policy
+type Policy = policy.Policy
+
+// PolicyError reports a policy that is set up but could not be loaded;
+// until it loads, commands are only suggested
+type PolicyError struct {
+	Source string
+	Err    error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("organization policy %s: %v", e.Source, e.Err)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// systemPolicyPath is where administrators install the policy for every
+// user of a machine
+func systemPolicyPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(valueOrDefault(os.Getenv("ProgramData"), `C:\ProgramData`), AppName, "policy.json")
+	}
+	return filepath.Join("/etc", AppName, "policy.json")
+}
+
+// systemPolicyKeyPath is where administrators install the public key the
+// policy must be signed with
+func systemPolicyKeyPath() string {
+	return filepath.Join(filepath.Dir(systemPolicyPath()), "policy.pub")
+}
+
+// policyKey returns the key the policy must be signed with: the one built
+// in, or else the one installed; nil when neither is, and an unsigned
+// policy is taken as it is
+func policyKey() (ed25519.PublicKey, error) {
+	if PolicyPublicKey != "" {
+		return policy.ParsePublicKey([]byte(PolicyPublicKey))
+	}
+	data, err := os.ReadFile(systemPolicyKeyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return policy.ParsePublicKey(data)
+}
+
+// PolicySource returns where the policy comes from: the system-wide file
+// when installed, so users can't swap it by changing their environment,
+// otherwise the file or URL in $AI_TERMINAL_TUI_POLICY; "" when there is
+// no policy
+func PolicySource() string {
+	path := systemPolicyPath()
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return os.Getenv(PolicyEnv)
+}
+
+// The policy in force: read when first asked for, and again by
+// ReloadPolicy; policyData is what it was parsed from
+var (
+	policyMu     sync.Mutex
+	policyRead   time.Time
+	policyData   []byte
+	activePolicy *Policy
+	policyErr    error
+)
+
+// LoadPolicy returns the organization policy, or nil when there is none
+func LoadPolicy() (*Policy, error) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	if policyRead.IsZero() {
+		policyRead = time.Now()
+		policyData, activePolicy, policyErr = readActivePolicy()
+	}
+	return activePolicy, policyErr
+}
+
+// ReloadPolicy reads the policy again once that is due, reporting whether
+// what is enforced changed. A policy that no longer loads, or whose
+// signature no longer checks out, is an error, and commands are then only
+// suggested.
+func ReloadPolicy() bool {
+	policyMu.Lock()
+	due := policyRead.IsZero() || time.Since(policyRead) >= policyReloadInterval(PolicySource())
+	policyMu.Unlock()
+	if !due {
+		return false
+	}
+	// Read without the lock, so a slow fetch doesn't hold up the checks
+	// of the policy in force
+	data, p, err := readActivePolicy()
+
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	changed := !bytes.Equal(data, policyData) || (err == nil) != (policyErr == nil)
+	policyRead = time.Now()
+	policyData, activePolicy, policyErr = data, p, err
+	return changed
+}
+
+// policyReloadInterval is how often the policy at source is read again
+func policyReloadInterval(source string) time.Duration {
+	if strings.Contains(source, "://") {
+		return policyURLReload
+	}
+	return policyFileReload
+}
+
+// readActivePolicy reads and parses the policy from where PolicySource
+// says; all nil when there is none
+func readActivePolicy() ([]byte, *Policy, error) {
+	source := PolicySource()
+	if source == "" {
+		return nil, nil, nil
+	}
+	data, err := readPolicy(source)
+	var p *Policy
+	if err == nil {
+		p, err = ParsePolicy(data, source)
+	}
+	if err != nil {
+		return nil, nil, &PolicyError{Source: source, Err: err}
+	}
+	return data, p, nil
+}
+
+// readPolicy reads a policy file, or fetches it from an https URL, which
+// air-gapped mode only lets be an internal host. With a key to check it
+// against, the policy must come with a matching signature.
+func readPolicy(source string) ([]byte, error) {
+	allow := func(u *url.URL) error {
+		if u.Scheme != "https" && !(u.Scheme == "http" && isInternalHost(u.Hostname(), nil)) {
+			return fmt.Errorf("fetch it over https")
+		}
+		// The policy is read before it applies, so the user's own config
+		// tells whether the TUI is air-gapped
+		config, _ := loadUserConfig()
+		return CheckEndpoint(config, u.String())
+	}
+	data, err := policy.Read(source, allow)
+	if err != nil {
+		return nil, err
+	}
+	key, err := policyKey()
+	if err != nil {
+		return nil, fmt.Errorf("public key: %v", err)
+	}
+	if key == nil {
+		return data, nil
+	}
+	signature, err := policy.Read(policy.SignatureSource(source), allow)
+	if err != nil {
+		return nil, fmt.Errorf("signature: %v", err)
+	}
+	if err := policy.Verify(data, signature, key); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ParsePolicy parses a policy, checking its patterns compile and its
+// settings are keys and values the config accepts
+func ParsePolicy(data []byte, source string) (*Policy, error) {
+	p, err := policy.Parse(data, source)
+	if err != nil {
+		return nil, err
+	}
+	config := defaultConfig()
+	if err := p.Apply(func(key, value string) error { return setConfigKey(&config, key, value) }); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// applyPolicy enforces the organization policy on config. A policy that
+// is set up but can't be loaded leaves commands only suggested, rather
+// than running them unchecked.
+func applyPolicy(config Config) (Config, error) {
+	p, err := LoadPolicy()
+	if err != nil {
+		config.SuggestOnly = true
+		return config, err
+	}
+	if p != nil {
+		// Checked when the policy was parsed
+		p.Apply(func(key, value string) error { return setConfigKey(&config, key, value) })
+	}
+	return config, nil
+}
+
+// reapplyPolicy enforces the policy over config again once it has been
+// reloaded: suggest-only goes back to the user's own setting, then the
+// policy applies, or turns it on if it no longer loads. Settings a new
+// policy stops enforcing keep their value until the next start.
+func reapplyPolicy(config Config) (Config, error) {
+	user, _ := loadUserConfig()
+	config.SuggestOnly = user.SuggestOnly
+	return applyPolicy(config)
+}
+
+// policyReloadMsg says the policy was read again, and whether what it
+// enforces changed
+type policyReloadMsg struct {
+	changed bool
+}
+
+// watchPolicy reads the policy again in the background when it is due
+func (m Model) watchPolicy() tea.Cmd {
+	source := PolicySource()
+	if source == "" {
+		return nil
+	}
+	return tea.Tick(policyReloadInterval(source), func(time.Time) tea.Msg {
+		return policyReloadMsg{changed: ReloadPolicy()}
+	})
+}
+
+// policyLocks reports whether the organization policy sets key
+func policyLocks(key string) bool {
+	p, _ := LoadPolicy()
+	return p.Locks(key)
+}
+
+// policyLocked is the error for changing key, which the organization
+// policy sets
+func policyLocked(key string) error {
+	return fmt.Errorf("%s is set by organization policy (%s)", key, PolicySource())
+}
+
+// printPolicy describes the policy for config --show
+func printPolicy(p *Policy) {
+	fmt.Printf("\nOrganization policy: %s\n", p.Source)
+	fmt.Printf("  deny rules:    %d\n", len(p.Deny))
+	for _, key := range p.Keys() {
+		fmt.Printf("  %s: %s (enforced)\n", key, p.Value(key))
+	}
+}
+
+// doctorPolicy reports the organization policy for doctor, warning when
+// the user could edit it and so isn't bound by it
+func doctorPolicy() {
+	source := PolicySource()
+	if source == "" {
+		return
+	}
+	p, err := LoadPolicy()
+	if err != nil {
+		fmt.Printf("  ✗ %v (commands are only suggested until it loads)\n", err)
+		return
+	}
+	fmt.Printf("  ✓ organization policy %s (%d deny rules, enforces %s)\n", source, len(p.Deny), valueOrDefault(strings.Join(p.Keys(), ", "), "no settings"))
+	switch key, _ := policyKey(); {
+	case key == nil:
+		fmt.Printf("  ✗ the policy is not signed; install its Ed25519 public key as %s for it to be checked\n", systemPolicyKeyPath())
+	case PolicyPublicKey != "":
+		fmt.Println("  ✓ policy signature matches the key built in")
+	default:
+		fmt.Printf("  ✓ policy signature matches %s\n", systemPolicyKeyPath())
+	}
+	if !strings.Contains(source, "://") {
+		if f, err := os.OpenFile(source, os.O_WRONLY, 0); err == nil {
+			f.Close()
+			fmt.Println("  ✗ the policy file is writable by you; make it read-only for users to be bound by it")
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// usePolicy points the TUI at a policy file signed with a fresh key,
+// forgetting any policy read before, and returns the file and the key to
+// sign it with
+func usePolicy(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	if _, err := os.Stat(systemPolicyPath()); err == nil {
+		t.Skipf("a system-wide policy is installed at %s", systemPolicyPath())
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	path := filepath.Join(dir, "policy.json")
+	t.Setenv(PolicyEnv, path)
+
+	key := PolicyPublicKey
+	PolicyPublicKey = base64.StdEncoding.EncodeToString(public)
+	resetPolicy()
+	t.Cleanup(func() {
+		PolicyPublicKey = key
+		resetPolicy()
+	})
+	return path, private
+}
+
+// resetPolicy forgets the policy read so far
+func resetPolicy() {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policyRead, policyData, activePolicy, policyErr = time.Time{}, nil, nil, nil
+}
+
+// writePolicy writes data to path, and its signature by key when key is set
+func writePolicy(t *testing.T, path, data string, key ed25519.PrivateKey) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path + ".sig")
+	if key != nil {
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(data)))
+		if err := os.WriteFile(path+".sig", []byte(signature), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadPolicySignature(t *testing.T) {
+	path, key := usePolicy(t)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const valid = `{"settings": {"block_elevated": true}}`
+
+	tests := []struct {
+		name string
+		data string
+		key  ed25519.PrivateKey
+		ok   bool
+	}{
+		{"good signature", valid, key, true},
+		{"missing signature", valid, nil, false},
+		{"signed with another key", valid, other, false},
+		{"malformed JSON", `{"settings": `, key, false},
+	}
+	for _, test := range tests {
+		writePolicy(t, path, test.data, test.key)
+		resetPolicy()
+		config, err := LoadConfig()
+		var policyErr *PolicyError
+		if test.ok {
+			if err != nil || !config.BlockElevated || config.SuggestOnly {
+				t.Errorf("%s: LoadConfig = block_elevated %t, suggest_only %t, %v", test.name, config.BlockElevated, config.SuggestOnly, err)
+			}
+		} else if !errors.As(err, &policyErr) || !config.SuggestOnly {
+			t.Errorf("%s: LoadConfig = suggest_only %t, %v, want suggest-only and a PolicyError", test.name, config.SuggestOnly, err)
+		}
+	}
+
+	// An edited policy no longer matches its signature
+	writePolicy(t, path, valid, key)
+	os.WriteFile(path, []byte(`{"settings": {"block_elevated": false}}`), 0600)
+	resetPolicy()
+	if _, err := LoadPolicy(); err == nil {
+		t.Error("LoadPolicy accepted a policy edited after it was signed")
+	}
+
+	// Without a key, a policy needs no signature
+	PolicyPublicKey = ""
+	writePolicy(t, path, valid, nil)
+	resetPolicy()
+	if p, err := LoadPolicy(); err != nil || p == nil {
+		t.Errorf("LoadPolicy without a key = %v, %v", p, err)
+	}
+}
+
+func TestReloadPolicy(t *testing.T) {
+	path, key := usePolicy(t)
+	writePolicy(t, path, `{"settings": {"block_elevated": true}}`, key)
+	config, err := LoadConfig()
+	if err != nil || !config.BlockElevated {
+		t.Fatalf("LoadConfig = block_elevated %t, %v", config.BlockElevated, err)
+	}
+	// reload reads the policy again as if it were due
+	reload := func() bool {
+		policyMu.Lock()
+		policyRead = time.Now().Add(-policyFileReload)
+		policyMu.Unlock()
+		return ReloadPolicy()
+	}
+
+	if ReloadPolicy() {
+		t.Error("ReloadPolicy read the policy again before it was due")
+	}
+	if reload() {
+		t.Error("ReloadPolicy reported a change in a policy that didn't change")
+	}
+
+	writePolicy(t, path, `{"settings": {"block_elevated": false}}`, key)
+	if !reload() {
+		t.Fatal("ReloadPolicy missed a changed policy")
+	}
+	if config, err = reapplyPolicy(config); err != nil || config.BlockElevated {
+		t.Errorf("after a change: block_elevated %t, %v", config.BlockElevated, err)
+	}
+
+	// A policy whose signature stops matching fails closed
+	os.WriteFile(path, []byte(`{"settings": {}}`), 0600)
+	if !reload() {
+		t.Fatal("ReloadPolicy missed a policy that no longer verifies")
+	}
+	var policyErr *PolicyError
+	if config, err = reapplyPolicy(config); !errors.As(err, &policyErr) || !config.SuggestOnly {
+		t.Errorf("after a bad signature: suggest_only %t, %v", config.SuggestOnly, err)
+	}
+
+	// and opens again once it is signed
+	writePolicy(t, path, `{"settings": {}}`, key)
+	if !reload() {
+		t.Fatal("ReloadPolicy missed a policy that verifies again")
+	}
+	if config, err = reapplyPolicy(config); err != nil || config.SuggestOnly {
+		t.Errorf("after signing again: suggest_only %t, %v", config.SuggestOnly, err)
+	}
+}
+
+func TestPolicyReloadInterval(t *testing.T) {
+	if got := policyReloadInterval("/etc/ai-terminal-tui/policy.json"); got != policyFileReload {
+		t.Errorf("file reload interval = %v", got)
+	}
+	if got := policyReloadInterval("https://corp.example/policy.json"); got != policyURLReload {
+		t.Errorf("URL reload interval = %v", got)
+	}
+}
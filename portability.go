@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Userland flavours whose command-line tools differ in flags and syntax
+const (
+	UserlandGNU     = "gnu"
+	UserlandBSD     = "bsd"
+	UserlandBusyBox = "busybox"
+	UserlandWindows = "windows"
+)
+
+var (
+	userlandOnce   sync.Once
+	cachedUserland string
+)
+
+// DetectUserland reports which flavour of core utilities is installed. GNU
+// tools answer --version, BSD tools reject it, and BusyBox names itself.
+// The result is cached for the life of the process.
+func DetectUserland() string {
+	userlandOnce.Do(func() {
+		cachedUserland = detectUserland()
+	})
+	return cachedUserland
+}
+
+func detectUserland() string {
+	if runtime.GOOS == "windows" {
+		return UserlandWindows
+	}
+
+	out, err := exec.Command("sed", "--version").CombinedOutput()
+	text := strings.ToLower(string(out))
+	switch {
+	case strings.Contains(text, "busybox") || strings.Contains(text, "not gnu"):
+		return UserlandBusyBox
+	case err == nil && strings.Contains(text, "gnu"):
+		return UserlandGNU
+	case runtime.GOOS == "linux":
+		return UserlandGNU
+	}
+	return UserlandBSD
+}
+
+// userlandHints returns prompt guidance for the detected userland
+func userlandHints(userland string) string {
+	switch userland {
+	case UserlandBSD:
+		return "Core utilities are BSD variants, not GNU: use `sed -i ''` for in-place edits, " +
+			"`sed -E` instead of `sed -r`, `date -v`/`date -j -f` instead of `date -d`, " +
+			"`stat -f` instead of `stat -c`, `du -d` instead of `--max-depth`, and avoid `grep -P`, " +
+			"`find -printf` and other GNU-only long options."
+	case UserlandGNU:
+		return "Core utilities are GNU coreutils: use `sed -i` without a suffix argument, `date -d`, `stat -c`."
+	case UserlandBusyBox:
+		return "Core utilities are BusyBox applets: stick to POSIX options and avoid GNU long options."
+	}
+	return ""
+}
+
+// portabilityRule flags a construct that breaks on a particular userland
+type portabilityRule struct {
+	userland string
+	pattern  *regexp.Regexp
+	message  string
+}
+
+var portabilityRules = []portabilityRule{
+	// GNU-only constructs that misbehave on BSD/macOS
+	{UserlandBSD, regexp.MustCompile(`\bsed\s+(-[a-zA-Z]*\s+)*-i\s+['"]?[^'"\s-]`), "BSD sed -i requires a backup suffix argument; use sed -i '' ..."},
+	{UserlandBSD, regexp.MustCompile(`\bsed\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*r`), "BSD sed does not support -r; use -E"},
+	{UserlandBSD, regexp.MustCompile(`\bdate\s+([^|;&\n]*\s)?(-d\b|--date\b)`), "BSD date has no -d/--date; use -v or -j -f"},
+	{UserlandBSD, regexp.MustCompile(`\bdate\s+([^|;&\n]*\s)?--(?:iso-8601|rfc-3339|rfc-email|utc|universal|reference)\b`), "BSD date has no long options; use -I, -R, -u, -r or +FORMAT"},
+	{UserlandBSD, regexp.MustCompile(`\bstat\s+([^|;&\n]*\s)?(-c\b|--format\b|--printf\b)`), "BSD stat uses -f FORMAT, not -c/--format"},
+	{UserlandBSD, regexp.MustCompile(`\bdu\s+([^|;&\n]*\s)?--max-depth\b`), "BSD du uses -d DEPTH, not --max-depth"},
+	{UserlandBSD, regexp.MustCompile(`\bgrep\s+([^|;&\n]*\s)?-[a-zA-Z]*P`), "BSD grep has no -P (Perl regex); use -E or perl"},
+	{UserlandBSD, regexp.MustCompile(`\bfind\s+[^|;&\n]*-printf\b`), "BSD find has no -printf; use -exec stat or -print"},
+	{UserlandBSD, regexp.MustCompile(`\bxargs\s+([^|;&\n]*\s)?(-r\b|--no-run-if-empty\b)`), "BSD xargs has no -r; it already skips empty input"},
+	{UserlandBSD, regexp.MustCompile(`\bbase64\s+([^|;&\n]*\s)?-w\s*\d`), "BSD base64 has no -w; use -b"},
+	{UserlandBSD, regexp.MustCompile(`\bls\s+([^|;&\n]*\s)?--color\b`), "BSD ls uses -G for colour, not --color"},
+	{UserlandBSD, regexp.MustCompile(`\bhead\s+([^|;&\n]*\s)?-n\s*-\d`), "BSD head does not accept negative line counts"},
+	{UserlandBSD, regexp.MustCompile(`\breadlink\s+([^|;&\n]*\s)?-f\b`), "readlink -f is missing on older macOS; realpath is safer"},
+
+	// BSD-only constructs that misbehave on GNU
+	{UserlandGNU, regexp.MustCompile(`\bsed\s+(-[a-zA-Z]*\s+)*-i\s*(''|"")`), "GNU sed treats '' after -i as the script; use sed -i without a suffix"},
+	{UserlandGNU, regexp.MustCompile(`\bdate\s+([^|;&\n]*\s)?-v\s*[+-]?\d`), "GNU date has no -v adjustments; use -d 'N days ago'"},
+	{UserlandGNU, regexp.MustCompile(`\bdate\s+([^|;&\n]*\s)?-j\b`), "GNU date has no -j; use -d"},
+	{UserlandGNU, regexp.MustCompile(`\bdate\s+([^|;&\n]*\s)?-r\s*\d{5,}\b`), "GNU date -r takes a file, not seconds; use -d @SECONDS"},
+	{UserlandGNU, regexp.MustCompile(`\bstat\s+([^|;&\n]*\s)?-f\s*['"]?%`), "GNU stat -f reports filesystem status; use -c FORMAT"},
+}
+
+// CheckPortability returns warnings for constructs in command that are known
+// not to work with the given userland
+func CheckPortability(command, userland string) []string {
+	var warnings []string
+	for _, rule := range portabilityRules {
+		if rule.userland == userland && rule.pattern.MatchString(command) {
+			warnings = append(warnings, rule.message)
+		}
+	}
+	return warnings
+}
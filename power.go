@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// powerCacheTTL is how long a read power state is reused: batteries change
+// slowly, and on macOS reading one runs pmset
+const powerCacheTTL = time.Minute
+
+// PowerStatus is whether the machine runs on battery, and how charged the
+// battery is
+type PowerStatus struct {
+	HasBattery bool
+	OnBattery  bool
+	// Charge is the battery level in percent, or -1 when unknown
+	Charge int
+}
+
+// String describes the power state, e.g. "on battery, 42%"
+func (p PowerStatus) String() string {
+	state := "plugged in"
+	if p.OnBattery {
+		state = "on battery"
+	}
+	if p.Charge < 0 {
+		return state
+	}
+	return fmt.Sprintf("%s, %d%%", state, p.Charge)
+}
+
+// powerCache keeps the last power state read
+var powerCache struct {
+	sync.Mutex
+	status PowerStatus
+	taken  time.Time
+}
+
+// CurrentPower returns the power state, reading it at most once per
+// powerCacheTTL
+func CurrentPower() PowerStatus {
+	powerCache.Lock()
+	defer powerCache.Unlock()
+	if powerCache.taken.IsZero() || time.Since(powerCache.taken) > powerCacheTTL {
+		powerCache.status, powerCache.taken = ReadPower(), time.Now()
+	}
+	return powerCache.status
+}
+
+// batteryWarning warns about a heavy command up for review while on
+// battery, or returns "" when it isn't heavy or the machine is plugged in
+func (m Model) batteryWarning() string {
+	if !m.config.BatteryWarning || m.estimate == nil || m.estimate.Duration < heavyOperation {
+		return ""
+	}
+	power := CurrentPower()
+	if !power.OnBattery {
+		return ""
+	}
+	return fmt.Sprintf("Running %s: this heavy command will drain the battery", power)
+}
+
+// powerSaving reports whether background AI activity is paused, because
+// the battery is below battery_saver
+func (m Model) powerSaving() bool {
+	if m.config.BatterySaver <= 0 {
+		return false
+	}
+	power := CurrentPower()
+	return power.OnBattery && power.Charge >= 0 && power.Charge < m.config.BatterySaver
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// pmsetChargeRe finds the charge in pmset -g batt output
+var pmsetChargeRe = regexp.MustCompile(`(\d+)%`)
+
+// ReadPower reads the power state from sysfs on Linux and pmset on macOS
+func ReadPower() PowerStatus {
+	status := PowerStatus{Charge: -1}
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("pmset", "-g", "batt").Output()
+		if err != nil {
+			return status
+		}
+		status.HasBattery = strings.Contains(string(out), "InternalBattery")
+		status.OnBattery = strings.Contains(string(out), "'Battery Power'")
+		if match := pmsetChargeRe.FindSubmatch(out); match != nil && status.HasBattery {
+			status.Charge, _ = strconv.Atoi(string(match[1]))
+		}
+		return status
+	}
+
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	pluggedIn := false
+	for _, supply := range supplies {
+		read := func(name string) string {
+			data, _ := os.ReadFile(filepath.Join(supply, name))
+			return strings.TrimSpace(string(data))
+		}
+		switch read("type") {
+		case "Mains", "USB":
+			if read("online") == "1" {
+				pluggedIn = true
+			}
+		case "Battery":
+			// Peripherals like mice report batteries of their own
+			if read("scope") == "Device" {
+				continue
+			}
+			status.HasBattery = true
+			if read("status") == "Discharging" {
+				status.OnBattery = true
+			}
+			if charge, err := strconv.Atoi(read("capacity")); err == nil {
+				status.Charge = charge
+			}
+		}
+	}
+	status.OnBattery = status.OnBattery && !pluggedIn
+	return status
+}
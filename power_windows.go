@@ -0,0 +1,38 @@
+//go:build windows
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// getSystemPowerStatus reports the power source and battery charge
+var getSystemPowerStatus = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// systemPowerStatus mirrors SYSTEM_POWER_STATUS
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// ReadPower reads the power state from GetSystemPowerStatus
+func ReadPower() PowerStatus {
+	status := PowerStatus{Charge: -1}
+	var s systemPowerStatus
+	if ok, _, _ := getSystemPowerStatus.Call(uintptr(unsafe.Pointer(&s))); ok == 0 {
+		return status
+	}
+	// 128 means there is no battery, 255 that its state is unknown
+	status.HasBattery = s.BatteryFlag&128 == 0 && s.BatteryFlag != 255
+	status.OnBattery = status.HasBattery && s.ACLineStatus == 0
+	if status.HasBattery && s.BatteryLifePercent <= 100 {
+		status.Charge = int(s.BatteryLifePercent)
+	}
+	return status
+}
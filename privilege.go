@@ -0,0 +1,128 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	// elevatorRe matches a command that runs as root or Administrator by
+	// starting with sudo, doas, su, pkexec or runas
+	elevatorRe = regexp.MustCompile(`^\s*(?:\w+=\S*\s+)*(?:(?:env|time|nohup|command|exec)\s+)*(sudo|doas|su|pkexec|gsudo|runas)(?:\.exe)?(?:\s|$)`)
+	// runAsRe matches PowerShell starting an elevated process
+	runAsRe = regexp.MustCompile(`(?i)\bStart-Process\b.*\s-Verb\s+RunAs\b`)
+	// systemPathRe matches paths the system owns
+	systemPathRe = regexp.MustCompile(`^(?:/(?:etc|usr|bin|sbin|lib|lib32|lib64|boot|opt|srv|System|Library)(?:/|$)|/var/(?:lib|spool|www)(?:/|$)|(?i)[A-Z]:\\(?:Windows|Program Files(?: \(x86\))?|ProgramData)(?:\\|$))`)
+	// redirectTargetRe finds the files output is redirected to
+	redirectTargetRe = regexp.MustCompile(`\d?>>?\s*([^\s;&|]+)`)
+)
+
+// writeTools change the paths they are given; destinationTools only the
+// last one
+var (
+	writeTools = map[string]bool{
+		"mv": true, "rm": true, "rmdir": true, "chmod": true, "chown": true, "chgrp": true, "touch": true,
+		"mkdir": true, "tee": true, "truncate": true, "shred": true, "unlink": true, "chattr": true,
+		"Set-Content": true, "Add-Content": true, "Out-File": true, "Remove-Item": true, "New-Item": true,
+		"Move-Item": true, "del": true, "erase": true, "rd": true, "md": true,
+	}
+	destinationTools = map[string]bool{
+		"cp": true, "install": true, "ln": true, "rsync": true, "Copy-Item": true, "copy": true, "xcopy": true, "robocopy": true,
+	}
+)
+
+// Elevation is how a command gets more privileges than the user has: the
+// tool it elevates with, and the system paths it writes to
+type Elevation struct {
+	Tools []string
+	Paths []string
+}
+
+// Elevated reports whether the command needs elevated privileges
+func (e Elevation) Elevated() bool {
+	return len(e.Tools) > 0 || len(e.Paths) > 0
+}
+
+// String describes the elevation as a warning line
+func (e Elevation) String() string {
+	var parts []string
+	if len(e.Tools) > 0 {
+		parts = append(parts, "runs as root/Administrator with "+strings.Join(e.Tools, ", "))
+	}
+	if len(e.Paths) > 0 {
+		parts = append(parts, "writes to system paths "+strings.Join(e.Paths, ", "))
+	}
+	return "ELEVATED: " + strings.Join(parts, "; ")
+}
+
+// DetectElevation finds the privilege escalation in a command line: sudo,
+// doas, su, pkexec, runas and Start-Process -Verb RunAs, and writes to
+// paths the system owns
+func DetectElevation(command string) Elevation {
+	var e Elevation
+	seen := make(map[string]bool)
+	add := func(list *[]string, value string) {
+		if !seen[value] {
+			seen[value] = true
+			*list = append(*list, value)
+		}
+	}
+
+	for _, segment := range commandSeparatorRe.Split(command, -1) {
+		if match := elevatorRe.FindStringSubmatch(segment); match != nil {
+			add(&e.Tools, match[1])
+		}
+		if runAsRe.MatchString(segment) {
+			add(&e.Tools, "Start-Process -Verb RunAs")
+		}
+		for _, match := range redirectTargetRe.FindAllStringSubmatch(segment, -1) {
+			if target := strings.Trim(match[1], `'"`); systemPathRe.MatchString(target) {
+				add(&e.Paths, target)
+			}
+		}
+		match := segmentToolRe.FindStringSubmatch(segment)
+		if match == nil {
+			continue
+		}
+		tool := filepath.Base(match[2])
+		var args []string
+		for _, field := range strings.Fields(segment[len(match[0]):]) {
+			if field = strings.Trim(field, `'"`); !strings.HasPrefix(field, "-") && !strings.ContainsAny(field, "<>") {
+				args = append(args, field)
+			}
+		}
+		switch {
+		case writeTools[tool]:
+		case destinationTools[tool] && len(args) > 0:
+			args = args[len(args)-1:]
+		case tool == "sed" && strings.Contains(segment, " -i"):
+		case tool == "dd":
+			for i, arg := range args {
+				args[i] = strings.TrimPrefix(arg, "of=")
+			}
+		default:
+			continue
+		}
+		for _, arg := range args {
+			if systemPathRe.MatchString(arg) {
+				add(&e.Paths, arg)
+			}
+		}
+	}
+	return e
+}
+
+// elevationBlocked reports whether the command under review is refused
+// for needing elevated privileges
+func (m Model) elevationBlocked() bool {
+	return m.config.BlockElevated && m.elevation.Elevated()
+}
+
+// renderElevationBadge shows the elevation in the review, in magenta to
+// stand apart from the risk badge
+func renderElevationBadge(e Elevation) string {
+	return lipgloss.NewStyle().Foreground(theme.BadgeText).Background(theme.Elevated).Padding(0, 1).Render(e.String())
+}
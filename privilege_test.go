@@ -0,0 +1,50 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetectElevation(t *testing.T) {
+	tests := []struct {
+		command string
+		tools   []string
+		paths   []string
+	}{
+		{"sudo apt install jq", []string{"sudo"}, nil},
+		{"DEBIAN_FRONTEND=noninteractive sudo apt-get -y upgrade", []string{"sudo"}, nil},
+		{"env sudo -E make install", []string{"sudo"}, nil},
+		{"nohup doas rc-service sshd restart", []string{"doas"}, nil},
+		{"su - postgres", []string{"su"}, nil},
+		{"cd /tmp && sudo rm -rf build", []string{"sudo"}, nil},
+		{"make && pkexec make install", []string{"pkexec"}, nil},
+		{"runas.exe /user:Administrator cmd", []string{"runas"}, nil},
+		{"Start-Process pwsh -Verb RunAs", []string{"Start-Process -Verb RunAs"}, nil},
+		// Writes to paths the system owns
+		{"echo 127.0.0.1 db >> /etc/hosts", nil, []string{"/etc/hosts"}},
+		{"cp app.conf /etc/nginx/conf.d/", nil, []string{"/etc/nginx/conf.d/"}},
+		{"cp /etc/hosts hosts.bak", nil, nil},
+		{"rm -f '/usr/local/bin/tool'", nil, []string{"/usr/local/bin/tool"}},
+		{"sed -i s/a/b/ /etc/fstab", nil, []string{"/etc/fstab"}},
+		{"sed s/a/b/ /etc/fstab", nil, nil},
+		{"dd if=x of=/boot/x", nil, []string{"/boot/x"}},
+		{`Set-Content C:\Windows\System32\drivers\etc\hosts x`, nil, []string{`C:\Windows\System32\drivers\etc\hosts`}},
+		{"sudo tee /etc/motd", []string{"sudo"}, []string{"/etc/motd"}},
+		// Not elevation
+		{"echo sudo make me a sandwich", nil, nil},
+		{"grep -r sudo /var/log", nil, nil},
+		{"pseudo-tool --help", nil, nil},
+		{"cat /etc/hosts", nil, nil},
+		{"echo hi > /tmp/out", nil, nil},
+		{"mkdir -p ~/etc/app", nil, nil},
+	}
+	for _, test := range tests {
+		e := DetectElevation(test.command)
+		if !slices.Equal(e.Tools, test.tools) || !slices.Equal(e.Paths, test.paths) {
+			t.Errorf("DetectElevation(%q) = %q, %q, want %q, %q", test.command, e.Tools, e.Paths, test.tools, test.paths)
+		}
+		if e.Elevated() != (len(test.tools)+len(test.paths) > 0) {
+			t.Errorf("DetectElevation(%q).Elevated() = %t", test.command, e.Elevated())
+		}
+	}
+}
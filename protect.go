@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// defaultProtectedPaths hold keys and credentials, which generated commands
+// don't run against unless protected_paths says otherwise
+var defaultProtectedPaths = []string{"~/.ssh", "~/.gnupg"}
+
+var (
+	// homeRefRe matches the ways a command names the home directory at the
+	// start of a path
+	homeRefRe = regexp.MustCompile(`(?i)^(?:~|\$\{?HOME\}?|\$env:(?:USERPROFILE|HOME)|%USERPROFILE%)(?:[/\\]|$)`)
+	// driveRe matches a Windows path's drive
+	driveRe = regexp.MustCompile(`^[a-zA-Z]:/`)
+	// recursiveFlagRe matches the options that make a command go through a
+	// directory and everything under it
+	recursiveFlagRe = regexp.MustCompile(`(?:^|\s)(?:-[a-z]*[rR][a-z]*|--recursive|(?i:-Recurse|/s))(?:\s|$)`)
+)
+
+// ParseProtectedPaths validates a protected_paths setting: comma-separated
+// absolute paths, or paths in the home directory starting with ~
+func ParseProtectedPaths(value string) ([]string, error) {
+	var paths []string
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if normalizeProtectedPath(p, "") == "" {
+			return nil, fmt.Errorf("%q (expected an absolute path, or one starting with ~)", p)
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// normalizeProtectedPath makes word an absolute, clean path with forward
+// slashes, lowercased on Windows drives, to compare with others. Relative
+// paths are taken from cwd; "" means it can't be told where word points.
+func normalizeProtectedPath(word, cwd string) string {
+	if loc := homeRefRe.FindStringIndex(word); loc != nil {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		word = home + "/" + word[loc[1]:]
+	}
+	word = strings.ReplaceAll(word, `\`, "/")
+	switch {
+	case driveRe.MatchString(word):
+		return strings.ToLower(path.Clean(word))
+	case strings.HasPrefix(word, "/"):
+		return path.Clean(word)
+	case cwd == "" || strings.HasPrefix(word, "-") || strings.ContainsAny(word, "$%*?"):
+		return ""
+	}
+	return normalizeProtectedPath(strings.TrimRight(cwd, `/\`)+"/"+word, "")
+}
+
+// within reports whether p is dir or a path under it
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// ProtectedPaths lists the protected paths command refers to: a word of it
+// naming one or a path under it, with ~ and $HOME expanded and relative
+// paths taken from cwd, or a directory above one that a recursive command
+// goes through, like rm -r ~
+func ProtectedPaths(command, cwd string, protected []string) []string {
+	targets := make([]string, len(protected))
+	for i, p := range protected {
+		targets[i] = normalizeProtectedPath(p, "")
+	}
+	var found []string
+	seen := make(map[string]bool)
+	for _, segment := range commandSeparatorRe.Split(command, -1) {
+		recursive := recursiveFlagRe.MatchString(segment)
+		words := strings.FieldsFunc(segment, func(r rune) bool {
+			return unicode.IsSpace(r) || strings.ContainsRune("()<>`", r)
+		})
+		for _, word := range words {
+			word = strings.Trim(word, `'"`)
+			if i := strings.LastIndexByte(word, '='); i >= 0 {
+				// --file=~/.ssh/id_rsa, of=/dev/sda
+				word = strings.Trim(word[i+1:], `'"`)
+			}
+			p := normalizeProtectedPath(word, cwd)
+			if p == "" {
+				continue
+			}
+			for i, target := range targets {
+				if target == "" || seen[protected[i]] {
+					continue
+				}
+				if within(p, target) || recursive && within(target, p) {
+					seen[protected[i]] = true
+					found = append(found, protected[i])
+				}
+			}
+		}
+	}
+	return found
+}
+
+// protectedPathsNotice says why a command touching protected paths isn't
+// run
+func protectedPathsNotice(paths []string) string {
+	return "This touches " + strings.Join(paths, ", ") + ", which protected_paths keeps generated commands from running against. " +
+		"Check it and run it yourself if you mean to."
+}
+
+// renderProtectedBadge labels a command with the protected paths it
+// touches
+func renderProtectedBadge(paths []string) string {
+	return lipgloss.NewStyle().Foreground(theme.BadgeText).Background(theme.Error).Padding(0, 1).Render("PROTECTED: touches " + strings.Join(paths, ", "))
+}
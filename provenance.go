@@ -0,0 +1,23 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// provenanceGlyph marks, in the gutter, the lines of the scrollback where
+// a generated command was put at the prompt, to tell them from commands
+// the user typed
+const provenanceGlyph = "▌"
+
+// provenanceMarker is the marker as drawn, in the accent color
+func provenanceMarker() []byte {
+	return []byte(lipgloss.NewStyle().Foreground(theme.Accent).Render(asciiText(provenanceGlyph)) + " ")
+}
+
+// markProvenance puts the marker at the start of the line the shell is on,
+// the prompt a generated command is about to be echoed after. A
+// full-screen program's screen, which isn't kept in the scrollback, isn't
+// marked.
+func (m *Model) markProvenance() {
+	if !m.altScreen {
+		m.screen.Mark(provenanceMarker())
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// queueSettle is how long a queued command is given to reach the
+// foreground before the shell is asked whether it is free for the next
+const queueSettle = 500 * time.Millisecond
+
+var (
+	// promptMarkRe matches the shell integration marks (OSC 133) of the
+	// prompt (A, B), a command starting (C) and finishing (D)
+	promptMarkRe = regexp.MustCompile(`\x1b\]133;([ABCD])`)
+	// altScreenRe matches a program switching to the alternate screen and
+	// back, as full-screen programs like vim, less and htop do
+	altScreenRe = regexp.MustCompile(`\x1b\[\?(?:1049|1047|47)([hl])`)
+)
+
+// queuedCommand is a generated command held until the shell is back at
+// its prompt, with the request it answers and how it came to run
+type queuedCommand struct {
+	query   string
+	command string
+	source  string
+}
+
+// watchForeground follows the shell integration marks and the alternate
+// screen, which tell a program holding the terminal where the shell can't
+// be asked, as in a remote shell
+func (m *Model) watchForeground(chunk []byte) {
+	if marks := promptMarkRe.FindAllSubmatch(chunk, -1); marks != nil {
+		m.commandRunning = marks[len(marks)-1][1][0] == 'C'
+	}
+	if matches := altScreenRe.FindAllSubmatch(chunk, -1); matches != nil {
+		m.altScreen = matches[len(matches)-1][1][0] == 'h'
+	}
+}
+
+// shellBusy reports whether a program other than the shell holds the
+// terminal, so text sent now would go to it rather than the prompt. The
+// local shell is asked which process is in the foreground; elsewhere a
+// command started (OSC 133;C) and not finished, or the alternate screen,
+// tells.
+func (m Model) shellBusy() bool {
+	if m.pty == nil {
+		return false
+	}
+	if len(m.remotes) == 0 {
+		if busy, known := m.pty.Busy(); known {
+			return busy
+		}
+	}
+	return m.commandRunning || m.altScreen
+}
+
+// fullScreenApp returns the name of the full-screen program, like vim,
+// less or htop, holding the terminal on the alternate screen, where text
+// sent would be keys to it; "" when none does. Where the program can't be
+// named, as in a remote shell, it is "a full-screen program".
+func (m Model) fullScreenApp() string {
+	if m.pty == nil || !m.altScreen {
+		return ""
+	}
+	if len(m.remotes) == 0 {
+		if busy, known := m.pty.Busy(); known && !busy {
+			return ""
+		}
+		if name := m.pty.Foreground(); name != "" {
+			return name
+		}
+	}
+	return "a full-screen program"
+}
+
+// fullScreenNotice says why a command isn't sent while a full-screen
+// program holds the terminal
+func fullScreenNotice(app string) string {
+	return fmt.Sprintf("%s is running full screen, so the command would be typed into it rather than run by the shell. Copy it, or quit %s and ask again.", app, app)
+}
+
+// execute sends a generated command to the shell and runs it, or queues
+// it while a program holds the terminal or commands queued before it wait
+func (m *Model) execute(query, command, source string) {
+	if len(m.queue) > 0 || m.shellBusy() {
+		countFeature("queue")
+		m.queue = append(m.queue, queuedCommand{query: query, command: command, source: source})
+		return
+	}
+	m.executeNow(query, command, source)
+}
+
+// executeNow sends a generated command to the shell, logs it, and starts
+// watching it
+func (m *Model) executeNow(query, command, source string) {
+	m.sendCommand(command)
+	m.auditExecuted(AuditEntry{Query: query, Command: command, Source: source})
+	if source == AuditRun {
+		m.startProgress(command)
+	}
+	m.trackSSH(command)
+	m.commandSubmitted(command)
+	if source == AuditRun {
+		m.offerUndo(command)
+	}
+	m.queueSent = time.Now()
+}
+
+// runQueue sends the next queued command, or the step waiting to run, once
+// the shell is back at its prompt, one at a time
+func (m *Model) runQueue() tea.Cmd {
+	waiting := m.steps != nil && m.steps.waiting
+	if len(m.queue) == 0 && !waiting || time.Since(m.queueSent) < queueSettle || m.shellBusy() {
+		return nil
+	}
+	if len(m.queue) > 0 {
+		next := m.queue[0]
+		m.queue = m.queue[1:]
+		m.executeNow(next.query, next.command, next.source)
+		return nil
+	}
+	m.steps.waiting = false
+	model, cmd := m.runStep()
+	*m = model
+	return cmd
+}
+
+// dropQueue discards the commands waiting for the prompt; a step waiting
+// is offered again
+func (m *Model) dropQueue() {
+	countFeature("drop queue")
+	m.queue = nil
+	if m.steps != nil {
+		m.steps.waiting = false
+	}
+}
+
+// renderQueue is the status line shown while commands wait for the prompt
+func (m Model) renderQueue() string {
+	commands := make([]string, len(m.queue))
+	for i, q := range m.queue {
+		commands[i] = q.command
+		if j := strings.IndexByte(q.command, '\n'); j >= 0 {
+			commands[i] = q.command[:j] + " …"
+		}
+	}
+	return lipgloss.NewStyle().
+		Foreground(theme.Info).
+		Width(m.width).
+		MaxHeight(1).
+		Render(fmt.Sprintf(" ⏸ %d queued until the prompt is back: %s  (Alt+X: drop)", len(m.queue), strings.Join(commands, "; ")))
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// posixShells read quotes, globs and substitutions the way CheckQuoting
+// understands them
+var posixShells = map[string]bool{
+	"bash": true, "sh": true, "dash": true, "ash": true, "zsh": true, "ksh": true, "mksh": true,
+}
+
+var (
+	// typographicQuotes are what a model or a word processor writes where
+	// the shell needs plain quotes
+	typographicQuotes = map[rune]string{'“': `"`, '”': `"`, '„': `"`, '‘': `'`, '’': `'`}
+	// findPatternFlags take a pattern that find matches itself
+	findPatternFlags = map[string]bool{
+		"-name": true, "-iname": true, "-path": true, "-ipath": true, "-wholename": true, "-iwholename": true,
+		"-lname": true, "-ilname": true, "-regex": true, "-iregex": true,
+	}
+	// patternOptionRe matches options of grep, rsync, tar and the like
+	// whose value is a pattern the tool matches itself
+	patternOptionRe = regexp.MustCompile(`^--(?:include|exclude|exclude-dir|include-dir|wildcards-match|filter)=`)
+	// grepTools take a pattern as their first argument
+	grepTools = map[string]bool{"grep": true, "egrep": true, "fgrep": true, "rg": true, "ag": true, "zgrep": true}
+	// splittingTools take file names, which an unquoted substitution
+	// splits on spaces
+	splittingTools = map[string]bool{
+		"rm": true, "mv": true, "cp": true, "chmod": true, "chown": true, "chgrp": true, "tar": true,
+		"cat": true, "ls": true, "touch": true, "mkdir": true, "rmdir": true, "ln": true, "stat": true, "du": true,
+	}
+	// downloadSubstitutionRe matches a substitution whose output comes from
+	// the network
+	downloadSubstitutionRe = regexp.MustCompile(`^(?:\$\(|` + "`" + `)\s*(?:curl|wget|fetch|iwr|Invoke-WebRequest)\b`)
+	// urlWordRe matches words that are URLs
+	urlWordRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+	// commandPrefixes run the command after them
+	commandPrefixes = map[string]bool{
+		"sudo": true, "doas": true, "env": true, "time": true, "nice": true, "nohup": true, "command": true, "exec": true,
+	}
+)
+
+// quoteFrame is a quote or substitution the scan is inside
+type quoteFrame struct {
+	kind  byte // ', ", ` or ( for $(
+	start int
+	depth int // of parentheses inside $(
+}
+
+// substitution is a command substitution, $(...) or `...`
+type substitution struct {
+	start, end int
+	// quoted is set for substitutions inside double quotes
+	quoted bool
+}
+
+// quoteScan is a command as the shell reads its quoting: which bytes are
+// outside any quotes, and what was left open
+type quoteScan struct {
+	command string
+	// plain marks bytes outside quotes and substitutions and not escaped,
+	// where blanks split words and operators end commands
+	plain []bool
+	// open are the quotes and substitutions never closed, outermost first
+	open []quoteFrame
+	// subs are the substitutions outside any others
+	subs []substitution
+	// quotedBackticks are backticks opened inside double quotes
+	quotedBackticks []int
+}
+
+// scanQuotes scans a command's quoting. Here-documents and comments are
+// left out, as their text isn't quoted like the rest.
+func scanQuotes(command string) quoteScan {
+	s := quoteScan{command: command, plain: make([]bool, len(command))}
+	var stack []quoteFrame
+	push := func(kind byte, i int) {
+		stack = append(stack, quoteFrame{kind: kind, start: i})
+	}
+	pop := func(i int) {
+		frame := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		outer := len(stack) == 0 || len(stack) == 1 && stack[0].kind == '"'
+		if frame.kind != '\'' && frame.kind != '"' && outer {
+			s.subs = append(s.subs, substitution{start: frame.start, end: i + 1, quoted: len(stack) == 1})
+		}
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		var top byte
+		if len(stack) > 0 {
+			top = stack[len(stack)-1].kind
+		}
+		switch top {
+		case '\'':
+			if c == '\'' {
+				pop(i)
+			}
+			continue
+		case '"':
+			switch {
+			case c == '\\':
+				i++
+			case c == '"':
+				pop(i)
+			case c == '`':
+				s.quotedBackticks = append(s.quotedBackticks, i)
+				push('`', i)
+			case c == '$' && i+1 < len(command) && command[i+1] == '(':
+				push('(', i)
+				i++
+			}
+			continue
+		}
+
+		switch {
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"':
+			push(c, i)
+		case c == '`' && top == '`':
+			pop(i)
+		case c == '`':
+			push('`', i)
+		case c == '$' && i+1 < len(command) && command[i+1] == '(':
+			push('(', i)
+			i++
+		case c == '(' && top == '(':
+			stack[len(stack)-1].depth++
+		case c == ')' && top == '(':
+			if stack[len(stack)-1].depth == 0 {
+				pop(i)
+			} else {
+				stack[len(stack)-1].depth--
+			}
+		case top != 0:
+		case c == '#' && (i == 0 || strings.ContainsRune(" \t\n;|&(", rune(command[i-1]))):
+			// A comment runs to the end of the line
+			for i+1 < len(command) && command[i+1] != '\n' {
+				i++
+			}
+		case c == '<' && strings.HasPrefix(command[i:], "<<") && !strings.HasPrefix(command[i:], "<<<"):
+			// A here-document's body isn't quoted like the command
+			s.open = stack
+			return s
+		default:
+			s.plain[i] = true
+		}
+	}
+	s.open = stack
+	return s
+}
+
+// column is the 1-based column of byte i, counted in runes
+func (s quoteScan) column(i int) int {
+	return utf8.RuneCountInString(s.command[:i]) + 1
+}
+
+// isOperator reports whether byte i ends a command: ; | & or a newline,
+// but not the & of a redirection like 2>&1
+func (s quoteScan) isOperator(i int) bool {
+	if !s.plain[i] {
+		return false
+	}
+	switch s.command[i] {
+	case ';', '|', '\n':
+		return true
+	case '&':
+		return i == 0 || (s.command[i-1] != '>' && s.command[i-1] != '<')
+	}
+	return false
+}
+
+// segments splits the command into the words of each simple command, as
+// byte ranges
+func (s quoteScan) segments() [][][2]int {
+	var segments [][][2]int
+	var words [][2]int
+	start := -1
+	for i := 0; i <= len(s.command); i++ {
+		end := i == len(s.command)
+		blank := !end && s.plain[i] && (s.command[i] == ' ' || s.command[i] == '\t')
+		operator := !end && s.isOperator(i)
+		if end || blank || operator {
+			if start >= 0 {
+				words = append(words, [2]int{start, i})
+				start = -1
+			}
+			if (end || operator) && len(words) > 0 {
+				segments = append(segments, words)
+				words = nil
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	return segments
+}
+
+// hasGlob reports whether the bytes of a word include an unquoted glob
+// character, returning it
+func (s quoteScan) hasGlob(word [2]int) (byte, bool) {
+	for i := word[0]; i < word[1]; i++ {
+		if c := s.command[i]; s.plain[i] && (c == '*' || c == '?' || c == '[') {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// CheckQuoting returns warnings for the quoting mistakes generated commands
+// most often make: quotes left open or typographic, globs the shell
+// expands before the tool that should match them sees them, and command
+// substitutions that split file names or run what they download. Only
+// POSIX shells are checked.
+func CheckQuoting(command, shell string) []string {
+	if !posixShells[shellName(shell)] {
+		return nil
+	}
+	s := scanQuotes(command)
+	var warnings []string
+
+	for i, r := range command {
+		if plain, ok := typographicQuotes[r]; ok && i < len(s.plain) && s.plain[i] {
+			warnings = append(warnings, fmt.Sprintf("Typographic quote %c at column %d: the shell takes it literally; use %s", r, s.column(i), plain))
+			break
+		}
+	}
+
+	// What follows an open quote can't be read reliably, so only the
+	// outermost is reported
+	if len(s.open) > 0 {
+		frame := s.open[0]
+		switch frame.kind {
+		case '\'':
+			warning := fmt.Sprintf("Unbalanced ' quote opened at column %d", s.column(frame.start))
+			if strings.Contains(command, `\'`) {
+				warning += `: a backslash doesn't escape ' inside single quotes; write '\'' instead`
+			}
+			warnings = append(warnings, warning)
+		case '"':
+			warnings = append(warnings, fmt.Sprintf(`Unbalanced " quote opened at column %d`, s.column(frame.start)))
+		case '`':
+			warnings = append(warnings, fmt.Sprintf("Unclosed ` substitution opened at column %d", s.column(frame.start)))
+		case '(':
+			warnings = append(warnings, fmt.Sprintf("Unclosed $( substitution opened at column %d", s.column(frame.start)))
+		}
+	}
+
+	for _, i := range s.quotedBackticks {
+		if end := strings.IndexByte(command[i+1:], '`'); end >= 0 {
+			inner := command[i : i+end+2]
+			warnings = append(warnings, fmt.Sprintf("%s inside double quotes runs %s as a command; use single quotes or escape the backticks if it is meant as text", inner, strings.Trim(inner, "`")))
+			break
+		}
+	}
+
+	for _, words := range s.segments() {
+		warnings = append(warnings, s.checkSegment(words)...)
+	}
+	return warnings
+}
+
+// checkSegment checks the words of one simple command for unquoted globs,
+// URLs and substitutions
+func (s quoteScan) checkSegment(words [][2]int) []string {
+	text := func(word [2]int) string {
+		return s.command[word[0]:word[1]]
+	}
+	// The tool is the first word that isn't an assignment or a prefix
+	// like sudo
+	tool := -1
+	for i, word := range words {
+		w := text(word)
+		if !strings.Contains(w, "=") && !commandPrefixes[w] && !strings.HasPrefix(w, "-") {
+			tool = i
+			break
+		}
+	}
+	if tool < 0 {
+		return nil
+	}
+	name := text(words[tool])
+
+	var warnings []string
+	patternSeen := false
+	for i, word := range words[tool+1:] {
+		w := text(word)
+		glob, globbed := s.hasGlob(word)
+		var previous string
+		if i > 0 {
+			previous = text(words[tool+i])
+		}
+		switch {
+		case !globbed:
+		case findPatternFlags[previous]:
+			warnings = append(warnings, fmt.Sprintf("%s %s: the shell expands the pattern before %s sees it; quote it: '%s'", previous, w, name, strings.Trim(w, `'"`)))
+		case patternOptionRe.MatchString(w):
+			option, pattern, _ := strings.Cut(w, "=")
+			warnings = append(warnings, fmt.Sprintf("%s: the shell expands the pattern before %s sees it; quote it: %s='%s'", w, name, option, pattern))
+		case urlWordRe.MatchString(w):
+			warnings = append(warnings, fmt.Sprintf("The URL %s has an unquoted %c, which the shell treats as a glob (zsh stops with \"no matches found\"); quote the URL", w, glob))
+		case grepTools[name] && !patternSeen && !strings.HasPrefix(w, "-"):
+			warnings = append(warnings, fmt.Sprintf("%s pattern %s is unquoted, so the shell may expand it as a glob; quote it", name, w))
+		case name == "tr":
+			warnings = append(warnings, fmt.Sprintf("tr set %s is unquoted, so the shell may expand it as a glob; quote it", w))
+		}
+		if !strings.HasPrefix(w, "-") {
+			patternSeen = true
+		}
+
+		// An & inside a URL ends the command there and backgrounds it
+		if urlWordRe.MatchString(w) && word[1] < len(s.command) && s.command[word[1]] == '&' &&
+			word[1]+1 < len(s.command) && !strings.ContainsRune(" \t\n&", rune(s.command[word[1]+1])) {
+			warnings = append(warnings, fmt.Sprintf("The unquoted & after %s runs %s in the background and the rest of the URL as another command; quote the URL", w, name))
+		}
+	}
+
+	for _, sub := range s.subs {
+		if sub.start < words[0][0] || sub.start >= words[len(words)-1][1] {
+			continue
+		}
+		text := s.command[sub.start:sub.end]
+		switch {
+		case downloadSubstitutionRe.MatchString(text) && (sub.start == words[tool][0] || name == "eval" || name == "sh" || name == "bash"):
+			warnings = append(warnings, fmt.Sprintf("%s runs what it downloads as part of the command", text))
+		case !sub.quoted && splittingTools[name] && sub.start > words[tool][0]:
+			warnings = append(warnings, fmt.Sprintf("The unquoted %s is split on spaces and expanded as globs, so file names with spaces break; quote it, or use find -exec or xargs -0", text))
+		}
+	}
+	return warnings
+}
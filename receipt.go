@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// receipt is an AI-run command waiting for the shell to report it
+// finished, for the line noting how it went
+type receipt struct {
+	command string
+	start   time.Time
+}
+
+// startReceipt times an AI-suggested command just sent to the shell
+func (m *Model) startReceipt(command string) {
+	m.receipt = &receipt{command: command, start: time.Now()}
+}
+
+// addReceipt puts a receipt line in chunk where the shell integration
+// reports the AI-run command finished (OSC 133;D): its exit code, how long
+// it took, and the command. Without the report no receipt is shown, as
+// the exit code isn't known.
+func (m *Model) addReceipt(chunk []byte) []byte {
+	if m.receipt == nil {
+		return chunk
+	}
+	loc := exitStatusRe.FindSubmatchIndex(chunk)
+	if loc == nil {
+		return chunk
+	}
+	code, _ := strconv.Atoi(string(chunk[loc[2]:loc[3]]))
+	elapsed := time.Since(m.receipt.start)
+	command := m.receipt.command
+	m.receipt = nil
+
+	// The line goes after the sequence's terminator, BEL or ST
+	end := loc[1]
+	if i := bytes.IndexAny(chunk[end:], "\a\x1b"); i >= 0 {
+		end += i + 1
+		if chunk[end-1] == '\x1b' && end < len(chunk) && chunk[end] == '\\' {
+			end++
+		}
+	}
+
+	// Output that didn't end its last line gets one ended first
+	before := m.output[max(0, len(m.output)-256):]
+	text := strings.TrimRight(ansi.Strip(string(before)+string(chunk[:loc[0]])), "\r")
+	line := renderReceipt(code, elapsed, command) + "\r\n"
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		line = "\r\n" + line
+	}
+
+	out := make([]byte, 0, len(chunk)+len(line))
+	out = append(out, chunk[:end]...)
+	out = append(out, line...)
+	return append(out, chunk[end:]...)
+}
+
+// renderReceipt is the dimmed line noting how an AI-run command went
+func renderReceipt(code int, elapsed time.Duration, command string) string {
+	mark := "✓"
+	if code != 0 {
+		mark = "✗"
+	}
+	if i := strings.IndexByte(command, '\n'); i >= 0 {
+		command = command[:i] + " …"
+	}
+	return lipgloss.NewStyle().
+		Foreground(theme.Dim).
+		Render(asciiText(fmt.Sprintf("%s exit %d in %s: %s", mark, code, formatElapsed(elapsed), command)))
+}
+
+// formatElapsed shows how long a command took, to the tenth of a second
+// when that matters
+func formatElapsed(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < 10*time.Second:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return formatDuration(d)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// redactedMarker replaces every secret sent to the model
+const redactedMarker = "[REDACTED]"
+
+// secretPatterns match common credentials. When a pattern has a group named
+// secret only that part is replaced, keeping e.g. the variable name that
+// tells the model what was there.
+var secretPatterns = []*regexp.Regexp{
+	// Private key blocks, including one cut off by the end of the text
+	regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`),
+	// AWS access key IDs and secret keys
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`),
+	// Bearer tokens in headers
+	regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/=-]{12,})`),
+	// GitHub, Slack and OpenAI-style API tokens, and JWTs
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),
+	// Passwords in URLs
+	regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s:/@]+:(?P<secret>[^\s@/]+)@`),
+	// Passwords given on the command line, as mysql -pSECRET or
+	// --password SECRET
+	regexp.MustCompile(`\b(?:mysql|mysqldump|mysqladmin|mariadb)\b[^|;&\n]*?\s-p(?P<secret>[^\s"']+)`),
+	regexp.MustCompile(`(?i)--password\s+["']?(?P<secret>[^\s"']+)`),
+	// Assignments to variables that look like they hold a secret
+	regexp.MustCompile(`(?i)\b[A-Za-z0-9_]*(?:password|passwd|secret|token|api_?key)[A-Za-z0-9_]*["']?\s*[=:]\s*["']?(?P<secret>[^\s"']{6,})`),
+}
+
+// ParseRedactPatterns parses the redact_patterns setting: a JSON array of
+// regular expressions, or a single one
+func ParseRedactPatterns(value string) ([]string, error) {
+	var patterns []string
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		if err := json.Unmarshal([]byte(value), &patterns); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+	} else if value != "" {
+		patterns = []string{value}
+	}
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return patterns, nil
+}
+
+// RedactSecrets replaces credentials in text with a marker before it is sent
+// to the model, using the built-in patterns and the user's redact_patterns.
+// It returns the redacted text and how many secrets were found.
+func RedactSecrets(config Config, text string) (string, int) {
+	patterns := secretPatterns
+	for _, pattern := range config.RedactPatterns {
+		// Patterns are validated when set; one broken by hand is skipped
+		if re, err := regexp.Compile(pattern); err == nil {
+			patterns = append(patterns[:len(patterns):len(patterns)], re)
+		}
+	}
+
+	count := 0
+	for _, re := range patterns {
+		secret := re.SubexpIndex("secret")
+		var b strings.Builder
+		last := 0
+		for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if strings.Contains(text[start:end], redactedMarker) {
+				continue
+			}
+			count++
+			// Without a secret group, or with one left unmatched, the
+			// whole match goes
+			if secret >= 0 && loc[2*secret] >= 0 {
+				start, end = loc[2*secret], loc[2*secret+1]
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(redactedMarker)
+			last = end
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+	return text, count
+}
+
+// redactedNotice is shown wherever context about to be sent had secrets
+func redactedNotice(n int) string {
+	return lipgloss.NewStyle().Foreground(theme.Warning).Render(fmt.Sprintf("(%d secret(s) redacted)", n))
+}
+
+// redactText is RedactSecrets for callers that do not need the count
+func redactText(config Config, text string) string {
+	text, _ = RedactSecrets(config, text)
+	return text
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// startRegenerate switches the prompt to asking for an optional correction
+// to command, the last suggestion for genQuery. unused is set when command
+// was shown but never run, so dismissing it counts as a rejection.
+func (m *Model) startRegenerate(command string, unused bool) {
+	if unused {
+		m.rejectCommand(command)
+	}
+	m.lastSuggestion = command
+	m.candidates = nil
+	m.pending, m.warnings, m.risk = "", nil, Risk{}
+	m.confirmingRisk = false
+	m.script = nil
+
+	m.showPrompt = true
+	m.translating = false
+	m.naming = false
+	m.regenerating = true
+	m.input.Placeholder = "Optional correction, e.g. use rsync not cp"
+	m.input.Focus()
+}
+
+// canRegenerate reports whether there is a generated suggestion to retry
+func (m Model) canRegenerate() bool {
+	return m.genQuery != "" && m.lastSuggestion != ""
+}
+
+// regenerate asks again for genQuery, replaying earlier attempts and the
+// correction so the model does not have to start over
+func (m *Model) regenerate(feedback string) {
+	m.attempts = append(m.attempts, ai.Attempt{Command: m.lastSuggestion, Feedback: feedback})
+	m.regenerating = false
+	m.loading = true
+}
+
+// renderRegenerate draws the prompt while asking for a correction
+func (m Model) renderRegenerate(titleStyle, hintStyle lipgloss.Style) string {
+	previousStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	return fmt.Sprintf(
+		"%s\n%s\n\n%s\n\n%s",
+		titleStyle.Render("Regenerate: "+m.genQuery),
+		previousStyle.Render("Previous suggestion: "+firstLine(m.lastSuggestion)),
+		m.input.View(),
+		hintStyle.Render("Type a correction (or nothing) and press Enter, Esc to cancel"),
+	)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Where releases are published, and how often to look
+const (
+	releaseAPIURL        = "https://api.github.com/repos/eng-elias-owis/ai-terminal-tui/releases/latest"
+	releaseModulePath    = "github.com/eng-elias-owis/ai-terminal-tui"
+	releaseCheckInterval = 24 * time.Hour
+	releaseCheckTimeout  = 5 * time.Second
+	releaseToastDuration = 15 * time.Second
+)
+
+// releaseTagRe is what a release tag must look like to be offered, since
+// it ends up in a command typed into the shell
+var releaseTagRe = regexp.MustCompile(`^v\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?$`)
+
+// ReleaseInfo is the cached result of the last release check
+type ReleaseInfo struct {
+	Checked   time.Time `json:"checked"`
+	Tag       string    `json:"tag"`
+	URL       string    `json:"url"`
+	Notes     string    `json:"notes"`
+	Dismissed string    `json:"dismissed,omitempty"`
+}
+
+// releaseMsg delivers a release newer than the running version
+type releaseMsg ReleaseInfo
+
+// releaseToastExpiredMsg hides the release toast after a while
+type releaseToastExpiredMsg struct{}
+
+// getReleaseCachePath returns where the last release check is cached
+func getReleaseCachePath() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "release-check.json")
+}
+
+// loadReleaseCache reads the cached release check, if any
+func loadReleaseCache() ReleaseInfo {
+	var info ReleaseInfo
+	if data, err := os.ReadFile(getReleaseCachePath()); err == nil {
+		json.Unmarshal(data, &info)
+	}
+	return info
+}
+
+// saveReleaseCache stores the release check result
+func saveReleaseCache(info ReleaseInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getReleaseCachePath(), data, 0600)
+}
+
+// fetchLatestRelease asks GitHub for the latest published release
+func fetchLatestRelease() (ReleaseInfo, error) {
+	client := &http.Client{Timeout: releaseCheckTimeout}
+	req, err := http.NewRequest("GET", releaseAPIURL, nil)
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", AppName+"/"+Version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ReleaseInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ReleaseInfo{}, fmt.Errorf("release check failed (status %d)", resp.StatusCode)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+		Body    string `json:"body"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return ReleaseInfo{}, err
+	}
+	return ReleaseInfo{Tag: release.TagName, URL: release.HTMLURL, Notes: release.Body}, nil
+}
+
+// CheckForRelease returns the latest release, hitting the network at most
+// once a day. ok is false when there is nothing new to announce.
+func CheckForRelease() (ReleaseInfo, bool) {
+	cache := loadReleaseCache()
+	if time.Since(cache.Checked) >= releaseCheckInterval {
+		latest, err := fetchLatestRelease()
+		if err != nil {
+			// Try again next time rather than every startup
+			cache.Checked = time.Now()
+			saveReleaseCache(cache)
+			return cache, false
+		}
+		latest.Checked = time.Now()
+		latest.Dismissed = cache.Dismissed
+		cache = latest
+		saveReleaseCache(cache)
+	}
+
+	if !releaseTagRe.MatchString(cache.Tag) || cache.Tag == cache.Dismissed || !newerVersion(cache.Tag, Version) {
+		return cache, false
+	}
+	return cache, true
+}
+
+// newerVersion reports whether tag is a later version than current. Builds
+// without a release version never nag.
+func newerVersion(tag, current string) bool {
+	latest, ok := parseVersion(tag)
+	if !ok {
+		return false
+	}
+	running, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range latest {
+		if latest[i] != running[i] {
+			return latest[i] > running[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses vMAJOR.MINOR.PATCH, ignoring any pre-release suffix
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(version, "v")
+	version, _, _ = strings.Cut(version, "-")
+	fields := strings.Split(version, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// releaseSummary is the first line of the release notes that is not a
+// heading, shortened to 80 characters
+func releaseSummary(notes string) string {
+	for _, line := range strings.Split(notes, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Drop a list marker, but not the ** of bold text
+		for _, marker := range []string{"- ", "* ", "+ "} {
+			line = strings.TrimPrefix(line, marker)
+		}
+		line = strings.TrimSpace(line)
+		if runes := []rune(line); len(runes) > 80 {
+			line = string(runes[:77]) + "..."
+		}
+		return line
+	}
+	return ""
+}
+
+// checkRelease looks for a new release in the background
+func (m Model) checkRelease() tea.Cmd {
+	if !m.config.UpdateCheck || m.config.Airgapped() {
+		return nil
+	}
+	return func() tea.Msg {
+		if info, ok := CheckForRelease(); ok {
+			return releaseMsg(info)
+		}
+		return nil
+	}
+}
+
+// updateCommand is the shell command that installs a release; ok is false
+// for a tag that isn't a plain version, which never reaches the shell
+func updateCommand(tag string) (command string, ok bool) {
+	if !releaseTagRe.MatchString(tag) {
+		return "", false
+	}
+	return "go install " + releaseModulePath + "@" + tag, true
+}
+
+// dismissRelease hides the release notice until a newer version comes out
+func (m *Model) dismissRelease() {
+	cache := loadReleaseCache()
+	cache.Dismissed = m.release.Tag
+	saveReleaseCache(cache)
+	m.release = nil
+	m.releaseToast = false
+}
+
+// renderReleaseToast is the one-line notice shown above the terminal
+func (m Model) renderReleaseToast() string {
+	text := fmt.Sprintf(" %s %s is available", AppName, m.release.Tag)
+	if summary := releaseSummary(m.release.Notes); summary != "" {
+		text += ": " + summary
+	}
+	text += "  (Ctrl+K for options)"
+
+	return lipgloss.NewStyle().
+		Foreground(theme.BadgeText).
+		Background(theme.Info).
+		Width(m.width).
+		MaxHeight(1).
+		Render(text)
+}
+
+// renderReleaseNotice is the line added to the prompt while an update is
+// available
+func (m Model) renderReleaseNotice() string {
+	return lipgloss.NewStyle().Foreground(theme.Info).Render(
+		fmt.Sprintf("Update available: %s (Ctrl+Y types the go install command, Ctrl+X dismisses)", m.release.Tag))
+}
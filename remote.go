@@ -0,0 +1,208 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// RemoteHost is a machine the user has ssh'd into from the shell. Its
+// system is guessed from what it prints at login.
+type RemoteHost struct {
+	Host     string
+	OS       string
+	Distro   string
+	Userland string
+	// PackageManager follows from the distribution when it is recognised
+	PackageManager string
+}
+
+// sshTailBytes is how much of the previous output chunk is kept so markers
+// split across reads are still found
+const sshTailBytes = 200
+
+// sshMaxBanner limits how much output after connecting is searched for the
+// remote system; past the login banner it is mostly the user's commands
+const sshMaxBanner = 16 * 1024
+
+// sshOptionsWithValue are the ssh flags that take an argument
+const sshOptionsWithValue = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// sshClosedRe matches ssh reporting that a session ended or never started
+var sshClosedRe = regexp.MustCompile(`Connection to \S+ closed|Connection closed by |ssh: connect to host |ssh: Could not resolve hostname |Permission denied \((?:publickey|password|keyboard|gssapi|hostbased)|Host key verification failed`)
+
+// remoteSystems recognise systems from login banners and prompts, most
+// specific first
+var remoteSystems = []struct {
+	pattern        *regexp.Regexp
+	os             string
+	distro         string
+	userland       string
+	packageManager string
+}{
+	{regexp.MustCompile(`Ubuntu[ \d.]*(?:LTS)?`), "linux", "", UserlandGNU, "apt"},
+	{regexp.MustCompile(`Debian GNU/Linux[ \d.]*`), "linux", "", UserlandGNU, "apt"},
+	{regexp.MustCompile(`Red Hat Enterprise Linux[ \w.]*|Rocky Linux[ \d.]*|AlmaLinux[ \d.]*|CentOS[ \w.]*|Fedora[ \w.]*`), "linux", "", UserlandGNU, "dnf"},
+	{regexp.MustCompile(`Amazon Linux[ \d.]*`), "linux", "", UserlandGNU, "yum"},
+	{regexp.MustCompile(`Alpine Linux|Welcome to Alpine`), "linux", "Alpine Linux", UserlandBusyBox, "apk"},
+	{regexp.MustCompile(`Arch Linux`), "linux", "", UserlandGNU, "pacman"},
+	{regexp.MustCompile(`SUSE[ \w.]*`), "linux", "", UserlandGNU, "zypper"},
+	{regexp.MustCompile(`FreeBSD[ \w.-]*`), "freebsd", "", UserlandBSD, "pkg"},
+	{regexp.MustCompile(`OpenBSD[ \w.-]*`), "openbsd", "", UserlandBSD, "pkg_add"},
+	{regexp.MustCompile(`Darwin Kernel|macOS`), "darwin", "", UserlandBSD, "brew"},
+	{regexp.MustCompile(`Microsoft Windows \[Version`), "windows", "", UserlandWindows, "winget"},
+	{regexp.MustCompile(`BusyBox v\d`), "linux", "", UserlandBusyBox, ""},
+	{regexp.MustCompile(`GNU/Linux|Linux \S+ \d+\.\d+\S* .*(?:x86_64|aarch64)`), "linux", "", UserlandGNU, ""},
+}
+
+// ParseSSHCommand returns the host an interactive ssh command line connects
+// to. Commands that run something remotely and return are not sessions.
+func ParseSSHCommand(line string) (string, bool) {
+	args := strings.Fields(line)
+	if len(args) == 0 || filepath.Base(args[0]) != "ssh" {
+		return "", false
+	}
+
+	destination := ""
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if destination != "" {
+			// Anything after the destination is a remote command
+			return "", false
+		}
+		if arg == "--" {
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			destination = arg
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			if strings.IndexByte(sshOptionsWithValue, arg[j]) >= 0 {
+				if j == len(arg)-1 {
+					i++
+				}
+				break
+			}
+		}
+	}
+	if destination == "" {
+		return "", false
+	}
+
+	host := strings.TrimPrefix(destination, "ssh://")
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	if strings.HasPrefix(destination, "ssh://") {
+		host, _, _ = strings.Cut(host, ":")
+	}
+	return host, host != ""
+}
+
+// trackSSH notes a command submitted to the shell, switching the context
+// to the remote host when it starts an ssh session
+func (m *Model) trackSSH(line string) {
+	if host, ok := ParseSSHCommand(strings.TrimSpace(line)); ok {
+		countFeature("ssh context")
+		m.remotes = append(m.remotes, &RemoteHost{Host: host})
+		m.sshTail, m.sshBanner = "", 0
+	}
+}
+
+// watchSSH looks at new shell output for ssh sessions ending and for what
+// system the current remote host runs
+func (m *Model) watchSSH(chunk []byte) {
+	if len(m.remotes) == 0 {
+		return
+	}
+	tail := m.sshTail
+	text := tail + ansi.Strip(string(chunk))
+	m.sshTail = text[max(0, len(text)-sshTailBytes):]
+
+	for _, loc := range sshClosedRe.FindAllStringIndex(text, -1) {
+		// Markers in the tail were handled with the previous chunk
+		if loc[1] > len(tail) && len(m.remotes) > 0 {
+			m.remotes = m.remotes[:len(m.remotes)-1]
+			m.sshBanner = 0
+		}
+	}
+
+	remote := m.currentRemote()
+	if remote == nil || remote.OS != "" || m.sshBanner > sshMaxBanner {
+		return
+	}
+	m.sshBanner += len(chunk)
+	for _, system := range remoteSystems {
+		if match := system.pattern.FindString(text); match != "" {
+			remote.OS = system.os
+			remote.Distro = system.distro
+			if remote.Distro == "" && system.os == "linux" && system.packageManager != "" {
+				remote.Distro = strings.TrimSpace(match)
+			}
+			remote.Userland = system.userland
+			remote.PackageManager = system.packageManager
+			return
+		}
+	}
+}
+
+// currentRemote returns the host the shell is connected to, or nil when
+// it is local
+func (m Model) currentRemote() *RemoteHost {
+	if len(m.remotes) == 0 {
+		return nil
+	}
+	return m.remotes[len(m.remotes)-1]
+}
+
+// overSSH reports whether the shell's commands reach the machine over SSH:
+// an ssh session started in the TUI, or the TUI itself run in one
+func (m Model) overSSH() bool {
+	return len(m.remotes) > 0 || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// remoteContext returns a copy of the current remote host for a request,
+// which must not see it change while the output is still being watched
+func (m Model) remoteContext() *RemoteHost {
+	remote := m.currentRemote()
+	if remote == nil {
+		return nil
+	}
+	copied := *remote
+	return &copied
+}
+
+// SetRemote replaces what was gathered about the local machine with what
+// is known of the remote host, since generated commands will run there
+func (c *PromptContext) SetRemote(remote *RemoteHost) {
+	if remote == nil {
+		return
+	}
+	c.Remote = remote
+	c.OS, c.Arch = remote.OS, ""
+	c.Distro = remote.Distro
+	c.Userland = remote.Userland
+	c.PackageManager = remote.PackageManager
+	c.Shell, c.Cwd = "", ""
+	c.Git, c.WSL, c.Tools, c.Cloud, c.Kube, c.Containers, c.Systemd = nil, nil, nil, nil, nil, nil, nil
+	c.Archive = nil
+	c.DomainContext = ""
+}
+
+// remoteNotice warns in the prompt that commands will run remotely
+func remoteNotice(remote *RemoteHost) string {
+	system := "system unknown"
+	switch {
+	case remote.Distro != "":
+		system = remote.Distro
+	case remote.OS != "":
+		system = remote.OS
+	}
+	return lipgloss.NewStyle().Foreground(theme.Warning).Render(
+		"Connected to " + remote.Host + " over ssh (" + system + "): commands will run there, not locally")
+}
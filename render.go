@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Values of the renderer config key
+const (
+	RendererVT    = "vt"    // a VT100/xterm emulator drawing a grid of cells
+	RendererLines = "lines" // the output as lines of text, escape codes passed through
+)
+
+// ParseRenderer validates a renderer setting
+func ParseRenderer(value string) (string, error) {
+	switch value {
+	case RendererVT:
+		if minimalBuild {
+			return "", fmt.Errorf("%q is left out of this build (built with -tags minimal)", value)
+		}
+		return value, nil
+	case RendererLines:
+		return value, nil
+	}
+	return "", fmt.Errorf("%q (expected %s or %s)", value, RendererVT, RendererLines)
+}
+
+// Bounds of the output a renderer keeps: past maxRendered bytes it drops
+// all but the last keptRendered
+const (
+	maxRendered  = 100000
+	keptRendered = 50000
+)
+
+// terminalRenderer draws the shell's output in the terminal area. The
+// renderer config key picks the implementation, so devices short on CPU
+// can keep to the cheapest.
+type terminalRenderer interface {
+	// Write takes output from the shell, returning what the terminal
+	// answers to queries in it, like the cursor position, for the shell
+	Write(chunk []byte) []byte
+	// Resize tells the size of the terminal area
+	Resize(width, height int)
+	// Mark puts the provenance marker on the line the shell's cursor is on
+	Mark(marker []byte)
+	// Lines returns the rows to draw in width by height, ghost shown
+	// dimmed after the cursor
+	Lines(width, height int, ghost string) []string
+}
+
+// defaultRenderer is the renderer unless the config picks one: the VT
+// emulator, where the build has it
+func defaultRenderer() string {
+	if minimalBuild {
+		return RendererLines
+	}
+	return RendererVT
+}
+
+// newRenderer returns the renderer named by the renderer config key. A
+// minimal build, without the VT emulator, always draws lines.
+func newRenderer(name string) terminalRenderer {
+	if name == RendererLines || minimalBuild {
+		return &lineRenderer{}
+	}
+	return newVTRenderer()
+}
+
+// lineRenderer shows the output as lines of text: escape codes pass through
+// to the terminal, and carriage returns and cursor movement aren't
+// followed. It is cheap, and right for shells drawing plain output.
+type lineRenderer struct {
+	output []byte
+}
+
+// Write appends chunk to the output kept. Queries pass through to the
+// terminal, which answers them itself.
+func (r *lineRenderer) Write(chunk []byte) []byte {
+	r.output = append(r.output, chunk...)
+	if len(r.output) > maxRendered {
+		r.output = r.output[len(r.output)-keptRendered:]
+	}
+	return nil
+}
+
+// Resize does nothing; lines are wrapped as they are drawn
+func (r *lineRenderer) Resize(width, height int) {}
+
+// Mark puts marker at the start of the last line, once
+func (r *lineRenderer) Mark(marker []byte) {
+	start := bytes.LastIndexByte(r.output, '\n') + 1
+	if bytes.HasPrefix(r.output[start:], marker) {
+		return
+	}
+	line := append(marker, r.output[start:]...)
+	r.output = append(r.output[:start], line...)
+}
+
+// Lines keeps the last lines that fit once wrapped, so the line with the
+// shell's cursor stays in view
+func (r *lineRenderer) Lines(width, height int, ghost string) []string {
+	lines := strings.Split(string(r.output), "\n")
+	if len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+	if ghost != "" {
+		lines[len(lines)-1] += ghost
+	}
+	return fitRows(lines, width, height)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Risk levels of a command; high-risk commands need a typed confirmation,
+// catastrophic ones the command itself typed back before they reach the
+// shell, and blocked ones are refused by policy whatever is confirmed
+const (
+	RiskNone = iota
+	RiskMedium
+	RiskHigh
+	RiskCatastrophic
+	RiskBlocked
+)
+
+// riskConfirmation is what has to be typed to run a high-risk command, and
+// catastrophicConfirmation what may be typed instead of a catastrophic one
+const (
+	riskConfirmation         = "yes"
+	catastrophicConfirmation = "yes-i-am-sure"
+)
+
+// networkConstructs are the constructs that change the network, where a
+// mistake can cut off the connection a remote session goes over
+var networkConstructs = map[string]bool{"firewall": true, "route": true, "interface": true, "network service": true}
+
+// riskRule recognises a command that can do damage that is hard to undo.
+// Rules for the same construct run worst first, and only the first that
+// matches counts, so rm -rf / is not also reported as rm -rf.
+type riskRule struct {
+	level     int
+	construct string
+	pattern   *regexp.Regexp
+	reason    string
+}
+
+var riskRules = []riskRule{
+	// Deleting and overwriting data
+	{RiskCatastrophic, "rm", regexp.MustCompile(`\brm\s+(?:-\S+\s+)*(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s+(?:-\S+\s+)*(?:["']?/["']?\*?|~/?|"?\$HOME/?"?)(?:\s|$|;|&|\|)`), "deletes recursively from / or the home directory"},
+	{RiskHigh, "rm", regexp.MustCompile(`\brm\s+(?:-\S+\s+)*(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s+(?:-\S+\s+)*(?:\*|\.)(?:\s|$|;|&|\|)`), "deletes recursively everything here"},
+	{RiskMedium, "rm", regexp.MustCompile(`\brm\s+(?:-\S+\s+)*-(?:[a-zA-Z]*[rR][a-zA-Z]*f|[a-zA-Z]*f[a-zA-Z]*[rR])[a-zA-Z]*\b`), "deletes recursively without asking"},
+	{RiskCatastrophic, "dd", regexp.MustCompile(`\bdd\b[^|;&]*\bof=/dev/(?:sd[a-z]|nvme\d|hd[a-z]|vd[a-z]|xvd[a-z]|r?disk\d|mmcblk\d|md\d|dm-\d|mapper/|loop\d)`), "overwrites a disk with dd"},
+	{RiskHigh, "dd", regexp.MustCompile(`\bdd\b[^|;&]*\bof=/dev/`), "overwrites a device with dd"},
+	{RiskMedium, "dd", regexp.MustCompile(`\bdd\b[^|;&]*\bof=`), "overwrites a file with dd"},
+	{RiskCatastrophic, "format", regexp.MustCompile(`\b(?:mkfs(?:\.\w+)?|mke2fs|mkswap|wipefs|Format-Volume|Clear-Disk)\b|\bformat\s+[a-zA-Z]:`), "formats or wipes a disk"},
+	{RiskHigh, "format", regexp.MustCompile(`\b(?:fdisk|sfdisk|sgdisk|parted|diskpart)\b`), "repartitions a disk"},
+	{RiskCatastrophic, "device", regexp.MustCompile(`>\s*/dev/(?:sd[a-z]|nvme\d|hd[a-z]|vd[a-z]|xvd[a-z]|disk\d|mmcblk\d)`), "writes straight to a disk device"},
+	{RiskMedium, "shred", regexp.MustCompile(`\bshred\b`), "shreds files beyond recovery"},
+	{RiskCatastrophic, "Remove-Item", regexp.MustCompile(`(?i)\bRemove-Item\b[^|;]*-Recurse\b[^|;]*(?:\s[A-Z]:\\?\s|\s[A-Z]:\\\*|\s\$env:USERPROFILE\b)`), "deletes a drive or profile recursively"},
+	{RiskMedium, "Remove-Item", regexp.MustCompile(`(?i)\bRemove-Item\b[^|;]*-Recurse\b[^|;]*-Force\b|\bRemove-Item\b[^|;]*-Force\b[^|;]*-Recurse\b`), "deletes recursively without asking"},
+
+	// Permissions and ownership
+	{RiskBlocked, "chmod", regexp.MustCompile(`\bchmod\s+(?:-\S+\s+)*-[a-zA-Z]*R[a-zA-Z]*\s+(?:-\S+\s+)*(?:0?777|a\+rwx|ugo\+rwx)\b`), "makes a whole tree writable by everyone"},
+	{RiskMedium, "chmod", regexp.MustCompile(`\bchmod\s+(?:-\S+\s+)*(?:0?777|a\+rwx|ugo\+rwx)\b`), "makes files writable by everyone"},
+	{RiskCatastrophic, "chown", regexp.MustCompile(`\bch(?:own|mod|grp)\s+(?:-\S+\s+)*-[a-zA-Z]*R[a-zA-Z]*\s+\S+\s+/(?:\s|$)`), "changes ownership or permissions of the whole filesystem"},
+
+	// Running code from the internet
+	{RiskHigh, "pipe to shell", regexp.MustCompile(`\b(?:curl|wget|iwr|Invoke-WebRequest|irm|Invoke-RestMethod)\b[^|;]*\|\s*(?:sudo\s+)?(?:-\S+\s+)*(?:ba|z|da|k|fi)?sh\b|\b(?:curl|wget)\b[^|;]*\|\s*(?:sudo\s+)?python3?\b|(?i)\b(?:iwr|irm|Invoke-WebRequest|Invoke-RestMethod)\b[^|;]*\|\s*(?:iex|Invoke-Expression)\b`), "runs a script downloaded from the internet without showing it"},
+
+	// The Windows registry
+	{RiskHigh, "registry", regexp.MustCompile(`(?i)\breg(?:\.exe)?\s+(?:add|delete|import|restore|load|unload)\b`), "edits the Windows registry"},
+	{RiskHigh, "registry", regexp.MustCompile(`(?i)\b(?:Set-ItemProperty|New-ItemProperty|Remove-ItemProperty|Remove-Item|New-Item|Set-Item)\b[^|;]*\bHK(?:LM|CU|CR|U|CC)\b`), "edits the Windows registry"},
+
+	// The whole system
+	{RiskHigh, "fork bomb", regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`), "is a fork bomb"},
+	{RiskHigh, "kill", regexp.MustCompile(`\bkill\s+(?:-9\s+|-KILL\s+|-s\s+KILL\s+)?-1\b`), "kills every process you own"},
+	{RiskMedium, "shutdown", regexp.MustCompile(`\b(?:shutdown|reboot|poweroff|halt|Stop-Computer|Restart-Computer)\b`), "shuts down or restarts the machine"},
+
+	// The network, which a mistake in can lock out a remote session
+	{RiskHigh, "firewall", regexp.MustCompile(`\bip6?tables(?:-restore)?\b[^|;&]*\s-(?:[ADIRFPXNZE]\b|-(?:append|delete|insert|replace|flush|policy|delete-chain|new-chain|zero|rename-chain)\b)|\bip6?tables-restore\b|\bnft\s+(?:-\S+\s+)*(?:add|insert|delete|flush|replace|create|destroy|-f)\b|\bufw\s+(?:--\S+\s+)*(?:enable|disable|reset|allow|deny|reject|limit|delete|insert|route|default)\b|\bfirewall-cmd\b[^|;&]*--(?:add|remove|set|change|reload|complete-reload|panic-on)|\bpfctl\b[^|;&]*\s-[dEFf]\b|(?i)\bnetsh\s+(?:advfirewall|firewall)\b[^|;&]*\b(?:set|add|delete|reset)\b|(?i)\b(?:New|Set|Remove|Enable|Disable)-NetFirewall\w*`), "changes the firewall, which could cut off remote access"},
+	{RiskHigh, "route", regexp.MustCompile(`\broute\s+(?:-\S+\s+)*(?:add|del|delete|change|flush)\b|\bip\s+(?:-\S+\s+)*r(?:oute|o)?\s+(?:add|del|delete|change|replace|flush|append)\b|(?i)\bnetsh\s+interface\b[^|;&]*\b(?:add|delete|set)\s+route\b|(?i)\b(?:New|Set|Remove)-NetRoute\b`), "changes the routing table, which could cut off remote access"},
+	{RiskHigh, "interface", regexp.MustCompile(`\bip\s+(?:-\S+\s+)*(?:link|l)\s+set\b[^|;&]*\bdown\b|\bip\s+(?:-\S+\s+)*(?:address|addr|a)\s+(?:del|delete|flush|replace)\b|\bifconfig\s+\S+\s+(?:down\b|inet6?\s|\d)|\bif(?:down|up)\s|\bnmcli\b[^|;&]*\b(?:down|delete|off|disconnect|modify)\b|(?i)\bnetsh\s+interface\b[^|;&]*\b(?:set|delete)\s+(?:interface|address|dnsservers?)\b|(?i)\b(?:Disable-NetAdapter|Restart-NetAdapter|Remove-NetIPAddress|New-NetIPAddress|Set-NetIPAddress|Set-NetIPInterface|Set-DnsClientServerAddress)\b`), "reconfigures a network interface, which could cut off remote access"},
+	{RiskHigh, "network service", regexp.MustCompile(`\bsystemctl\s+(?:-\S+\s+)*(?:stop|restart|disable|mask)\s+(?:\S+\s+)*(?:networking|network|NetworkManager|systemd-networkd|sshd?|ssh\.socket)(?:\.service)?(?:\s|$)|\bservice\s+(?:networking|network|network-manager|sshd?)\s+(?:stop|restart)\b`), "stops or restarts the network or SSH service, which could cut off remote access"},
+
+	// History that can't be recovered
+	{RiskMedium, "git push", regexp.MustCompile(`\bgit\s+push\b[^|;&]*(?:\s--force\b|\s-f\b|\s--force-with-lease\b)`), "rewrites history on the remote"},
+	{RiskMedium, "git", regexp.MustCompile(`\bgit\s+(?:reset\s+--hard|clean\s+-[a-zA-Z]*f)`), "throws away uncommitted work"},
+}
+
+// Risk is how much damage a command could do, and why. Denied is set when
+// the organization policy's deny rules block it; Network when it changes
+// the firewall, routes, interfaces or the services remote access needs.
+type Risk struct {
+	Level   int
+	Reasons []string
+	Denied  bool
+	Network bool
+}
+
+// AssessRisk runs a command through the organization policy's deny rules
+// and the risk rules, returning the worst level matched and a reason for
+// each construct found
+func AssessRisk(command string) Risk {
+	var risk Risk
+	if p, _ := LoadPolicy(); p != nil {
+		for _, reason := range p.Denied(command) {
+			risk.Level, risk.Denied = RiskBlocked, true
+			risk.Reasons = append(risk.Reasons, "organization policy: "+reason)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, rule := range riskRules {
+		if seen[rule.construct] || !rule.pattern.MatchString(command) {
+			continue
+		}
+		seen[rule.construct] = true
+		risk.Network = risk.Network || networkConstructs[rule.construct]
+		risk.Level = max(risk.Level, rule.level)
+		risk.Reasons = append(risk.Reasons, rule.reason)
+	}
+	return risk
+}
+
+// Label names the risk level
+func (r Risk) Label() string {
+	switch r.Level {
+	case RiskBlocked:
+		return "BLOCKED BY POLICY"
+	case RiskCatastrophic:
+		return "CATASTROPHIC"
+	case RiskHigh:
+		return "HIGH RISK"
+	case RiskMedium:
+		return "RISK"
+	}
+	return ""
+}
+
+// String renders the risk as a warning line, or "" when there is none
+func (r Risk) String() string {
+	if r.Level == RiskNone {
+		return ""
+	}
+	return r.Label() + ": " + strings.Join(r.Reasons, "; ")
+}
+
+// NeedsConfirmation reports whether the risk needs a typed confirmation
+// before the command runs, or for catastrophic ones, before it is typed at
+// the prompt at all
+func (r Risk) NeedsConfirmation(run bool) bool {
+	return r.Level == RiskCatastrophic || run && r.Level == RiskHigh
+}
+
+// ConfirmationPrompt asks for what has to be typed to let the command
+// through
+func (r Risk) ConfirmationPrompt() string {
+	if r.Level == RiskCatastrophic {
+		return fmt.Sprintf("This command could destroy the system or a disk. Type it back exactly, or type %s, and press Enter: ", catastrophicConfirmation)
+	}
+	if r.Network {
+		return fmt.Sprintf("This command changes the network, and a mistake could lock you out of a remote machine. Type %s and press Enter to run it: ", riskConfirmation)
+	}
+	return fmt.Sprintf("This command is high risk. Type %s and press Enter to run it: ", riskConfirmation)
+}
+
+// Confirmed reports whether typed confirms command at this risk: the word
+// for a high-risk command, and for a catastrophic one the command itself,
+// spaced any way, or the longer phrase
+func (r Risk) Confirmed(typed, command string) bool {
+	typed = strings.TrimSpace(typed)
+	if r.Level == RiskCatastrophic {
+		return typed == catastrophicConfirmation || strings.Join(strings.Fields(typed), " ") == strings.Join(strings.Fields(command), " ")
+	}
+	return strings.EqualFold(typed, riskConfirmation)
+}
+
+// renderRiskBadge shows the risk in the review: in the warning colour for
+// medium, the error colour for high
+func renderRiskBadge(risk Risk) string {
+	color := theme.Warning
+	if risk.Level >= RiskHigh {
+		color = theme.Error
+	}
+	return lipgloss.NewStyle().Foreground(theme.BadgeText).Background(color).Padding(0, 1).Render(risk.String())
+}
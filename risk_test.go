@@ -0,0 +1,121 @@
+package main
+
+import "testing"
+
+func TestAssessRisk(t *testing.T) {
+	t.Setenv(PolicyEnv, "")
+	resetPolicy()
+	tests := []struct {
+		command string
+		level   int
+	}{
+		// Deleting from / or the home directory, however it is prefixed
+		{"rm -rf /", RiskCatastrophic},
+		{"rm -rf /*", RiskCatastrophic},
+		{"rm -rf ~", RiskCatastrophic},
+		{"rm -r -f $HOME/", RiskCatastrophic},
+		{"rm --recursive --force /", RiskCatastrophic},
+		{"rm -rf -- /", RiskCatastrophic},
+		{"sudo rm -rf /", RiskCatastrophic},
+		{"sudo -E env FOO=1 rm -rf /", RiskCatastrophic},
+		{"cd /tmp && rm -rf / ", RiskCatastrophic},
+		{`rm -rf "/"`, RiskCatastrophic},
+		{`rm -rf "$HOME"`, RiskCatastrophic},
+		{"rm -rf '/'*", RiskCatastrophic},
+		// A quoted ~ is a directory called ~, not the home directory
+		{"rm -rf '~'", RiskMedium},
+		{"rm -rf ./", RiskMedium},
+		{"rm -rf .", RiskHigh},
+		{"rm -r *", RiskHigh},
+		{"rm -rf build", RiskMedium},
+		{"rm -rf /tmp/build", RiskMedium},
+		{"rm build.log", RiskNone},
+		{"rm -i /etc/hosts", RiskNone},
+		{"firmware-update", RiskNone},
+		// Disks
+		{"sudo dd if=image.iso of=/dev/sdb bs=4M", RiskCatastrophic},
+		{"dd if=/dev/zero of=/dev/null count=1", RiskHigh},
+		{"dd if=a of=b", RiskMedium},
+		{"sudo mkfs.ext4 /dev/sdb1", RiskCatastrophic},
+		{"parted /dev/sda print", RiskHigh},
+		{"cat image > /dev/sda", RiskCatastrophic},
+		{"echo hi > /dev/null", RiskNone},
+		{"Format-Volume -DriveLetter D", RiskCatastrophic},
+		// Permissions
+		{"chmod -R 777 /var/www", RiskBlocked},
+		{"sudo chmod -R a+rwx .", RiskBlocked},
+		{"chmod 777 script.sh", RiskMedium},
+		{"chmod 755 script.sh", RiskNone},
+		{"sudo chown -R me /", RiskCatastrophic},
+		{"chown -R me /srv/app", RiskNone},
+		// Downloads run in a shell
+		{"curl -fsSL https://example.com/install.sh | sh", RiskHigh},
+		{"wget -qO- https://example.com/x | sudo bash", RiskHigh},
+		{"curl https://example.com/get.py | python3", RiskHigh},
+		{"iwr https://example.com/x.ps1 | iex", RiskHigh},
+		{"curl -o install.sh https://example.com/install.sh", RiskNone},
+		{"curl https://example.com | shellcheck -", RiskNone},
+		// The whole system
+		{":(){ :|:& };:", RiskHigh},
+		{"kill -9 -1", RiskHigh},
+		{"kill -9 1234", RiskNone},
+		{"sudo reboot", RiskMedium},
+		// The network
+		{"sudo iptables -F", RiskHigh},
+		{"iptables -L -n", RiskNone},
+		{"sudo ufw enable", RiskHigh},
+		{"ufw status", RiskNone},
+		{"sudo ip link set eth0 down", RiskHigh},
+		{"ip addr show", RiskNone},
+		{"sudo systemctl restart sshd", RiskHigh},
+		{"systemctl status sshd", RiskNone},
+		{"systemctl restart nginx", RiskNone},
+		// History
+		{"git push --force origin main", RiskMedium},
+		{"git push origin main", RiskNone},
+		{"git reset --hard HEAD~1", RiskMedium},
+		{"git clean -fdx", RiskMedium},
+		{"git status", RiskNone},
+		// The registry
+		{`reg delete HKCU\Software\Foo /f`, RiskHigh},
+		{`reg query HKCU\Software\Foo`, RiskNone},
+	}
+	for _, test := range tests {
+		if got := AssessRisk(test.command); got.Level != test.level {
+			t.Errorf("AssessRisk(%q) = %d (%s), want %d", test.command, got.Level, got, test.level)
+		}
+	}
+}
+
+func TestAssessRiskReportsEachConstructOnce(t *testing.T) {
+	t.Setenv(PolicyEnv, "")
+	resetPolicy()
+	risk := AssessRisk("rm -rf / && dd if=/dev/zero of=/dev/sda")
+	if risk.Level != RiskCatastrophic || len(risk.Reasons) != 2 {
+		t.Errorf("got %d with reasons %q, want catastrophic with one reason for rm and one for dd", risk.Level, risk.Reasons)
+	}
+	if network := AssessRisk("sudo ufw reset"); !network.Network {
+		t.Errorf("AssessRisk(ufw reset).Network = false")
+	}
+}
+
+func TestRiskConfirmed(t *testing.T) {
+	tests := []struct {
+		level          int
+		typed, command string
+		want           bool
+	}{
+		{RiskHigh, "yes", "rm -rf .", true},
+		{RiskHigh, " YES ", "rm -rf .", true},
+		{RiskHigh, "y", "rm -rf .", false},
+		{RiskCatastrophic, "yes", "rm -rf /", false},
+		{RiskCatastrophic, "rm  -rf   /", "rm -rf /", true},
+		{RiskCatastrophic, "rm -rf /tmp", "rm -rf /", false},
+		{RiskCatastrophic, catastrophicConfirmation, "rm -rf /", true},
+	}
+	for _, test := range tests {
+		if got := (Risk{Level: test.level}).Confirmed(test.typed, test.command); got != test.want {
+			t.Errorf("Risk{%d}.Confirmed(%q, %q) = %t, want %t", test.level, test.typed, test.command, got, test.want)
+		}
+	}
+}
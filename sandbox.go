@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Sandboxes commands can be tried in before they run in the real shell
+const (
+	SandboxAuto    = "auto" // the first one installed
+	SandboxOff     = "off"
+	SandboxBwrap   = "bwrap"
+	SandboxDocker  = "docker"
+	SandboxPodman  = "podman"
+	SandboxWindows = "windows"
+)
+
+// DefaultSandboxImage is the container image docker and podman run
+// commands in
+const DefaultSandboxImage = "debian:stable-slim"
+
+// ValidateSandbox checks a value of the sandbox config key
+func ValidateSandbox(value string) error {
+	switch value {
+	case SandboxAuto, SandboxOff, SandboxBwrap, SandboxDocker, SandboxPodman, SandboxWindows:
+		return nil
+	}
+	return fmt.Errorf("expected auto, off, bwrap, docker, podman or windows")
+}
+
+// SandboxResult is how a command fared in the sandbox
+type SandboxResult struct {
+	Backend  string
+	Command  string
+	Output   string
+	ExitCode int
+	Duration time.Duration
+	// Err is set when the sandbox itself failed, rather than the command
+	Err error
+}
+
+// sandboxView is a sandboxed run of the command under review: running
+// until result arrives, then showing its output
+type sandboxView struct {
+	seq    int
+	result *SandboxResult
+	scroll int
+}
+
+// sandboxMsg carries the result of a sandboxed run
+type sandboxMsg struct {
+	seq    int
+	result SandboxResult
+}
+
+// sandboxAvailable reports whether the command under review can be tried
+// in the sandbox: one is installed, the shell is local, and commands may
+// run at all
+func (m Model) sandboxAvailable() bool {
+	return m.sandboxBackend != "" && len(m.remotes) == 0 && !m.config.SuggestOnlyMode()
+}
+
+// startSandbox runs the command under review in the sandbox. Commands
+// policy keeps from running stay out of it too.
+func (m Model) startSandbox() (tea.Model, tea.Cmd) {
+	command := strings.TrimSpace(m.reviewInput.Value())
+	if command == "" || !m.sandboxAvailable() || m.risk.Level == RiskBlocked || m.elevationBlocked() {
+		return m, nil
+	}
+	countFeature("sandbox")
+	m.sandboxSeq++
+	m.sandbox = &sandboxView{seq: m.sandboxSeq}
+	config, backend, cwd, seq := m.config, m.sandboxBackend, m.shellCwd(), m.sandboxSeq
+	return m, func() tea.Msg {
+		return sandboxMsg{seq: seq, result: RunSandbox(config, backend, command, cwd)}
+	}
+}
+
+// updateSandbox handles keys while the review shows a sandboxed run: p or
+// Enter promotes the command to the real shell, Esc goes back to the
+// review; a run still going is abandoned
+func (m Model) updateSandbox(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.sandbox.scroll = max(0, m.sandbox.scroll-1)
+	case "down", "j":
+		m.sandbox.scroll++
+	case "p", "enter":
+		if m.sandbox.result == nil {
+			return m, nil
+		}
+		countFeature("sandbox promote")
+		m.sandbox = nil
+		m.reviewChoice = reviewRun
+		if m.production || m.lintBlocked() {
+			// These still take Ctrl+Y in the review
+			return m, nil
+		}
+		return m.chooseReviewAction(reviewRun), nil
+	case "esc", "ctrl+k":
+		m.sandbox = nil
+	}
+	return m, nil
+}
+
+// renderSandbox shows the sandboxed run in the review, in place of its
+// actions
+func (m Model) renderSandbox(hintStyle lipgloss.Style, height int) string {
+	s := m.sandbox
+	infoStyle := lipgloss.NewStyle().Foreground(theme.Info)
+	if s.result == nil {
+		return infoStyle.Render("Running in the "+m.sandboxBackend+" sandbox...") + "\n\n" +
+			hintStyle.Render("Esc to go back to the review")
+	}
+
+	r := s.result
+	var b strings.Builder
+	switch {
+	case r.Err != nil:
+		b.WriteString(lipgloss.NewStyle().Foreground(theme.Error).Render(fmt.Sprintf("The %s sandbox failed: %v", r.Backend, r.Err)))
+	case r.ExitCode != 0:
+		b.WriteString(lipgloss.NewStyle().Foreground(theme.Warning).Render(fmt.Sprintf("In the %s sandbox: exit status %d after %s", r.Backend, r.ExitCode, r.Duration)))
+	default:
+		b.WriteString(infoStyle.Render(fmt.Sprintf("In the %s sandbox: succeeded after %s", r.Backend, r.Duration)))
+	}
+	b.WriteString("\n")
+
+	output := strings.TrimRight(plainText([]byte(r.Output)), "\n")
+	if output == "" {
+		output = "(no output)"
+	}
+	lines := strings.Split(wrapBidi(output, max(10, m.width-10), m.reorderBidi()), "\n")
+	visible := max(1, height)
+	scroll := min(s.scroll, max(0, len(lines)-visible))
+	outputStyle := lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(normalBorder()).
+		BorderForeground(theme.Dim).
+		PaddingLeft(1)
+	b.WriteString(outputStyle.Render(strings.Join(lines[scroll:min(len(lines), scroll+visible)], "\n")))
+	b.WriteString("\n\n")
+	b.WriteString(hintStyle.Render("↑/↓ scroll, p or Enter to run it in the real shell, Esc to go back to the review"))
+	return b.String()
+}
@@ -0,0 +1,13 @@
+//go:build minimal
+
+package main
+
+// SandboxBackend finds no sandbox in a minimal build
+func SandboxBackend(preference, shell string) (string, error) {
+	return "", leftOut("the sandbox")
+}
+
+// RunSandbox runs nothing in a minimal build
+func RunSandbox(config Config, backend, command, cwd string) SandboxResult {
+	return SandboxResult{Backend: backend, Command: command, Err: leftOut("the sandbox")}
+}
@@ -0,0 +1,217 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sandboxTimeout bounds a sandboxed run; Windows Sandbox boots a whole
+	// system first, so it gets longer
+	sandboxTimeout        = 2 * time.Minute
+	windowsSandboxTimeout = 5 * time.Minute
+	// sandboxOutputLimit is how much of the end of the output is kept
+	sandboxOutputLimit = 64 << 10
+)
+
+// windowsSandboxExe starts Windows Sandbox, an optional Windows feature
+const windowsSandboxExe = `C:\Windows\System32\WindowsSandbox.exe`
+
+// SandboxBackend picks the sandbox commands for shell are tried in: the
+// one configured, or on auto the first installed of bubblewrap, docker and
+// podman, or Windows Sandbox for PowerShell and cmd on Windows. Containers
+// run a POSIX shell, so they only take commands written for one.
+func SandboxBackend(preference, shell string) (string, error) {
+	if preference == SandboxOff {
+		return "", errors.New("the sandbox is off")
+	}
+	dialect, _ := ParseDialect(shell)
+	windowsShell := dialect == DialectPowerShell || dialect == DialectCmd
+	usable := func(backend string) error {
+		switch backend {
+		case SandboxBwrap:
+			if runtime.GOOS != "linux" {
+				return errors.New("bubblewrap only runs on Linux")
+			}
+		case SandboxDocker, SandboxPodman:
+			if windowsShell {
+				return fmt.Errorf("%s runs a POSIX shell, not %s", backend, dialectNames[dialect])
+			}
+		case SandboxWindows:
+			if runtime.GOOS != "windows" || !windowsShell {
+				return errors.New("Windows Sandbox runs PowerShell and cmd commands on Windows")
+			}
+			if _, err := os.Stat(windowsSandboxExe); err != nil {
+				return errors.New("Windows Sandbox is not enabled (Windows features: Windows Sandbox)")
+			}
+			return nil
+		}
+		if _, err := exec.LookPath(backend); err != nil {
+			return fmt.Errorf("%s is not installed", backend)
+		}
+		return nil
+	}
+
+	if preference != SandboxAuto {
+		if err := usable(preference); err != nil {
+			return "", err
+		}
+		return preference, nil
+	}
+	for _, backend := range []string{SandboxBwrap, SandboxDocker, SandboxPodman, SandboxWindows} {
+		if usable(backend) == nil {
+			return backend, nil
+		}
+	}
+	return "", errors.New("no sandbox found: install bubblewrap, docker or podman, or enable Windows Sandbox")
+}
+
+// RunSandbox runs command in a throwaway sandbox and returns its output.
+// The host's files are there to read but not to change: bubblewrap shows
+// the whole filesystem read-only, containers the working directory, and
+// Windows Sandbox maps it read-only. Writes anywhere else land in a
+// scratch space thrown away afterwards. The network is cut unless allowed.
+func RunSandbox(config Config, backend, command, cwd string) SandboxResult {
+	result := SandboxResult{Backend: backend, Command: command}
+	start := time.Now()
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+
+	var output []byte
+	var err error
+	if backend == SandboxWindows {
+		output, result.ExitCode, err = runWindowsSandbox(config, command, cwd)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), sandboxTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, backend, sandboxArgs(config, backend, command, cwd)...)
+		output, err = cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		switch {
+		case ctx.Err() != nil:
+			err = fmt.Errorf("stopped after %s", sandboxTimeout)
+		case errors.As(err, &exitErr):
+			result.ExitCode, err = exitErr.ExitCode(), nil
+		}
+	}
+	result.Duration = time.Since(start).Round(100 * time.Millisecond)
+	result.Err = err
+	if len(output) > sandboxOutputLimit {
+		output = output[len(output)-sandboxOutputLimit:]
+		if i := bytes.IndexByte(output, '\n'); i >= 0 {
+			output = output[i+1:]
+		}
+		output = append([]byte("... (earlier output cut)\n"), output...)
+	}
+	result.Output = string(output)
+	return result
+}
+
+// sandboxArgs are the arguments of bwrap, docker or podman that run
+// command in the sandbox
+func sandboxArgs(config Config, backend, command, cwd string) []string {
+	if backend == SandboxBwrap {
+		args := []string{
+			"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp", "--tmpfs", "/run",
+			"--unshare-all", "--die-with-parent", "--new-session", "--chdir", cwd,
+		}
+		if config.SandboxNetwork {
+			args = append(args, "--share-net")
+		}
+		// The host's own shell, with everything installed on the host
+		return append(append(args, "--"), shellCommand(config.Shell, command).Args...)
+	}
+
+	image := valueOrDefault(config.SandboxImage, DefaultSandboxImage)
+	args := []string{"run", "--rm", "-i", "--pids-limit", "512", "--memory", "1g",
+		"-v", cwd + ":" + cwd + ":ro", "-w", cwd, "--entrypoint", ""}
+	if !config.SandboxNetwork {
+		args = append(args, "--network", "none")
+	}
+	// bash when the image has it, as generated commands assume it
+	return append(args, image, "sh", "-c", `if command -v bash >/dev/null; then exec bash -c "$1"; fi; exec sh -c "$1"`, "sh", command)
+}
+
+// windowsSandboxRunner is the script Windows Sandbox runs at logon: the
+// command with its output captured, then its exit status, then shutdown
+const windowsSandboxRunner = `Set-Location C:\work
+%s
+Set-Content C:\sandbox\exit.txt $code
+Stop-Computer -Force`
+
+// runWindowsSandbox runs a PowerShell or cmd command in Windows Sandbox.
+// A shared folder carries the command in and its output and exit status
+// out; the working directory is mapped read-only.
+func runWindowsSandbox(config Config, command, cwd string) ([]byte, int, error) {
+	dir, err := os.MkdirTemp("", "ai-terminal-sandbox-")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	run := `& C:\sandbox\command.ps1 *> C:\sandbox\output.txt
+$code = if ($?) { 0 } else { 1 }
+if ($LASTEXITCODE) { $code = $LASTEXITCODE }`
+	script := "command.ps1"
+	if dialect, _ := ParseDialect(config.Shell); dialect == DialectCmd {
+		run = `cmd /c C:\sandbox\command.cmd > C:\sandbox\output.txt 2>&1
+$code = $LASTEXITCODE`
+		script = "command.cmd"
+	}
+	if err := os.WriteFile(filepath.Join(dir, script), []byte(command+"\r\n"), 0600); err != nil {
+		return nil, 0, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.ps1"), []byte(fmt.Sprintf(windowsSandboxRunner, run)), 0600); err != nil {
+		return nil, 0, err
+	}
+
+	escape := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	networking := "Disable"
+	if config.SandboxNetwork {
+		networking = "Default"
+	}
+	wsb := fmt.Sprintf(`<Configuration>
+  <Networking>%s</Networking>
+  <MappedFolders>
+    <MappedFolder><HostFolder>%s</HostFolder><SandboxFolder>C:\sandbox</SandboxFolder><ReadOnly>false</ReadOnly></MappedFolder>
+    <MappedFolder><HostFolder>%s</HostFolder><SandboxFolder>C:\work</SandboxFolder><ReadOnly>true</ReadOnly></MappedFolder>
+  </MappedFolders>
+  <LogonCommand><Command>powershell -NoProfile -ExecutionPolicy Bypass -File C:\sandbox\run.ps1</Command></LogonCommand>
+</Configuration>`, networking, escape(dir), escape(cwd))
+	wsbPath := filepath.Join(dir, "sandbox.wsb")
+	if err := os.WriteFile(wsbPath, []byte(wsb), 0600); err != nil {
+		return nil, 0, err
+	}
+	if err := exec.Command(windowsSandboxExe, wsbPath).Start(); err != nil {
+		return nil, 0, err
+	}
+
+	// The sandbox starts detached; its exit status file says it is done
+	deadline := time.Now().Add(windowsSandboxTimeout)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(filepath.Join(dir, "exit.txt")); err == nil {
+			code, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+			output, _ := os.ReadFile(filepath.Join(dir, "output.txt"))
+			return output, code, nil
+		}
+		time.Sleep(time.Second)
+	}
+	return nil, 0, fmt.Errorf("no result after %s", windowsSandboxTimeout)
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// scriptDraft is a multi-line script returned by the model, held for review
+// instead of being typed into the shell line by line
+type scriptDraft struct {
+	content  string
+	query    string
+	warnings []string
+	scroll   int
+	edited   bool
+
+	// editor is non-nil while the script is being edited, and path while
+	// choosing where to save it. A high-risk script runs only once its
+	// confirmation is typed, as a command in the review does; stepwise
+	// tells it is then run line by line.
+	editor    *textarea.Model
+	path      *textinput.Model
+	status    string
+	overwrite bool
+	stepwise  bool
+}
+
+// catastrophicScriptNotice says why a script that could destroy the system
+// or a disk isn't run from the review
+const catastrophicScriptNotice = "This script could destroy the system or a disk, so it isn't run from here. Save or copy it, check it, and run it yourself."
+
+// isScript reports whether a generated command is really a multi-line script
+func isScript(command string) bool {
+	return strings.Contains(strings.TrimSpace(command), "\n")
+}
+
+// newScriptDraft prepares a script for review
+func newScriptDraft(content, query string) *scriptDraft {
+	content = strings.TrimSpace(content) + "\n"
+	return &scriptDraft{
+		content:  content,
+		query:    query,
+		warnings: scriptWarnings(content),
+	}
+}
+
+// scriptWarnings lists the portability problems in a script, what it
+// could destroy and the privileges it takes
+func scriptWarnings(content string) []string {
+	warnings := CheckPortability(content, DetectUserland())
+	if risk := AssessRisk(content); risk.Level > RiskNone {
+		warnings = append(warnings, risk.String())
+	}
+	if elevation := DetectElevation(content); elevation.Elevated() {
+		warnings = append(warnings, elevation.String())
+	}
+	return append(warnings, DateWarnings(content)...)
+}
+
+// scriptExtensions maps shell dialects to script file extensions
+var scriptExtensions = map[string]string{
+	DialectBash:       ".sh",
+	DialectPowerShell: ".ps1",
+	DialectCmd:        ".bat",
+	DialectFish:       ".fish",
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// defaultScriptName suggests a file name from the first few words of the
+// request, with the extension for the configured shell
+func defaultScriptName(query, shell string) string {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) > 4 {
+		words = words[:4]
+	}
+	name := strings.Trim(nonSlugChars.ReplaceAllString(strings.Join(words, "-"), "-"), "-")
+	if name == "" {
+		name = "script"
+	}
+
+	ext := ".sh"
+	if dialect, err := ParseDialect(shell); err == nil {
+		ext = scriptExtensions[dialect]
+	}
+	return name + ext
+}
+
+// scriptInvocation is how to run a saved script from the shell prompt
+func scriptInvocation(path, shell string) string {
+	if filepath.IsAbs(path) || strings.ContainsAny(path, `/\`) {
+		return path
+	}
+	dialect, _ := ParseDialect(shell)
+	switch dialect {
+	case DialectPowerShell:
+		return `.\` + path
+	case DialectCmd:
+		return path
+	}
+	return "./" + path
+}
+
+// saveScript writes the script to path, relative to the shell's working
+// directory, and makes it executable
+func (m Model) saveScript(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	full := path
+	if !filepath.IsAbs(full) {
+		if cwd := m.shellCwd(); cwd != "" {
+			full = filepath.Join(cwd, path)
+		}
+	}
+
+	if _, err := os.Stat(full); err == nil && !m.script.overwrite {
+		return "", os.ErrExist
+	}
+	if err := os.WriteFile(full, []byte(m.script.content), 0755); err != nil {
+		return "", err
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(full, 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// updateScript handles keys while a script is under review
+func (m Model) updateScript(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s := m.script
+
+	if m.confirmingRisk {
+		return m.updateRiskConfirm(msg, strings.TrimSpace(s.content), func(m Model) Model {
+			if m.script.stepwise {
+				return m.runScriptSteps(true)
+			}
+			return m.runScript(true)
+		}), nil
+	}
+
+	if s.editor != nil {
+		if msg.Type == tea.KeyEsc {
+			value := strings.TrimRight(s.editor.Value(), "\n") + "\n"
+			if value != s.content {
+				s.content = value
+				s.edited = true
+				s.warnings = scriptWarnings(value)
+			}
+			s.editor = nil
+			return m, nil
+		}
+		editor, cmd := s.editor.Update(msg)
+		s.editor = &editor
+		return m, cmd
+	}
+
+	if s.path != nil {
+		switch msg.Type {
+		case tea.KeyEsc:
+			s.path, s.status, s.overwrite = nil, "", false
+		case tea.KeyEnter:
+			path := strings.TrimSpace(s.path.Value())
+			if path == "" {
+				return m, nil
+			}
+			saved, err := m.saveScript(path)
+			if err == os.ErrExist {
+				s.status = path + " already exists. Press Enter again to overwrite."
+				s.overwrite = true
+				return m, nil
+			}
+			if err != nil {
+				s.status = "Error: " + err.Error()
+				return m, nil
+			}
+			return m.finishScript(saved), nil
+		default:
+			input, cmd := s.path.Update(msg)
+			s.path = &input
+			s.overwrite = false
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	s.status = ""
+	switch msg.String() {
+	case "enter":
+		return m.runScript(false), nil
+	case "up", "k":
+		s.scroll = max(0, s.scroll-1)
+	case "down", "j":
+		s.scroll = min(s.scroll+1, max(0, strings.Count(s.content, "\n")-1))
+	case "e":
+		editor := textarea.New()
+		editor.ShowLineNumbers = true
+		editor.CharLimit = 0
+		editor.MaxHeight = 0
+		editor.SetWidth(m.width - 6)
+		editor.SetHeight(max(3, m.height*2/3-6))
+		editor.Cursor.SetMode(cursor.CursorStatic)
+		editor.SetValue(s.content)
+		editor.Focus()
+		s.editor = &editor
+	case "s":
+		if !m.scriptSavable() {
+			// The warning says why; the script has to be edited first
+			return m, nil
+		}
+		input := textinput.New()
+		input.Prompt = "Save as: "
+		input.SetValue(defaultScriptName(s.query, m.config.Shell))
+		input.CursorEnd()
+		input.Focus()
+		s.path = &input
+	case "c":
+		if err := m.copyCommand(s.query, s.content); err != nil {
+			s.warnings = append(s.warnings, err.Error())
+			return m, nil
+		}
+		m.script = nil
+		m.closePrompt()
+	case "r":
+		return m.runScriptSteps(false), nil
+	case "ctrl+r":
+		if m.genQuery != "" {
+			m.startRegenerate(s.content, true)
+		}
+	case "esc", "ctrl+k":
+		m.rejectCommand(s.content)
+		m.script = nil
+		m.closePrompt()
+	}
+	return m, nil
+}
+
+// confirmScript holds a high-risk script for its confirmation to be typed,
+// as the review holds a command, unless confirmed already; it reports
+// whether the script is held
+func (m *Model) confirmScript(confirmed, stepwise bool) bool {
+	risk := AssessRisk(m.script.content)
+	if confirmed || !risk.NeedsConfirmation(true) {
+		return false
+	}
+	m.risk, m.confirmingRisk, m.riskTyped = risk, true, ""
+	m.script.stepwise = stepwise
+	return true
+}
+
+// runScriptSteps runs a script of plain commands line by line, each step
+// confirmed, once the script as a whole may run
+func (m Model) runScriptSteps(confirmed bool) Model {
+	s := m.script
+	steps := m.chainSteps(s.content)
+	if steps == nil || m.config.SuggestOnlyMode() || !m.scriptSavable() {
+		return m
+	}
+	if AssessRisk(s.content).Level == RiskCatastrophic {
+		s.status = catastrophicScriptNotice
+		return m
+	}
+	if protected := ProtectedPaths(s.content, m.shellCwd(), m.config.ProtectedPaths); len(protected) > 0 {
+		s.status = protectedPathsNotice(protected)
+		return m
+	}
+	if m.confirmScript(confirmed, true) {
+		return m
+	}
+	outcome := OutcomeAccepted
+	if s.edited {
+		outcome = OutcomeEdited
+	}
+	m.recordHistory(HistoryEntry{Kind: HistoryAI, Query: s.query, Command: s.content, Outcome: outcome})
+	m.script = nil
+	m.closePrompt()
+	m.startSteps(steps, s.query)
+	return m
+}
+
+// runScript sends the script under review to the shell as one command, once
+// it parses, with high-risk scripts confirmed as in the review
+func (m Model) runScript(confirmed bool) Model {
+	s := m.script
+	if m.config.SuggestOnlyMode() || !m.scriptSavable() {
+		return m
+	}
+	if m.config.SyntaxCheck {
+		if err := CheckSyntax(s.content, m.config.Shell); err != nil {
+			s.status = "Syntax error: " + err.Error() + "; fix it with e before running"
+			return m
+		}
+	}
+	if AssessRisk(s.content).Level == RiskCatastrophic {
+		s.status = catastrophicScriptNotice
+		return m
+	}
+	if protected := ProtectedPaths(s.content, m.shellCwd(), m.config.ProtectedPaths); len(protected) > 0 {
+		s.status = protectedPathsNotice(protected)
+		return m
+	}
+	if app := m.fullScreenApp(); app != "" {
+		s.status = fullScreenNotice(app)
+		return m
+	}
+	if m.confirmScript(confirmed, false) {
+		return m
+	}
+
+	countFeature("run script")
+	outcome := OutcomeAccepted
+	if s.edited {
+		outcome = OutcomeEdited
+	}
+	m.recordHistory(HistoryEntry{Kind: HistoryAI, Query: s.query, Command: s.content, Outcome: outcome})
+	command := strings.TrimSpace(s.content)
+	m.execute(s.query, command, AuditRun)
+	m.script = nil
+	m.closePrompt()
+	return m
+}
+
+// scriptSavable reports whether policy lets the script under review be
+// saved or run: not when it is blocked, or elevated under block_elevated
+func (m Model) scriptSavable() bool {
+	content := m.script.content
+	return AssessRisk(content).Level != RiskBlocked && !(m.config.BlockElevated && DetectElevation(content).Elevated())
+}
+
+// finishScript records the saved script and types its invocation at the
+// shell prompt so it can be run or adjusted
+func (m Model) finishScript(path string) Model {
+	countFeature("save script")
+	outcome := OutcomeAccepted
+	if m.script.edited {
+		outcome = OutcomeEdited
+	}
+	m.recordHistory(HistoryEntry{Kind: HistoryAI, Query: m.script.query, Command: m.script.content, Outcome: outcome})
+
+	invocation := scriptInvocation(path, m.config.Shell)
+	if m.config.SuggestOnlyMode() || m.fullScreenApp() != "" {
+		// Saving writes the file but nothing is typed at the prompt, nor
+		// into a full-screen program
+		m.script = nil
+		m.closePrompt()
+		m.answer, m.answerTitle, m.answerScroll = "Saved "+path+". Run it with:\n\n  "+invocation, "Script saved", 0
+		return m
+	}
+	if m.pty != nil {
+		m.pty.Write([]byte(invocation))
+	}
+	m.typed = lineTracker{line: invocation, known: true}
+	m.auditTyped(AuditEntry{Query: m.script.query, Command: invocation, Source: AuditScript})
+	m.script = nil
+	m.closePrompt()
+	return m
+}
+
+// renderScript draws the script review box
+func (m Model) renderScript(maxHeight int) string {
+	s := m.script
+
+	boxStyle := lipgloss.NewStyle().
+		Border(roundedBorder()).
+		BorderForeground(theme.Accent).
+		Padding(0, 1).
+		Width(m.width - 2)
+
+	titleStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	warnStyle := lipgloss.NewStyle().Foreground(theme.Warning)
+
+	lines := strings.Split(strings.TrimRight(s.content, "\n"), "\n")
+	kind := "Generated script"
+	if SplitSteps(s.content, m.config.Shell) == nil {
+		// Heredocs, loops and script blocks run as one command
+		kind = "Multi-line command"
+	}
+	title := titleStyle.Render(fmt.Sprintf("%s (%d lines)", kind, len(lines)))
+
+	if s.editor != nil {
+		return boxStyle.Render(title + "\n" + s.editor.View() + "\n" +
+			hintStyle.Render("Esc to finish editing"))
+	}
+
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	explanation := ""
+	if m.explanation != nil && m.explanation.Summary != "" {
+		explanation = renderExplanation(m.explanation, m.width-6, s.edited) + "\n"
+		b.WriteString(explanation)
+	}
+
+	// Border, title, blank lines, explanation, warnings and hint take the
+	// remaining rows
+	visible := max(1, maxHeight-6-len(s.warnings)-lipgloss.Height(explanation)+1)
+	if s.path != nil {
+		visible = max(1, visible-2)
+	}
+	if s.status != "" {
+		visible = max(1, visible-1)
+	}
+	scroll := min(s.scroll, max(0, len(lines)-visible))
+	numberStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	for i := scroll; i < min(len(lines), scroll+visible); i++ {
+		b.WriteString(numberStyle.Render(fmt.Sprintf("%3d ", i+1)) + highlightShell(lines[i]) + "\n")
+	}
+
+	for _, warning := range s.warnings {
+		b.WriteString(warnStyle.Render("⚠ "+warning) + "\n")
+	}
+	b.WriteString("\n")
+
+	if s.path != nil {
+		b.WriteString(s.path.View() + "\n")
+	}
+	if s.status != "" {
+		b.WriteString(warnStyle.Render(s.status) + "\n")
+	}
+	if m.confirmingRisk {
+		b.WriteString(warnStyle.Render(m.risk.ConfirmationPrompt()) + m.riskTyped + "█\n\n")
+		b.WriteString(hintStyle.Render("Esc to go back"))
+	} else if s.path != nil {
+		b.WriteString(hintStyle.Render("Enter to save (made executable), Esc to go back"))
+	} else if AssessRisk(s.content).Level == RiskBlocked {
+		b.WriteString(hintStyle.Render("↑/↓ scroll, e edit, c copy, Ctrl+R regenerate, Esc discard (policy blocks saving this script)"))
+	} else if m.config.BlockElevated && DetectElevation(s.content).Elevated() {
+		b.WriteString(hintStyle.Render("↑/↓ scroll, e edit, c copy, Ctrl+R regenerate, Esc discard (block_elevated refuses saving this script)"))
+	} else if m.config.SuggestOnlyMode() {
+		b.WriteString(hintStyle.Render("↑/↓ scroll, e edit, s save to file, c copy, Ctrl+R regenerate, Esc discard"))
+	} else if m.chainSteps(s.content) != nil {
+		b.WriteString(hintStyle.Render("↑/↓ scroll, Enter run, e edit, s save to file, c copy, r run line by line, Ctrl+R regenerate, Esc discard"))
+	} else {
+		b.WriteString(hintStyle.Render("↑/↓ scroll, Enter run, e edit, s save to file, c copy, Ctrl+R regenerate, Esc discard"))
+	}
+	return boxStyle.Render(b.String())
+}
+
+// shellKeywords are highlighted in scripts
+var shellKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"for": true, "in": true, "do": true, "done": true, "while": true,
+	"until": true, "case": true, "esac": true, "function": true,
+	"return": true, "local": true, "export": true, "set": true,
+	"foreach": true, "param": true, "end": true,
+}
+
+var shellTokenRe = regexp.MustCompile(`#.*$|'[^']*'?|"(?:[^"\\]|\\.)*"?|\$\{[^}]*\}?|\$[A-Za-z_][A-Za-z0-9_]*|\$[0-9@#?*!$-]|[A-Za-z_][A-Za-z0-9_-]*|\s+|.`)
+
+// highlightShell colours one line of a shell script: comments, strings,
+// variables and keywords. It is a lexer, not a parser, which is enough to
+// make scripts readable at a glance.
+func highlightShell(line string) string {
+	commentStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	stringStyle := lipgloss.NewStyle().Foreground(theme.Accent)
+	varStyle := lipgloss.NewStyle().Foreground(theme.Warning)
+	keywordStyle := lipgloss.NewStyle().Foreground(theme.Info).Bold(true)
+
+	var b strings.Builder
+	atWordStart := true
+	for _, token := range shellTokenRe.FindAllString(line, -1) {
+		switch {
+		case strings.HasPrefix(token, "#") && atWordStart:
+			b.WriteString(commentStyle.Render(token))
+		case strings.HasPrefix(token, "'") || strings.HasPrefix(token, `"`):
+			b.WriteString(stringStyle.Render(token))
+		case strings.HasPrefix(token, "$") && len(token) > 1:
+			b.WriteString(varStyle.Render(token))
+		case shellKeywords[token]:
+			b.WriteString(keywordStyle.Render(token))
+		default:
+			b.WriteString(token)
+		}
+		atWordStart = strings.TrimSpace(token) == "" || strings.ContainsAny(token, ";|&(")
+	}
+	return b.String()
+}
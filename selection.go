@@ -0,0 +1,391 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// answerMsg carries the model's reply to a question about selected text
+type answerMsg string
+
+// Ways copy mode marks text: not at all, character-wise from where v was
+// pressed, or whole lines from where V was
+const (
+	markNone = iota
+	markChars
+	markLines
+)
+
+// selection is copy mode over the plain-text scrollback: a cursor moved
+// over the lines, and text marked from an anchor to it
+type selection struct {
+	lines []string
+	// cursor is the line the cursor is on, and col its column in runes,
+	// kept past the end of shorter lines it moves through
+	cursor int
+	col    int
+	// mark is how text is marked, from anchor and anchorCol to the cursor
+	mark      int
+	anchor    int
+	anchorCol int
+	// notice reports the last copy to the clipboard, or why it failed
+	notice string
+}
+
+// newSelection starts copy mode on the last line of the scrollback
+func newSelection(output []byte) *selection {
+	lines := lastLines(plainText(output), maxScrollbackLines)
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	return &selection{lines: lines, cursor: len(lines) - 1}
+}
+
+// maxScrollbackLines bounds how far back copy mode can reach
+const maxScrollbackLines = 5000
+
+// bounds returns the first and last selected line indexes
+func (s *selection) bounds() (int, int) {
+	if s.mark == markNone {
+		return s.cursor, s.cursor
+	}
+	if s.anchor < s.cursor {
+		return s.anchor, s.cursor
+	}
+	return s.cursor, s.anchor
+}
+
+// column returns the cursor's column on its line
+func (s *selection) column() int {
+	return columnOn(s.lines[s.cursor], s.col)
+}
+
+// columnOn clamps col to the runes of line
+func columnOn(line string, col int) int {
+	return max(0, min(col, utf8.RuneCountInString(line)-1))
+}
+
+// charBounds returns the start and end of a character-wise selection,
+// in order, as line and column
+func (s *selection) charBounds() (int, int, int, int) {
+	anchorCol := columnOn(s.lines[s.anchor], s.anchorCol)
+	if s.anchor < s.cursor || s.anchor == s.cursor && anchorCol <= s.column() {
+		return s.anchor, anchorCol, s.cursor, s.column()
+	}
+	return s.cursor, s.column(), s.anchor, anchorCol
+}
+
+// text returns the marked text, or the cursor's line when nothing is marked
+func (s *selection) text() string {
+	start, end := s.bounds()
+	if s.mark != markChars {
+		return strings.Join(s.lines[start:end+1], "\n")
+	}
+	startLine, startCol, endLine, endCol := s.charBounds()
+	var parts []string
+	for i := startLine; i <= endLine; i++ {
+		runes := []rune(s.lines[i])
+		from, to := 0, len(runes)
+		if i == startLine {
+			from = min(startCol, len(runes))
+		}
+		if i == endLine {
+			to = min(endCol+1, len(runes))
+		}
+		parts = append(parts, string(runes[from:to]))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// move shifts the cursor by delta lines, clamped to the scrollback
+func (s *selection) move(delta int) {
+	s.cursor = max(0, min(len(s.lines)-1, s.cursor+delta))
+}
+
+// moveCol shifts the cursor by delta columns within its line
+func (s *selection) moveCol(delta int) {
+	s.col = columnOn(s.lines[s.cursor], s.column()+delta)
+}
+
+// moveWord moves the cursor to the start of the next word, or with back
+// the start of the word before, going on to the neighbouring line at
+// either end of this one
+func (s *selection) moveWord(back bool) {
+	runes := []rune(s.lines[s.cursor])
+	col := s.column()
+	isSpace := func(i int) bool { return i < 0 || i >= len(runes) || unicode.IsSpace(runes[i]) }
+	if back {
+		if col == 0 || strings.TrimSpace(string(runes[:col])) == "" {
+			if s.cursor > 0 {
+				s.move(-1)
+				s.col = utf8.RuneCountInString(s.lines[s.cursor])
+			}
+			return
+		}
+		col--
+		for col > 0 && isSpace(col) {
+			col--
+		}
+		for col > 0 && !isSpace(col-1) {
+			col--
+		}
+		s.col = col
+		return
+	}
+	for col < len(runes) && !isSpace(col) {
+		col++
+	}
+	for col < len(runes) && isSpace(col) {
+		col++
+	}
+	if col >= len(runes) {
+		if s.cursor < len(s.lines)-1 {
+			s.move(1)
+			s.col = 0
+		}
+		return
+	}
+	s.col = col
+}
+
+// toggleMark starts marking text the given way from the cursor, switches
+// an existing mark to it, or clears a mark made that way
+func (s *selection) toggleMark(mark int) {
+	switch s.mark {
+	case mark:
+		s.mark = markNone
+	case markNone:
+		s.mark, s.anchor, s.anchorCol = mark, s.cursor, s.column()
+	default:
+		s.mark = mark
+	}
+}
+
+// yank copies the marked text to the clipboard, reporting how it went in
+// the status line, and reports whether it was copied
+func (m Model) yank() bool {
+	s := m.selection
+	text := s.text()
+	if err := copyToClipboard(m.caps, text); err != nil {
+		s.notice = "⚠ " + err.Error()
+		return false
+	}
+	countFeature("copy mode")
+	s.notice = fmt.Sprintf("Copied %d line(s)", strings.Count(text, "\n")+1)
+	s.mark = markNone
+	return true
+}
+
+// updateSelection handles keys while copy mode is active
+func (m Model) updateSelection(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	page := max(1, m.height-4)
+	m.selection.notice = ""
+
+	switch msg.String() {
+	case "up", "k":
+		m.selection.move(-1)
+	case "down", "j":
+		m.selection.move(1)
+	case "left", "h":
+		m.selection.moveCol(-1)
+	case "right", "l":
+		m.selection.moveCol(1)
+	case "w":
+		m.selection.moveWord(false)
+	case "b":
+		m.selection.moveWord(true)
+	case "0", "^":
+		m.selection.col = 0
+	case "$":
+		m.selection.col = utf8.RuneCountInString(m.selection.lines[m.selection.cursor])
+	case "pgup", "ctrl+b":
+		m.selection.move(-page)
+	case "pgdown", "ctrl+f":
+		m.selection.move(page)
+	case "g", "home":
+		m.selection.move(-len(m.selection.lines))
+	case "G", "end":
+		m.selection.move(len(m.selection.lines))
+	case "v", " ":
+		m.selection.toggleMark(markChars)
+	case "V":
+		m.selection.toggleMark(markLines)
+	case "y":
+		m.yank()
+	case "enter":
+		if m.yank() {
+			m.selection = nil
+		}
+	case "a", "?":
+		// Ask AI about the selection
+		m.askContext = m.selection.text()
+		m.selection = nil
+		m.showPrompt = true
+		m.input.Placeholder = "Ask a question about the selected text..."
+		m.input.Focus()
+	case "esc", "q", "ctrl+c":
+		m.selection = nil
+	}
+	return m, nil
+}
+
+// queryAsk sends a question about the selected text, or about the attached
+// file or image when nothing is selected, to the model
+func (m Model) queryAsk(question string) tea.Cmd {
+	config := m.config
+	selected := m.askContext
+	cwd := m.shellCwd()
+	notes := m.notesContext()
+	remote := m.remoteContext()
+	conversation := m.conversationContext()
+	attachment := m.attachmentContext()
+	return func() tea.Msg {
+		ctx := GatherPromptContext(config, cwd)
+		ctx.Notes = notes
+		ctx.Attachment = attachment
+		ctx.Conversation = conversation
+		ctx.SetRemote(remote)
+		answer, err := AskAboutText(config, question, selected, ctx)
+		if err != nil {
+			return errMsg(err)
+		}
+		return answerMsg(answer)
+	}
+}
+
+// updateAnswer handles keys while an answer overlay is shown
+func (m Model) updateAnswer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.answerScroll = max(0, m.answerScroll-1)
+	case "down", "j":
+		m.answerScroll++
+	case "esc", "q", "enter", "ctrl+k":
+		m.answer = ""
+		m.answerScroll = 0
+	}
+	return m, nil
+}
+
+// renderSelection draws the scrollback with the cursor and marked text
+// highlighted, keeping the cursor in view
+func (m Model) renderSelection(height int) string {
+	s := m.selection
+	height = max(1, height)
+
+	top := 0
+	if s.cursor >= height {
+		top = s.cursor - height + 1
+	}
+	bottom := min(len(s.lines), top+height)
+
+	var rows []string
+	for i := top; i < bottom; i++ {
+		rows = append(rows, s.renderLine(i))
+	}
+
+	return strings.Join(rows, "\n")
+}
+
+// renderLine draws line i, marked runes reversed and the cursor on its
+// column. A space after the line stands for its end, which a mark across
+// lines covers.
+func (s *selection) renderLine(i int) string {
+	selectedStyle := lipgloss.NewStyle().Reverse(true)
+	cursorStyle := lipgloss.NewStyle().Background(theme.Accent).Foreground(theme.BadgeText)
+
+	runes := append([]rune(s.lines[i]), ' ')
+	from, to := 0, -1
+	switch s.mark {
+	case markLines:
+		if start, end := s.bounds(); i >= start && i <= end {
+			to = len(runes) - 1
+		}
+	case markChars:
+		startLine, startCol, endLine, endCol := s.charBounds()
+		if i >= startLine && i <= endLine {
+			to = len(runes) - 1
+			if i == startLine {
+				from = startCol
+			}
+			if i == endLine {
+				to = endCol
+			}
+		}
+	}
+	cursor := -1
+	if i == s.cursor {
+		cursor = s.column()
+	}
+
+	// Runes are drawn in runs sharing a style
+	style := func(j int) int {
+		switch {
+		case j == cursor:
+			return 2
+		case j >= from && j <= to:
+			return 1
+		}
+		return 0
+	}
+	styles := []func(string) string{
+		func(text string) string { return text },
+		func(text string) string { return selectedStyle.Render(text) },
+		func(text string) string { return cursorStyle.Render(text) },
+	}
+	var b strings.Builder
+	run := 0
+	for j := 1; j <= len(runes); j++ {
+		if j == len(runes) || style(j) != style(run) {
+			b.WriteString(styles[style(run)](string(runes[run:j])))
+			run = j
+		}
+	}
+	return b.String()
+}
+
+// renderSelectionStatus is the hint line shown in copy mode
+func (m Model) renderSelectionStatus() string {
+	start, end := m.selection.bounds()
+	status := fmt.Sprintf(" COPY  %d line(s)  h/j/k/l move  v/V select  y copy  a ask AI  Esc exit", end-start+1)
+	if m.selection.notice != "" {
+		status = " COPY  " + m.selection.notice
+	}
+	return lipgloss.NewStyle().
+		Foreground(theme.BadgeText).
+		Background(theme.Accent).
+		Width(m.width).
+		MaxHeight(1).
+		Render(status)
+}
+
+// renderAnswer draws the answer overlay, scrolled by answerScroll
+func (m Model) renderAnswer(maxHeight int) string {
+	boxStyle := lipgloss.NewStyle().
+		Border(roundedBorder()).
+		BorderForeground(theme.Info).
+		Padding(0, 1).
+		Width(m.width - 2)
+
+	titleStyle := lipgloss.NewStyle().Foreground(theme.Info).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+
+	wrapped := wrapBidi(m.answer, m.width-6, m.reorderBidi())
+	lines := strings.Split(wrapped, "\n")
+
+	// Border, title, blank line and hint take five rows
+	visible := max(1, maxHeight-5)
+	scroll := min(m.answerScroll, max(0, len(lines)-visible))
+	lines = lines[scroll:min(len(lines), scroll+visible)]
+
+	return boxStyle.Render(
+		titleStyle.Render(valueOrDefault(m.answerTitle, "AI answer")) + "\n" +
+			strings.Join(lines, "\n") + "\n\n" +
+			hintStyle.Render("↑/↓ scroll, Esc to close"),
+	)
+}
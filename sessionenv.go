@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// envAssignRes match a command setting an environment variable for the
+// rest of the session, in each dialect, capturing its name and value
+var envAssignRes = map[string]*regexp.Regexp{
+	DialectBash:       regexp.MustCompile(`^export\s+([A-Za-z_]\w*)=(.*)$`),
+	DialectFish:       regexp.MustCompile(`^set\s+(?:-gx|-xg|-x)\s+([A-Za-z_]\w*)\s+(.*)$`),
+	DialectPowerShell: regexp.MustCompile(`(?i)^\$env:([A-Za-z_]\w*)\s*=\s*(.*)$`),
+	DialectCmd:        regexp.MustCompile(`(?i)^set\s+"?([A-Za-z_]\w*)=(.*?)"?$`),
+}
+
+// sessionVar is an environment variable a generated command set in the
+// shell for the session
+type sessionVar struct {
+	Name  string
+	Value string
+	Set   time.Time
+	Query string
+}
+
+// EnvAssignments returns the variables command sets when that is all it
+// does, like export TOKEN=$(openssl rand -hex 16); nil otherwise
+func EnvAssignments(command, shell string) []sessionVar {
+	dialect, err := ParseDialect(shell)
+	if err != nil {
+		return nil
+	}
+	segments := []string{strings.TrimSpace(command)}
+	if steps := SplitSteps(command, shell); steps != nil {
+		segments = segments[:0]
+		for _, step := range steps {
+			segments = append(segments, step.Command)
+		}
+	}
+	var vars []sessionVar
+	for _, segment := range segments {
+		match := envAssignRes[dialect].FindStringSubmatch(segment)
+		if match == nil {
+			return nil
+		}
+		vars = append(vars, sessionVar{Name: match[1], Value: match[2]})
+	}
+	return vars
+}
+
+// maskValue shows enough of a value to recognize it; an expression that
+// makes the value, like $(openssl rand -hex 16), is shown whole
+func maskValue(value string) string {
+	if strings.ContainsAny(value, "$`(") {
+		return value
+	}
+	runes := []rune(strings.Trim(value, `'"`))
+	if len(runes) <= 4 {
+		return "••••"
+	}
+	return string(runes[:4]) + "••••"
+}
+
+// maskCommand is command with the values of vars masked, for the history
+func maskCommand(command string, vars []sessionVar) string {
+	for _, v := range vars {
+		if v.Value != "" {
+			command = strings.ReplaceAll(command, v.Value, maskValue(v.Value))
+		}
+	}
+	return command
+}
+
+// unsetCommand is how shell removes variable name
+func unsetCommand(name, shell string) string {
+	dialect, _ := ParseDialect(shell)
+	switch dialect {
+	case DialectPowerShell:
+		return "Remove-Item Env:" + name
+	case DialectCmd:
+		return "set " + name + "="
+	case DialectFish:
+		return "set -e " + name
+	}
+	return "unset " + name
+}
+
+// setSessionEnv sets the variables of a generated command in the shell
+// without the values showing on screen or in the shell's history: the
+// command goes in a temporary script that a line starting with a space,
+// which shells leave out of history, sources and then deletes. A remote
+// shell, where that file isn't, gets the command itself after the space.
+func (m *Model) setSessionEnv(query, command string, vars []sessionVar) {
+	countFeature("session env")
+	line := command
+	if len(m.remotes) == 0 {
+		if path, err := m.writeScript(command); err == nil {
+			line = scriptSourcerOnce(path, m.config.Shell)
+		}
+	}
+	m.execute(query, " "+line, AuditRun)
+	for _, v := range vars {
+		v.Set, v.Query = time.Now(), query
+		m.sessionEnv = append(m.removeSessionVar(v.Name), v)
+	}
+}
+
+// removeSessionVar returns the session's variables without name
+func (m *Model) removeSessionVar(name string) []sessionVar {
+	var kept []sessionVar
+	for _, v := range m.sessionEnv {
+		if v.Name != name {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// revokeSessionVar removes variable i from the shell, once it is at its
+// prompt, and from the list
+func (m *Model) revokeSessionVar(i int) {
+	if app := m.fullScreenApp(); app != "" || m.shellBusy() {
+		m.envErr = "The shell is busy; revoke the variable once it is back at its prompt"
+		return
+	}
+	countFeature("revoke session env")
+	m.sendCommand(" " + unsetCommand(m.sessionEnv[i].Name, m.config.Shell))
+	m.sessionEnv = m.removeSessionVar(m.sessionEnv[i].Name)
+	m.envCursor = max(0, min(m.envCursor, len(m.sessionEnv)-1))
+}
+
+// updateSessionEnv handles keys while the session's variables are shown
+func (m Model) updateSessionEnv(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.envErr = ""
+	switch msg.String() {
+	case "up", "k":
+		m.envCursor = max(0, m.envCursor-1)
+	case "down", "j":
+		m.envCursor = min(len(m.sessionEnv)-1, m.envCursor+1)
+	case "d", "delete", "backspace":
+		if len(m.sessionEnv) > 0 {
+			m.revokeSessionVar(m.envCursor)
+		}
+	case "esc", "q", "alt+w":
+		m.envOpen = false
+	}
+	return m, nil
+}
+
+// renderSessionEnv lists the variables generated commands set this
+// session, values masked
+func (m Model) renderSessionEnv() string {
+	boxStyle := lipgloss.NewStyle().
+		Border(roundedBorder()).
+		BorderForeground(theme.Info).
+		Padding(0, 1).
+		Width(m.width - 2)
+
+	titleStyle := lipgloss.NewStyle().Foreground(theme.Info).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	selectedStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Session environment, set in the shell by generated commands") + "\n\n")
+	if len(m.sessionEnv) == 0 {
+		b.WriteString(hintStyle.Render("None yet. A generated command that only sets variables, like export TOKEN=…, sets them here, kept off the screen and out of the shell's history.") + "\n")
+	}
+	for i, v := range m.sessionEnv {
+		line := fmt.Sprintf("%s=%s", v.Name, maskValue(v.Value))
+		detail := fmt.Sprintf("  set %s ago", formatDuration(time.Since(v.Set).Truncate(time.Second)))
+		if v.Query != "" {
+			detail += " for " + v.Query
+		}
+		if i == m.envCursor {
+			b.WriteString(selectedStyle.Render("> "+line) + hintStyle.Render(detail))
+		} else {
+			b.WriteString("  " + line + hintStyle.Render(detail))
+		}
+		b.WriteString("\n")
+	}
+	if m.envErr != "" {
+		b.WriteString("\n" + lipgloss.NewStyle().Foreground(theme.Warning).Render("⚠ "+m.envErr) + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("↑/↓ to move, d to revoke (unset in the shell), Esc to close"))
+	return boxStyle.Render(b.String())
+}
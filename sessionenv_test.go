@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct{ value, want string }{
+		{"abcd1234", "abcd••••"},
+		{"'abcd1234'", "abcd••••"},
+		{"abcd", "••••"},
+		{"", "••••"},
+		// Counted in characters, not bytes
+		{"ééé", "••••"},
+		{"пароль123", "паро••••"},
+		{"秘密のトークン", "秘密のト••••"},
+		// An expression that makes the value is shown whole
+		{"$(openssl rand -hex 16)", "$(openssl rand -hex 16)"},
+	}
+	for _, test := range tests {
+		got := maskValue(test.value)
+		if got != test.want || !utf8.ValidString(got) {
+			t.Errorf("maskValue(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestMaskCommand(t *testing.T) {
+	vars := []sessionVar{{Name: "TOKEN", Value: "s3cr3t-value"}, {Name: "PIN", Value: "ключ"}}
+	got := maskCommand("export TOKEN=s3cr3t-value PIN=ключ", vars)
+	if want := "export TOKEN=s3cr•••• PIN=••••"; got != want {
+		t.Errorf("maskCommand = %q, want %q", got, want)
+	}
+}
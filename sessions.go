@@ -0,0 +1,461 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// validSessionName keeps session names usable as directory names
+var validSessionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// errNoRecording is returned for sessions in a build without recording
+var errNoRecording = errors.New("session recording is left out of this build (built with -tags minimal)")
+
+// SessionMeta describes a named session archive. A name can be reused, so
+// one archive may cover several runs of the TUI.
+type SessionMeta struct {
+	Name     string    `json:"name"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Sessions []string  `json:"sessions"`
+}
+
+// GetSessionsDir returns the directory holding named session archives
+func GetSessionsDir() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "sessions")
+}
+
+// ValidateSessionName checks that name can be used for an archive
+func ValidateSessionName(name string) error {
+	if !validSessionName.MatchString(name) {
+		return fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-' (up to 64 characters)", name)
+	}
+	return nil
+}
+
+// loadSessionMeta reads the metadata of a named session
+func loadSessionMeta(name string) (SessionMeta, error) {
+	var meta SessionMeta
+	data, err := os.ReadFile(filepath.Join(GetSessionsDir(), name, "session.json"))
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// saveSessionMeta writes the metadata of a named session
+func saveSessionMeta(meta SessionMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(GetSessionsDir(), meta.Name, "session.json"), data, 0600)
+}
+
+// transcriptWriter appends the shell output of a named session to its
+// transcript from a goroutine of its own, so a slow disk or another
+// instance holding the file lock never holds up the UI
+type transcriptWriter struct {
+	file *os.File
+	wake chan struct{}
+	done chan struct{}
+
+	mu      sync.Mutex
+	pending []byte
+	closed  bool
+}
+
+func newTranscriptWriter(file *os.File) *transcriptWriter {
+	w := &transcriptWriter{file: file, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+// Write queues data to be appended; it never waits on the file
+func (w *transcriptWriter) Write(data []byte) {
+	w.mu.Lock()
+	w.pending = append(w.pending, data...)
+	w.mu.Unlock()
+	w.signal()
+}
+
+// Close appends what is still queued, then closes the file
+func (w *transcriptWriter) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.signal()
+	<-w.done
+}
+
+func (w *transcriptWriter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+		// Already woken; the writer takes everything queued
+	}
+}
+
+// run appends what is queued each time it is woken, in one locked write
+func (w *transcriptWriter) run() {
+	defer close(w.done)
+	for range w.wake {
+		w.mu.Lock()
+		data, closed := w.pending, w.closed
+		w.pending = nil
+		w.mu.Unlock()
+
+		if len(data) > 0 {
+			// The transcript is a convenience; failing to write it is not
+			// fatal
+			appendLocked(w.file, data)
+		}
+		if closed {
+			w.file.Close()
+			return
+		}
+	}
+}
+
+// nameSession starts archiving the running session under name. The
+// transcript so far is written out and the rest is appended as it arrives.
+// Naming an already named session renames its archive.
+func (m *Model) nameSession(name string) error {
+	if minimalBuild {
+		return errNoRecording
+	}
+	if err := ValidateSessionName(name); err != nil {
+		return err
+	}
+	if m.sessionName != "" {
+		return m.renameSession(name)
+	}
+	dir := filepath.Join(GetSessionsDir(), name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	meta, err := loadSessionMeta(name)
+	if err != nil {
+		meta = SessionMeta{Name: name, Created: time.Now()}
+	}
+	if len(meta.Sessions) == 0 || meta.Sessions[len(meta.Sessions)-1] != m.session {
+		meta.Sessions = append(meta.Sessions, m.session)
+	}
+	meta.Updated = time.Now()
+	if err := saveSessionMeta(meta); err != nil {
+		return err
+	}
+
+	if m.transcript != nil {
+		m.transcript.Close()
+	}
+	transcript, err := os.OpenFile(filepath.Join(dir, "transcript.log"), appendFlags, 0600)
+	if err != nil {
+		return err
+	}
+	m.transcript = newTranscriptWriter(transcript)
+	m.transcript.Write(m.output)
+	m.sessionName = name
+	return nil
+}
+
+// renameSession moves the archive of the running session to a new name
+func (m *Model) renameSession(name string) error {
+	if name == m.sessionName {
+		return nil
+	}
+	newDir := filepath.Join(GetSessionsDir(), name)
+	if _, err := os.Stat(newDir); err == nil {
+		return fmt.Errorf("a session named %s already exists", name)
+	}
+
+	if m.transcript != nil {
+		m.transcript.Close()
+		m.transcript = nil
+	}
+	if err := os.Rename(filepath.Join(GetSessionsDir(), m.sessionName), newDir); err != nil {
+		return err
+	}
+
+	meta, err := loadSessionMeta(name)
+	if err != nil {
+		return err
+	}
+	meta.Name = name
+	meta.Updated = time.Now()
+	if err := saveSessionMeta(meta); err != nil {
+		return err
+	}
+
+	transcript, err := os.OpenFile(filepath.Join(newDir, "transcript.log"), appendFlags, 0600)
+	if err != nil {
+		return err
+	}
+	m.transcript = newTranscriptWriter(transcript)
+	m.sessionName = name
+	return nil
+}
+
+// toggleNaming switches the open prompt to asking for a session name
+func (m *Model) toggleNaming() {
+	m.naming = !m.naming
+	m.translating = false
+	m.regenerating = false
+	if m.naming {
+		m.input.Placeholder = "e.g. prod-incident-jan-12"
+	} else {
+		m.input.Placeholder = promptPlaceholder
+	}
+}
+
+// closeSession finishes the archive of a named session, writing its runbook
+func (m *Model) closeSession() {
+	if minimalBuild || m.sessionName == "" {
+		return
+	}
+	if m.transcript != nil {
+		m.transcript.Close()
+		m.transcript = nil
+	}
+
+	meta, err := loadSessionMeta(m.sessionName)
+	if err != nil {
+		return
+	}
+	meta.Updated = time.Now()
+	saveSessionMeta(meta)
+
+	entries, err := LoadHistory()
+	if err != nil {
+		return
+	}
+	runbook := renderRunbook(meta, sessionEntries(entries, meta))
+	os.WriteFile(filepath.Join(GetSessionsDir(), meta.Name, "runbook.md"), []byte(runbook), 0600)
+}
+
+// sessionEntries filters history down to the runs covered by meta
+func sessionEntries(entries []HistoryEntry, meta SessionMeta) []HistoryEntry {
+	ids := make(map[string]bool)
+	for _, id := range meta.Sessions {
+		ids[id] = true
+	}
+	var result []HistoryEntry
+	for _, entry := range entries {
+		if ids[entry.Session] {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// renderRunbook turns a session's history into a markdown runbook: the
+// commands that were run, in order, with the AI requests and answers that
+// led to them
+func renderRunbook(meta SessionMeta, entries []HistoryEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Runbook: %s\n\n", meta.Name)
+	fmt.Fprintf(&b, "Recorded %s to %s.\n", meta.Created.Format(time.DateTime), meta.Updated.Format(time.DateTime))
+
+	run := ""
+	for _, entry := range entries {
+		if entry.Session != run {
+			run = entry.Session
+			fmt.Fprintf(&b, "\n## %s\n\n", entry.Time.Format(time.DateTime))
+		}
+
+		switch {
+		case entry.Kind == HistoryShell:
+			fmt.Fprintf(&b, "- `%s`", entry.Command)
+		case entry.Kind == HistoryAI && entry.Outcome != OutcomeRejected:
+			if isScript(entry.Command) {
+				fmt.Fprintf(&b, "- %s (AI, script):\n\n```\n%s```\n", entry.Query, entry.Command)
+				continue
+			}
+			fmt.Fprintf(&b, "- `%s` (AI: %s)", entry.Command, entry.Query)
+		case entry.Kind == HistoryAsk:
+			fmt.Fprintf(&b, "- Asked: %s\n\n  > %s\n", entry.Query, strings.ReplaceAll(entry.Answer, "\n", "\n  > "))
+			continue
+		default:
+			continue
+		}
+		if entry.Cwd != "" {
+			fmt.Fprintf(&b, " in `%s`", entry.Cwd)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// listSessions returns every named session, most recently updated first
+func listSessions() ([]SessionMeta, error) {
+	dirs, err := os.ReadDir(GetSessionsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SessionMeta
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		if meta, err := loadSessionMeta(dir.Name()); err == nil {
+			sessions = append(sessions, meta)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Updated.After(sessions[j].Updated)
+	})
+	return sessions, nil
+}
+
+// readTranscript returns the plain text of a session's transcript
+func readTranscript(name string) string {
+	data, err := os.ReadFile(filepath.Join(GetSessionsDir(), name, "transcript.log"))
+	if err != nil {
+		return ""
+	}
+	return plainText(data)
+}
+
+// handleSessionsCommand handles the sessions subcommands
+func handleSessionsCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui sessions list")
+		fmt.Println("       ai-terminal-tui sessions show NAME [--transcript]")
+		fmt.Println("       ai-terminal-tui sessions search TEXT")
+		os.Exit(1)
+	}
+	if minimalBuild {
+		fmt.Printf("Error: %v\n", errNoRecording)
+		os.Exit(1)
+		return
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		sessions, err := listSessions()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No named sessions yet. Start one with: ai-terminal-tui --session NAME")
+			return
+		}
+		for _, meta := range sessions {
+			fmt.Printf("%-32s %s  (%d run(s))\n", meta.Name, meta.Updated.Format(time.DateTime), len(meta.Sessions))
+		}
+
+	case "show":
+		if len(args) < 2 {
+			usage()
+		}
+		meta, err := loadSessionMeta(args[1])
+		if err != nil {
+			fmt.Printf("Error: no session named %q\n", args[1])
+			os.Exit(1)
+		}
+		entries, err := LoadHistory()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(renderRunbook(meta, sessionEntries(entries, meta)))
+		if len(args) > 2 && args[2] == "--transcript" {
+			fmt.Printf("\n## Transcript\n\n%s\n", readTranscript(meta.Name))
+		}
+
+	case "search":
+		if len(args) < 2 {
+			usage()
+		}
+		searchSessions(strings.Join(args[1:], " "))
+
+	default:
+		usage()
+	}
+}
+
+// searchSessions prints every place text occurs in named sessions: their
+// names, AI requests, commands, answers and transcripts
+func searchSessions(text string) {
+	sessions, err := listSessions()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	entries, err := LoadHistory()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	needle := strings.ToLower(text)
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), needle) }
+	found := 0
+
+	for _, meta := range sessions {
+		var matches []string
+		if contains(meta.Name) {
+			matches = append(matches, "name matches")
+		}
+		for _, entry := range sessionEntries(entries, meta) {
+			switch {
+			case contains(entry.Query):
+				matches = append(matches, "asked: "+entry.Query)
+			case contains(entry.Command):
+				matches = append(matches, "ran: "+firstLine(entry.Command))
+			case contains(entry.Answer):
+				matches = append(matches, "answer to: "+entry.Query)
+			}
+		}
+		for _, line := range strings.Split(readTranscript(meta.Name), "\n") {
+			if contains(line) {
+				matches = append(matches, "output: "+strings.TrimSpace(line))
+			}
+		}
+
+		if len(matches) == 0 {
+			continue
+		}
+		found++
+		fmt.Printf("%s (%s)\n", meta.Name, meta.Updated.Format(time.DateOnly))
+		for i, match := range matches {
+			if i == 5 {
+				fmt.Printf("  ... and %d more\n", len(matches)-i)
+				break
+			}
+			fmt.Printf("  %s\n", match)
+		}
+	}
+
+	if found == 0 {
+		fmt.Printf("No sessions mention %q\n", text)
+	}
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
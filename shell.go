@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ValidateShell checks that shell resolves to an executable file, either as
+// a path or as a name looked up in PATH
+func ValidateShell(shell string) error {
+	if strings.TrimSpace(shell) == "" {
+		return fmt.Errorf("no shell configured")
+	}
+
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		return fmt.Errorf("shell %q was not found or is not executable", shell)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("shell %q: %w", shell, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("shell %q is a directory", shell)
+	}
+
+	return nil
+}
+
+// uniqueShells drops candidates that do not exist or that resolve to the same
+// binary as an earlier candidate, preserving order
+func uniqueShells(candidates []string) []string {
+	seen := make(map[string]bool)
+	var shells []string
+
+	for _, candidate := range candidates {
+		if ValidateShell(candidate) != nil {
+			continue
+		}
+		path, err := exec.LookPath(candidate)
+		if err != nil {
+			continue
+		}
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			real = path
+		}
+		if seen[real] {
+			continue
+		}
+		seen[real] = true
+		shells = append(shells, path)
+	}
+
+	return shells
+}
+
+// ensureValidShell returns shell if it is usable. Otherwise it explains the
+// problem, lists the detected shells and, on an interactive terminal, lets
+// the user pick one and saves it to the config; non-interactive runs exit.
+func ensureValidShell(shell string) string {
+	err := ValidateShell(shell)
+	if err == nil {
+		return shell
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+
+	shells := DetectShells()
+	if len(shells) == 0 {
+		fmt.Fprintln(os.Stderr, "No other shells were detected on this system.")
+		fmt.Fprintln(os.Stderr, "Set one with: ai-terminal-tui config --set-key shell /path/to/shell")
+		os.Exit(1)
+	}
+
+	fmt.Fprintln(os.Stderr, "Detected shells:")
+	for i, candidate := range shells {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, candidate)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprintf(os.Stderr, "Set one with: ai-terminal-tui config --set-key shell %s\n", shells[0])
+		os.Exit(1)
+	}
+
+	for {
+		fmt.Printf("Select a shell to use and save to the config [1-%d], or press Enter to quit: ", len(shells))
+
+		var choice string
+		fmt.Scanln(&choice)
+		if choice == "" {
+			os.Exit(1)
+		}
+
+		n, err := strconv.Atoi(choice)
+		if err != nil || n < 1 || n > len(shells) {
+			continue
+		}
+
+		selected := shells[n-1]
+		if err := UpdateConfigKey("shell", selected); err != nil {
+			fmt.Printf("Warning: could not save shell to config: %v\n", err)
+		} else {
+			fmt.Printf("✓ Updated shell = %s\n", selected)
+		}
+		return selected
+	}
+}
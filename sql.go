@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultSQLLimit is the LIMIT added to SELECTs unless sql_limit says
+// otherwise
+const defaultSQLLimit = 100
+
+var (
+	// sqlClientRe finds database clients in a command line
+	sqlClientRe = regexp.MustCompile(`(?:^|[\s;&|(])(?:psql|mysql|mariadb|sqlite3|sqlcmd|duckdb|clickhouse-client)\s`)
+	// sqlStatementRe matches a command that is itself a SQL snippet
+	sqlStatementRe = regexp.MustCompile(`(?i)^\s*(?:SELECT|WITH|INSERT|UPDATE|DELETE|DROP|ALTER|TRUNCATE|CREATE|GRANT|REVOKE|MERGE)\b`)
+	// sqlWriteRe matches statements that change data or schema
+	sqlWriteRe = regexp.MustCompile(`(?i)\b(INSERT\s+INTO|UPDATE\s+\S+\s+SET|DELETE\s+FROM|DROP\s+\w+|ALTER\s+\w+|TRUNCATE|CREATE\s+\w+|GRANT|REVOKE|MERGE\s+INTO)\b`)
+)
+
+// sqlContext describes the active connection and query defaults for the
+// SQL domain
+func sqlContext(config Config) string {
+	var b strings.Builder
+	b.WriteString(sqlConnectionContext(config))
+	if config.SQLLimit > 0 {
+		fmt.Fprintf(&b, "Add LIMIT %d to SELECT queries unless the request asks for every row or aggregates.\n", config.SQLLimit)
+	}
+	return b.String()
+}
+
+// SQLWrite returns the statement that makes a command write to a database,
+// or "" when it only reads. Only commands running a database client, or
+// bare SQL in the SQL domain, are checked.
+func SQLWrite(command, domain string) string {
+	if !sqlClientRe.MatchString(command) && !(domain == DomainSQL && sqlStatementRe.MatchString(command)) {
+		return ""
+	}
+	words := strings.Fields(sqlWriteRe.FindString(command))
+	if len(words) == 3 {
+		// UPDATE table SET: leave out the table
+		words = words[:1]
+	}
+	return strings.ToUpper(strings.Join(words, " "))
+}
+
+// sqlWriteWarning explains why a command writing to a database is held
+func sqlWriteWarning(statement string) string {
+	return fmt.Sprintf("This query writes to the database (%s)", statement)
+}
+
+// sqlConnectionNames lists the configured connections, sorted
+func sqlConnectionNames(config Config) []string {
+	var names []string
+	for name := range config.SQLConnections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
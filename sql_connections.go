@@ -0,0 +1,155 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Database engines a connection can name
+const (
+	EnginePostgres = "postgres"
+	EngineMySQL    = "mysql"
+	EngineSQLite   = "sqlite"
+)
+
+// SQLConnection is a database named in sql_connections. The password is
+// never kept: clients read it from ~/.pgpass, ~/.my.cnf or the environment.
+type SQLConnection struct {
+	Name     string
+	Engine   string
+	Host     string
+	Port     string
+	Database string
+	User     string
+}
+
+// ParseSQLConnection parses a connection URL such as
+// postgres://user@host:5432/db, mysql://user@host/db or sqlite:///path.db
+func ParseSQLConnection(name, raw string) (SQLConnection, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return SQLConnection{}, err
+	}
+	c := SQLConnection{Name: name, Host: u.Hostname(), Port: u.Port(), User: u.User.Username()}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		c.Engine = EnginePostgres
+		c.Database = strings.TrimPrefix(u.Path, "/")
+	case "mysql", "mariadb":
+		c.Engine = EngineMySQL
+		c.Database = strings.TrimPrefix(u.Path, "/")
+	case "sqlite", "sqlite3":
+		c.Engine = EngineSQLite
+		c.Database = u.Host + u.Path
+		c.Host = ""
+	default:
+		return SQLConnection{}, fmt.Errorf("unsupported database %q in %s (expected postgres, mysql or sqlite)", u.Scheme, name)
+	}
+	if c.Database == "" {
+		return SQLConnection{}, fmt.Errorf("connection %s names no database", name)
+	}
+	return c, nil
+}
+
+// ParseSQLConnections parses the sql_connections setting: comma-separated
+// NAME=URL pairs. URLs with a password are refused so it never reaches the
+// config file or a prompt.
+func ParseSQLConnections(value string) (map[string]string, error) {
+	connections := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || ValidateSessionName(name) != nil {
+			return nil, fmt.Errorf("expected NAME=URL, got %q", pair)
+		}
+		if u, err := url.Parse(strings.TrimSpace(raw)); err == nil {
+			if _, set := u.User.Password(); set {
+				return nil, fmt.Errorf("leave the password out of %s; psql reads ~/.pgpass and mysql ~/.my.cnf", name)
+			}
+		}
+		if _, err := ParseSQLConnection(name, raw); err != nil {
+			return nil, err
+		}
+		connections[name] = strings.TrimSpace(raw)
+	}
+	return connections, nil
+}
+
+// ActiveSQLConnection returns the connection SQL requests are about:
+// sql_connection, or the only one configured
+func ActiveSQLConnection(config Config) *SQLConnection {
+	name := config.SQLConnection
+	if name == "" && len(config.SQLConnections) == 1 {
+		for only := range config.SQLConnections {
+			name = only
+		}
+	}
+	raw, ok := config.SQLConnections[name]
+	if !ok {
+		return nil
+	}
+	c, err := ParseSQLConnection(name, raw)
+	if err != nil {
+		return nil
+	}
+	return &c
+}
+
+// String renders the connection as a URL, without any password
+func (c SQLConnection) String() string {
+	if c.Engine == EngineSQLite {
+		return "sqlite://" + c.Database
+	}
+	u := url.URL{Scheme: c.Engine, Host: c.Host, Path: "/" + c.Database}
+	if c.Port != "" {
+		u.Host += ":" + c.Port
+	}
+	if c.User != "" {
+		u.User = url.User(c.User)
+	}
+	return u.String()
+}
+
+// ClientCommand returns the client invocation that opens the connection
+func (c SQLConnection) ClientCommand() string {
+	var args []string
+	flag := func(name, value string) {
+		if value != "" {
+			args = append(args, name, value)
+		}
+	}
+	switch c.Engine {
+	case EnginePostgres:
+		args = append(args, "psql")
+		flag("-h", c.Host)
+		flag("-p", c.Port)
+		flag("-U", c.User)
+		flag("-d", c.Database)
+	case EngineMySQL:
+		args = append(args, "mysql")
+		flag("-h", c.Host)
+		flag("-P", c.Port)
+		flag("-u", c.User)
+		args = append(args, c.Database)
+	case EngineSQLite:
+		args = append(args, "sqlite3", c.Database)
+	}
+	return strings.Join(args, " ")
+}
+
+// sqlConnectionContext describes the active connection for the SQL domain
+func sqlConnectionContext(config Config) string {
+	c := ActiveSQLConnection(config)
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("Connection %q (%s):\n%s\n", c.Name, c.String(), c.ClientCommand()) +
+		"Run queries through that client invocation; never put a password on the command line.\n"
+}
@@ -0,0 +1,35 @@
+//go:build minimal
+
+package main
+
+import "strings"
+
+// SQLConnection stands in for a database named in sql_connections, which
+// a minimal build can't connect the SQL domain to
+type SQLConnection struct {
+	Name string
+}
+
+// ParseSQLConnection refuses every connection in a minimal build
+func ParseSQLConnection(name, raw string) (SQLConnection, error) {
+	return SQLConnection{}, leftOut("sql_connections")
+}
+
+// ParseSQLConnections accepts only clearing sql_connections in a minimal
+// build
+func ParseSQLConnections(value string) (map[string]string, error) {
+	if strings.Trim(value, ", ") != "" {
+		return nil, leftOut("sql_connections")
+	}
+	return map[string]string{}, nil
+}
+
+// ActiveSQLConnection finds none in a minimal build
+func ActiveSQLConnection(config Config) *SQLConnection {
+	return nil
+}
+
+// sqlConnectionContext has no connection to describe in a minimal build
+func sqlConnectionContext(config Config) string {
+	return ""
+}
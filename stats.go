@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Assumptions behind the time saved estimate: typing speed, and how long it
+// takes to look up a command you don't remember
+const (
+	typingCharsPerSecond = 4.0
+	lookupSeconds        = 20.0
+	maxTopDirectories    = 5
+)
+
+// SessionStats summarises one or more sessions of history
+type SessionStats struct {
+	Session          string     `json:"session,omitempty"`
+	Start            time.Time  `json:"start"`
+	End              time.Time  `json:"end"`
+	CommandsExecuted int        `json:"commands_executed"`
+	Accepted         int        `json:"suggestions_accepted"`
+	Edited           int        `json:"suggestions_edited"`
+	Rejected         int        `json:"suggestions_rejected"`
+	InlineAccepted   int        `json:"inline_accepted"`
+	TimeSavedSeconds int        `json:"time_saved_seconds"`
+	TokensUsed       int        `json:"tokens_used"`
+	TopDirectories   []DirCount `json:"top_directories"`
+}
+
+// DirCount is how many commands were run in a directory
+type DirCount struct {
+	Dir   string `json:"dir"`
+	Count int    `json:"count"`
+}
+
+// ComputeStats aggregates history entries into session statistics
+func ComputeStats(entries []HistoryEntry) SessionStats {
+	var stats SessionStats
+	var saved float64
+	dirs := make(map[string]int)
+
+	for _, entry := range entries {
+		if stats.Start.IsZero() || entry.Time.Before(stats.Start) {
+			stats.Start = entry.Time
+		}
+		if entry.Time.After(stats.End) {
+			stats.End = entry.Time
+		}
+		stats.TokensUsed += entry.Tokens
+
+		switch entry.Kind {
+		case HistoryShell:
+			stats.CommandsExecuted++
+		case HistoryAI:
+			switch entry.Outcome {
+			case OutcomeAccepted:
+				stats.Accepted++
+				stats.CommandsExecuted++
+				saved += lookupSeconds + typingSeconds(len(entry.Command)-len(entry.Query))
+			case OutcomeEdited:
+				stats.Edited++
+				saved += lookupSeconds / 2
+			case OutcomeRejected:
+				stats.Rejected++
+			}
+		case HistoryInline:
+			stats.InlineAccepted++
+			saved += typingSeconds(len(entry.Command))
+		}
+
+		if entry.Cwd != "" && (entry.Kind == HistoryShell || entry.Outcome == OutcomeAccepted) {
+			dirs[entry.Cwd]++
+		}
+	}
+
+	stats.TimeSavedSeconds = int(saved)
+	for dir, count := range dirs {
+		stats.TopDirectories = append(stats.TopDirectories, DirCount{dir, count})
+	}
+	sort.Slice(stats.TopDirectories, func(i, j int) bool {
+		a, b := stats.TopDirectories[i], stats.TopDirectories[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Dir < b.Dir
+	})
+	if len(stats.TopDirectories) > maxTopDirectories {
+		stats.TopDirectories = stats.TopDirectories[:maxTopDirectories]
+	}
+	return stats
+}
+
+// typingSeconds is how long typing chars characters takes, never negative
+func typingSeconds(chars int) float64 {
+	return max(0, float64(chars)/typingCharsPerSecond)
+}
+
+// acceptanceRate is the share of AI suggestions that were used as-is
+func (s SessionStats) acceptanceRate() int {
+	total := s.Accepted + s.Edited + s.Rejected
+	if total == 0 {
+		return 0
+	}
+	return s.Accepted * 100 / total
+}
+
+// String renders the stats as plain text
+func (s SessionStats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Commands executed:   %d\n", s.CommandsExecuted)
+	fmt.Fprintf(&b, "AI suggestions:      %d accepted, %d edited, %d rejected (%d%% accepted)\n",
+		s.Accepted, s.Edited, s.Rejected, s.acceptanceRate())
+	fmt.Fprintf(&b, "Inline completions:  %d accepted\n", s.InlineAccepted)
+	fmt.Fprintf(&b, "Time saved:          ~%s\n", (time.Duration(s.TimeSavedSeconds) * time.Second).String())
+	fmt.Fprintf(&b, "Tokens used:         %d\n", s.TokensUsed)
+	if len(s.TopDirectories) > 0 {
+		b.WriteString("Top directories:\n")
+		for _, dir := range s.TopDirectories {
+			fmt.Fprintf(&b, "  %4d  %s\n", dir.Count, dir.Dir)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// sessionStats summarises the running session, including tokens spent
+// since the last history entry
+func (m Model) sessionStats() SessionStats {
+	stats := ComputeStats(m.history)
+	stats.Session = m.session
+	stats.Start = m.sessionStart
+	stats.End = time.Now()
+	stats.TokensUsed += int(tokensUsed.Load())
+	return stats
+}
+
+// renderStats draws the session statistics overlay
+func (m Model) renderStats() string {
+	stats := m.sessionStats()
+
+	boxStyle := lipgloss.NewStyle().
+		Border(roundedBorder()).
+		BorderForeground(theme.Info).
+		Padding(0, 1).
+		Width(m.width - 2)
+
+	titleStyle := lipgloss.NewStyle().Foreground(theme.Info).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+
+	elapsed := stats.End.Sub(stats.Start).Round(time.Second)
+	return boxStyle.Render(
+		titleStyle.Render(fmt.Sprintf("Session %s (%s)", stats.Session, elapsed)) + "\n\n" +
+			stats.String() + "\n\n" +
+			hintStyle.Render("Esc to close"),
+	)
+}
+
+// handleStatsCommand prints statistics for the most recent session, or for
+// all recorded history with --all
+func handleStatsCommand(args []string) {
+	asJSON, all := false, false
+	session := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--all":
+			all = true
+		case "--session":
+			if i+1 >= len(args) {
+				fmt.Println("Error: --session requires a session ID")
+				os.Exit(1)
+			}
+			session = args[i+1]
+			i++
+		default:
+			fmt.Printf("Unknown stats option: %s\n", args[i])
+			fmt.Println("Usage: ai-terminal-tui stats [--json] [--all | --session ID]")
+			os.Exit(1)
+		}
+	}
+
+	entries, err := LoadHistory()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No history recorded yet. Statistics are collected while the TUI runs.")
+		return
+	}
+
+	if !all {
+		if session == "" {
+			session = entries[len(entries)-1].Session
+		}
+		var filtered []HistoryEntry
+		for _, entry := range entries {
+			if entry.Session == session {
+				filtered = append(filtered, entry)
+			}
+		}
+		if len(filtered) == 0 {
+			fmt.Printf("Error: no history for session %s\n", session)
+			os.Exit(1)
+		}
+		entries = filtered
+	}
+
+	stats := ComputeStats(entries)
+	if !all {
+		stats.Session = session
+	}
+
+	if asJSON {
+		data, _ := json.MarshalIndent(stats, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if all {
+		fmt.Printf("All sessions (%s to %s)\n\n", stats.Start.Format(time.DateTime), stats.End.Format(time.DateTime))
+	} else {
+		fmt.Printf("Session %s (%s to %s)\n\n", stats.Session, stats.Start.Format(time.DateTime), stats.End.Format(time.DateTime))
+	}
+	fmt.Println(stats.String())
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// stepPollInterval is how often a running step is checked for having
+// finished, when the shell doesn't report it
+const stepPollInterval = 250 * time.Millisecond
+
+// Step is one command of a chain, with the operator that joined it to the
+// step before: "&&", ";" or "\n", and "" for the first
+type Step struct {
+	Command string
+	Op      string
+}
+
+// compoundKeywords start shell constructs that span separators; chains
+// using them are run whole
+var compoundKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true, "for": true, "foreach": true,
+	"while": true, "until": true, "do": true, "done": true, "case": true, "esac": true, "select": true,
+	"function": true, "switch": true, "try": true, "catch": true, "finally": true,
+}
+
+// SplitSteps splits a command line into the commands chained with &&, ;
+// or newlines, leaving quotes, substitutions, subshells and pipes intact.
+// It returns nil for a single command, and for what can't be split safely:
+// control structures, here-documents, unbalanced quotes and cmd.exe.
+func SplitSteps(command, shell string) []Step {
+	dialect, err := ParseDialect(shell)
+	if err != nil || dialect == DialectCmd {
+		return nil
+	}
+	// PowerShell escapes with a backtick; POSIX shells and fish with a
+	// backslash, and quote with backticks
+	escape := byte('\\')
+	if dialect == DialectPowerShell {
+		escape = '`'
+	}
+
+	var steps []Step
+	var b strings.Builder
+	op, depth, quote := "", 0, byte(0)
+	flush := func(next string) {
+		if text := strings.TrimSpace(b.String()); text != "" {
+			steps = append(steps, Step{Command: text, Op: op})
+			op = next
+		}
+		b.Reset()
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		var next byte
+		if i+1 < len(command) {
+			next = command[i+1]
+		}
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == escape && next != 0:
+			b.WriteByte(c)
+			i++
+			c = next
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || (c == '`' && escape != '`'):
+			quote = c
+		case c == '(' || c == '{' || (c == '[' && next == '['):
+			depth++
+		case c == ')' || c == '}' || (c == ']' && next == ']'):
+			depth--
+		case depth > 0:
+		case c == '#' && (i == 0 || strings.ContainsRune(" \t\n", rune(command[i-1]))):
+			// A comment runs to the end of the line
+			for i < len(command) && command[i] != '\n' {
+				i++
+			}
+			i--
+			continue
+		case c == '<' && next == '<' && !strings.HasPrefix(command[i:], "<<<"):
+			return nil
+		case c == '&' && next == '&':
+			flush("&&")
+			i++
+			continue
+		case c == '|' && next == '|':
+			b.WriteString("||")
+			i++
+			continue
+		case c == ';' && next == ';':
+			return nil
+		case c == ';':
+			flush(";")
+			continue
+		case c == '\n':
+			flush("\n")
+			continue
+		}
+		b.WriteByte(c)
+	}
+	if quote != 0 || depth != 0 {
+		return nil
+	}
+	flush("")
+
+	if len(steps) < 2 {
+		return nil
+	}
+	for _, step := range steps {
+		if compoundKeywords[strings.ToLower(strings.Fields(step.Command)[0])] {
+			return nil
+		}
+	}
+	return steps
+}
+
+// chainSteps returns the steps the command would run as, or nil when it
+// runs whole: a single command, step_commands off, or in a remote shell,
+// whose commands can't be told apart from ssh itself
+func (m Model) chainSteps(command string) []Step {
+	if !m.config.StepCommands || len(m.remotes) > 0 {
+		return nil
+	}
+	return SplitSteps(command, m.config.Shell)
+}
+
+// exitUnknown is the exit status of a step the shell didn't report one for
+const exitUnknown = -1
+
+// Outcomes of a step
+const (
+	stepPending = iota
+	stepRan
+	stepFailed
+	stepSkipped
+)
+
+// stepRun is a chain of commands run one step at a time. Between steps it
+// asks whether to run or skip the next; while one runs, the keyboard
+// belongs to the shell.
+type stepRun struct {
+	steps   []Step
+	outcome []int
+	exit    []int
+	current int
+	query   string
+
+	// running is set while the current step is in the shell; seq tells its
+	// polls from those of earlier steps, idle counts polls that found the
+	// shell back at its prompt, and interrupted is set once it was sent
+	// Ctrl+C for Alt+X or command_timeout
+	running     bool
+	started     time.Time
+	seq         int
+	idle        int
+	interrupted bool
+	// waiting is set while the step chosen to run waits for a program
+	// holding the terminal to exit
+	waiting bool
+}
+
+// stepTickMsg polls whether the running step has finished
+type stepTickMsg struct{ seq int }
+
+// stepTick schedules the next poll of the running step
+func stepTick(seq int) tea.Cmd {
+	return tea.Tick(stepPollInterval, func(time.Time) tea.Msg {
+		return stepTickMsg{seq: seq}
+	})
+}
+
+// startSteps holds a chain of commands to be confirmed step by step
+func (m *Model) startSteps(steps []Step, query string) {
+	countFeature("steps")
+	m.steps = &stepRun{
+		steps:   steps,
+		outcome: make([]int, len(steps)),
+		exit:    make([]int, len(steps)),
+		query:   query,
+	}
+}
+
+// stepBlocked reports whether the step up next was chained with && to one
+// that failed, so the chain would have stopped before it
+func (s *stepRun) stepBlocked() bool {
+	return s.current > 0 && s.steps[s.current].Op == "&&" && s.outcome[s.current-1] == stepFailed
+}
+
+// runStep sends the current step to the shell
+func (m Model) runStep() (Model, tea.Cmd) {
+	s := m.steps
+	command := s.steps[s.current].Command
+	m.sendCommand(command)
+	m.auditExecuted(AuditEntry{Query: s.query, Command: command, Source: AuditStep})
+	m.trackSSH(command)
+	m.commandSubmitted(command)
+	s.running, s.started, s.idle, s.interrupted = true, time.Now(), 0, false
+	s.seq++
+	m.queueSent = time.Now()
+	return m, stepTick(s.seq)
+}
+
+// runStepWhenIdle runs the current step, or has it wait while a program
+// holds the terminal
+func (m Model) runStepWhenIdle() (Model, tea.Cmd) {
+	if m.shellBusy() {
+		countFeature("queue")
+		m.steps.waiting = true
+		return m, nil
+	}
+	return m.runStep()
+}
+
+// finishStep records how the running step ended and moves to the next,
+// closing the run after the last
+func (m *Model) finishStep(code int) {
+	s := m.steps
+	s.running = false
+	s.outcome[s.current], s.exit[s.current] = stepRan, code
+	if code != 0 && code != exitUnknown {
+		s.outcome[s.current] = stepFailed
+	}
+	s.current++
+	if s.current == len(s.steps) {
+		m.steps = nil
+	}
+}
+
+// watchSteps finishes the running step once the shell integration reports
+// its exit status (OSC 133;D)
+func (m *Model) watchSteps(chunk []byte) {
+	if m.steps == nil || !m.steps.running {
+		return
+	}
+	if match := exitStatusRe.FindSubmatch(chunk); match != nil {
+		code, _ := strconv.Atoi(string(match[1]))
+		m.finishStep(code)
+	}
+}
+
+// stepTicked finishes the running step once the shell has had no command
+// in the foreground for two polls, for shells without integration. The
+// exit status is then unknown and taken as success, unless the step was
+// interrupted. Where the foreground can't be told, as on Windows, the next
+// step is offered right away. A step still running after command_timeout
+// is interrupted.
+func (m *Model) stepTicked(msg stepTickMsg) tea.Cmd {
+	s := m.steps
+	if s == nil || !s.running || msg.seq != s.seq {
+		return nil
+	}
+	busy, known := false, false
+	if m.pty != nil {
+		busy, known = m.pty.Busy()
+	}
+	switch {
+	case !known:
+		m.finishStep(exitUnknown)
+		return nil
+	case busy:
+		s.idle = 0
+		if timeout := m.commandTimeout(); timeout > 0 && !s.interrupted && time.Since(s.started) > timeout {
+			m.interruptForeground()
+			s.interrupted = true
+		}
+	case time.Since(s.started) > 2*stepPollInterval:
+		if s.idle++; s.idle >= 2 {
+			code := exitUnknown
+			if s.interrupted {
+				// The status a shell gives a job ended by SIGINT
+				code = 130
+			}
+			m.finishStep(code)
+			return nil
+		}
+	}
+	return stepTick(s.seq)
+}
+
+// updateSteps handles keys between steps: Enter runs the next one, s skips
+// it, a runs all that are left as one chain and Esc stops. While a program
+// holds the terminal, the step waits for it to exit, the keyboard going to
+// the program.
+func (m Model) updateSteps(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s := m.steps
+	switch msg.String() {
+	case "enter", "y":
+		if s.stepBlocked() {
+			return m, nil
+		}
+		return m.runStepWhenIdle()
+	case "ctrl+y":
+		if s.stepBlocked() {
+			countFeature("step override")
+			return m.runStepWhenIdle()
+		}
+	case "s", "n":
+		s.outcome[s.current] = stepSkipped
+		s.current++
+		if s.current == len(s.steps) {
+			m.steps = nil
+		}
+	case "a":
+		if s.stepBlocked() {
+			return m, nil
+		}
+		var b strings.Builder
+		for i, step := range s.steps[s.current:] {
+			if i > 0 {
+				if step.Op == "&&" {
+					b.WriteString(" && ")
+				} else {
+					b.WriteString("; ")
+				}
+			}
+			b.WriteString(step.Command)
+		}
+		m.steps = nil
+		m.execute(s.query, b.String(), AuditStep)
+	case "esc", "q", "ctrl+k":
+		m.steps = nil
+	}
+	return m, nil
+}
+
+// renderSteps draws the chain with what became of each step, and asks
+// about the next
+func (m Model) renderSteps() string {
+	s := m.steps
+	boxStyle := lipgloss.NewStyle().
+		Border(roundedBorder()).
+		BorderForeground(theme.Accent).
+		Padding(0, 1).
+		Width(m.width - 2)
+	titleStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(theme.Dim)
+	warnStyle := lipgloss.NewStyle().Foreground(theme.Warning)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Run step %d of %d?", s.current+1, len(s.steps))))
+	b.WriteString("\n\n")
+	for i, step := range s.steps {
+		var line string
+		switch {
+		case i == s.current:
+			line = titleStyle.Render("▶ " + step.Command)
+		case s.outcome[i] == stepRan && s.exit[i] == exitUnknown:
+			line = lipgloss.NewStyle().Foreground(theme.Text).Render("• " + step.Command + " (ran)")
+		case s.outcome[i] == stepRan:
+			line = lipgloss.NewStyle().Foreground(theme.Accent).Render("✓ " + step.Command)
+		case s.outcome[i] == stepFailed:
+			line = lipgloss.NewStyle().Foreground(theme.Error).Render(fmt.Sprintf("✗ %s (exit status %d)", step.Command, s.exit[i]))
+		case s.outcome[i] == stepSkipped:
+			line = hintStyle.Render("– " + step.Command + " (skipped)")
+		default:
+			line = hintStyle.Render("  " + step.Command)
+		}
+		if i+1 < len(s.steps) && s.steps[i+1].Op == "&&" {
+			line += hintStyle.Render(" &&")
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n")
+
+	if s.stepBlocked() {
+		b.WriteString(warnStyle.Render(fmt.Sprintf("Step %d failed, and && would stop the chain here. Press Ctrl+Y to run this step anyway, ", s.current)))
+		b.WriteString(hintStyle.Render("s to skip it, Esc to stop"))
+	} else {
+		b.WriteString(hintStyle.Render("Enter to run this step, s to skip it, a to run the rest, Esc to stop"))
+	}
+	return boxStyle.Render(b.String())
+}
+
+// renderStepStatus is the status line shown while a step runs, or waits to
+// run until the prompt is back
+func (m Model) renderStepStatus() string {
+	s := m.steps
+	status := fmt.Sprintf(" Step %d of %d running: %s  (Alt+X: stop)", s.current+1, len(s.steps), s.steps[s.current].Command)
+	if s.waiting {
+		status = fmt.Sprintf(" ⏸ Step %d of %d waits until the prompt is back: %s  (Alt+X: don't run it)", s.current+1, len(s.steps), s.steps[s.current].Command)
+	}
+	return lipgloss.NewStyle().
+		Foreground(theme.Info).
+		Width(m.width).
+		MaxHeight(1).
+		Render(status)
+}
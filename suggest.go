@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// suggestDebounce is how long typing must pause before a completion is
+// requested
+const suggestDebounce = 300 * time.Millisecond
+
+// minSuggestLength is the shortest input line worth completing
+const minSuggestLength = 3
+
+// suggestTickMsg fires after the debounce delay; stale ticks are ignored
+type suggestTickMsg struct {
+	seq int
+}
+
+// suggestionMsg carries a completion for the line typed at request time
+type suggestionMsg struct {
+	seq        int
+	suggestion string
+}
+
+// lineTracker follows what the user has typed at the shell prompt by
+// watching the keys forwarded to the PTY. Keys that edit the line in ways
+// we cannot mirror (history, cursor movement, shell completion) mark the
+// line as unknown until it is submitted or cleared.
+type lineTracker struct {
+	line  string
+	known bool
+}
+
+// newLineTracker returns a tracker for an empty prompt
+func newLineTracker() lineTracker {
+	return lineTracker{known: true}
+}
+
+// track updates the line for a key about to be sent to the shell
+func (t *lineTracker) track(k tea.KeyMsg) {
+	if k.Alt {
+		// Alt chords are readline word motions and edits
+		t.known = false
+		return
+	}
+
+	switch k.Type {
+	case tea.KeyRunes:
+		t.line += string(k.Runes)
+	case tea.KeySpace:
+		t.line += " "
+	case tea.KeyBackspace:
+		if _, size := utf8.DecodeLastRuneInString(t.line); size > 0 {
+			t.line = t.line[:len(t.line)-size]
+		}
+	case tea.KeyEnter, tea.KeyCtrlC, tea.KeyCtrlU, tea.KeyCtrlD:
+		t.line = ""
+		t.known = true
+	default:
+		t.known = false
+	}
+}
+
+// current returns the typed line and whether it can be trusted
+func (t lineTracker) current() (string, bool) {
+	return t.line, t.known
+}
+
+// scheduleSuggestion starts the debounce timer for an inline suggestion
+func (m *Model) scheduleSuggestion() tea.Cmd {
+	m.suggestion = ""
+	m.suggestSeq++
+
+	// Suggestions are accepted by typing them into the shell, and paused
+	// while saving a low battery
+	if !m.config.InlineSuggestions || m.config.SuggestOnlyMode() || m.powerSaving() {
+		return nil
+	}
+	line, known := m.typed.current()
+	if !known || len(strings.TrimSpace(line)) < minSuggestLength {
+		return nil
+	}
+
+	seq := m.suggestSeq
+	return tea.Tick(suggestDebounce, func(time.Time) tea.Msg {
+		return suggestTickMsg{seq: seq}
+	})
+}
+
+// requestSuggestion asks the model to complete the current line
+func (m Model) requestSuggestion(seq int) tea.Cmd {
+	line, known := m.typed.current()
+	if !known || seq != m.suggestSeq {
+		return nil
+	}
+
+	config := m.config
+	cwd := m.shellCwd()
+	notes := m.notesContext()
+	remote := m.remoteContext()
+	checkSyntax := config.SyntaxCheck && len(m.remotes) == 0
+	return func() tea.Msg {
+		ctx := GatherPromptContext(config, cwd)
+		ctx.Notes = notes
+		ctx.SetRemote(remote)
+		suggestion, err := CompleteLine(config, line, ctx)
+		if err != nil {
+			// Suggestions are best-effort; errors are not worth interrupting for
+			return nil
+		}
+		// A completion the shell can't parse is worse than none
+		if checkSyntax && suggestion != "" && CheckSyntax(line+suggestion, config.Shell) != nil {
+			return nil
+		}
+		// Nor is one that would turn the line catastrophic
+		if AssessRisk(line+suggestion).Level >= RiskCatastrophic && AssessRisk(line).Level < RiskCatastrophic {
+			return nil
+		}
+		return suggestionMsg{seq: seq, suggestion: suggestion}
+	}
+}
+
+// acceptSuggestion types the suggested remainder into the shell
+func (m *Model) acceptSuggestion() {
+	countFeature("inline suggestion")
+	if m.pty != nil {
+		m.pty.Write([]byte(m.suggestion))
+	}
+	m.recordHistory(HistoryEntry{Kind: HistoryInline, Query: m.typed.line, Command: m.suggestion})
+	m.auditTyped(AuditEntry{Query: m.typed.line, Command: m.typed.line + m.suggestion, Model: completionModel(m.config), Source: AuditInline})
+	m.typed.line += m.suggestion
+	m.suggestion = ""
+	m.suggestSeq++
+}
+
+// completionModel is the model inline suggestions are made with
+func completionModel(config Config) string {
+	if config.CompletionModel != "" {
+		return config.CompletionModel
+	}
+	return config.Model
+}
+
+// CompleteLine asks the model to complete a partially typed command line and
+// returns only the text to append to it
+func CompleteLine(config Config, line string, ctx PromptContext) (string, error) {
+	contents, err := chatCompletion(config, ai.Request{
+		Model: completionModel(config),
+		Messages: []ai.Message{
+			{Role: "system", Content: "You complete partially typed shell command lines. " +
+				"Respond with ONLY the full completed command line, starting with exactly the text typed so far. " +
+				"No explanations, no markdown.\n\n" + ctx.String()},
+			{Role: "user", Content: fmt.Sprintf("Typed so far: %s", line)},
+		},
+		Temperature: 0,
+		MaxTokens:   60,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	completion := strings.SplitN(ai.CleanCommand(contents[0]), "\n", 2)[0]
+	if !strings.HasPrefix(completion, line) {
+		return "", nil
+	}
+	return strings.TrimRight(completion[len(line):], " "), nil
+}
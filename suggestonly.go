@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// suggestOnlyFlag is set by --suggest-only on the command line, which
+// turns the mode on whatever the config says
+var suggestOnlyFlag bool
+
+// suggestOnlyNotice explains in the TUI why nothing runs
+const suggestOnlyNotice = "Suggest-only mode: commands are shown, never run or typed at the prompt. Copy it, or cancel."
+
+// SuggestOnlyMode reports whether commands may only be shown: nothing is
+// run, typed into the shell, or executed by a subcommand
+func (c Config) SuggestOnlyMode() bool {
+	return suggestOnlyFlag || c.SuggestOnly
+}
+
+// stripSuggestOnly takes --suggest-only out of the command line, up to a
+// "--" that starts a command of its own, so it can come before or after the
+// subcommand
+func stripSuggestOnly(args []string) []string {
+	kept := args[:1:1]
+	for i, arg := range args[1:] {
+		if arg == "--" {
+			return append(kept, args[i+1:]...)
+		}
+		if arg == "--suggest-only" {
+			suggestOnlyFlag = true
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept
+}
+
+// refuseInSuggestOnly stops a subcommand that would execute something
+func refuseInSuggestOnly(config Config, what string) {
+	if config.SuggestOnlyMode() {
+		fmt.Printf("Error: suggest-only mode is on, so %s is disabled (turn off suggest_only, and drop --suggest-only)\n", what)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syntaxTimeout bounds one syntax check; a shell that takes longer to
+// start is not waited for
+const syntaxTimeout = 2 * time.Second
+
+// powershellParseScript parses the command on stdin with PowerShell's own
+// parser, which runs nothing, and prints the first error
+const powershellParseScript = `$errors = $null
+[void][System.Management.Automation.Language.Parser]::ParseInput([Console]::In.ReadToEnd(), [ref]$null, [ref]$errors)
+if ($errors) { $errors[0].Message; exit 1 }`
+
+// syntaxPrefixRe matches what shells put before a syntax error, like
+// "bash: line 1: syntax error: " or "sh: 1: Syntax error: "
+var syntaxPrefixRe = regexp.MustCompile(`^(?:[\w./-]+: )?(?:(?:line )?\d+: )?(?i:syntax error:? )?`)
+
+// syntaxCache keeps the last result, as the review checks again on every
+// edit
+var syntaxCache struct {
+	sync.Mutex
+	key string
+	err error
+}
+
+// CheckSyntax parses a command with the shell it is meant for, without
+// running it: -n for POSIX shells and fish, the parser for PowerShell. It
+// returns the shell's syntax error, or nil when the command parses or the
+// shell can't check it.
+func CheckSyntax(command, shell string) error {
+	key := shell + "\x00" + command
+	syntaxCache.Lock()
+	if syntaxCache.key == key {
+		err := syntaxCache.err
+		syntaxCache.Unlock()
+		return err
+	}
+	syntaxCache.Unlock()
+
+	err := checkSyntax(command, shell)
+
+	syntaxCache.Lock()
+	syntaxCache.key, syntaxCache.err = key, err
+	syntaxCache.Unlock()
+	return err
+}
+
+func checkSyntax(command, shell string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), syntaxTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch shellName(shell) {
+	case "bash", "sh", "dash", "ash", "zsh", "ksh", "mksh", "fish":
+		cmd = exec.CommandContext(ctx, shell, "-n")
+	case "powershell", "pwsh":
+		cmd = exec.CommandContext(ctx, shell, "-NoProfile", "-NonInteractive", "-Command", powershellParseScript)
+	default:
+		return nil
+	}
+	cmd.Stdin = strings.NewReader(command + "\n")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if ctx.Err() != nil || !errors.As(err, &exitErr) {
+		return nil
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return errors.New(syntaxPrefixRe.ReplaceAllString(line, ""))
+		}
+	}
+	return errors.New("the shell can't parse it")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// maxSystemdUnits caps how many units of each kind are listed in prompts
+const maxSystemdUnits = 10
+
+// systemdStopWords are request words too common to pick out a unit
+var systemdStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "why": true, "won't": true, "wont": true,
+	"not": true, "does": true, "doesn't": true, "can't": true, "start": true, "stop": true,
+	"restart": true, "reload": true, "enable": true, "disable": true, "service": true,
+	"services": true, "unit": true, "units": true, "status": true, "logs": true, "log": true,
+	"show": true, "what": true, "with": true, "from": true, "this": true, "that": true,
+	"check": true, "failed": true, "failing": true, "running": true, "systemd": true,
+}
+
+// SystemdUnit is a loaded unit and its state, e.g. "active (running)"
+type SystemdUnit struct {
+	Name  string
+	State string
+}
+
+// SystemdContext is the units a request may be about: those that failed,
+// and those whose names share a word with the request
+type SystemdContext struct {
+	Failed   []string
+	Matching []SystemdUnit
+}
+
+// systemctlLines runs systemctl, returning its output lines or nil when it
+// fails, as it does on machines not booted with systemd
+func systemctlLines(args ...string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), domainTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "systemctl", append(args, "--no-legend", "--plain", "--no-pager")...).Output()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// GatherSystemdContext lists failed units and the units a request names,
+// returning nil off Linux, without systemd, or when there is nothing to say
+func GatherSystemdContext(query string) *SystemdContext {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil
+	}
+
+	var c SystemdContext
+	for _, line := range systemctlLines("--failed") {
+		if len(c.Failed) == maxSystemdUnits {
+			break
+		}
+		c.Failed = append(c.Failed, strings.Fields(line)[0])
+	}
+
+	var words []string
+	for word := range queryWords(query) {
+		if !systemdStopWords[word] {
+			words = append(words, word)
+		}
+	}
+	if len(words) > 0 {
+		// UNIT LOAD ACTIVE SUB DESCRIPTION
+		for _, line := range systemctlLines("list-units", "--all", "--type=service,timer,socket") {
+			fields := strings.Fields(line)
+			if len(fields) < 4 || len(c.Matching) == maxSystemdUnits {
+				continue
+			}
+			base := strings.ToLower(fields[0][:max(0, strings.LastIndexByte(fields[0], '.'))])
+			for _, word := range words {
+				if strings.Contains(base, word) {
+					c.Matching = append(c.Matching, SystemdUnit{Name: fields[0], State: fmt.Sprintf("%s (%s)", fields[2], fields[3])})
+					break
+				}
+			}
+		}
+	}
+
+	if len(c.Failed) == 0 && len(c.Matching) == 0 {
+		return nil
+	}
+	return &c
+}
+
+// GatherSystemd adds the units the request may be about to the context.
+// It runs systemctl, so only generation requests ask for it.
+func (c *PromptContext) GatherSystemd(config Config, query string) {
+	if config.SystemdContext {
+		c.Systemd = GatherSystemdContext(query)
+	}
+}
+
+// String renders the units for the system prompt
+func (c *SystemdContext) String() string {
+	var b strings.Builder
+	if len(c.Failed) > 0 {
+		fmt.Fprintf(&b, "Failed systemd units: %s\n", strings.Join(c.Failed, ", "))
+	}
+	if len(c.Matching) > 0 {
+		var units []string
+		for _, unit := range c.Matching {
+			units = append(units, unit.Name+" "+unit.State)
+		}
+		fmt.Fprintf(&b, "systemd units matching the request: %s\n", strings.Join(units, ", "))
+	}
+	b.WriteString("Use these exact unit names with systemctl and journalctl -u.\n")
+	return b.String()
+}
@@ -0,0 +1,272 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Telemetry modes. Counting is local by default; nothing leaves the machine
+// unless the user opts in and names a collector.
+const (
+	TelemetryOff   = "off"
+	TelemetryLocal = "local"
+	TelemetryOn    = "on"
+)
+
+// Sending happens at most this often
+const (
+	telemetryInterval = 24 * time.Hour
+	telemetryTimeout  = 5 * time.Second
+)
+
+// TelemetryReport is exactly what is sent: how often each feature was used,
+// with the version and platform. It never contains queries, commands,
+// output, paths or any identifier.
+type TelemetryReport struct {
+	Version  string         `json:"version"`
+	OS       string         `json:"os"`
+	Arch     string         `json:"arch"`
+	Since    string         `json:"since"`
+	Features map[string]int `json:"features"`
+}
+
+// telemetryState is the local aggregate kept between sends
+type telemetryState struct {
+	Since    time.Time      `json:"since"`
+	Features map[string]int `json:"features"`
+	LastSent time.Time      `json:"last_sent,omitempty"`
+}
+
+// telemetryCommands are the subcommands counted as features. Anything else
+// on the command line is never recorded.
+var telemetryCommands = map[string]bool{
+	"setup": true, "doctor": true, "config": true, "generate": true,
+	"howto": true, "translate": true, "stats": true, "digest": true, "sessions": true,
+	"commitmsg": true, "templates": true, "--session": true, "-s": true,
+	"conversations": true, "personas": true, "themes": true, "plan": true, "history": true, "audit": true, "job": true, "diagnose": true, "--conversation": true, "-c": true,
+}
+
+var (
+	telemetryMu   sync.Mutex
+	featureCounts = make(map[string]int)
+)
+
+// countFeature notes one use of a feature in this process
+func countFeature(name string) {
+	telemetryMu.Lock()
+	featureCounts[name]++
+	telemetryMu.Unlock()
+}
+
+// getTelemetryPath returns where feature counts are aggregated
+func getTelemetryPath() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "telemetry.json")
+}
+
+// updateTelemetryState applies fn to the stored aggregate while holding the
+// file lock, so concurrent instances never lose counts
+func updateTelemetryState(fn func(state *telemetryState)) error {
+	file, err := os.OpenFile(getTelemetryPath(), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return err
+	}
+	defer unlockFile(file)
+
+	var state telemetryState
+	if data, err := io.ReadAll(file); err == nil && len(data) > 0 {
+		json.Unmarshal(data, &state)
+	}
+	if state.Features == nil {
+		state.Features = make(map[string]int)
+	}
+	if state.Since.IsZero() {
+		state.Since = time.Now()
+	}
+	fn(&state)
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(data, 0)
+	return err
+}
+
+// loadTelemetryState reads the stored aggregate without changing it
+func loadTelemetryState() telemetryState {
+	var state telemetryState
+	if data, err := os.ReadFile(getTelemetryPath()); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+// FlushTelemetry adds this process's feature counts to the local aggregate.
+// With telemetry off they are dropped.
+func FlushTelemetry(config Config) {
+	telemetryMu.Lock()
+	counts := featureCounts
+	featureCounts = make(map[string]int)
+	telemetryMu.Unlock()
+
+	if config.Telemetry == TelemetryOff || len(counts) == 0 {
+		return
+	}
+	updateTelemetryState(func(state *telemetryState) {
+		for name, n := range counts {
+			state.Features[name] += n
+		}
+	})
+}
+
+// countCommand records use of a CLI subcommand straight away, since
+// subcommands exit without running any cleanup
+func countCommand(config Config, command string) {
+	if telemetryCommands[command] {
+		switch command {
+		case "-s":
+			command = "--session"
+		case "-c":
+			command = "--conversation"
+		}
+		countFeature("command " + command)
+		FlushTelemetry(config)
+	}
+}
+
+// telemetryEnabled reports whether reports may leave the machine
+func telemetryEnabled(config Config) bool {
+	return config.Telemetry == TelemetryOn && config.TelemetryURL != "" && !config.Airgapped()
+}
+
+// buildTelemetryReport turns the local aggregate into the report to send
+func buildTelemetryReport(state telemetryState) TelemetryReport {
+	features := state.Features
+	if features == nil {
+		features = make(map[string]int)
+	}
+	since := ""
+	if !state.Since.IsZero() {
+		// A date is enough, and a timestamp would be closer to an identifier
+		since = state.Since.UTC().Format(time.DateOnly)
+	}
+	return TelemetryReport{
+		Version:  Version,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Since:    since,
+		Features: features,
+	}
+}
+
+// SendTelemetry posts the aggregate to the configured collector when the
+// user opted in and the last report is old enough, then starts a new period
+func SendTelemetry(config Config) error {
+	if !telemetryEnabled(config) {
+		return nil
+	}
+	if err := CheckEndpoint(config, config.TelemetryURL); err != nil {
+		return err
+	}
+
+	return updateTelemetryState(func(state *telemetryState) {
+		if time.Since(state.LastSent) < telemetryInterval || len(state.Features) == 0 {
+			return
+		}
+		data, err := json.Marshal(buildTelemetryReport(*state))
+		if err != nil {
+			return
+		}
+
+		client := checkedClient(config, telemetryTimeout)
+		resp, err := client.Post(config.TelemetryURL, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return
+		}
+		*state = telemetryState{Since: time.Now(), Features: make(map[string]int), LastSent: time.Now()}
+	})
+}
+
+// sendTelemetry sends the report in the background when one is due
+func (m Model) sendTelemetry() tea.Cmd {
+	if !telemetryEnabled(m.config) {
+		return nil
+	}
+	config := m.config
+	return func() tea.Msg {
+		SendTelemetry(config)
+		return nil
+	}
+}
+
+// handleTelemetryCommand handles the telemetry subcommands
+func handleTelemetryCommand(args []string) {
+	config := mustLoadConfig()
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+
+	switch args[0] {
+	case "show":
+		state := loadTelemetryState()
+		fmt.Printf("Mode: %s\n", config.Telemetry)
+		switch {
+		case config.Telemetry == TelemetryOff:
+			fmt.Println("Nothing is recorded or sent.")
+		case config.Airgapped():
+			fmt.Println("Counts are kept locally. Air-gapped mode: nothing is sent.")
+		case telemetryEnabled(config):
+			next := "at the next start"
+			if due := state.LastSent.Add(telemetryInterval); time.Until(due) > 0 {
+				next = "after " + due.Format(time.DateTime)
+			}
+			fmt.Printf("Sent to %s %s.\n", config.TelemetryURL, next)
+		case config.Telemetry == TelemetryOn:
+			fmt.Println("Counts are kept locally: set telemetry_url to choose where they are sent.")
+		default:
+			fmt.Println("Counts are kept locally and never sent.")
+		}
+		fmt.Printf("Stored in %s\n\n", getTelemetryPath())
+
+		data, _ := json.MarshalIndent(buildTelemetryReport(state), "", "  ")
+		fmt.Println("Report:")
+		fmt.Println(string(data))
+
+	case "reset":
+		if err := os.Remove(getTelemetryPath()); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✓ Local feature counts deleted")
+
+	default:
+		fmt.Println("Usage: ai-terminal-tui telemetry [show|reset]")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build minimal
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Telemetry modes. A minimal build leaves telemetry out: whatever the
+// mode, nothing is counted, kept or sent.
+const (
+	TelemetryOff   = "off"
+	TelemetryLocal = "local"
+	TelemetryOn    = "on"
+)
+
+// countFeature counts nothing in a minimal build
+func countFeature(name string) {}
+
+// countCommand counts nothing in a minimal build
+func countCommand(config Config, command string) {}
+
+// FlushTelemetry keeps nothing in a minimal build
+func FlushTelemetry(config Config) {}
+
+// sendTelemetry sends nothing in a minimal build
+func (m Model) sendTelemetry() tea.Cmd {
+	return nil
+}
+
+// handleTelemetryCommand says telemetry isn't in the build
+func handleTelemetryCommand(args []string) {
+	fmt.Printf("Error: %v; nothing is recorded or sent\n", leftOut("telemetry"))
+	os.Exit(1)
+}
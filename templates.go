@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// templateExt is the file extension of prompt templates
+const templateExt = ".txt"
+
+// exampleTemplate is written when a new template is created, to show the
+// available placeholders
+const exampleTemplate = `Write a command for the following task: {{query}}
+
+Prefer commands that are safe to run twice. Ask for confirmation before
+deleting anything.
+
+Available placeholders: {{query}} {{os}} {{arch}} {{distro}} {{shell}}
+{{cwd}} {{git_branch}} {{package_manager}} {{date}}
+`
+
+// GetTemplatesDir returns the directory holding prompt templates
+func GetTemplatesDir() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "templates")
+}
+
+// ValidateTemplateName checks that name can be used as a template file name
+func ValidateTemplateName(name string) error {
+	if !validSessionName.MatchString(name) {
+		return fmt.Errorf("invalid template name %q: use letters, digits, '.', '_' and '-' (up to 64 characters)", name)
+	}
+	return nil
+}
+
+// templatePath returns the file of the template called name
+func templatePath(name string) string {
+	return filepath.Join(GetTemplatesDir(), name+templateExt)
+}
+
+// ListTemplates returns the names of the user's prompt templates, sorted
+func ListTemplates() ([]string, error) {
+	files, err := os.ReadDir(GetTemplatesDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if name, ok := strings.CutSuffix(file.Name(), templateExt); ok && !file.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// LoadTemplate reads the template called name
+func LoadTemplate(name string) (string, error) {
+	if err := ValidateTemplateName(name); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(templatePath(name))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no template named %q in %s", name, GetTemplatesDir())
+	}
+	return string(data), err
+}
+
+// ExpandTemplate fills the placeholders of a template with the query and the
+// environment. A template without {{query}} gets the query appended.
+// Unknown placeholders are left as they are.
+func ExpandTemplate(template, query string, ctx PromptContext) string {
+	if !strings.Contains(template, "{{query}}") {
+		template = strings.TrimRight(template, "\n") + "\n\n{{query}}"
+	}
+
+	branch := ""
+	if ctx.Git != nil {
+		branch = ctx.Git.Branch
+	}
+	return strings.NewReplacer(
+		"{{query}}", query,
+		"{{os}}", ctx.OS,
+		"{{arch}}", ctx.Arch,
+		"{{distro}}", ctx.Distro,
+		"{{shell}}", shellName(ctx.Shell),
+		"{{cwd}}", ctx.Cwd,
+		"{{git_branch}}", branch,
+		"{{package_manager}}", ctx.PackageManager,
+		"{{date}}", time.Now().Format(time.DateOnly),
+	).Replace(strings.TrimSpace(template))
+}
+
+// openTemplatePicker lists the templates to choose from in the prompt
+func (m *Model) openTemplatePicker() {
+	names, err := ListTemplates()
+	if err != nil || len(names) == 0 {
+		m.input.Placeholder = "No templates yet: create one with ai-terminal-tui templates edit NAME"
+		return
+	}
+	// The first entry clears the template
+	m.templatePicker = append([]string{""}, names...)
+	m.templateCursor = 0
+	for i, name := range m.templatePicker {
+		if name == m.templateName {
+			m.templateCursor = i
+		}
+	}
+}
+
+// updateTemplatePicker handles keys while choosing a template
+func (m Model) updateTemplatePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyUp, tea.KeyShiftTab:
+		m.templateCursor = max(0, m.templateCursor-1)
+	case tea.KeyDown, tea.KeyTab:
+		m.templateCursor = min(len(m.templatePicker)-1, m.templateCursor+1)
+	case tea.KeyEnter:
+		name := m.templatePicker[m.templateCursor]
+		m.templatePicker = nil
+		if name == "" {
+			m.templateName, m.templateText = "", ""
+			return m, nil
+		}
+		text, err := LoadTemplate(name)
+		if err != nil {
+			m.input.Placeholder = err.Error()
+			return m, nil
+		}
+		countFeature("template")
+		m.templateName, m.templateText = name, text
+	case tea.KeyEsc, tea.KeyCtrlP:
+		m.templatePicker = nil
+	}
+	return m, nil
+}
+
+// renderTemplatePicker lists the templates with the cursor highlighted
+func (m Model) renderTemplatePicker(titleStyle, hintStyle lipgloss.Style) string {
+	selectedStyle := lipgloss.NewStyle().Foreground(theme.Accent).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Choose a prompt template") + "\n\n")
+	for i, name := range m.templatePicker {
+		if name == "" {
+			name = "(none)"
+		}
+		if i == m.templateCursor {
+			b.WriteString(selectedStyle.Render("> " + name))
+		} else {
+			b.WriteString("  " + name)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(hintStyle.Render("↑/↓ to move, Enter to use, Esc to cancel"))
+	return b.String()
+}
+
+// handleTemplatesCommand handles the templates subcommands
+func handleTemplatesCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui templates list")
+		fmt.Println("       ai-terminal-tui templates show NAME")
+		fmt.Println("       ai-terminal-tui templates edit NAME")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		names, err := ListTemplates()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(names) == 0 {
+			fmt.Println("No templates yet. Create one with: ai-terminal-tui templates edit NAME")
+			return
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+
+	case "show":
+		if len(args) < 2 {
+			usage()
+		}
+		text, err := LoadTemplate(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(text)
+
+	case "edit":
+		if len(args) < 2 {
+			usage()
+		}
+		if err := ValidateTemplateName(args[1]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		path := templatePath(args[1])
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := os.MkdirAll(GetTemplatesDir(), 0700); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(path, []byte(exampleTemplate), 0600); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := openInEditor(path); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,81 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// DetectShells returns the usable shells listed in /etc/shells, with $SHELL
+// first when set
+func DetectShells() []string {
+	candidates := []string{os.Getenv("SHELL")}
+
+	if data, err := os.ReadFile("/etc/shells"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				candidates = append(candidates, line)
+			}
+		}
+	}
+	candidates = append(candidates, "/bin/bash", "/bin/zsh", "/bin/sh")
+
+	return uniqueShells(candidates)
+}
+
+// IsTerminal checks if the given file descriptor is a terminal
+func IsTerminal(fd int) bool {
+	return term.IsTerminal(fd)
+}
+
+// GetTerminalSize returns the size of the terminal
+func GetTerminalSize(fd int) (width, height int, err error) {
+	return term.GetSize(fd)
+}
+
+// MakeRaw puts the terminal into raw mode
+func MakeRaw(fd int) (*term.State, error) {
+	return term.MakeRaw(fd)
+}
+
+// Restore restores the terminal to a previous state
+func Restore(fd int, state *term.State) error {
+	return term.Restore(fd, state)
+}
+
+// SetupTerminal prepares the terminal for the TUI
+func SetupTerminal() (*term.State, error) {
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	return oldState, nil
+}
+
+// RestoreTerminal restores the terminal to normal mode
+func RestoreTerminal(state *term.State) error {
+	if state != nil {
+		return term.Restore(int(os.Stdin.Fd()), state)
+	}
+	return nil
+}
+
+// KillProcess kills a process by sending the appropriate signal
+func KillProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
+
+// IsWindows returns false for Unix systems
+func IsWindows() bool {
+	return false
+}
+
+// PathSeparator returns the OS-specific path separator
+func PathSeparator() string {
+	return "/"
+}
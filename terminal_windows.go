@@ -0,0 +1,100 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// DetectShells returns the usable shells found on this Windows system
+func DetectShells() []string {
+	return uniqueShells([]string{
+		"pwsh.exe",
+		`C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`,
+		`C:\Windows\System32\cmd.exe`,
+		"bash.exe",
+	})
+}
+
+// IsTerminal checks if the given file descriptor is a terminal on Windows
+func IsTerminal(fd int) bool {
+	var mode uint32
+	err := windows.GetConsoleMode(windows.Handle(fd), &mode)
+	return err == nil
+}
+
+// GetTerminalSize returns the size of the terminal on Windows
+func GetTerminalSize(fd int) (width, height int, err error) {
+	var info windows.ConsoleScreenBufferInfo
+	err = windows.GetConsoleScreenBufferInfo(windows.Handle(fd), &info)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	width = int(info.Window.Right - info.Window.Left + 1)
+	height = int(info.Window.Bottom - info.Window.Top + 1)
+	return width, height, nil
+}
+
+// WindowsTerminalState stores the previous console mode
+type WindowsTerminalState struct {
+	stdinMode  uint32
+	stdoutMode uint32
+}
+
+// MakeRaw puts the terminal into raw mode on Windows
+func MakeRaw(fd int) (*WindowsTerminalState, error) {
+	var state WindowsTerminalState
+
+	// Get current console mode for stdin
+	err := windows.GetConsoleMode(windows.Handle(fd), &state.stdinMode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set raw mode (disable echo, line input, etc.)
+	rawMode := state.stdinMode &^ (windows.ENABLE_ECHO_INPUT | windows.ENABLE_LINE_INPUT | windows.ENABLE_PROCESSED_INPUT)
+	rawMode |= windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+
+	err = windows.SetConsoleMode(windows.Handle(fd), rawMode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// Restore restores the terminal to a previous state on Windows
+func Restore(fd int, state *WindowsTerminalState) error {
+	if state == nil {
+		return nil
+	}
+	return windows.SetConsoleMode(windows.Handle(fd), state.stdinMode)
+}
+
+// SetupTerminal prepares the terminal for the TUI on Windows
+func SetupTerminal() (*WindowsTerminalState, error) {
+	return MakeRaw(int(os.Stdin.Fd()))
+}
+
+// RestoreTerminal restores the terminal to normal mode on Windows
+func RestoreTerminal(state *WindowsTerminalState) error {
+	return Restore(int(os.Stdin.Fd()), state)
+}
+
+// KillProcess kills a process on Windows
+func KillProcess(process *os.Process) error {
+	return process.Kill()
+}
+
+// IsWindows returns true for Windows systems
+func IsWindows() bool {
+	return true
+}
+
+// PathSeparator returns the OS-specific path separator
+func PathSeparator() string {
+	return "\\"
+}
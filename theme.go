@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ThemeDefault is the built-in theme drawn in the terminal's own palette
+const ThemeDefault = "default"
+
+// minContrast is the contrast ratio text needs against its background,
+// WCAG AA for normal text
+const minContrast = 4.5
+
+// Theme maps the roles colours play in the UI to terminal colours: ANSI
+// numbers like "10", or hex like "#00ff00"
+type Theme struct {
+	Name        string         `json:"-"`
+	Description string         `json:"description"`
+	Accent      lipgloss.Color `json:"accent"`     // borders, titles and selected items
+	Info        lipgloss.Color `json:"info"`       // estimates, notices and the status bar
+	Warning     lipgloss.Color `json:"warning"`    // warnings and failed-command offers
+	Error       lipgloss.Color `json:"error"`      // errors and high-risk badges
+	Dim         lipgloss.Color `json:"dim"`        // hints and placeholders
+	Text        lipgloss.Color `json:"text"`       // plain overlay text
+	Elevated    lipgloss.Color `json:"elevated"`   // the elevated-privileges badge
+	Background  lipgloss.Color `json:"background"` // overlays
+	BadgeText   lipgloss.Color `json:"badge_text"` // text on badges and highlights
+}
+
+// builtinThemes ship with the program; a user file of the same name
+// replaces one
+var builtinThemes = map[string]Theme{
+	ThemeDefault: {
+		Description: "Green on black in the terminal's palette",
+		Accent:      "10", Info: "12", Warning: "11", Error: "9", Dim: "8", Text: "7", Elevated: "13",
+		Background: "0", BadgeText: "0",
+	},
+	"high-contrast": {
+		Description: "Pure colours on black, above WCAG AAA contrast",
+		Accent:      "#00ff00", Info: "#00ffff", Warning: "#ffff00", Error: "#ff8080", Dim: "#d0d0d0", Text: "#ffffff",
+		Elevated: "#ff80ff", Background: "#000000", BadgeText: "#000000",
+	},
+}
+
+// theme is the theme the UI is drawn in, set at startup by ApplyTheme
+var theme = builtinThemes[ThemeDefault]
+
+// GetThemesDir returns the directory holding user-defined themes
+func GetThemesDir() string {
+	configPath := GetConfigPath()
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "themes")
+}
+
+// themePath returns the file of the user-defined theme called name
+func themePath(name string) string {
+	return filepath.Join(GetThemesDir(), name+".json")
+}
+
+// ValidateThemeName checks that name can be used as a theme file name
+func ValidateThemeName(name string) error {
+	if !validSessionName.MatchString(name) {
+		return fmt.Errorf("invalid theme name %q: use letters, digits, '.', '_' and '-' (up to 64 characters)", name)
+	}
+	return nil
+}
+
+// LoadTheme returns the theme called name, preferring the user's file over
+// a built-in one. Colours a file leaves out are the default theme's.
+func LoadTheme(name string) (Theme, error) {
+	if err := ValidateThemeName(name); err != nil {
+		return Theme{}, err
+	}
+	data, err := os.ReadFile(themePath(name))
+	if os.IsNotExist(err) {
+		if t, ok := builtinThemes[name]; ok {
+			t.Name = name
+			return t, nil
+		}
+		return Theme{}, fmt.Errorf("no theme named %q (see ai-terminal-tui themes list)", name)
+	}
+	if err != nil {
+		return Theme{}, err
+	}
+
+	t := builtinThemes[ThemeDefault]
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Theme{}, fmt.Errorf("invalid theme %s: %v", themePath(name), err)
+	}
+	for _, role := range t.roles() {
+		if _, ok := colorRGB(*role.color); !ok {
+			return Theme{}, fmt.Errorf("theme %s: %s is %q, not an ANSI number or #rrggbb", themePath(name), role.name, *role.color)
+		}
+	}
+	t.Name = name
+	return t, nil
+}
+
+// ListThemes returns the names of the built-in and user-defined themes,
+// sorted
+func ListThemes() ([]string, error) {
+	seen := make(map[string]bool)
+	for name := range builtinThemes {
+		seen[name] = true
+	}
+	files, err := os.ReadDir(GetThemesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if name, ok := strings.CutSuffix(file.Name(), ".json"); ok && !file.IsDir() {
+			seen[name] = true
+		}
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// themeRole is one colour of a theme, by its name in the theme file
+type themeRole struct {
+	name  string
+	color *lipgloss.Color
+}
+
+// roles lists the theme's colours
+func (t *Theme) roles() []themeRole {
+	return []themeRole{
+		{"accent", &t.Accent}, {"info", &t.Info}, {"warning", &t.Warning}, {"error", &t.Error},
+		{"dim", &t.Dim}, {"text", &t.Text}, {"elevated", &t.Elevated},
+		{"background", &t.Background}, {"badge_text", &t.BadgeText},
+	}
+}
+
+// foregrounds are the colours drawn as text on the background
+func (t *Theme) foregrounds() []themeRole {
+	return t.roles()[:7]
+}
+
+// badges are the colours drawn as backgrounds behind badge_text
+func (t *Theme) badges() []themeRole {
+	return []themeRole{{"info", &t.Info}, {"warning", &t.Warning}, {"error", &t.Error}, {"accent", &t.Accent}, {"elevated", &t.Elevated}}
+}
+
+// xtermPalette is how xterm draws the 16 ANSI colours; terminals differ, so
+// checks of palette colours are estimates
+var xtermPalette = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// colorRGB resolves a colour to red, green and blue: hex as given, the 16
+// ANSI colours as xterm draws them, and the 256-colour cube and greys
+func colorRGB(c lipgloss.Color) ([3]uint8, bool) {
+	s := string(c)
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return [3]uint8{}, false
+		}
+		return [3]uint8{uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+	}
+	n, err := strconv.Atoi(s)
+	switch {
+	case err != nil || n < 0 || n > 255:
+		return [3]uint8{}, false
+	case n < 16:
+		return xtermPalette[n], true
+	case n < 232:
+		level := func(v int) uint8 {
+			if v == 0 {
+				return 0
+			}
+			return uint8(55 + v*40)
+		}
+		n -= 16
+		return [3]uint8{level(n / 36), level(n / 6 % 6), level(n % 6)}, true
+	}
+	grey := uint8(8 + (n-232)*10)
+	return [3]uint8{grey, grey, grey}, true
+}
+
+// luminance is the WCAG relative luminance of a colour
+func luminance(rgb [3]uint8) float64 {
+	var channels [3]float64
+	for i, v := range rgb {
+		c := float64(v) / 255
+		if c <= 0.03928 {
+			channels[i] = c / 12.92
+		} else {
+			channels[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*channels[0] + 0.7152*channels[1] + 0.0722*channels[2]
+}
+
+// ContrastRatio is the WCAG contrast ratio of two colours, from 1 to 21; it
+// is 0 when either isn't a colour
+func ContrastRatio(a, b lipgloss.Color) float64 {
+	ra, okA := colorRGB(a)
+	rb, okB := colorRGB(b)
+	if !okA || !okB {
+		return 0
+	}
+	la, lb := luminance(ra), luminance(rb)
+	return (max(la, lb) + 0.05) / (min(la, lb) + 0.05)
+}
+
+// adjustContrast darkens or lightens fg, whichever moves it away from bg,
+// until it reaches minContrast against bg
+func adjustContrast(fg, bg lipgloss.Color) lipgloss.Color {
+	if ContrastRatio(fg, bg) >= minContrast {
+		return fg
+	}
+	from, _ := colorRGB(fg)
+	back, _ := colorRGB(bg)
+	var target float64
+	if luminance(back) < 0.18 {
+		target = 255
+	}
+	for step := 1; step <= 20; step++ {
+		blend := float64(step) / 20
+		var rgb [3]uint8
+		for i, v := range from {
+			rgb[i] = uint8(math.Round(float64(v) + (target-float64(v))*blend))
+		}
+		adjusted := lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]))
+		if ContrastRatio(adjusted, bg) >= minContrast {
+			return adjusted
+		}
+	}
+	return fg
+}
+
+// EnforceContrast adjusts the theme's text colours to minContrast against
+// its background and the terminal's, and picks black or white badge text,
+// whichever reads better on every badge
+func EnforceContrast(t Theme, terminalBg lipgloss.Color) Theme {
+	for _, role := range t.foregrounds() {
+		*role.color = adjustContrast(*role.color, t.Background)
+		*role.color = adjustContrast(*role.color, terminalBg)
+	}
+	worst := func(text lipgloss.Color) float64 {
+		ratio := math.Inf(1)
+		for _, badge := range t.badges() {
+			ratio = min(ratio, ContrastRatio(text, *badge.color))
+		}
+		return ratio
+	}
+	if current := worst(t.BadgeText); current < minContrast {
+		black, white := lipgloss.Color("#000000"), lipgloss.Color("#ffffff")
+		t.BadgeText = black
+		if worst(white) > worst(black) {
+			t.BadgeText = white
+		}
+	}
+	return t
+}
+
+// terminalBackground is black or white, as the terminal's background is
+// dark or light. lipgloss asks the terminal once at startup; asking again
+// would keep a terminal that doesn't answer waiting for another timeout.
+func terminalBackground() lipgloss.Color {
+	if lipgloss.HasDarkBackground() {
+		return "#000000"
+	}
+	return "#ffffff"
+}
+
+// ApplyTheme sets the theme the UI is drawn in. On a light terminal the
+// overlays take its background, and every theme's colours are adjusted to
+// stay readable; on a dark one the default theme's palette colours are
+// the user's own and left alone.
+func ApplyTheme(name string) error {
+	t, err := LoadTheme(name)
+	if err != nil {
+		return err
+	}
+	bg := terminalBackground()
+	light := !lipgloss.HasDarkBackground()
+	if light || name != ThemeDefault {
+		if light {
+			t.Background = bg
+		}
+		t = EnforceContrast(t, bg)
+	}
+	theme = t
+	return nil
+}
+
+// contrastPair is text of one colour drawn on another, as checked by
+// themes check
+type contrastPair struct {
+	where  string
+	fg, bg lipgloss.Color
+}
+
+// contrastPairs lists where the theme draws text on a background: overlay
+// text, the status bar on the terminal's background, and badges
+func (t Theme) contrastPairs(terminalBg lipgloss.Color) []contrastPair {
+	var pairs []contrastPair
+	for _, role := range t.foregrounds() {
+		pairs = append(pairs, contrastPair{"overlay " + role.name, *role.color, t.Background})
+	}
+	pairs = append(pairs,
+		contrastPair{"status bar info", t.Info, terminalBg},
+		contrastPair{"status bar warning", t.Warning, terminalBg})
+	for _, badge := range t.badges() {
+		pairs = append(pairs, contrastPair{"badge on " + badge.name, t.BadgeText, *badge.color})
+	}
+	return pairs
+}
+
+// handleThemesCommand handles the themes subcommands
+func handleThemesCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: ai-terminal-tui themes list")
+		fmt.Println("       ai-terminal-tui themes check [NAME]")
+		fmt.Println("       ai-terminal-tui themes edit NAME")
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		names, err := ListThemes()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			t, err := LoadTheme(name)
+			if err != nil {
+				fmt.Printf("%-20s (%v)\n", name, err)
+				continue
+			}
+			fmt.Printf("%-20s %s\n", name, t.Description)
+		}
+
+	case "check":
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		} else {
+			name = mustLoadConfig().Theme
+		}
+		t, err := LoadTheme(name)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		bg := terminalBackground()
+		fmt.Printf("Theme %s against this terminal's background %s (palette colours as xterm draws them):\n", name, bg)
+		failed := 0
+		for _, pair := range t.contrastPairs(bg) {
+			ratio := ContrastRatio(pair.fg, pair.bg)
+			mark := "✓"
+			if ratio < minContrast {
+				mark = "✗"
+				failed++
+			}
+			fmt.Printf("  %s %-20s %-8s on %-8s %5.1f:1\n", mark, pair.where, pair.fg, pair.bg, ratio)
+		}
+		if failed > 0 {
+			fmt.Printf("%d below %.1f:1; the TUI adjusts them except for the default theme on a dark background\n", failed, minContrast)
+			os.Exit(1)
+		}
+
+	case "edit":
+		if len(args) < 2 {
+			usage()
+		}
+		name := args[1]
+		if err := ValidateThemeName(name); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		path := themePath(name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// A new theme starts from the built-in one of its name, or the
+			// default
+			t, ok := builtinThemes[name]
+			if !ok {
+				t = builtinThemes[ThemeDefault]
+				t.Description = "My theme"
+			}
+			data, err := json.MarshalIndent(t, "", "  ")
+			if err == nil {
+				err = os.MkdirAll(GetThemesDir(), 0700)
+			}
+			if err == nil {
+				err = os.WriteFile(path, append(data, '\n'), 0600)
+			}
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := openInEditor(path); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	default:
+		usage()
+	}
+}
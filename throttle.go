@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Throttle settings: whether heavy commands are offered a throttled
+// variant, and when that variant is the one that runs by default
+const (
+	ThrottleOff     = "off"
+	ThrottleOffer   = "offer"
+	ThrottleBattery = "battery"
+	ThrottleAlways  = "always"
+)
+
+// heavyOperation is the estimate from which a command is heavy enough to
+// be worth throttling
+const heavyOperation = 10 * time.Second
+
+var (
+	// segmentToolRe finds the program of one command, after sudo and
+	// environment assignments
+	segmentToolRe = regexp.MustCompile(`^(\s*(?:sudo\s+(?:-\S+\s+)*)?(?:\w+=\S*\s+)*)(\S+)`)
+	// curlDownloadRe matches curl saving what it downloads
+	curlDownloadRe = regexp.MustCompile(`\s(?:-[a-zA-Z]*[oO]\b|--output\b|--remote-name\b)`)
+)
+
+// ParseThrottle validates a throttle setting
+func ParseThrottle(value string) (string, error) {
+	switch value {
+	case ThrottleOff, ThrottleOffer, ThrottleBattery, ThrottleAlways:
+		return value, nil
+	}
+	return "", fmt.Errorf("%q (expected off, offer, battery or always)", value)
+}
+
+// ParseBandwidth parses a transfer rate in bytes per second, such as 5M
+func ParseBandwidth(value string) (int64, error) {
+	n := parseDDSize(strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(value), "/s")))
+	if n <= 0 {
+		return 0, fmt.Errorf("%q (expected a rate in bytes per second, like 500K or 10M)", value)
+	}
+	return n, nil
+}
+
+// lowPriority is the prefix that runs a command at the lowest CPU and, on
+// Linux, disk priority
+func lowPriority() string {
+	if _, err := exec.LookPath("ionice"); err == nil {
+		return "nice -n 19 ionice -c 3 "
+	}
+	return "nice -n 19 "
+}
+
+// ThrottledVariant rewrites a command line so its heavy commands go easy on
+// the machine: disk and CPU heavy ones run at low priority, and network
+// copies and downloads are capped at bandwidth bytes per second. network
+// reports whether a cap was added. The variant is "" when there is nothing
+// to throttle, or no way to on Windows.
+func ThrottledVariant(command string, bandwidth int64) (variant string, network bool) {
+	if runtime.GOOS == "windows" {
+		return "", false
+	}
+
+	var b strings.Builder
+	changed, start := false, 0
+	bounds := append(commandSeparatorRe.FindAllStringIndex(command, -1), []int{len(command), len(command)})
+	for _, bound := range bounds {
+		segment := command[start:bound[0]]
+		match := segmentToolRe.FindStringSubmatchIndex(segment)
+		if match == nil {
+			b.WriteString(command[start:bound[1]])
+			start = bound[1]
+			continue
+		}
+		prefix, word, rest := segment[:match[4]], segment[match[4]:match[5]], segment[match[5]:]
+		tool := filepath.Base(word)
+
+		priority, options := "", ""
+		if _, heavy := opRates[tool]; heavy {
+			priority = lowPriority()
+		}
+		switch tool {
+		case "rsync":
+			for _, field := range strings.Fields(rest) {
+				if remotePathRe.MatchString(strings.Trim(field, `'"`)) {
+					// rsync takes KiB/s
+					options = fmt.Sprintf(" --bwlimit=%d", max(1, bandwidth>>10))
+					break
+				}
+			}
+		case "scp":
+			// scp takes Kbit/s
+			options = fmt.Sprintf(" -l %d", max(1, bandwidth*8/1000))
+		case "curl":
+			if curlDownloadRe.MatchString(rest) {
+				options = fmt.Sprintf(" --limit-rate %d", bandwidth)
+			}
+		case "wget":
+			options = fmt.Sprintf(" --limit-rate=%d", bandwidth)
+		}
+		if priority != "" || options != "" {
+			changed = true
+			network = network || options != ""
+		}
+
+		b.WriteString(prefix + priority + word + options + rest)
+		b.WriteString(command[bound[0]:bound[1]])
+		start = bound[1]
+	}
+	if !changed {
+		return "", false
+	}
+	return b.String(), network
+}
+
+// offerThrottle works out the throttled variant of a heavy command under
+// review: one estimated to take a while, or that copies over the network
+func (m *Model) offerThrottle(command string) {
+	m.throttled = ""
+	if m.config.Throttle == ThrottleOff || len(m.remotes) > 0 {
+		return
+	}
+	bandwidth, err := ParseBandwidth(m.config.ThrottleBandwidth)
+	if err != nil {
+		return
+	}
+	variant, network := ThrottledVariant(command, bandwidth)
+	if network || (m.estimate != nil && m.estimate.Duration >= heavyOperation) {
+		m.throttled = variant
+	}
+}
+
+// throttleByDefault reports whether heavy commands run throttled unless
+// the original is chosen: always, or on battery when so configured
+func (m Model) throttleByDefault() bool {
+	switch m.config.Throttle {
+	case ThrottleAlways:
+		return true
+	case ThrottleBattery:
+		return CurrentPower().OnBattery
+	}
+	return false
+}
+
+// toggleThrottle switches the command under review between its throttled
+// variant and the original
+func (m *Model) toggleThrottle() {
+	switch {
+	case m.unthrottled != "":
+		m.reviewInput.SetValue(m.unthrottled)
+		m.unthrottled = ""
+	case m.throttled != "":
+		countFeature("throttle")
+		m.unthrottled = m.reviewInput.Value()
+		m.reviewInput.SetValue(m.throttled)
+	default:
+		return
+	}
+	m.reviewInput.CursorEnd()
+	m.assessCommand(m.reviewInput.Value())
+}
+
+// renderThrottle offers the throttled variant in the review, or says the
+// command shown is one
+func (m Model) renderThrottle(style lipgloss.Style) string {
+	switch {
+	case m.unthrottled != "":
+		return style.Render("🐢 Throttled to spare the machine; Ctrl+T for the original") + "\n"
+	case m.throttled != "":
+		return style.Render("🐢 Ctrl+T to run it throttled: "+m.throttled) + "\n"
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Limits on how much of the inventory is sent to the model
+const (
+	maxPromptAliases   = 40
+	maxAliasValueChars = 80
+)
+
+// commonTools are probed on PATH. Most are modern replacements for classic
+// utilities that the model would otherwise never dare to suggest.
+var commonTools = []string{
+	"rg", "fd", "fdfind", "exa", "eza", "bat", "batcat", "jq", "yq", "fzf",
+	"delta", "dust", "duf", "ncdu", "sd", "zoxide", "htop", "btop", "tldr",
+	"http", "curl", "wget", "rsync", "tmux", "git", "gh", "docker", "podman",
+	"kubectl", "python3", "node",
+}
+
+// ToolInventory lists what is available on the machine beyond the basics
+type ToolInventory struct {
+	Installed []string
+	// Aliases maps alias names to their expansion, from the shell's profile
+	Aliases map[string]string
+}
+
+var (
+	toolsOnce     sync.Once
+	detectedTools []string
+)
+
+// DetectTools returns which common tools are installed. PATH does not
+// change while the TUI runs, so the probe happens once.
+func DetectTools() []string {
+	toolsOnce.Do(func() {
+		for _, tool := range commonTools {
+			if _, err := exec.LookPath(tool); err == nil {
+				detectedTools = append(detectedTools, tool)
+			}
+		}
+	})
+	return detectedTools
+}
+
+// GatherToolInventory collects installed tools and the aliases defined in
+// the profile files of shell
+func GatherToolInventory(shell string) *ToolInventory {
+	return &ToolInventory{
+		Installed: DetectTools(),
+		Aliases:   LoadAliases(shell),
+	}
+}
+
+// profileFiles returns the startup files where shell users define aliases
+func profileFiles(shell string) []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	join := func(names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(home, name)
+		}
+		return paths
+	}
+
+	switch shellName(shell) {
+	case "zsh":
+		return join(".zshrc", ".zsh_aliases", ".aliases")
+	case "fish":
+		return join(".config/fish/config.fish")
+	case "bash", "sh":
+		return join(".bashrc", ".bash_aliases", ".bash_profile", ".profile", ".aliases")
+	}
+	return nil
+}
+
+var (
+	posixAliasRe = regexp.MustCompile(`^\s*alias\s+([A-Za-z0-9_.:+-]+)=(.+)$`)
+	fishAliasRe  = regexp.MustCompile(`^\s*(?:alias|abbr\s+(?:-a|--add))\s+([A-Za-z0-9_.:+-]+)[\s=]+(.+)$`)
+)
+
+// LoadAliases parses alias definitions from the shell's profile files.
+// Running an interactive shell to ask would be exact but slow, and could
+// have side effects, so simple `alias name=value` lines are read instead.
+func LoadAliases(shell string) map[string]string {
+	re := posixAliasRe
+	if shellName(shell) == "fish" {
+		re = fishAliasRe
+	}
+
+	aliases := make(map[string]string)
+	for _, path := range profileFiles(shell) {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			match := re.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+			if value := unquoteAlias(match[2]); value != "" {
+				aliases[match[1]] = value
+			}
+		}
+		file.Close()
+	}
+	return aliases
+}
+
+// unquoteAlias strips the quotes around an alias value and any trailing
+// comment after them
+func unquoteAlias(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 0 && (value[0] == '\'' || value[0] == '"') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+		return ""
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// String renders the inventory for the system prompt
+func (t *ToolInventory) String() string {
+	var b strings.Builder
+	if len(t.Installed) > 0 {
+		fmt.Fprintf(&b, "Installed tools: %s (do not suggest other non-standard tools without saying they need installing)\n",
+			strings.Join(t.Installed, ", "))
+	}
+
+	if len(t.Aliases) > 0 {
+		names := make([]string, 0, len(t.Aliases))
+		for name := range t.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > maxPromptAliases {
+			names = names[:maxPromptAliases]
+		}
+
+		b.WriteString("Shell aliases (use them where they fit):\n")
+		for _, name := range names {
+			value := t.Aliases[name]
+			if len(value) > maxAliasValueChars {
+				value = value[:maxAliasValueChars-3] + "..."
+			}
+			fmt.Fprintf(&b, "  %s = %s\n", name, value)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
+
+	"github.com/eng-elias-owis/ai-terminal-tui/pkg/ai"
+)
+
+// Shell dialects commands can be translated between
+const (
+	DialectBash       = "bash"
+	DialectPowerShell = "powershell"
+	DialectCmd        = "cmd"
+	DialectFish       = "fish"
+)
+
+// dialectNames are the human-readable names used in prompts
+var dialectNames = map[string]string{
+	DialectBash:       "bash (POSIX shell)",
+	DialectPowerShell: "PowerShell",
+	DialectCmd:        "Windows cmd.exe",
+	DialectFish:       "fish",
+}
+
+// ParseDialect maps a shell name or path to a dialect. sh, zsh, dash and ksh
+// are close enough to bash for translation purposes.
+func ParseDialect(shell string) (string, error) {
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(shell, `\`, "/")))
+	name = strings.TrimSuffix(name, ".exe")
+
+	switch name {
+	case "bash", "sh", "zsh", "dash", "ksh":
+		return DialectBash, nil
+	case "powershell", "pwsh", "ps":
+		return DialectPowerShell, nil
+	case "cmd":
+		return DialectCmd, nil
+	case "fish":
+		return DialectFish, nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (expected bash, powershell, cmd or fish)", shell)
+}
+
+// TranslateCommand rewrites command for the target dialect. from may be empty
+// to let the model recognise the source dialect itself.
+func TranslateCommand(config Config, command, from, to string, ctx PromptContext) (string, error) {
+	source := "the user's command (infer its shell from the syntax)"
+	if from != "" {
+		source = "the user's " + dialectNames[from] + " command"
+	}
+
+	contents, err := chatCompletion(config, ai.Request{
+		Messages: []ai.Message{
+			{Role: "system", Content: "You translate shell commands between shells. " +
+				fmt.Sprintf("Rewrite %s as an equivalent command for %s. ", source, dialectNames[to]) +
+				"Preserve behaviour exactly, including quoting, globbing, pipes and environment variables; " +
+				"use the target shell's native cmdlets or built-ins where the source relies on them. " +
+				"Respond with ONLY the translated command, no explanations, no markdown formatting.\n\n" +
+				ctx.String()},
+			{Role: "user", Content: command},
+		},
+		Temperature: 0,
+		MaxTokens:   300,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	translated := ai.CleanCommand(contents[0])
+	if translated == "" {
+		return "", fmt.Errorf("no response from AI")
+	}
+	return translated, nil
+}
+
+// toggleTranslate switches the open prompt between describing a task and
+// pasting a command to translate into the configured shell
+func (m *Model) toggleTranslate() {
+	m.translating = !m.translating
+	m.naming = false
+	m.regenerating = false
+	if m.translating {
+		m.input.Placeholder = "Paste a command from another shell..."
+	} else {
+		m.input.Placeholder = promptPlaceholder
+	}
+}
+
+// queryTranslate translates a pasted command into the configured shell's
+// dialect; the result goes through the usual review before it runs
+func (m Model) queryTranslate(command string) tea.Cmd {
+	config := m.config
+	cwd := m.shellCwd()
+	notes := m.notesContext()
+	return func() tea.Msg {
+		to, err := ParseDialect(config.Shell)
+		if err != nil {
+			return errMsg(err)
+		}
+		ctx := GatherPromptContext(config, cwd)
+		ctx.Notes = notes
+		translated, err := TranslateCommand(config, command, "", to, ctx)
+		if err != nil {
+			return errMsg(err)
+		}
+		return aiResponseMsg{translated}
+	}
+}
+
+// handleTranslateCommand handles the translate command. Without --to the
+// command is translated for the shell in the config; without a command
+// argument it is read from stdin.
+func handleTranslateCommand(args []string) {
+	var from, to string
+	var words []string
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--from", "--to":
+			if i+1 >= len(args) {
+				fmt.Printf("Error: %s requires a shell name\n", args[i])
+				os.Exit(1)
+			}
+			dialect, err := ParseDialect(args[i+1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if args[i] == "--from" {
+				from = dialect
+			} else {
+				to = dialect
+			}
+			i++
+		default:
+			words = append(words, args[i])
+		}
+	}
+
+	command := strings.Join(words, " ")
+	if command == "" && !isatty.IsTerminal(os.Stdin.Fd()) {
+		input, _ := io.ReadAll(os.Stdin)
+		command = strings.TrimSpace(string(input))
+	}
+	if command == "" {
+		fmt.Println("Error: translate requires a command")
+		fmt.Println("Usage: ai-terminal-tui translate [--from SHELL] [--to SHELL] \"command\"")
+		os.Exit(1)
+	}
+
+	config := mustLoadConfig()
+	if config.LiteLLMURL == "" {
+		fmt.Println("Error: litellm_url not configured. Run 'ai-terminal-tui setup' first.")
+		os.Exit(1)
+	}
+	if to == "" {
+		dialect, err := ParseDialect(config.Shell)
+		if err != nil {
+			fmt.Printf("Error: configured shell: %v; pass --to\n", err)
+			os.Exit(1)
+		}
+		to = dialect
+	}
+
+	translated, err := TranslateCommand(config, command, from, to, GatherPromptContext(config, ""))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(translated)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// undoRule recognises a command that changes something, with what its
+// undo can't put back; Caveat is "" when it undoes cleanly
+type undoRule struct {
+	Pattern *regexp.Regexp
+	Caveat  string
+}
+
+// undoRules recognise mutating commands by the tool a segment starts with
+// and its arguments. The first rule that matches a segment decides it.
+var undoRules = []undoRule{
+	{regexp.MustCompile(`^(?:rm|rmdir|unlink|shred|del|erase|rd|Remove-Item)\b`),
+		"Deleted files don't go to a trash; only a backup or snapshot brings them back."},
+	{regexp.MustCompile(`^(?:mv|cp|Move-Item|Copy-Item|move|copy)\b`),
+		"Files it overwrote are gone, unless they were backed up."},
+	{regexp.MustCompile(`^(?:chmod|chown|chgrp|setfacl)\b.*\s-[a-zA-Z]*R`),
+		"The previous modes and owners weren't recorded, so restoring them recursively is a guess."},
+	{regexp.MustCompile(`^(?:chmod|chown|chgrp|setfacl|chattr|ln|mkdir|md|touch|New-Item)\b`), ""},
+	{regexp.MustCompile(`^sed\b.*\s-[a-zA-Z]*i`),
+		"The contents it replaced weren't kept, unless sed was given a backup suffix."},
+	{regexp.MustCompile(`^(?:truncate|tee|Set-Content|Out-File)\b`),
+		"The old contents of the files it wrote weren't kept."},
+	{regexp.MustCompile(`^git\s+(?:reset\s.*--hard|clean|checkout\s+(?:\.|--)|restore)`),
+		"Uncommitted changes it discarded are gone; only commits come back, from git reflog."},
+	{regexp.MustCompile(`^git\s+push\b.*\s(?:-f|--force)`),
+		"Anyone who fetched the rewritten history has it already."},
+	{regexp.MustCompile(`^git\s+(?:reset|commit|merge|rebase|cherry-pick|revert|stash|branch\s+-[dDmM]|tag|checkout|switch|pull|push)\b`), ""},
+	{regexp.MustCompile(`^(?:systemctl|service)\b.*\b(?:stop|restart|kill)\b`),
+		"Starting it again doesn't bring back the connections or in-memory state it lost."},
+	{regexp.MustCompile(`^(?:systemctl|service)\b.*\b(?:start|enable|disable|mask|unmask|reload)\b`), ""},
+	{regexp.MustCompile(`^(?:apt|apt-get|dnf|yum|zypper|pacman|brew|pip3?|npm|choco|winget)\b.*\b(?:purge|-Rns)\b`),
+		"Reinstalling doesn't bring back the configuration purged with it."},
+	{regexp.MustCompile(`^(?:apt|apt-get|dnf|yum|zypper|pacman|brew|pip3?|npm|choco|winget|snap)\b.*\b(?:install|remove|uninstall|erase|upgrade|-S|-R)\b`),
+		"Dependencies it pulled in or removed may not be put back exactly."},
+	{regexp.MustCompile(`^(?:docker|podman)\s+(?:rm|rmi|volume\s+rm|system\s+prune|container\s+prune)\b`),
+		"Containers and volumes come back empty, without the data they held."},
+	{regexp.MustCompile(`^(?:docker|podman)\s+(?:stop|kill|start|restart|run|network|tag)\b`), ""},
+	{regexp.MustCompile(`^kubectl\s+delete\b`),
+		"Recreated resources come back without their data, and with new names and addresses where those were generated."},
+	{regexp.MustCompile(`^kubectl\s+(?:apply|create|scale|patch|edit|label|annotate|rollout|set|cordon|drain|uncordon)\b`), ""},
+	{regexp.MustCompile(`^(?:kill|pkill|killall|Stop-Process|taskkill)\b`),
+		"A killed process can only be started afresh, without the state it had."},
+	{regexp.MustCompile(`^(?:crontab|iptables|ip6tables|nft|ufw|firewall-cmd|useradd|userdel|usermod|groupadd|passwd)\b`), ""},
+	{regexp.MustCompile(`^tar\b.*\s-?[a-zA-Z]*x`),
+		"Files the archive overwrote are gone, unless they were backed up."},
+	{regexp.MustCompile(`^(?:unzip|patch|rsync)\b`), ""},
+}
+
+// UndoCaveats reports whether command changes something worth offering to
+// undo, and what an undo can't put back
+func UndoCaveats(command string) (bool, []string) {
+	mutating := false
+	var caveats []string
+	for _, segment := range commandSeparatorRe.Split(command, -1) {
+		match := segmentToolRe.FindStringSubmatchIndex(segment)
+		if match == nil {
+			continue
+		}
+		segment = strings.TrimSpace(segment[match[4]:])
+		for _, rule := range undoRules {
+			if !rule.Pattern.MatchString(segment) {
+				continue
+			}
+			mutating = true
+			if rule.Caveat != "" && !slices.Contains(caveats, rule.Caveat) {
+				caveats = append(caveats, rule.Caveat)
+			}
+			break
+		}
+	}
+	return mutating, caveats
+}
+
+// undoable is a command that changed something, offered for undoing
+type undoable struct {
+	Command string
+	Caveats []string
+}
+
+// offerUndo offers to undo a command run for the AI when it changes
+// something
+func (m *Model) offerUndo(command string) {
+	if mutating, caveats := UndoCaveats(command); mutating {
+		m.undoOffer = &undoable{Command: command, Caveats: caveats}
+	}
+}
+
+// queryUndo asks for the command that reverses the offered one; the answer
+// is held for review with what it can't put back
+func (m *Model) queryUndo() tea.Cmd {
+	offer := m.undoOffer
+	m.undoOffer = nil
+	countFeature("undo")
+
+	output := redactText(m.config, m.recentOutput(fixOutputLines))
+	query := fmt.Sprintf("I ran `%s`. Give a command that undoes it, putting things back as they were before. If it can't be undone completely, give the command that comes closest.\n\nTerminal output since:\n%s",
+		offer.Command, untrustedBlock("terminal output", output))
+	// Always held for review: the undo is worked out from the command
+	// alone, not from what it actually changed
+	m.undoCaveats = append([]string{"worked out from the command alone; check it puts back what you expect."}, offer.Caveats...)
+	m.showPrompt = true
+	m.loading = true
+	m.lastQuery = "undo: " + offer.Command
+	m.genQuery, m.attempts = query, nil
+	return m.queryAI(query, nil)
+}
+
+// renderUndoOffer is the status line shown while an undo is on offer
+func (m Model) renderUndoOffer() string {
+	return lipgloss.NewStyle().
+		Foreground(theme.Dim).
+		Width(m.width).
+		MaxHeight(1).
+		Render(fmt.Sprintf(" Ran %s  (Alt+U: how do I undo this?)", m.undoOffer.Command))
+}
@@ -0,0 +1,686 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+// vtScrollback is how many rows scrolled off the top of the screen the
+// emulator keeps
+const vtScrollback = 2000
+
+// vtTabWidth is the distance between tab stops
+const vtTabWidth = 8
+
+// vtCell is a character on the emulated screen
+type vtCell struct {
+	// text is the character with any combining marks; "" for the right
+	// half of a wide character
+	text string
+	// style is its SGR parameters, like "1;31", "" for the default
+	style string
+}
+
+// vtRow is a row of the emulated screen or its scrollback
+type vtRow struct {
+	cells []vtCell
+	// marked is set on rows a generated command was put at the prompt on
+	marked bool
+}
+
+// vtPen is the rendition characters are printed with, set by SGR
+type vtPen struct {
+	// attrs holds the SGR attributes 1 to 9 that are on, by number
+	attrs  [10]bool
+	fg, bg string
+}
+
+// style is the pen as SGR parameters
+func (p vtPen) style() string {
+	var params []string
+	for i, on := range p.attrs {
+		if on {
+			params = append(params, strconv.Itoa(i))
+		}
+	}
+	if p.fg != "" {
+		params = append(params, p.fg)
+	}
+	if p.bg != "" {
+		params = append(params, p.bg)
+	}
+	return strings.Join(params, ";")
+}
+
+// vtCursor is a cursor position and pen, as saved by DECSC
+type vtCursor struct {
+	x, y int
+	pen  vtPen
+}
+
+// vtRenderer emulates a VT100/xterm terminal: it follows cursor movement,
+// erasing, scroll regions, colors and the alternate screen on a grid of
+// cells, so full-screen programs and line editors draw as they would in a
+// terminal of their own. It costs more than lineRenderer for each byte.
+type vtRenderer struct {
+	parser *ansi.Parser
+
+	width, height int
+	main, alt     []vtRow
+	altScreen     bool
+	scrollback    []vtRow
+
+	x, y     int
+	wrapNext bool
+	pen      vtPen
+	saved    vtCursor
+	// top and bottom are the rows of the scroll region
+	top, bottom  int
+	autowrap     bool
+	cursorHidden bool
+	lastRune     rune
+	marker       string
+
+	// replies are the answers to queries, like the cursor position, for
+	// the shell
+	replies []byte
+}
+
+// newVTRenderer returns an emulator of the default 80x24 size
+func newVTRenderer() *vtRenderer {
+	r := &vtRenderer{parser: ansi.NewParser(), autowrap: true}
+	r.parser.SetHandler(ansi.Handler{
+		Print:     r.print,
+		Execute:   r.execute,
+		HandleCsi: r.csi,
+		HandleEsc: r.esc,
+	})
+	r.Resize(80, 24)
+	return r
+}
+
+// Write runs chunk through the emulator and returns the answers to the
+// queries in it
+func (r *vtRenderer) Write(chunk []byte) []byte {
+	for _, b := range chunk {
+		r.parser.Advance(b)
+	}
+	replies := r.replies
+	r.replies = nil
+	return replies
+}
+
+// rows is the screen shown, main or alternate
+func (r *vtRenderer) rows() []vtRow {
+	if r.altScreen {
+		return r.alt
+	}
+	return r.main
+}
+
+// blankRow is an empty row in the pen's background, as erasing leaves
+func (r *vtRenderer) blankRow() vtRow {
+	cells := make([]vtCell, r.width)
+	blank := r.blank()
+	for i := range cells {
+		cells[i] = blank
+	}
+	return vtRow{cells: cells}
+}
+
+// blank is an erased cell
+func (r *vtRenderer) blank() vtCell {
+	return vtCell{text: " ", style: r.pen.bg}
+}
+
+// Resize changes the size of the screen, keeping the rows with the cursor
+// in view: blank rows go from the bottom, others scroll into the
+// scrollback
+func (r *vtRenderer) Resize(width, height int) {
+	width, height = max(width, 1), max(height, 1)
+	if width == r.width && height == r.height {
+		return
+	}
+	r.width, r.height = width, height
+	r.main = r.fitRows(r.main, true)
+	r.alt = r.fitRows(r.alt, false)
+	r.x, r.y = min(r.x, width-1), min(r.y, height-1)
+	r.saved.x, r.saved.y = min(r.saved.x, width-1), min(r.saved.y, height-1)
+	r.top, r.bottom = 0, height-1
+	r.wrapNext = false
+}
+
+// fitRows resizes the rows of a screen to the current size
+func (r *vtRenderer) fitRows(rows []vtRow, main bool) []vtRow {
+	for i := range rows {
+		cells := rows[i].cells
+		if len(cells) > r.width {
+			cells = cells[:r.width]
+		}
+		for len(cells) < r.width {
+			cells = append(cells, vtCell{text: " "})
+		}
+		rows[i].cells = cells
+	}
+	for len(rows) > r.height && len(rows)-1 > r.y && rowBlank(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	if extra := len(rows) - r.height; extra > 0 {
+		if main {
+			r.pushScrollback(rows[:extra]...)
+		}
+		rows = rows[extra:]
+		if main == !r.altScreen {
+			r.y = max(0, r.y-extra)
+		}
+	}
+	for len(rows) < r.height {
+		rows = append(rows, vtRow{cells: r.blankRow().cells})
+	}
+	return rows
+}
+
+// pushScrollback keeps rows scrolled off the top of the main screen
+func (r *vtRenderer) pushScrollback(rows ...vtRow) {
+	r.scrollback = append(r.scrollback, rows...)
+	if over := len(r.scrollback) - vtScrollback; over > 0 {
+		r.scrollback = append([]vtRow(nil), r.scrollback[over:]...)
+	}
+}
+
+// rowBlank reports whether a row has nothing on it
+func rowBlank(row vtRow) bool {
+	for _, c := range row.cells {
+		if c.text != " " || c.style != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// print puts a character at the cursor and moves past it
+func (r *vtRenderer) print(ch rune) {
+	rows := r.rows()
+	w := runewidth.RuneWidth(ch)
+	if w == 0 {
+		// A combining mark joins the character before it
+		x := r.x
+		if !r.wrapNext {
+			x--
+		}
+		if x >= 0 {
+			rows[r.y].cells[x].text += string(ch)
+		}
+		return
+	}
+	if r.wrapNext || r.x+w > r.width {
+		if !r.autowrap {
+			r.x = max(0, r.width-w)
+		} else {
+			r.x = 0
+			r.lineFeed()
+		}
+	}
+	r.wrapNext = false
+	cells := rows[r.y].cells
+	style := r.pen.style()
+	cells[r.x] = vtCell{text: string(ch), style: style}
+	if w == 2 && r.x+1 < r.width {
+		cells[r.x+1] = vtCell{style: style}
+	}
+	r.lastRune = ch
+	r.x += w
+	if r.x >= r.width {
+		r.x = r.width - 1
+		r.wrapNext = true
+	}
+}
+
+// execute handles a control character
+func (r *vtRenderer) execute(b byte) {
+	switch b {
+	case '\r':
+		r.x, r.wrapNext = 0, false
+	case '\n', '\v', '\f':
+		r.lineFeed()
+	case '\b':
+		r.x, r.wrapNext = max(0, r.x-1), false
+	case '\t':
+		r.x = min(r.width-1, (r.x/vtTabWidth+1)*vtTabWidth)
+	}
+}
+
+// lineFeed moves the cursor down a row, scrolling at the bottom of the
+// scroll region
+func (r *vtRenderer) lineFeed() {
+	r.wrapNext = false
+	switch {
+	case r.y == r.bottom:
+		r.scrollUp(r.top, r.bottom, 1)
+	case r.y < r.height-1:
+		r.y++
+	}
+}
+
+// reverseIndex moves the cursor up a row, scrolling at the top of the
+// scroll region
+func (r *vtRenderer) reverseIndex() {
+	r.wrapNext = false
+	switch {
+	case r.y == r.top:
+		r.scrollDown(r.top, r.bottom, 1)
+	case r.y > 0:
+		r.y--
+	}
+}
+
+// scrollUp moves rows top to bottom up by n, blank rows coming in at the
+// bottom. Rows leaving the top of the whole main screen go to the
+// scrollback.
+func (r *vtRenderer) scrollUp(top, bottom, n int) {
+	rows := r.rows()
+	n = min(n, bottom-top+1)
+	if !r.altScreen && top == 0 {
+		kept := make([]vtRow, n)
+		copy(kept, rows[:n])
+		r.pushScrollback(kept...)
+	}
+	copy(rows[top:bottom+1], rows[top+n:bottom+1])
+	for i := bottom - n + 1; i <= bottom; i++ {
+		rows[i] = r.blankRow()
+	}
+}
+
+// scrollDown moves rows top to bottom down by n, blank rows coming in at
+// the top
+func (r *vtRenderer) scrollDown(top, bottom, n int) {
+	rows := r.rows()
+	n = min(n, bottom-top+1)
+	copy(rows[top+n:bottom+1], rows[top:bottom+1-n])
+	for i := top; i < top+n; i++ {
+		rows[i] = r.blankRow()
+	}
+}
+
+// erase blanks cells from to to (exclusive) of row y
+func (r *vtRenderer) erase(y, from, to int) {
+	cells := r.rows()[y].cells
+	blank := r.blank()
+	for i := max(0, from); i < min(to, len(cells)); i++ {
+		cells[i] = blank
+	}
+}
+
+// param returns parameter i of a sequence, def when missing or 0
+func param(params ansi.Params, i, def int) int {
+	v, _, _ := params.Param(i, def)
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// csi handles a control sequence
+func (r *vtRenderer) csi(cmd ansi.Cmd, params ansi.Params) {
+	if cmd.Intermediate() != 0 {
+		return
+	}
+	if cmd.Prefix() == '?' {
+		r.privateMode(cmd.Final() == 'h', params)
+		return
+	}
+	if cmd.Prefix() != 0 {
+		return
+	}
+	n := param(params, 0, 1)
+	switch cmd.Final() {
+	case 'A':
+		r.y = max(0, r.y-n)
+	case 'B', 'e':
+		r.y = min(r.height-1, r.y+n)
+	case 'C', 'a':
+		r.x = min(r.width-1, r.x+n)
+	case 'D':
+		r.x = max(0, r.x-n)
+	case 'E':
+		r.x, r.y = 0, min(r.height-1, r.y+n)
+	case 'F':
+		r.x, r.y = 0, max(0, r.y-n)
+	case 'G', '`':
+		r.x = min(r.width-1, n-1)
+	case 'H', 'f':
+		r.y = min(r.height-1, n-1)
+		r.x = min(r.width-1, param(params, 1, 1)-1)
+	case 'd':
+		r.y = min(r.height-1, n-1)
+	case 'J':
+		r.eraseDisplay(param(params, 0, 0))
+	case 'K':
+		switch param(params, 0, 0) {
+		case 0:
+			r.erase(r.y, r.x, r.width)
+		case 1:
+			r.erase(r.y, 0, r.x+1)
+		case 2:
+			r.erase(r.y, 0, r.width)
+		}
+	case 'L':
+		if r.y >= r.top && r.y <= r.bottom {
+			r.scrollDown(r.y, r.bottom, n)
+		}
+	case 'M':
+		if r.y >= r.top && r.y <= r.bottom {
+			rows := r.rows()
+			n = min(n, r.bottom-r.y+1)
+			copy(rows[r.y:r.bottom+1], rows[r.y+n:r.bottom+1])
+			for i := r.bottom - n + 1; i <= r.bottom; i++ {
+				rows[i] = r.blankRow()
+			}
+		}
+	case '@':
+		cells := r.rows()[r.y].cells
+		n = min(n, r.width-r.x)
+		copy(cells[r.x+n:], cells[r.x:])
+		r.erase(r.y, r.x, r.x+n)
+	case 'P':
+		cells := r.rows()[r.y].cells
+		n = min(n, r.width-r.x)
+		copy(cells[r.x:], cells[r.x+n:])
+		r.erase(r.y, r.width-n, r.width)
+	case 'X':
+		r.erase(r.y, r.x, r.x+n)
+	case 'S':
+		r.scrollUp(r.top, r.bottom, n)
+	case 'T':
+		r.scrollDown(r.top, r.bottom, n)
+	case 'b':
+		for range min(n, r.width*r.height) {
+			r.print(r.lastRune)
+		}
+	case 'r':
+		top, bottom := param(params, 0, 1)-1, param(params, 1, r.height)-1
+		if top < bottom && bottom < r.height {
+			r.top, r.bottom = top, bottom
+			r.x, r.y = 0, 0
+		}
+	case 'm':
+		r.sgr(params)
+	case 's':
+		r.saved = vtCursor{x: r.x, y: r.y, pen: r.pen}
+	case 'u':
+		r.restoreCursor()
+	case 'n':
+		switch param(params, 0, 0) {
+		case 5:
+			r.replies = append(r.replies, "\x1b[0n"...)
+		case 6:
+			r.replies = append(r.replies, fmt.Sprintf("\x1b[%d;%dR", r.y+1, r.x+1)...)
+		}
+	case 'c':
+		r.replies = append(r.replies, "\x1b[?62;22c"...)
+	}
+	if cmd.Final() != 'm' {
+		r.wrapNext = false
+	}
+}
+
+// eraseDisplay handles ED: below the cursor, above it, the screen, or the
+// scrollback
+func (r *vtRenderer) eraseDisplay(mode int) {
+	rows := r.rows()
+	switch mode {
+	case 0:
+		r.erase(r.y, r.x, r.width)
+		for y := r.y + 1; y < len(rows); y++ {
+			rows[y] = r.blankRow()
+		}
+	case 1:
+		r.erase(r.y, 0, r.x+1)
+		for y := 0; y < r.y; y++ {
+			rows[y] = r.blankRow()
+		}
+	case 2:
+		for y := range rows {
+			rows[y] = r.blankRow()
+		}
+	case 3:
+		r.scrollback = nil
+	}
+}
+
+// privateMode sets or resets DEC private modes: the alternate screen,
+// cursor visibility and autowrap
+func (r *vtRenderer) privateMode(set bool, params ansi.Params) {
+	params.ForEach(0, func(_, mode int, _ bool) {
+		switch mode {
+		case 25:
+			r.cursorHidden = !set
+		case 7:
+			r.autowrap = set
+		case 47, 1047, 1049:
+			if set == r.altScreen {
+				return
+			}
+			if set && mode == 1049 {
+				r.saved = vtCursor{x: r.x, y: r.y, pen: r.pen}
+			}
+			r.altScreen = set
+			if set {
+				for y := range r.alt {
+					r.alt[y] = r.blankRow()
+				}
+			}
+			if !set && mode == 1049 {
+				r.restoreCursor()
+			}
+			r.top, r.bottom, r.wrapNext = 0, r.height-1, false
+		}
+	})
+}
+
+// sgr sets the pen from Select Graphic Rendition parameters
+func (r *vtRenderer) sgr(params ansi.Params) {
+	if len(params) == 0 {
+		r.pen = vtPen{}
+		return
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i].Param(0)
+		switch {
+		case p == 0:
+			r.pen = vtPen{}
+		case p >= 1 && p <= 9:
+			r.pen.attrs[p] = true
+			if p == 6 {
+				r.pen.attrs[6], r.pen.attrs[5] = false, true
+			}
+		case p == 21:
+			r.pen.attrs[4] = true
+		case p == 22:
+			r.pen.attrs[1], r.pen.attrs[2] = false, false
+		case p >= 23 && p <= 29:
+			r.pen.attrs[p-20] = false
+		case p >= 30 && p <= 37 || p >= 90 && p <= 97:
+			r.pen.fg = strconv.Itoa(p)
+		case p == 39:
+			r.pen.fg = ""
+		case p >= 40 && p <= 47 || p >= 100 && p <= 107:
+			r.pen.bg = strconv.Itoa(p)
+		case p == 49:
+			r.pen.bg = ""
+		case p == 38 || p == 48:
+			color, used := extendedColor(params[i:])
+			i += used
+			if color != "" && p == 38 {
+				r.pen.fg = "38;" + color
+			} else if color != "" {
+				r.pen.bg = "48;" + color
+			}
+		case p == 58:
+			_, used := extendedColor(params[i:])
+			i += used
+		}
+	}
+}
+
+// extendedColor reads the color after 38 or 48: 5;N for the 256-color
+// palette or 2;R;G;B for direct color, separated by ; or :. It returns the
+// color as SGR parameters to follow the 38 or 48, and how many parameters
+// it took.
+func extendedColor(params ansi.Params) (string, int) {
+	colon := params[0].HasMore()
+	var values []int
+	for i := 1; i < len(params); i++ {
+		values = append(values, params[i].Param(0))
+		if colon && !params[i].HasMore() || !colon && i == 4 {
+			break
+		}
+	}
+	switch {
+	case len(values) >= 2 && values[0] == 5:
+		used := len(values)
+		if !colon {
+			used = 2
+		}
+		return "5;" + strconv.Itoa(values[1]), used
+	case len(values) >= 4 && values[0] == 2:
+		// 38:2::R:G:B names a color space before the components
+		c := values[len(values)-3:]
+		return fmt.Sprintf("2;%d;%d;%d", c[0], c[1], c[2]), len(values)
+	case colon:
+		return "", len(values)
+	}
+	return "", min(1, len(values))
+}
+
+// esc handles an escape sequence
+func (r *vtRenderer) esc(cmd ansi.Cmd) {
+	if cmd.Intermediate() != 0 {
+		return
+	}
+	switch cmd.Final() {
+	case '7':
+		r.saved = vtCursor{x: r.x, y: r.y, pen: r.pen}
+	case '8':
+		r.restoreCursor()
+	case 'D':
+		r.lineFeed()
+	case 'E':
+		r.x = 0
+		r.lineFeed()
+	case 'M':
+		r.reverseIndex()
+	case 'c':
+		r.reset()
+	}
+}
+
+// restoreCursor moves the cursor back to where it was saved (DECRC), kept
+// on a screen made smaller since
+func (r *vtRenderer) restoreCursor() {
+	r.x, r.y = min(r.saved.x, r.width-1), min(r.saved.y, r.height-1)
+	r.pen = r.saved.pen
+	r.wrapNext = false
+}
+
+// reset puts the terminal back as it started (RIS), at its size
+func (r *vtRenderer) reset() {
+	width, height := r.width, r.height
+	*r = vtRenderer{parser: r.parser, autowrap: true, marker: r.marker}
+	r.Resize(width, height)
+}
+
+// Mark marks the row the cursor is on; the marker is drawn before it
+func (r *vtRenderer) Mark(marker []byte) {
+	if r.altScreen {
+		return
+	}
+	r.marker = string(marker)
+	r.main[r.y].marked = true
+}
+
+// Lines draws the screen: on the main screen the scrollback and the rows
+// down to the cursor or the last written, whichever is lower, with the
+// cursor shown and ghost after it; the bottom of the alternate screen
+func (r *vtRenderer) Lines(width, height int, ghost string) []string {
+	var rows []vtRow
+	cursor := r.y
+	if r.altScreen {
+		rows = r.alt
+	} else {
+		last := r.y
+		for y := len(r.main) - 1; y > r.y; y-- {
+			if !rowBlank(r.main[y]) {
+				last = y
+				break
+			}
+		}
+		rows = append(append(make([]vtRow, 0, len(r.scrollback)+last+1), r.scrollback...), r.main[:last+1]...)
+		cursor += len(r.scrollback)
+	}
+	start := max(0, len(rows)-height)
+	lines := make([]string, 0, len(rows)-start)
+	for i := start; i < len(rows); i++ {
+		x := -1
+		if i == cursor && !r.cursorHidden {
+			x = r.x
+		}
+		line := renderVTRow(rows[i].cells, x, ghost)
+		if rows[i].marked {
+			line = r.marker + line
+		}
+		lines = append(lines, ansi.Truncate(line, width, ""))
+	}
+	return lines
+}
+
+// renderVTRow draws cells as text with SGR sequences, without trailing
+// blanks; the cursor, at x unless it is -1, is drawn in reverse video, or
+// ghost is put there instead
+func renderVTRow(cells []vtCell, x int, ghost string) string {
+	end := len(cells)
+	for end > 0 && cells[end-1].text == " " && cells[end-1].style == "" {
+		end--
+	}
+	if x >= 0 {
+		if ghost != "" {
+			end = min(x, len(cells))
+		} else {
+			end = max(end, min(x+1, len(cells)))
+		}
+	}
+	var b strings.Builder
+	style := ""
+	for i := 0; i < end; i++ {
+		c := cells[i]
+		if c.text == "" {
+			continue
+		}
+		s := c.style
+		if i == x && ghost == "" {
+			s = strings.TrimPrefix(s+";7", ";")
+		}
+		if s != style {
+			b.WriteString("\x1b[0")
+			if s != "" {
+				b.WriteString(";" + s)
+			}
+			b.WriteString("m")
+			style = s
+		}
+		b.WriteString(c.text)
+	}
+	if style != "" {
+		b.WriteString("\x1b[0m")
+	}
+	if x >= 0 && ghost != "" {
+		b.WriteString(ghost)
+	}
+	return b.String()
+}
@@ -0,0 +1,9 @@
+//go:build minimal
+
+package main
+
+// newVTRenderer stands in for the VT emulator, which a minimal build
+// leaves out; its renderer draws lines
+func newVTRenderer() terminalRenderer {
+	return &lineRenderer{}
+}
@@ -0,0 +1,26 @@
+//go:build !minimal
+
+package main
+
+import "testing"
+
+// FuzzVTRenderer feeds the emulator arbitrary output around a resize,
+// which must never panic, however malformed the sequences
+func FuzzVTRenderer(f *testing.F) {
+	f.Add([]byte("\x1b[22;72H\x1b7"), uint8(40), uint8(10), []byte("\x1b8X"))
+	f.Add([]byte("\x1b[?7l"), uint8(1), uint8(1), []byte("世界"))
+	f.Add([]byte("\x1b[?1049h\x1b[5;10r\x1b[3L"), uint8(20), uint8(3), []byte("\x1b[?1049l\x1b[2J\x1bc"))
+	f.Add([]byte("á\x1b[10@\x1b[5P\x1b[3X\x1b[2b"), uint8(0), uint8(0), []byte("\x1b[6n\x1b[38;5;300m\x1b[48;2;1;2m"))
+
+	f.Fuzz(func(t *testing.T, before []byte, width, height uint8, after []byte) {
+		r := newVTRenderer()
+		r.Write(before)
+		r.Mark([]byte("| "))
+		r.Resize(int(width), int(height))
+		r.Write(after)
+		r.Mark([]byte("| "))
+		r.Lines(int(width), int(height), "ghost")
+		r.Resize(80, 24)
+		r.Lines(80, 24, "")
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// WSL interop preferences for Windows-side commands
+const (
+	WSLInteropAuto       = "auto"
+	WSLInteropLinux      = "linux"
+	WSLInteropCmd        = "cmd"
+	WSLInteropPowerShell = "powershell"
+)
+
+// WSLContext describes a Windows Subsystem for Linux environment
+type WSLContext struct {
+	Distro string
+	// Mounts maps Windows drive letters to their mount points, e.g. C -> /mnt/c
+	Mounts  map[string]string
+	Interop string
+}
+
+// IsWSL reports whether the process is running inside WSL
+func IsWSL() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" || os.Getenv("WSL_INTEROP") != "" {
+		return true
+	}
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
+
+// GatherWSLContext returns WSL details, or nil when not running under WSL
+func GatherWSLContext(interop string) *WSLContext {
+	if !IsWSL() {
+		return nil
+	}
+	if interop == "" {
+		interop = WSLInteropAuto
+	}
+
+	return &WSLContext{
+		Distro:  os.Getenv("WSL_DISTRO_NAME"),
+		Mounts:  windowsDriveMounts(),
+		Interop: interop,
+	}
+}
+
+// windowsDriveMounts finds drvfs/9p mounts of Windows drives in /proc/mounts
+func windowsDriveMounts() map[string]string {
+	mounts := make(map[string]string)
+
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return mounts
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || (fields[2] != "drvfs" && fields[2] != "9p") {
+			continue
+		}
+		// The source is the drive root, e.g. "C:\" or "drvfs" with a path option
+		source := fields[0]
+		if len(source) >= 2 && source[1] == ':' {
+			mounts[strings.ToUpper(source[:1])] = fields[1]
+		}
+	}
+
+	return mounts
+}
+
+// String renders the WSL details and interop guidance for the system prompt
+func (w *WSLContext) String() string {
+	var b strings.Builder
+
+	b.WriteString("Environment: Windows Subsystem for Linux")
+	if w.Distro != "" {
+		fmt.Fprintf(&b, " (%s)", w.Distro)
+	}
+	b.WriteString("\n")
+
+	if len(w.Mounts) > 0 {
+		drives := make([]string, 0, len(w.Mounts))
+		for drive := range w.Mounts {
+			drives = append(drives, drive)
+		}
+		sort.Strings(drives)
+
+		var mounts []string
+		for _, drive := range drives {
+			mounts = append(mounts, fmt.Sprintf("%s: at %s", drive, w.Mounts[drive]))
+		}
+		fmt.Fprintf(&b, "Windows drives are mounted as %s\n", strings.Join(mounts, ", "))
+	}
+
+	switch w.Interop {
+	case WSLInteropLinux:
+		b.WriteString("Always generate Linux commands; do not invoke Windows executables.\n")
+	case WSLInteropCmd:
+		b.WriteString("For tasks on the Windows side, invoke Windows commands via `cmd.exe /c ...`; otherwise generate Linux commands.\n")
+	case WSLInteropPowerShell:
+		b.WriteString("For tasks on the Windows side, invoke Windows commands via `powershell.exe -NoProfile -Command ...`; otherwise generate Linux commands.\n")
+	default:
+		b.WriteString("Generate Linux commands unless the request is about Windows itself (services, registry, Windows apps), " +
+			"in which case call Windows executables such as `powershell.exe` or `cmd.exe /c` from this shell.\n")
+	}
+
+	return b.String()
+}